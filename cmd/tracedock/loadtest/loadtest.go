@@ -0,0 +1,154 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/generator"
+	"github.com/tracedock/tracedock/internal/loadtest"
+	"github.com/tracedock/tracedock/internal/logger"
+	"github.com/tracedock/tracedock/internal/orchestrator"
+)
+
+var (
+	paramConfigFile string
+	paramTargets    []string
+	paramDuration   time.Duration
+	paramRequests   int
+	paramWorkers    int
+	paramPayloads   int
+	paramTraces     int
+	paramJSON       bool
+
+	paramServices  []string
+	paramDepth     int
+	paramFanOut    int
+	paramErrorRate float64
+	paramSeed      uint64
+)
+
+var LoadTestCmd = &cobra.Command{
+	Use:   "loadtest [flags]",
+	Short: "Measures how many spans the ingestion handles",
+	Long: `Measures how many spans the ingestion handles, sending synthetic traces to
+the OTLP receivers listening on the loopback, through the pipelines of the
+configuration.
+
+The requests are prepared before measuring and sent from the same process, so
+the results are reproducible and only depend on the receivers and pipelines.
+The memory allocated by the client is measured against a receiver discarding
+the requests and left out. Exporters of the configuration do send the data,
+use a configuration without them to measure the processing alone.
+
+For each target, the throughput, the latency percentiles of the requests and
+the memory allocated per span are reported.`,
+	Example: `  tracedock loadtest --duration 30s
+  tracedock loadtest --config tracedock.yaml --targets grpc --workers 8 --json`,
+	Args: cobra.NoArgs,
+	Run:  execLoadTestCmd,
+}
+
+func init() {
+	LoadTestCmd.Flags().StringVarP(&paramConfigFile, "config", "c", "", "configuration whose pipelines process the traces, none when empty")
+	LoadTestCmd.Flags().StringSliceVarP(&paramTargets, "targets", "t", []string{"grpc", "http/protobuf", "http/json"}, "receivers measured, grpc, http/protobuf or http/json")
+	LoadTestCmd.Flags().DurationVarP(&paramDuration, "duration", "d", 10*time.Second, "how long each target is measured")
+	LoadTestCmd.Flags().IntVarP(&paramRequests, "requests", "n", 0, "requests sent to each target, 0 for no limit")
+	LoadTestCmd.Flags().IntVarP(&paramWorkers, "workers", "w", runtime.GOMAXPROCS(0), "requests sent concurrently")
+	LoadTestCmd.Flags().IntVarP(&paramPayloads, "payloads", "", 64, "distinct requests prepared before measuring")
+	LoadTestCmd.Flags().IntVarP(&paramTraces, "traces-per-request", "", 5, "traces sent in each request")
+	LoadTestCmd.Flags().BoolVarP(&paramJSON, "json", "", false, "print the reports as JSON lines")
+
+	LoadTestCmd.Flags().StringSliceVarP(&paramServices, "services", "", []string{"frontend", "cart", "checkout", "payment"}, "names of the services of each trace")
+	LoadTestCmd.Flags().IntVarP(&paramDepth, "depth", "", 3, "levels of spans in each trace")
+	LoadTestCmd.Flags().IntVarP(&paramFanOut, "fan-out", "", 3, "children of each span but the leaves")
+	LoadTestCmd.Flags().Float64VarP(&paramErrorRate, "error-rate", "", 0.01, "probability of each span failing, between 0 and 1")
+	LoadTestCmd.Flags().Uint64VarP(&paramSeed, "seed", "", 1, "seed of the generated traces")
+}
+
+func execLoadTestCmd(cmd *cobra.Command, args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg := config.NewConfig()
+	if paramConfigFile != "" {
+		if err := cfg.Load(paramConfigFile); err != nil {
+			logger.Error(fmt.Sprintf("error loading config file: %v", err))
+			return
+		}
+	}
+
+	// debug logs would be measured instead of the ingestion
+	if err := logger.SetLevel("info"); err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	ingestor, err := orchestrator.NewIngestor(cfg)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error creating ingestor: %v", err))
+		return
+	}
+
+	defer func() {
+		if err := ingestor.Shutdown(context.Background()); err != nil {
+			logger.Error(fmt.Sprintf("error shutting down exporters: %v", err))
+		}
+	}()
+
+	out := cmd.OutOrStdout()
+
+	for _, name := range paramTargets {
+		protocol, encoding, _ := strings.Cut(name, "/")
+
+		target, err := loadtest.NewTarget(ingestor, loadtest.Options{
+			Protocol: protocol,
+			Encoding: encoding,
+			Generator: generator.Options{
+				Services:  paramServices,
+				Depth:     paramDepth,
+				FanOut:    paramFanOut,
+				ErrorRate: paramErrorRate,
+				Seed:      paramSeed,
+			},
+			Receivers:        cfg.Receivers,
+			TracesPerRequest: paramTraces,
+			Payloads:         paramPayloads,
+			Workers:          paramWorkers,
+			Duration:         paramDuration,
+			Requests:         paramRequests,
+		})
+		if err != nil {
+			logger.Error(fmt.Sprintf("error preparing target %q: %v", name, err))
+			return
+		}
+
+		report := target.Run(ctx)
+		target.Close()
+
+		if paramJSON {
+			encoded, err := json.Marshal(report)
+			if err != nil {
+				logger.Error(fmt.Sprintf("error encoding the report of %q: %v", name, err))
+				return
+			}
+
+			fmt.Fprintf(out, "%s\n", encoded)
+		} else {
+			fmt.Fprint(out, report)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tracedock/tracedock/internal/loadtest"
+)
+
+func Test_LoadTestCmd(t *testing.T) {
+	t.Run("should return error for args", func(t *testing.T) {
+		var out = new(bytes.Buffer)
+
+		LoadTestCmd.SetOut(out)
+		LoadTestCmd.SetErr(out)
+		LoadTestCmd.SetArgs([]string{"unexpected"})
+
+		assert.Error(t, LoadTestCmd.Execute())
+		assert.Contains(t, out.String(), "Usage:")
+	})
+
+	t.Run("should report each target", func(t *testing.T) {
+		var out = new(bytes.Buffer)
+
+		LoadTestCmd.SetOut(out)
+		LoadTestCmd.SetArgs([]string{"--targets", "grpc,http/json", "--requests", "4", "--workers", "2", "--depth", "2", "--fan-out", "1", "--json"})
+
+		assert.NoError(t, LoadTestCmd.Execute())
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		assert.Len(t, lines, 2)
+
+		var reports [2]loadtest.Report
+		for n, line := range lines {
+			assert.NoError(t, json.Unmarshal([]byte(line), &reports[n]))
+		}
+
+		assert.Equal(t, "grpc", reports[0].Protocol)
+		assert.Equal(t, "json", reports[1].Encoding)
+
+		// a root, client and server spans in each of the 5 traces per request
+		assert.Equal(t, int64(4), reports[1].Requests)
+		assert.Equal(t, int64(60), reports[1].Spans)
+	})
+}
@@ -6,7 +6,11 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/tracedock/tracedock/cmd/tracedock/loadtest"
+	"github.com/tracedock/tracedock/cmd/tracedock/replay"
+	"github.com/tracedock/tracedock/cmd/tracedock/send"
 	"github.com/tracedock/tracedock/cmd/tracedock/server"
+	"github.com/tracedock/tracedock/cmd/tracedock/tail"
 	"github.com/tracedock/tracedock/cmd/tracedock/version"
 	"github.com/tracedock/tracedock/internal/logger"
 )
@@ -19,7 +23,11 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
+	rootCmd.AddCommand(loadtest.LoadTestCmd)
+	rootCmd.AddCommand(replay.ReplayCmd)
+	rootCmd.AddCommand(send.SendCmd)
 	rootCmd.AddCommand(server.ServerCmd)
+	rootCmd.AddCommand(tail.TailCmd)
 	rootCmd.AddCommand(version.VersionCmd)
 }
 
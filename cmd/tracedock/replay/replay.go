@@ -0,0 +1,129 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/exporter"
+	"github.com/tracedock/tracedock/internal/logger"
+	"github.com/tracedock/tracedock/internal/orchestrator"
+	"github.com/tracedock/tracedock/internal/otlpfile"
+)
+
+var (
+	paramConfigFile string
+	paramEndpoint   string
+	paramProtocol   string
+	paramEncoding   string
+	paramInsecure   bool
+	paramSpeed      float64
+)
+
+var ReplayCmd = &cobra.Command{
+	Use:   "replay <file>...",
+	Short: "Replays trace data captured by the file exporter",
+	Long: `Replays trace data captured by the file exporter, pushing it through the
+pipelines of the configuration file or sending it to an OTLP endpoint.
+
+Records are sent keeping the pace they were captured, multiplied by --speed,
+or as fast as possible with --speed 0.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  execReplayCmd,
+}
+
+func init() {
+	ReplayCmd.Flags().StringVarP(&paramConfigFile, "config", "c", "/etc/tracedock.yaml", "path to the configuration file whose pipelines process the data")
+	ReplayCmd.Flags().StringVarP(&paramEndpoint, "endpoint", "", "", "OTLP endpoint to send the data to instead of the pipelines")
+	ReplayCmd.Flags().StringVarP(&paramProtocol, "protocol", "", "grpc", "protocol used to send to the endpoint, grpc or http")
+	ReplayCmd.Flags().StringVarP(&paramEncoding, "encoding", "", "protobuf", "encoding used to send to an http endpoint, protobuf or json")
+	ReplayCmd.Flags().BoolVarP(&paramInsecure, "insecure", "", false, "disable TLS when sending to the endpoint")
+	ReplayCmd.Flags().Float64VarP(&paramSpeed, "speed", "", 1, "replay speed, 1 keeps the original pace and 0 doesn't wait")
+}
+
+func execReplayCmd(cmd *cobra.Command, args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if paramSpeed < 0 {
+		logger.Error(fmt.Sprintf("speed must not be negative, got %v", paramSpeed))
+		return
+	}
+
+	send, shutdown, err := newSender(ctx)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error preparing replay: %v", err))
+		return
+	}
+
+	defer func() {
+		if err := shutdown(context.Background()); err != nil {
+			logger.Error(fmt.Sprintf("error shutting down exporters: %v", err))
+		}
+	}()
+
+	for _, path := range args {
+		if err := replayFile(ctx, path, send); err != nil {
+			logger.Error(fmt.Sprintf("error replaying %s: %v", path, err))
+			return
+		}
+	}
+}
+
+// newSender returns where the records are sent to, the endpoint when given
+// or the pipelines of the configuration otherwise
+func newSender(ctx context.Context) (func(*tracecollectorv1.ExportTraceServiceRequest) error, func(context.Context) error, error) {
+	if paramEndpoint != "" {
+		exp, err := exporter.NewOTLPExporter(config.ConfigExporter{
+			Endpoint: paramEndpoint,
+			Protocol: paramProtocol,
+			Encoding: paramEncoding,
+			Insecure: paramInsecure,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		send := func(req *tracecollectorv1.ExportTraceServiceRequest) error {
+			return exp.Export(ctx, req.ResourceSpans)
+		}
+
+		return send, exp.Shutdown, nil
+	}
+
+	cfg := config.NewConfig()
+	if err := cfg.Load(paramConfigFile); err != nil {
+		return nil, nil, fmt.Errorf("loading config file: %w", err)
+	}
+
+	ingestor, err := orchestrator.NewIngestor(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	send := func(req *tracecollectorv1.ExportTraceServiceRequest) error {
+		return errors.Join(ingestor.IngestTraces(ctx, client.Info{}, req.ResourceSpans)...)
+	}
+
+	return send, ingestor.Shutdown, nil
+}
+
+func replayFile(ctx context.Context, path string, send func(*tracecollectorv1.ExportTraceServiceRequest) error) error {
+	reader, err := otlpfile.Open(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	logger.Info(fmt.Sprintf("replaying %s", path))
+
+	return otlpfile.Replay(ctx, reader, paramSpeed, send)
+}
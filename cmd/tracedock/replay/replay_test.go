@@ -0,0 +1,83 @@
+package replay
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/otlpfile"
+)
+
+func Test_ReplayCmd(t *testing.T) {
+	t.Run("should return error for no args", func(t *testing.T) {
+		var out = new(bytes.Buffer)
+
+		ReplayCmd.SetOut(out)
+		ReplayCmd.SetErr(out)
+		ReplayCmd.SetArgs([]string{})
+
+		assert.Error(t, ReplayCmd.Execute())
+		assert.Contains(t, out.String(), "Usage:")
+	})
+
+	t.Run("should send the captured records to the endpoint", func(t *testing.T) {
+		var received []*tracecollectorv1.ExportTraceServiceRequest
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req tracecollectorv1.ExportTraceServiceRequest
+
+			body, _ := io.ReadAll(r.Body)
+			assert.NoError(t, proto.Unmarshal(body, &req))
+
+			received = append(received, &req)
+		}))
+		t.Cleanup(srv.Close)
+
+		path := filepath.Join(t.TempDir(), "traces.ndjson")
+
+		w, err := otlpfile.NewWriter(otlpfile.Options{Path: path})
+		assert.NoError(t, err)
+
+		for _, name := range []string{"first", "second"} {
+			assert.NoError(t, w.Write(&tracecollectorv1.ExportTraceServiceRequest{ResourceSpans: []*trace.ResourceSpans{{
+				ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{{Name: name}}}},
+			}}}))
+		}
+		assert.NoError(t, w.Close())
+
+		ReplayCmd.SetArgs([]string{path, "--endpoint", srv.URL, "--protocol", "http", "--speed", "0"})
+
+		assert.NoError(t, ReplayCmd.Execute())
+		assert.Len(t, received, 2)
+		assert.Equal(t, "second", received[1].ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+	})
+
+	t.Run("should not replay with a negative speed", func(t *testing.T) {
+		var requests int
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+		}))
+		t.Cleanup(srv.Close)
+
+		path := filepath.Join(t.TempDir(), "traces.ndjson")
+
+		w, err := otlpfile.NewWriter(otlpfile.Options{Path: path})
+		assert.NoError(t, err)
+		assert.NoError(t, w.Write(&tracecollectorv1.ExportTraceServiceRequest{}))
+		assert.NoError(t, w.Close())
+
+		ReplayCmd.SetArgs([]string{path, "--endpoint", srv.URL, "--protocol", "http", "--speed", "-1"})
+
+		assert.NoError(t, ReplayCmd.Execute())
+		assert.Zero(t, requests)
+	})
+}
@@ -0,0 +1,203 @@
+package send
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/exporter"
+	"github.com/tracedock/tracedock/internal/generator"
+	"github.com/tracedock/tracedock/internal/logger"
+)
+
+var (
+	paramEndpoint    string
+	paramProtocol    string
+	paramEncoding    string
+	paramCompression string
+	paramInsecure    bool
+	paramHeaders     map[string]string
+
+	paramServices   []string
+	paramDepth      int
+	paramFanOut     int
+	paramAttributes map[string]string
+	paramErrorRate  float64
+	paramSeed       uint64
+
+	paramTraces   int
+	paramDuration time.Duration
+	paramRate     float64
+	paramWorkers  int
+)
+
+var SendCmd = &cobra.Command{
+	Use:   "send [flags]",
+	Short: "Sends synthetic traces to an OTLP endpoint",
+	Long: `Sends synthetic traces to an OTLP endpoint, to smoke-test a deployment or
+generate load.
+
+Each trace starts at the first service, whose spans call the following ones
+down to --depth levels, each span having --fan-out children. Calls between
+services are a client and a server span, so they show up in the service graph.
+
+Sending stops after --traces traces or --duration, whichever comes first, or
+when interrupted; with neither it runs until interrupted.`,
+	Example: `  tracedock send --endpoint localhost:4317 --insecure --traces 10
+  tracedock send --endpoint http://localhost:4318 --protocol http --encoding json --compression gzip \
+    --services frontend,cart,payment --depth 4 --fan-out 3 --error-rate 0.05 --rate 5000 --workers 8 --duration 1m`,
+	Args: cobra.NoArgs,
+	Run:  execSendCmd,
+}
+
+func init() {
+	SendCmd.Flags().StringVarP(&paramEndpoint, "endpoint", "", "localhost:4317", "OTLP endpoint to send the traces to")
+	SendCmd.Flags().StringVarP(&paramProtocol, "protocol", "", "grpc", "protocol used to send the traces, grpc or http")
+	SendCmd.Flags().StringVarP(&paramEncoding, "encoding", "", "protobuf", "encoding used with http, protobuf or json")
+	SendCmd.Flags().StringVarP(&paramCompression, "compression", "", "", "compression of the requests, gzip, zstd, snappy, deflate or none when empty")
+	SendCmd.Flags().BoolVarP(&paramInsecure, "insecure", "", false, "disable TLS when sending to the endpoint")
+	SendCmd.Flags().StringToStringVarP(&paramHeaders, "header", "H", nil, "header sent with the requests, e.g. -H x-api-key=secret")
+
+	SendCmd.Flags().StringSliceVarP(&paramServices, "services", "", []string{"frontend", "checkout", "payment"}, "names of the services, the first one receives the root span")
+	SendCmd.Flags().IntVarP(&paramDepth, "depth", "", 3, "levels of spans in each trace")
+	SendCmd.Flags().IntVarP(&paramFanOut, "fan-out", "", 2, "children of each span but the leaves")
+	SendCmd.Flags().StringToStringVarP(&paramAttributes, "attribute", "a", nil, "attribute added to every span, e.g. -a env=staging")
+	SendCmd.Flags().Float64VarP(&paramErrorRate, "error-rate", "", 0, "probability of each span failing, between 0 and 1")
+	SendCmd.Flags().Uint64VarP(&paramSeed, "seed", "", 0, "seed of the generated ids and durations, random when 0")
+
+	SendCmd.Flags().IntVarP(&paramTraces, "traces", "n", 1, "traces to send, 0 for no limit")
+	SendCmd.Flags().DurationVarP(&paramDuration, "duration", "d", 0, "how long to send traces, 0 for no limit")
+	SendCmd.Flags().Float64VarP(&paramRate, "rate", "r", 0, "spans sent per second, 0 for as fast as possible")
+	SendCmd.Flags().IntVarP(&paramWorkers, "workers", "w", 1, "requests sent concurrently")
+}
+
+// stats counts what was sent
+type stats struct {
+	traces atomic.Int64
+	spans  atomic.Int64
+	errors atomic.Int64
+}
+
+func execSendCmd(cmd *cobra.Command, args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if paramDuration > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, paramDuration)
+		defer cancel()
+	}
+
+	if paramWorkers < 1 {
+		logger.Error(fmt.Sprintf("workers must be at least 1, got %d", paramWorkers))
+		return
+	}
+
+	exp, err := exporter.NewOTLPExporter(config.ConfigExporter{
+		Endpoint:    paramEndpoint,
+		Protocol:    paramProtocol,
+		Encoding:    paramEncoding,
+		Compression: paramCompression,
+		Insecure:    paramInsecure,
+		Headers:     paramHeaders,
+	})
+	if err != nil {
+		logger.Error(fmt.Sprintf("error creating exporter: %v", err))
+		return
+	}
+
+	defer exp.Shutdown(context.Background())
+
+	start := time.Now()
+
+	result, err := send(ctx, exp)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error sending traces: %v", err))
+		return
+	}
+
+	elapsed := time.Since(start)
+
+	fmt.Fprintf(cmd.OutOrStdout(), "sent %d traces, %d spans in %s (%.0f spans/s), %d failed requests\n",
+		result.traces.Load(), result.spans.Load(), elapsed.Round(time.Millisecond),
+		float64(result.spans.Load())/elapsed.Seconds(), result.errors.Load())
+}
+
+// send runs the workers until the traces are sent or the context is done
+func send(ctx context.Context, exp exporter.Exporter) (*stats, error) {
+	var wg sync.WaitGroup
+	var result = &stats{}
+	var remaining atomic.Int64
+	var pacer = generator.NewPacer(paramRate)
+
+	seed := paramSeed
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+
+	remaining.Store(int64(paramTraces))
+
+	generators := make([]*generator.Generator, paramWorkers)
+	for n := range generators {
+		gen, err := generator.New(generator.Options{
+			Services:   paramServices,
+			Depth:      paramDepth,
+			FanOut:     paramFanOut,
+			Attributes: paramAttributes,
+			ErrorRate:  paramErrorRate,
+			Seed:       seed + uint64(n),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		generators[n] = gen
+	}
+
+	for _, gen := range generators {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				if paramTraces > 0 && remaining.Add(-1) < 0 {
+					return
+				}
+
+				if err := pacer.Wait(ctx, gen.SpansPerTrace()); err != nil {
+					return
+				}
+
+				if err := exp.Export(ctx, gen.Trace()); err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+
+					// only the first error is logged, not to flood the output
+					// when the endpoint is down
+					if result.errors.Add(1) == 1 {
+						logger.Error(fmt.Sprintf("error sending trace: %v", err))
+					}
+					continue
+				}
+
+				result.traces.Add(1)
+				result.spans.Add(int64(gen.SpansPerTrace()))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return result, nil
+}
@@ -0,0 +1,88 @@
+package send
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/server"
+)
+
+func Test_SendCmd(t *testing.T) {
+	t.Run("should return error for args", func(t *testing.T) {
+		var out = new(bytes.Buffer)
+
+		SendCmd.SetOut(out)
+		SendCmd.SetErr(out)
+		SendCmd.SetArgs([]string{"unexpected"})
+
+		assert.Error(t, SendCmd.Execute())
+		assert.Contains(t, out.String(), "Usage:")
+	})
+
+	for _, encoding := range []string{"protobuf", "json"} {
+		t.Run("should send the traces to an http endpoint with gzip and "+encoding, func(t *testing.T) {
+			var mu sync.Mutex
+			var out = new(bytes.Buffer)
+			var services = make(map[string]int)
+
+			receiver := server.NewHTTPServer(config.ConfigReceiverHTTP{})
+			receiver.RegisterTraceIngestor(server.TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				for _, rs := range batch {
+					services[rs.Resource.Attributes[0].Value.GetStringValue()] += len(rs.ScopeSpans[0].Spans)
+				}
+
+				return nil
+			}))
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+				receiver.HandleRequest(w, r)
+			}))
+			t.Cleanup(srv.Close)
+
+			// slice flags append to the values set by previous executions
+			SendCmd.Flags().Lookup("services").Value.(pflag.SliceValue).Replace(nil)
+
+			SendCmd.SetOut(out)
+			SendCmd.SetArgs([]string{
+				"--endpoint", srv.URL, "--protocol", "http", "--encoding", encoding, "--compression", "gzip",
+				"--services", "frontend,backend", "--depth", "2", "--fan-out", "2",
+				"--traces", "5", "--workers", "2",
+			})
+
+			assert.NoError(t, SendCmd.Execute())
+			assert.Equal(t, map[string]int{"frontend": 15, "backend": 10}, services)
+			assert.Contains(t, out.String(), "sent 5 traces, 25 spans")
+			assert.Contains(t, out.String(), "0 failed requests")
+		})
+	}
+
+	t.Run("should count the failed requests", func(t *testing.T) {
+		var out = new(bytes.Buffer)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(srv.Close)
+
+		SendCmd.SetOut(out)
+		SendCmd.SetArgs([]string{"--endpoint", srv.URL, "--protocol", "http", "--encoding", "protobuf", "--compression", "", "--traces", "3", "--workers", "1"})
+
+		assert.NoError(t, SendCmd.Execute())
+		assert.Contains(t, out.String(), "sent 0 traces, 0 spans")
+		assert.Contains(t, out.String(), "3 failed requests")
+	})
+}
@@ -1,20 +1,33 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/k8s"
 	"github.com/tracedock/tracedock/internal/logger"
+	"github.com/tracedock/tracedock/internal/metrics"
 	"github.com/tracedock/tracedock/internal/orchestrator"
 	"github.com/tracedock/tracedock/internal/server"
+	"github.com/tracedock/tracedock/internal/storage"
+	"github.com/tracedock/tracedock/internal/tail"
 )
 
 var (
 	paramGRPCPort   string
 	paramHTTPPort   string
+	paramOTLPPort   string
+	paramAdminPort  string
 	paramConfigFile string
+
+	paramZipkinPort     string
+	paramJaegerGRPCPort string
+	paramJaegerHTTPPort string
+
+	paramTailMaxRate int
 )
 
 var ServerCmd = &cobra.Command{
@@ -35,8 +48,14 @@ var ServerStartCmd = &cobra.Command{
 func init() {
 	ServerCmd.AddCommand(ServerStartCmd)
 
-	ServerStartCmd.PersistentFlags().StringVarP(&paramGRPCPort, "grpc-port", "", "0.0.0.0:4317", "tcp port for gRPC server")
-	ServerStartCmd.PersistentFlags().StringVarP(&paramHTTPPort, "http-port", "", "0.0.0.0:4318", "tcp port for HTTP server")
+	ServerStartCmd.PersistentFlags().StringVarP(&paramGRPCPort, "grpc-port", "", "0.0.0.0:4317", "tcp port or unix:///path socket for gRPC server")
+	ServerStartCmd.PersistentFlags().StringVarP(&paramHTTPPort, "http-port", "", "0.0.0.0:4318", "tcp port or unix:///path socket for HTTP server")
+	ServerStartCmd.PersistentFlags().StringVarP(&paramOTLPPort, "otlp-port", "", "", "tcp port or unix:///path socket serving both gRPC and HTTP, replacing --grpc-port and --http-port (disabled when empty)")
+	ServerStartCmd.PersistentFlags().StringVarP(&paramAdminPort, "admin-port", "", "127.0.0.1:8888", "tcp port for admin server")
+	ServerStartCmd.PersistentFlags().StringVarP(&paramZipkinPort, "zipkin-port", "", "", "tcp port for Zipkin server, e.g. 0.0.0.0:9411 (disabled when empty)")
+	ServerStartCmd.PersistentFlags().StringVarP(&paramJaegerGRPCPort, "jaeger-grpc-port", "", "", "tcp port or unix:///path socket for Jaeger gRPC server, e.g. 0.0.0.0:14250 (disabled when empty)")
+	ServerStartCmd.PersistentFlags().StringVarP(&paramJaegerHTTPPort, "jaeger-http-port", "", "", "tcp port for Jaeger HTTP server, e.g. 0.0.0.0:14268 (disabled when empty)")
+	ServerStartCmd.PersistentFlags().IntVarP(&paramTailMaxRate, "tail-max-rate", "", tail.DefaultMaxRate, "maximum spans per second streamed to each tail client")
 	ServerStartCmd.PersistentFlags().StringVarP(&paramConfigFile, "config", "c", "/etc/tracedock.yaml", "path to the configuration file")
 }
 
@@ -47,17 +66,78 @@ func execServerStartCmd(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	orchestrator := orchestrator.NewIngestor(cfg)
+	orchestrator, err := orchestrator.NewIngestor(cfg)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error creating ingestor: %v", err))
+		return
+	}
 
 	supervisor := server.NewSupervisor()
-	grpcServer := server.NewGRPCServer()
-	httpServer := server.NewHTTPServer()
+	grpcServer := server.NewGRPCServer(cfg.Receivers.GRPC)
+	httpServer := server.NewHTTPServer(cfg.Receivers.HTTP)
+	adminServer := server.NewAdminServer()
+
+	// a single port serves both protocols when given
+	if paramOTLPPort != "" {
+		supervisor.Add(paramOTLPPort, server.NewMuxServer(grpcServer, httpServer))
+	} else {
+		supervisor.Add(paramGRPCPort, grpcServer)
+		supervisor.Add(paramHTTPPort, httpServer)
+	}
+
+	supervisor.Add(paramAdminPort, adminServer)
+
+	adminServer.Handle("/metrics", metrics.Handler())
+
+	orchestrator.Tail = tail.NewHub(paramTailMaxRate)
+	adminServer.Handle("/tail", orchestrator.Tail)
+
+	if cfg.Kubernetes.Enabled {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		enricher, err := startKubernetesEnricher(ctx, cfg.Kubernetes)
+		if err != nil {
+			cancel()
+			logger.Error(fmt.Sprintf("error watching kubernetes pods: %v", err))
+			return
+		}
+
+		defer enricher.Stop()
+		defer cancel()
+
+		orchestrator.Kubernetes = enricher
+	}
+
+	if orchestrator.ServiceGraph != nil {
+		metrics.MustRegister(orchestrator.ServiceGraph)
+		adminServer.Handle("/servicegraph", orchestrator.ServiceGraph)
+	}
 
-	supervisor.Add(paramGRPCPort, grpcServer)
-	supervisor.Add(paramHTTPPort, httpServer)
+	if orchestrator.Storage != nil {
+		adminServer.Handle("/api/", storage.NewHandler(orchestrator.Storage))
+	}
+
+	grpcServer.RegisterTraceIngestor(orchestrator)
+	httpServer.RegisterTraceIngestor(orchestrator)
+
+	// legacy receivers are only started when their port is given
+	legacyServers := []struct {
+		addr   string
+		server server.Server
+	}{
+		{paramZipkinPort, server.NewZipkinServer(cfg.Receivers.HTTP)},
+		{paramJaegerGRPCPort, server.NewJaegerGRPCServer(cfg.Receivers.GRPC)},
+		{paramJaegerHTTPPort, server.NewJaegerHTTPServer(cfg.Receivers.HTTP)},
+	}
 
-	grpcServer.RegisterTraceIngestor(orchestrator.IngestTrace)
-	httpServer.RegisterTraceIngestor(orchestrator.IngestTrace)
+	for _, legacy := range legacyServers {
+		if legacy.addr == "" {
+			continue
+		}
+
+		legacy.server.RegisterTraceIngestor(orchestrator)
+		supervisor.Add(legacy.addr, legacy.server)
+	}
 
 	if err := supervisor.Run(); err != nil {
 		logger.Error(fmt.Sprintf("error starting supervisor: %v", err))
@@ -70,4 +150,29 @@ func execServerStartCmd(cmd *cobra.Command, args []string) {
 		logger.Error(fmt.Sprintf("error waiting for supervisor: %v", err))
 		return
 	}
+
+	if err := orchestrator.Shutdown(context.Background()); err != nil {
+		logger.Error(fmt.Sprintf("error shutting down exporters: %v", err))
+	}
+}
+
+// startKubernetesEnricher watches the pods until ctx is done, returning once
+// they are listed
+func startKubernetesEnricher(ctx context.Context, cfg config.ConfigKubernetes) (*k8s.Enricher, error) {
+	clientset, err := k8s.NewClientset(cfg.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := k8s.NewPodCache(clientset, cfg.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	enricher := k8s.NewEnricher(pods, cfg)
+	if err := enricher.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	return enricher, nil
 }
@@ -0,0 +1,181 @@
+package tail
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tracedock/tracedock/internal/logger"
+	"github.com/tracedock/tracedock/internal/tail"
+)
+
+var (
+	paramAdmin  string
+	paramFilter string
+	paramStage  string
+	paramRate   int
+	paramJSON   bool
+)
+
+var TailCmd = &cobra.Command{
+	Use:   "tail [flags]",
+	Short: "Prints the spans flowing through a running server",
+	Long: `Prints the spans flowing through a running server as they are ingested,
+connecting to its admin server.
+
+Spans are selected with a filter expression and shown when received
+(--stage before), after each pipeline (--stage after) or both. The server caps
+the spans sent per second, the spans left out are reported on stderr instead
+of slowing down the ingestion.`,
+	Example: `  tracedock tail --filter 'span.status == "error"'
+  tracedock tail --stage after --rate 10 --json`,
+	Args: cobra.NoArgs,
+	Run:  execTailCmd,
+}
+
+func init() {
+	TailCmd.Flags().StringVarP(&paramAdmin, "admin", "", "127.0.0.1:8888", "address of the admin server")
+	TailCmd.Flags().StringVarP(&paramFilter, "filter", "f", "", "expression the spans must match")
+	TailCmd.Flags().StringVarP(&paramStage, "stage", "", "", "stage to show, before or after the pipelines (both when empty)")
+	TailCmd.Flags().IntVarP(&paramRate, "rate", "", 0, "maximum spans per second, capped by the server")
+	TailCmd.Flags().BoolVarP(&paramJSON, "json", "", false, "print each span as a JSON line")
+}
+
+func execTailCmd(cmd *cobra.Command, args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := stream(ctx, cmd.OutOrStdout(), cmd.ErrOrStderr()); err != nil && ctx.Err() == nil {
+		logger.Error(fmt.Sprintf("error tailing spans: %v", err))
+	}
+}
+
+// stream prints the events sent by the admin server until the context is
+// done or the server closes the connection
+func stream(ctx context.Context, out, errOut io.Writer) error {
+	address := paramAdmin
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+
+	query := url.Values{}
+	if paramFilter != "" {
+		query.Set("filter", paramFilter)
+	}
+	if paramStage != "" {
+		query.Set("stage", paramStage)
+	}
+	if paramRate > 0 {
+		query.Set("rate", strconv.Itoa(paramRate))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(address, "/")+"/tail?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("admin server responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var event string
+	var scanner = bufio.NewScanner(resp.Body)
+
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := printEvent(out, errOut, event, []byte(strings.TrimPrefix(line, "data: "))); err != nil {
+				return err
+			}
+		case line == "":
+			event = ""
+		}
+	}
+
+	return scanner.Err()
+}
+
+func printEvent(out, errOut io.Writer, event string, data []byte) error {
+	switch event {
+	case "span":
+		if paramJSON {
+			_, err := fmt.Fprintf(out, "%s\n", data)
+			return err
+		}
+
+		var span tail.Span
+		if err := json.Unmarshal(data, &span); err != nil {
+			return err
+		}
+
+		_, err := fmt.Fprintln(out, formatSpan(span))
+		return err
+	case "dropped":
+		var dropped tail.Dropped
+		if err := json.Unmarshal(data, &dropped); err != nil {
+			return err
+		}
+
+		_, err := fmt.Fprintf(errOut, "... %d spans dropped\n", dropped.Count)
+		return err
+	}
+
+	return nil
+}
+
+// formatSpan returns a line with the span and its attributes sorted by key
+func formatSpan(span tail.Span) string {
+	var b strings.Builder
+
+	stage := span.Stage
+	if span.Pipeline != "" {
+		stage += ":" + span.Pipeline
+	}
+
+	fmt.Fprintf(&b, "%s [%s] %s %s %s/%s %s %s", span.Time.Format("15:04:05.000"), stage,
+		span.Service, strconv.Quote(span.Name), span.TraceID, span.SpanID, span.Kind, span.Duration)
+
+	if span.Status != "" && span.Status != "unset" {
+		b.WriteString(" " + strings.ToUpper(span.Status))
+		if span.Message != "" {
+			b.WriteString(": " + span.Message)
+		}
+	}
+
+	keys := make([]string, 0, len(span.Attributes))
+	for key := range span.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%s", key, span.Attributes[key])
+	}
+
+	return b.String()
+}
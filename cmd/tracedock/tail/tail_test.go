@@ -0,0 +1,71 @@
+package tail
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tracedock/tracedock/internal/tail"
+)
+
+func Test_TailCmd(t *testing.T) {
+	t.Run("should return error for args", func(t *testing.T) {
+		var out = new(bytes.Buffer)
+
+		TailCmd.SetOut(out)
+		TailCmd.SetErr(out)
+		TailCmd.SetArgs([]string{"unexpected"})
+
+		assert.Error(t, TailCmd.Execute())
+		assert.Contains(t, out.String(), "Usage:")
+	})
+
+	t.Run("should print the streamed spans", func(t *testing.T) {
+		var query string
+		var out, errOut = new(bytes.Buffer), new(bytes.Buffer)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.RawQuery
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: span\n")
+			fmt.Fprint(w, `data: {"stage":"after","pipeline":"default","time":"2024-01-02T03:04:05Z","service":"checkout",`)
+			fmt.Fprint(w, `"trace_id":"01","span_id":"02","name":"GET","kind":"server","status":"error","status_message":"boom",`)
+			fmt.Fprint(w, `"duration":1500000,"attributes":{"http.route":"/cart","http.method":"GET"}}`+"\n\n")
+			fmt.Fprint(w, "event: dropped\ndata: {\"count\":3}\n\n")
+		}))
+		t.Cleanup(srv.Close)
+
+		TailCmd.SetOut(out)
+		TailCmd.SetErr(errOut)
+		TailCmd.SetArgs([]string{"--admin", srv.URL, "--filter", `span.name == "GET"`, "--stage", tail.StageAfter, "--rate", "10"})
+
+		assert.NoError(t, TailCmd.Execute())
+		assert.Equal(t, "filter=span.name+%3D%3D+%22GET%22&rate=10&stage=after", query)
+		assert.Equal(t, "03:04:05.000 [after:default] checkout \"GET\" 01/02 server 1.5ms ERROR: boom http.method=GET http.route=/cart\n", out.String())
+		assert.Equal(t, "... 3 spans dropped\n", errOut.String())
+	})
+}
+
+func Test_formatSpan(t *testing.T) {
+	t.Run("should leave out the unset status", func(t *testing.T) {
+		line := formatSpan(tail.Span{
+			Stage:    tail.StageBefore,
+			Time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Service:  "checkout",
+			TraceID:  "01",
+			SpanID:   "02",
+			Name:     "GET",
+			Kind:     "client",
+			Status:   "unset",
+			Duration: time.Second,
+		})
+
+		assert.Equal(t, "03:04:05.000 [before] checkout \"GET\" 01/02 client 1s", line)
+	})
+}
@@ -0,0 +1,63 @@
+// Package client carries the information about the client that sent the
+// trace data from the receivers to the processors, through the context
+package client
+
+import (
+	"context"
+	"net"
+)
+
+// Protocols the trace data is received with
+const (
+	ProtocolOTLPGRPC   = "otlp_grpc"
+	ProtocolOTLPHTTP   = "otlp_http"
+	ProtocolZipkin     = "zipkin"
+	ProtocolJaegerGRPC = "jaeger_grpc"
+	ProtocolJaegerHTTP = "jaeger_http"
+)
+
+// Info describes the client that sent the trace data
+type Info struct {
+	// Protocol is the one the trace data was received with
+	Protocol string
+
+	// Addr is the address of the client, usually an ip:port, empty when
+	// unknown, e.g. for Unix domain sockets
+	Addr string
+
+	// Headers are the request headers, or gRPC metadata, the receiver was
+	// configured to keep, by their lowercase name
+	Headers map[string]string
+
+	// Tenant is the tenant the client sent the trace data for, empty when
+	// the receiver has no tenant header configured or it's missing
+	Tenant string
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the client info
+func NewContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext returns the client info carried by ctx, the zero Info when
+// there is none
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(contextKey{}).(Info)
+	return info
+}
+
+// IP returns the IP of the client address, empty when it has none
+func (i Info) IP() string {
+	host, _, err := net.SplitHostPort(i.Addr)
+	if err != nil {
+		host = i.Addr
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+
+	return ""
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FromContext(t *testing.T) {
+	t.Run("should return the info carried by the context", func(t *testing.T) {
+		ctx := NewContext(context.Background(), Info{Addr: "10.0.0.5:43210"})
+
+		assert.Equal(t, Info{Addr: "10.0.0.5:43210"}, FromContext(ctx))
+	})
+
+	t.Run("should return the zero info when there is none", func(t *testing.T) {
+		assert.Equal(t, Info{}, FromContext(context.Background()))
+	})
+}
+
+func Test_Info_IP(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{"10.0.0.5:43210", "10.0.0.5"},
+		{"10.0.0.5", "10.0.0.5"},
+		{"[2001:db8::1]:43210", "2001:db8::1"},
+		{"[::ffff:10.0.0.5]:43210", "10.0.0.5"},
+		{"@", ""},
+		{"", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run("should return "+tc.expected+" for "+tc.addr, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Info{Addr: tc.addr}.IP())
+		})
+	}
+}
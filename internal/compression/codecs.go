@@ -0,0 +1,206 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+type gzipCodec struct {
+	readers sync.Pool
+	writers sync.Pool
+}
+
+func (c *gzipCodec) newReader(r io.Reader, _ int64) (io.ReadCloser, error) {
+	gzr, ok := c.readers.Get().(*gzip.Reader)
+	if !ok {
+		var err error
+		if gzr, err = gzip.NewReader(r); err != nil {
+			return nil, err
+		}
+	} else if err := gzr.Reset(r); err != nil {
+		c.readers.Put(gzr)
+		return nil, err
+	}
+
+	return &pooledReader{Reader: gzr, release: func() { c.readers.Put(gzr) }}, nil
+}
+
+func (c *gzipCodec) newWriter(w io.Writer) io.WriteCloser {
+	gzw, ok := c.writers.Get().(*gzip.Writer)
+	if !ok {
+		return &pooledWriter{WriteCloser: gzip.NewWriter(w), release: func(wc io.WriteCloser) { c.writers.Put(wc) }}
+	}
+
+	gzw.Reset(w)
+	return &pooledWriter{WriteCloser: gzw, release: func(wc io.WriteCloser) { c.writers.Put(wc) }}
+}
+
+// deflateCodec is the zlib format, which is what HTTP and gRPC call deflate
+type deflateCodec struct {
+	readers sync.Pool
+	writers sync.Pool
+}
+
+func (c *deflateCodec) newReader(r io.Reader, _ int64) (io.ReadCloser, error) {
+	zr, ok := c.readers.Get().(io.ReadCloser)
+	if !ok {
+		var err error
+		if zr, err = zlib.NewReader(r); err != nil {
+			return nil, err
+		}
+	} else if err := zr.(zlib.Resetter).Reset(r, nil); err != nil {
+		c.readers.Put(zr)
+		return nil, err
+	}
+
+	return &pooledReader{Reader: zr, release: func() { c.readers.Put(zr) }}, nil
+}
+
+func (c *deflateCodec) newWriter(w io.Writer) io.WriteCloser {
+	zw, ok := c.writers.Get().(*zlib.Writer)
+	if !ok {
+		zw = zlib.NewWriter(w)
+	} else {
+		zw.Reset(w)
+	}
+
+	return &pooledWriter{WriteCloser: zw, release: func(wc io.WriteCloser) { c.writers.Put(wc) }}
+}
+
+// maxZstdWindow bounds the memory a frame can make the decoder allocate,
+// encoders use up to 8MB unless told otherwise
+const maxZstdWindow = 64 << 20
+
+type zstdCodec struct {
+	readers sync.Pool
+	writers sync.Pool
+}
+
+func (c *zstdCodec) newReader(r io.Reader, _ int64) (io.ReadCloser, error) {
+	zr, ok := c.readers.Get().(*zstd.Decoder)
+	if !ok {
+		var err error
+		if zr, err = zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxZstdWindow)); err != nil {
+			return nil, err
+		}
+	} else if err := zr.Reset(r); err != nil {
+		c.readers.Put(zr)
+		return nil, err
+	}
+
+	return &pooledReader{Reader: zr, release: func() {
+		// detaches the source so it can be released
+		zr.Reset(nil)
+		c.readers.Put(zr)
+	}}, nil
+}
+
+func (c *zstdCodec) newWriter(w io.Writer) io.WriteCloser {
+	zw, ok := c.writers.Get().(*zstd.Encoder)
+	if !ok {
+		zw, _ = zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	} else {
+		zw.Reset(w)
+	}
+
+	return &pooledWriter{WriteCloser: zw, release: func(wc io.WriteCloser) { c.writers.Put(wc) }}
+}
+
+// snappyCodec is the block format used over HTTP, which can't be streamed,
+// so the whole input is read and its decompressed size checked before being
+// decoded
+type snappyCodec struct{}
+
+func (snappyCodec) newReader(r io.Reader, maxSize int64) (io.ReadCloser, error) {
+	compressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxSize > 0 && int64(size) > maxSize {
+		return nil, ErrTooLarge
+	}
+
+	decoded, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(decoded)), nil
+}
+
+func (snappyCodec) newWriter(w io.Writer) io.WriteCloser {
+	return &snappyWriter{w: w}
+}
+
+// snappyWriter buffers the data, encoding it as a block when closed
+type snappyWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (s *snappyWriter) Write(p []byte) (int, error) {
+	return s.buf.Write(p)
+}
+
+func (s *snappyWriter) Close() error {
+	_, err := s.w.Write(snappy.Encode(nil, s.buf.Bytes()))
+	return err
+}
+
+// snappyFramedCodec is the framing format, streamed in chunks of at most
+// 64KB, which gRPC uses so its message size limit applies while decoding
+type snappyFramedCodec struct {
+	readers sync.Pool
+	writers sync.Pool
+}
+
+func (c *snappyFramedCodec) newReader(r io.Reader, _ int64) (io.ReadCloser, error) {
+	sr, ok := c.readers.Get().(*snappy.Reader)
+	if !ok {
+		sr = snappy.NewReader(r)
+	} else {
+		sr.Reset(r)
+	}
+
+	return &pooledReader{Reader: sr, release: func() {
+		// detaches the source so it can be released
+		sr.Reset(nil)
+		c.readers.Put(sr)
+	}}, nil
+}
+
+func (c *snappyFramedCodec) newWriter(w io.Writer) io.WriteCloser {
+	sw, ok := c.writers.Get().(*snappy.Writer)
+	if !ok {
+		sw = snappy.NewBufferedWriter(w)
+	} else {
+		sw.Reset(w)
+	}
+
+	return &pooledWriter{WriteCloser: sw, release: func(wc io.WriteCloser) { c.writers.Put(wc) }}
+}
+
+// pooledWriter gives its compressor back once closed
+type pooledWriter struct {
+	io.WriteCloser
+	release func(io.WriteCloser)
+}
+
+func (w *pooledWriter) Close() error {
+	err := w.WriteCloser.Close()
+	w.release(w.WriteCloser)
+
+	return err
+}
@@ -0,0 +1,162 @@
+// Package compression decompresses the bodies received and compresses the
+// data exported with the encodings used by OTLP, pooling the readers and
+// writers of each one. Importing it also registers them as gRPC compressors.
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// None leaves the data uncompressed
+	None = ""
+
+	Gzip    = "gzip"
+	Zstd    = "zstd"
+	Snappy  = "snappy"
+	Deflate = "deflate"
+)
+
+var (
+	// ErrUnsupported is returned for unknown compressions
+	ErrUnsupported = errors.New("unsupported compression")
+
+	// ErrTooLarge is returned when the decompressed data exceeds the limit
+	ErrTooLarge = errors.New("decompressed data too large")
+)
+
+// codec reads and writes a compression format
+type codec interface {
+	// newReader returns a reader decompressing r, closing it gives the
+	// decompressor back to its pool. Formats that can't be streamed check
+	// the decompressed size against maxSize, unless it's zero.
+	newReader(r io.Reader, maxSize int64) (io.ReadCloser, error)
+
+	// newWriter returns a writer compressing to w, closing it flushes the
+	// data and gives the compressor back to its pool
+	newWriter(w io.Writer) io.WriteCloser
+}
+
+var codecs = map[string]codec{
+	Gzip:    &gzipCodec{},
+	Zstd:    &zstdCodec{},
+	Snappy:  snappyCodec{},
+	Deflate: &deflateCodec{},
+}
+
+// Names returns the supported compressions, sorted
+func Names() []string {
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Validate returns ErrUnsupported, wrapped, for unknown compressions
+func Validate(name string) error {
+	if _, ok := codecs[name]; !ok && name != None {
+		return fmt.Errorf("%w %q", ErrUnsupported, name)
+	}
+
+	return nil
+}
+
+// Decode decompresses src into dst, returning ErrTooLarge as soon as the
+// decompressed data exceeds maxSize, unless it's zero
+func Decode(name string, dst *bytes.Buffer, src io.Reader, maxSize int64) error {
+	var reader = src
+
+	if err := Validate(name); err != nil {
+		return err
+	}
+
+	if c, ok := codecs[name]; ok {
+		decompressor, err := c.newReader(src, maxSize)
+		if err != nil {
+			return err
+		}
+		defer decompressor.Close()
+
+		reader = decompressor
+	}
+
+	if maxSize <= 0 {
+		_, err := dst.ReadFrom(reader)
+		return err
+	}
+
+	start := dst.Len()
+
+	if _, err := dst.ReadFrom(io.LimitReader(reader, maxSize+1)); err != nil {
+		return err
+	}
+
+	if int64(dst.Len()-start) > maxSize {
+		return ErrTooLarge
+	}
+
+	return nil
+}
+
+// Encode compresses data, returning it as is for None
+func Encode(name string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := Validate(name); err != nil {
+		return nil, err
+	}
+
+	c, ok := codecs[name]
+	if !ok {
+		return data, nil
+	}
+
+	compressor := c.newWriter(&buf)
+
+	if _, err := compressor.Write(data); err != nil {
+		compressor.Close()
+		return nil, err
+	}
+
+	if err := compressor.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// pooledReader gives its decompressor back once closed or fully read, the
+// latter for gRPC which never closes them
+type pooledReader struct {
+	io.Reader
+	release func()
+}
+
+func (r *pooledReader) Read(p []byte) (int, error) {
+	if r.release == nil {
+		return 0, io.EOF
+	}
+
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		r.Close()
+	}
+
+	return n, err
+}
+
+func (r *pooledReader) Close() error {
+	if r.release != nil {
+		r.release()
+		r.release = nil
+	}
+
+	return nil
+}
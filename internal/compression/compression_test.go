@@ -0,0 +1,109 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/encoding"
+)
+
+var payload = []byte(strings.Repeat("tracedock compresses spans ", 100))
+
+func Test_Encode(t *testing.T) {
+	t.Run("should round trip every compression", func(t *testing.T) {
+		for _, name := range append(Names(), None) {
+			// twice, so pooled readers and writers are reused
+			for range 2 {
+				compressed, err := Encode(name, payload)
+				assert.NoError(t, err, name)
+
+				if name != None {
+					assert.Less(t, len(compressed), len(payload), name)
+				}
+
+				var buf bytes.Buffer
+				assert.NoError(t, Decode(name, &buf, bytes.NewReader(compressed), 0), name)
+				assert.Equal(t, payload, buf.Bytes(), name)
+			}
+		}
+	})
+
+	t.Run("should return error for unsupported compressions", func(t *testing.T) {
+		_, err := Encode("lz4", payload)
+		assert.ErrorIs(t, err, ErrUnsupported)
+	})
+}
+
+func Test_Decode(t *testing.T) {
+	t.Run("should return error when the data exceeds the limit", func(t *testing.T) {
+		for _, name := range append(Names(), None) {
+			compressed, err := Encode(name, payload)
+			assert.NoError(t, err)
+
+			var buf bytes.Buffer
+			assert.ErrorIs(t, Decode(name, &buf, bytes.NewReader(compressed), 100), ErrTooLarge, name)
+			assert.LessOrEqual(t, buf.Len(), 101, name)
+		}
+	})
+
+	t.Run("should return error for unsupported compressions", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.ErrorIs(t, Decode("br", &buf, bytes.NewReader(payload), 0), ErrUnsupported)
+	})
+
+	t.Run("should return error for corrupted data", func(t *testing.T) {
+		for _, name := range Names() {
+			var buf bytes.Buffer
+			assert.Error(t, Decode(name, &buf, strings.NewReader("not compressed"), 0), name)
+		}
+	})
+}
+
+func Test_grpcCompressor(t *testing.T) {
+	t.Run("should register every compression with gRPC", func(t *testing.T) {
+		for _, name := range Names() {
+			compressor := encoding.GetCompressor(name)
+			assert.NotNil(t, compressor, name)
+
+			var compressed bytes.Buffer
+
+			w, err := compressor.Compress(&compressed)
+			assert.NoError(t, err)
+			w.Write(payload)
+			assert.NoError(t, w.Close())
+
+			r, err := compressor.Decompress(&compressed)
+			assert.NoError(t, err)
+
+			decompressed, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, payload, decompressed, name)
+		}
+	})
+
+	t.Run("should not allocate the length declared by snappy frames", func(t *testing.T) {
+		var before, after runtime.MemStats
+
+		frame := []byte("\xff\x06\x00\x00sNaPpY")
+
+		// a compressed chunk, its checksum then a block declaring 4GB
+		frame = append(frame, 0x00, 9, 0, 0, 0, 0, 0, 0)
+		frame = append(frame, 0xff, 0xff, 0xff, 0xff, 0x0f)
+
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		r, err := encoding.GetCompressor(Snappy).Decompress(bytes.NewReader(frame))
+		assert.NoError(t, err)
+
+		_, err = io.ReadAll(r)
+		assert.Error(t, err)
+
+		runtime.ReadMemStats(&after)
+		assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+	})
+}
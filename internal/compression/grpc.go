@@ -0,0 +1,39 @@
+package compression
+
+import (
+	"io"
+
+	"google.golang.org/grpc/encoding"
+
+	// gzip is registered by grpc itself
+	_ "google.golang.org/grpc/encoding/gzip"
+)
+
+func init() {
+	for _, name := range []string{Zstd, Deflate} {
+		encoding.RegisterCompressor(&grpcCompressor{name: name, codec: codecs[name]})
+	}
+
+	// the snappy block format would be decoded whole, whatever its size
+	encoding.RegisterCompressor(&grpcCompressor{name: Snappy, codec: &snappyFramedCodec{}})
+}
+
+// grpcCompressor adapts a codec to the gRPC compressor interface
+type grpcCompressor struct {
+	name  string
+	codec codec
+}
+
+func (c *grpcCompressor) Name() string {
+	return c.name
+}
+
+func (c *grpcCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return c.codec.newWriter(w), nil
+}
+
+// Decompress leaves the message size limit to gRPC, as it reads the
+// returned reader through one, so the codecs used must stream
+func (c *grpcCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return c.codec.newReader(r, 0)
+}
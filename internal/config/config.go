@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -21,12 +23,48 @@ type ConfigPerformance struct {
 	MemoryLimiter ConfigPerformanceMemoryLimiter `mapstructure:"memory_limiter"`
 }
 
+type ConfigTransform struct {
+	Function string
+
+	Field string
+	From  string
+	To    string
+	Value string
+
+	Length int
+	Type   string
+	Limit  int
+
+	Pattern     string
+	Replacement string
+
+	Code    string
+	Message string
+}
+
 type ConfigPipelineRules struct {
+	Name     string
 	Provider string
 
 	Match   map[string]map[string]string
 	Missing map[string]map[string]string
+	When    string
 	Set     map[string]string
+
+	Transform []ConfigTransform
+
+	Drop  string
+	Prune bool
+
+	Tenant    string
+	Exporters []string
+	Continue  bool
+
+	Detectors []string
+	Override  bool
+
+	Window   time.Duration
+	MaxItems int `mapstructure:"max_items"`
 }
 
 type ConfigPipeline struct {
@@ -34,11 +72,108 @@ type ConfigPipeline struct {
 	Rules []ConfigPipelineRules
 }
 
+type ConfigExporterRotation struct {
+	MaxSize  int `mapstructure:"max_size"`
+	Interval time.Duration
+}
+
+type ConfigExporter struct {
+	Name        string
+	Type        string
+	Endpoint    string
+	Protocol    string
+	Encoding    string
+	Insecure    bool
+	Timeout     time.Duration
+	Headers     map[string]string
+	Path        string
+	Compression string
+	Rotation    ConfigExporterRotation
+
+	Retention time.Duration
+	MaxSize   int `mapstructure:"max_size"`
+
+	Verbosity     string
+	SamplingRatio float64 `mapstructure:"sampling_ratio"`
+}
+
+type ConfigReceiverHTTPCORS struct {
+	AllowedOrigins []string      `mapstructure:"allowed_origins"`
+	AllowedHeaders []string      `mapstructure:"allowed_headers"`
+	MaxAge         time.Duration `mapstructure:"max_age"`
+}
+
+type ConfigReceiverHTTP struct {
+	MaxBodySize       int           `mapstructure:"max_body_size"`
+	MaxHeaderSize     int           `mapstructure:"max_header_size"`
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+	SocketPermissions string        `mapstructure:"socket_permissions"`
+	IncludeHeaders    []string      `mapstructure:"include_headers"`
+	TenantHeader      string        `mapstructure:"tenant_header"`
+	CORS              ConfigReceiverHTTPCORS
+}
+
+type ConfigReceiverGRPCEnforcementPolicy struct {
+	MinTime             time.Duration `mapstructure:"min_time"`
+	PermitWithoutStream bool          `mapstructure:"permit_without_stream"`
+}
+
+type ConfigReceiverGRPCKeepalive struct {
+	Time                  time.Duration
+	Timeout               time.Duration
+	MaxConnectionIdle     time.Duration                       `mapstructure:"max_connection_idle"`
+	MaxConnectionAge      time.Duration                       `mapstructure:"max_connection_age"`
+	MaxConnectionAgeGrace time.Duration                       `mapstructure:"max_connection_age_grace"`
+	EnforcementPolicy     ConfigReceiverGRPCEnforcementPolicy `mapstructure:"enforcement_policy"`
+}
+
+type ConfigReceiverGRPC struct {
+	MaxRecvMsgSize       int      `mapstructure:"max_recv_msg_size"`
+	MaxConcurrentStreams uint32   `mapstructure:"max_concurrent_streams"`
+	SocketPermissions    string   `mapstructure:"socket_permissions"`
+	IncludeHeaders       []string `mapstructure:"include_headers"`
+	TenantHeader         string   `mapstructure:"tenant_header"`
+	Keepalive            ConfigReceiverGRPCKeepalive
+}
+
+type ConfigReceivers struct {
+	HTTP ConfigReceiverHTTP
+	GRPC ConfigReceiverGRPC
+}
+
+type ConfigServiceGraph struct {
+	Enabled  bool
+	Wait     time.Duration
+	MaxItems int `mapstructure:"max_items"`
+}
+
+type ConfigKubernetes struct {
+	Enabled    bool
+	Kubeconfig string
+	Namespace  string
+	Labels     []string
+}
+
+type ConfigValidation struct {
+	Enabled        bool
+	Policy         string
+	MaxValueLength int `mapstructure:"max_value_length"`
+	MaxArrayLength int `mapstructure:"max_array_length"`
+}
+
 type Config struct {
-	Log         ConfigLog
-	Plugins     ConfigPlugins
-	Performance ConfigPerformance
-	Pipelines   []ConfigPipeline
+	Log          ConfigLog
+	Plugins      ConfigPlugins
+	Performance  ConfigPerformance
+	Receivers    ConfigReceivers
+	Exporters    []ConfigExporter
+	Pipelines    []ConfigPipeline
+	ServiceGraph ConfigServiceGraph `mapstructure:"service_graph"`
+	Kubernetes   ConfigKubernetes
+	Validation   ConfigValidation
 }
 
 func NewConfig() *Config {
@@ -68,4 +203,16 @@ func (c *Config) Load(file string) error {
 func (c *Config) setDefaults() {
 	viper.SetDefault("log.level", "INFO")
 	viper.SetDefault("plugins.folders", []string{"/etc/trackdock/plugins"})
+	viper.SetDefault("service_graph.wait", 10*time.Second)
+	viper.SetDefault("service_graph.max_items", 10000)
+	viper.SetDefault("validation.policy", "fix")
+	viper.SetDefault("validation.max_value_length", 65536)
+	viper.SetDefault("validation.max_array_length", 1024)
+	viper.SetDefault("receivers.http.max_body_size", 20)
+	viper.SetDefault("receivers.http.max_header_size", 64)
+	viper.SetDefault("receivers.http.read_timeout", 30*time.Second)
+	viper.SetDefault("receivers.http.read_header_timeout", 10*time.Second)
+	viper.SetDefault("receivers.http.write_timeout", 30*time.Second)
+	viper.SetDefault("receivers.http.idle_timeout", 2*time.Minute)
+	viper.SetDefault("receivers.grpc.max_recv_msg_size", 20)
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -20,16 +21,93 @@ performance:
     strategy: disk_dump
     max_consumption: 4096m
 
+receivers:
+  http:
+    max_body_size: 8
+    read_header_timeout: 5s
+    tenant_header: x-scope-orgid
+    cors:
+      allowed_origins: [https://*.example.com]
+      allowed_headers: [x-api-key]
+  grpc:
+    max_recv_msg_size: 16
+    max_concurrent_streams: 100
+    include_headers: [user-agent]
+    keepalive:
+      time: 1m
+      max_connection_age: 30m
+      enforcement_policy:
+        min_time: 10s
+        permit_without_stream: true
+
+exporters:
+- name: jaeger
+  type: otlp
+  endpoint: jaeger:4317
+  insecure: true
+  timeout: 1s
+- name: capture
+  type: file
+  path: /var/tmp/traces.ndjson.zst
+  compression: zstd
+  rotation:
+    max_size: 100
+    interval: 1h
+- name: console
+  type: debug
+  verbosity: detailed
+  sampling_ratio: 0.5
+- name: recent
+  type: storage
+  path: /var/lib/tracedock
+  retention: 72h
+  max_size: 2048
+
 pipelines:
 - name: main
   rules:
-  - provider: eraser
+  - provider: detector
+    detectors: [env, host]
+    override: true
+  - provider: dedup
+    window: 10m
+    max_items: 50000
+  - name: redis-fast-reads
+    provider: filter
+    drop: spans
+    prune: true
     match:
       attributes:
         db.system: redis
         db.statement: ^HGETALL.*
       duration:
         lt: 100ms
+  - provider: transform
+    transform:
+    - function: truncate
+      field: span.attributes.db.statement
+      length: 256
+  - provider: route
+    match:
+      resource:
+        deployment.environment: prod
+    when: resource.attributes["tenant.id"] != "internal"
+    exporters: [jaeger]
+    continue: true
+
+service_graph:
+  enabled: true
+  wait: 5s
+
+kubernetes:
+  enabled: true
+  namespace: shop
+  labels: [app, team]
+
+validation:
+  enabled: true
+  policy: drop
+  max_array_length: 128
 `)
 
 var configUnmarshaled = &Config{
@@ -45,12 +123,82 @@ var configUnmarshaled = &Config{
 			MaxConsumption: "4096m",
 		},
 	},
+	Receivers: ConfigReceivers{
+		HTTP: ConfigReceiverHTTP{
+			MaxBodySize:       8,
+			MaxHeaderSize:     64,
+			ReadTimeout:       30 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			TenantHeader:      "x-scope-orgid",
+			CORS: ConfigReceiverHTTPCORS{
+				AllowedOrigins: []string{"https://*.example.com"},
+				AllowedHeaders: []string{"x-api-key"},
+			},
+		},
+		GRPC: ConfigReceiverGRPC{
+			MaxRecvMsgSize:       16,
+			MaxConcurrentStreams: 100,
+			IncludeHeaders:       []string{"user-agent"},
+			Keepalive: ConfigReceiverGRPCKeepalive{
+				Time:             time.Minute,
+				MaxConnectionAge: 30 * time.Minute,
+				EnforcementPolicy: ConfigReceiverGRPCEnforcementPolicy{
+					MinTime:             10 * time.Second,
+					PermitWithoutStream: true,
+				},
+			},
+		},
+	},
+	Exporters: []ConfigExporter{
+		{
+			Name:     "jaeger",
+			Type:     "otlp",
+			Endpoint: "jaeger:4317",
+			Insecure: true,
+			Timeout:  time.Second,
+		},
+		{
+			Name:        "capture",
+			Type:        "file",
+			Path:        "/var/tmp/traces.ndjson.zst",
+			Compression: "zstd",
+			Rotation:    ConfigExporterRotation{MaxSize: 100, Interval: time.Hour},
+		},
+		{
+			Name:          "console",
+			Type:          "debug",
+			Verbosity:     "detailed",
+			SamplingRatio: 0.5,
+		},
+		{
+			Name:      "recent",
+			Type:      "storage",
+			Path:      "/var/lib/tracedock",
+			Retention: 72 * time.Hour,
+			MaxSize:   2048,
+		},
+	},
 	Pipelines: []ConfigPipeline{
 		{
 			Name: "main",
 			Rules: []ConfigPipelineRules{
 				{
-					Provider: "eraser",
+					Provider:  "detector",
+					Detectors: []string{"env", "host"},
+					Override:  true,
+				},
+				{
+					Provider: "dedup",
+					Window:   10 * time.Minute,
+					MaxItems: 50000,
+				},
+				{
+					Name:     "redis-fast-reads",
+					Provider: "filter",
+					Drop:     "spans",
+					Prune:    true,
 					Match: map[string]map[string]string{
 						"attributes": {
 							"db.system":    "redis",
@@ -61,9 +209,46 @@ var configUnmarshaled = &Config{
 						},
 					},
 				},
+				{
+					Provider: "transform",
+					Transform: []ConfigTransform{
+						{
+							Function: "truncate",
+							Field:    "span.attributes.db.statement",
+							Length:   256,
+						},
+					},
+				},
+				{
+					Provider: "route",
+					Match: map[string]map[string]string{
+						"resource": {
+							"deployment.environment": "prod",
+						},
+					},
+					When:      `resource.attributes["tenant.id"] != "internal"`,
+					Exporters: []string{"jaeger"},
+					Continue:  true,
+				},
 			},
 		},
 	},
+	ServiceGraph: ConfigServiceGraph{
+		Enabled:  true,
+		Wait:     5 * time.Second,
+		MaxItems: 10000,
+	},
+	Kubernetes: ConfigKubernetes{
+		Enabled:   true,
+		Namespace: "shop",
+		Labels:    []string{"app", "team"},
+	},
+	Validation: ConfigValidation{
+		Enabled:        true,
+		Policy:         "drop",
+		MaxValueLength: 65536,
+		MaxArrayLength: 128,
+	},
 }
 
 func Test_Config_Load(t *testing.T) {
@@ -0,0 +1,349 @@
+package exporter
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+const (
+	VerbositySummary  = "summary"
+	VerbosityNormal   = "normal"
+	VerbosityDetailed = "detailed"
+)
+
+// keyAttributes are printed with the normal verbosity, as they usually
+// tell what a span did
+var keyAttributes = []string{
+	"http.method", "http.request.method", "http.route", "http.target", "url.path",
+	"http.status_code", "http.response.status_code",
+	"rpc.system", "rpc.method", "db.system", "db.operation", "messaging.system",
+	"peer.service", "error.type",
+}
+
+// DebugExporter prints trace data as indented parent/child trees, meant to
+// verify what the pipelines did
+//
+// With the summary verbosity a line is printed for each trace, normal prints
+// the span trees with their key attributes and detailed adds every attribute,
+// the resources, events and links.
+type DebugExporter struct {
+	mu            sync.Mutex
+	out           io.Writer
+	verbosity     string
+	samplingRatio float64
+}
+
+// debugNode is a span in the tree of its trace
+type debugNode struct {
+	rs       *trace.ResourceSpans
+	span     *trace.Span
+	children []*debugNode
+}
+
+// NewDebugExporter creates a new debug exporter writing to the standard output
+func NewDebugExporter(cfg config.ConfigExporter) (*DebugExporter, error) {
+	e := &DebugExporter{
+		out:           os.Stdout,
+		verbosity:     cfg.Verbosity,
+		samplingRatio: cfg.SamplingRatio,
+	}
+
+	if e.verbosity == "" {
+		e.verbosity = VerbosityNormal
+	}
+
+	switch e.verbosity {
+	case VerbositySummary, VerbosityNormal, VerbosityDetailed:
+	default:
+		return nil, fmt.Errorf("unsupported verbosity %q for debug exporter", e.verbosity)
+	}
+
+	if e.samplingRatio == 0 {
+		e.samplingRatio = 1
+	}
+
+	if e.samplingRatio < 0 || e.samplingRatio > 1 {
+		return nil, fmt.Errorf("sampling ratio of debug exporter must be between 0 and 1, got %v", e.samplingRatio)
+	}
+
+	return e, nil
+}
+
+// Export prints the sampled traces of the given resources
+func (e *DebugExporter) Export(ctx context.Context, rss []*trace.ResourceSpans) error {
+	var b strings.Builder
+
+	for _, roots := range e.buildTrees(rss) {
+		e.printTrace(&b, roots)
+	}
+
+	if b.Len() == 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, err := io.WriteString(e.out, b.String())
+	return err
+}
+
+// Shutdown does nothing as the exporter holds no resources
+func (e *DebugExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// sampled decides by the trace id so every span of a trace is printed or
+// none is, even across exports
+func (e *DebugExporter) sampled(traceID []byte) bool {
+	if e.samplingRatio >= 1 {
+		return true
+	}
+
+	if len(traceID) < 8 {
+		return false
+	}
+
+	value := binary.BigEndian.Uint64(traceID[len(traceID)-8:])
+
+	return float64(value) < e.samplingRatio*math.MaxUint64
+}
+
+// buildTrees returns the root spans of each sampled trace, in the order the
+// traces were found. Spans whose parent isn't in the data are taken as roots.
+func (e *DebugExporter) buildTrees(rss []*trace.ResourceSpans) [][]*debugNode {
+	var order []string
+	var traces = make(map[string][]*debugNode)
+
+	for _, rs := range rss {
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				if !e.sampled(span.TraceId) {
+					continue
+				}
+
+				traceID := string(span.TraceId)
+				if _, ok := traces[traceID]; !ok {
+					order = append(order, traceID)
+				}
+
+				traces[traceID] = append(traces[traceID], &debugNode{rs: rs, span: span})
+			}
+		}
+	}
+
+	var trees = make([][]*debugNode, 0, len(order))
+
+	for _, traceID := range order {
+		var roots []*debugNode
+		var nodes = traces[traceID]
+		var bySpanID = make(map[string]*debugNode, len(nodes))
+
+		for _, node := range nodes {
+			bySpanID[string(node.span.SpanId)] = node
+		}
+
+		for _, node := range nodes {
+			if parent, ok := bySpanID[string(node.span.ParentSpanId)]; ok && parent != node {
+				parent.children = append(parent.children, node)
+			} else {
+				roots = append(roots, node)
+			}
+		}
+
+		// spans in parent cycles are unreachable from the roots, they are
+		// printed as roots on their own
+		var visited = make(map[*debugNode]bool, len(nodes))
+		var visit func(nodes []*debugNode)
+
+		visit = func(nodes []*debugNode) {
+			for _, node := range nodes {
+				visited[node] = true
+				visit(node.children)
+			}
+		}
+		visit(roots)
+
+		for _, node := range nodes {
+			if !visited[node] {
+				node.children = nil
+				roots = append(roots, node)
+			}
+		}
+
+		sortNodes(roots)
+		trees = append(trees, roots)
+	}
+
+	return trees
+}
+
+func sortNodes(nodes []*debugNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].span.StartTimeUnixNano < nodes[j].span.StartTimeUnixNano
+	})
+
+	for _, node := range nodes {
+		sortNodes(node.children)
+	}
+}
+
+func (e *DebugExporter) printTrace(b *strings.Builder, roots []*debugNode) {
+	var count int
+	var walk func(nodes []*debugNode)
+
+	walk = func(nodes []*debugNode) {
+		for _, node := range nodes {
+			count++
+			walk(node.children)
+		}
+	}
+	walk(roots)
+
+	root := roots[0]
+
+	if e.verbosity == VerbositySummary {
+		fmt.Fprintf(b, "trace %s %s %q %d spans %s%s\n",
+			hex.EncodeToString(root.span.TraceId), otlputil.ServiceName(root.rs), root.span.Name,
+			count, otlputil.SpanDuration(root.span), statusText(root.span.Status))
+		return
+	}
+
+	fmt.Fprintf(b, "trace %s (%d spans)\n", hex.EncodeToString(root.span.TraceId), count)
+
+	for i, node := range roots {
+		e.printNode(b, node, "", i == len(roots)-1)
+	}
+}
+
+func (e *DebugExporter) printNode(b *strings.Builder, node *debugNode, prefix string, last bool) {
+	var span = node.span
+	var branch, indent = "├─ ", "│  "
+
+	if last {
+		branch, indent = "└─ ", "   "
+	}
+
+	fmt.Fprintf(b, "%s%s%s %s [%s] %s%s\n", prefix, branch, otlputil.ServiceName(node.rs), span.Name,
+		otlputil.SpanKindName(span.Kind), otlputil.SpanDuration(span), statusText(span.Status))
+
+	detail := prefix + indent
+	if len(node.children) > 0 {
+		detail += "│ "
+	} else {
+		detail += "  "
+	}
+
+	if e.verbosity == VerbosityDetailed {
+		fmt.Fprintf(b, "%sspan_id=%s", detail, hex.EncodeToString(span.SpanId))
+		if len(span.ParentSpanId) > 0 {
+			fmt.Fprintf(b, " parent_span_id=%s", hex.EncodeToString(span.ParentSpanId))
+		}
+		b.WriteString("\n")
+
+		if attrs := node.rs.GetResource().GetAttributes(); len(attrs) > 0 {
+			printLine(b, detail, "resource:", attrs)
+		}
+
+		if len(span.Attributes) > 0 {
+			printLine(b, detail, "attributes:", span.Attributes)
+		}
+
+		for _, event := range span.Events {
+			printLine(b, detail, "event "+event.Name, event.Attributes)
+		}
+
+		for _, link := range span.Links {
+			printLine(b, detail, "link "+hex.EncodeToString(link.TraceId)+"/"+hex.EncodeToString(link.SpanId), link.Attributes)
+		}
+	} else {
+		var attrs []*common.KeyValue
+
+		for _, key := range keyAttributes {
+			if value, ok := otlputil.FindAttribute(span.Attributes, key); ok {
+				attrs = append(attrs, &common.KeyValue{Key: key, Value: value})
+			}
+		}
+
+		if len(attrs) > 0 {
+			printLine(b, detail, "", attrs)
+		}
+	}
+
+	for i, child := range node.children {
+		e.printNode(b, child, prefix+indent, i == len(node.children)-1)
+	}
+}
+
+// printLine writes a line with the label followed by the attributes
+func printLine(b *strings.Builder, prefix, label string, attrs []*common.KeyValue) {
+	var fields []string
+
+	if label != "" {
+		fields = append(fields, label)
+	}
+
+	for _, kv := range attrs {
+		fields = append(fields, kv.Key+"="+valueText(kv.Value))
+	}
+
+	b.WriteString(prefix + strings.Join(fields, " ") + "\n")
+}
+
+func statusText(status *trace.Status) string {
+	switch status.GetCode() {
+	case trace.Status_STATUS_CODE_OK:
+		return " OK"
+	case trace.Status_STATUS_CODE_ERROR:
+		if status.GetMessage() != "" {
+			return " ERROR: " + status.GetMessage()
+		}
+		return " ERROR"
+	default:
+		return ""
+	}
+}
+
+func valueText(value *common.AnyValue) string {
+	switch v := value.GetValue().(type) {
+	case *common.AnyValue_StringValue:
+		return strconv.Quote(v.StringValue)
+	case *common.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *common.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *common.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'f', -1, 64)
+	case *common.AnyValue_BytesValue:
+		return hex.EncodeToString(v.BytesValue)
+	case *common.AnyValue_ArrayValue:
+		var values []string
+		for _, item := range v.ArrayValue.GetValues() {
+			values = append(values, valueText(item))
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	case *common.AnyValue_KvlistValue:
+		var values []string
+		for _, kv := range v.KvlistValue.GetValues() {
+			values = append(values, kv.Key+"="+valueText(kv.Value))
+		}
+		return "{" + strings.Join(values, ", ") + "}"
+	default:
+		return ""
+	}
+}
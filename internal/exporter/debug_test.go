@@ -0,0 +1,132 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+)
+
+func newDebugTrace() []*trace.ResourceSpans {
+	traceID := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+
+	frontend := &trace.ResourceSpans{
+		Resource: &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "frontend")}},
+		ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{
+			{
+				TraceId: traceID, SpanId: []byte{1}, Name: "GET /cart", Kind: trace.Span_SPAN_KIND_SERVER,
+				StartTimeUnixNano: 0, EndTimeUnixNano: 120e6,
+				Attributes: []*common.KeyValue{stringAttr("http.route", "/cart"), stringAttr("internal.id", "42")},
+			},
+			{
+				TraceId: traceID, SpanId: []byte{3}, ParentSpanId: []byte{1}, Name: "render", Kind: trace.Span_SPAN_KIND_INTERNAL,
+				StartTimeUnixNano: 100e6, EndTimeUnixNano: 110e6,
+			},
+		}}},
+	}
+
+	checkout := &trace.ResourceSpans{
+		Resource: &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "checkout")}},
+		ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{{
+			TraceId: traceID, SpanId: []byte{2}, ParentSpanId: []byte{1}, Name: "charge", Kind: trace.Span_SPAN_KIND_SERVER,
+			StartTimeUnixNano: 10e6, EndTimeUnixNano: 90e6,
+			Status: &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: "declined"},
+			Events: []*trace.Span_Event{{Name: "exception", Attributes: []*common.KeyValue{stringAttr("exception.message", "boom")}}},
+		}}}},
+	}
+
+	return []*trace.ResourceSpans{frontend, checkout}
+}
+
+func exportDebug(t *testing.T, cfg config.ConfigExporter, rss []*trace.ResourceSpans) string {
+	var out bytes.Buffer
+
+	exp, err := NewDebugExporter(cfg)
+	assert.NoError(t, err)
+
+	exp.out = &out
+
+	assert.NoError(t, exp.Export(context.Background(), rss))
+	assert.NoError(t, exp.Shutdown(context.Background()))
+
+	return out.String()
+}
+
+func Test_DebugExporter_Export(t *testing.T) {
+	t.Run("should print a line per trace with summary verbosity", func(t *testing.T) {
+		out := exportDebug(t, config.ConfigExporter{Verbosity: "summary"}, newDebugTrace())
+
+		assert.Equal(t, "trace 00000000000000000000000000000001 frontend \"GET /cart\" 3 spans 120ms\n", out)
+	})
+
+	t.Run("should print span trees with key attributes", func(t *testing.T) {
+		out := exportDebug(t, config.ConfigExporter{}, newDebugTrace())
+
+		expected := strings.Join([]string{
+			"trace 00000000000000000000000000000001 (3 spans)",
+			"└─ frontend GET /cart [server] 120ms",
+			"   │ http.route=\"/cart\"",
+			"   ├─ checkout charge [server] 80ms ERROR: declined",
+			"   └─ frontend render [internal] 10ms",
+			"",
+		}, "\n")
+
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("should print every detail with detailed verbosity", func(t *testing.T) {
+		out := exportDebug(t, config.ConfigExporter{Verbosity: "detailed"}, newDebugTrace())
+
+		assert.Contains(t, out, "   │ resource: service.name=\"frontend\"\n")
+		assert.Contains(t, out, "   │ attributes: http.route=\"/cart\" internal.id=\"42\"\n")
+		assert.Contains(t, out, "   │    span_id=02 parent_span_id=01\n")
+		assert.Contains(t, out, "   │    event exception exception.message=\"boom\"\n")
+	})
+
+	t.Run("should print spans in parent cycles as roots", func(t *testing.T) {
+		rss := []*trace.ResourceSpans{{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{
+			{TraceId: []byte{1}, SpanId: []byte{1}, ParentSpanId: []byte{2}, Name: "a"},
+			{TraceId: []byte{1}, SpanId: []byte{2}, ParentSpanId: []byte{1}, Name: "b"},
+		}}}}}
+
+		out := exportDebug(t, config.ConfigExporter{Verbosity: "summary"}, rss)
+
+		assert.Contains(t, out, "2 spans")
+	})
+
+	t.Run("should sample whole traces", func(t *testing.T) {
+		sampled := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+		dropped := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+		rss := []*trace.ResourceSpans{{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{
+			{TraceId: sampled, SpanId: []byte{1}, Name: "sampled"},
+			{TraceId: dropped, SpanId: []byte{1}, Name: "dropped"},
+		}}}}}
+
+		out := exportDebug(t, config.ConfigExporter{Verbosity: "summary", SamplingRatio: 0.5}, rss)
+
+		assert.Contains(t, out, "sampled")
+		assert.NotContains(t, out, "dropped")
+	})
+}
+
+func Test_NewDebugExporter(t *testing.T) {
+	t.Run("should return error for unknown verbosity", func(t *testing.T) {
+		_, err := NewDebugExporter(config.ConfigExporter{Verbosity: "verbose"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for sampling ratio out of range", func(t *testing.T) {
+		_, err := NewDebugExporter(config.ConfigExporter{SamplingRatio: 1.5})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,73 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+)
+
+var (
+	// ErrUnknownType is returned when the configured exporter
+	// type isn't supported
+	ErrUnknownType = errors.New("unknown exporter type")
+)
+
+// ResourceErrors is returned by the exporters sending each resource on its
+// own when some of them failed, holding the error of each resource by index,
+// nil for the ones exported
+type ResourceErrors []error
+
+func (e ResourceErrors) Error() string {
+	return errors.Join(e...).Error()
+}
+
+func (e ResourceErrors) Unwrap() []error {
+	var errs []error
+
+	for _, err := range e {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// Exporter sends processed trace data to its destination
+type Exporter interface {
+	// Export sends the given resources to the destination
+	Export(ctx context.Context, rss []*trace.ResourceSpans) error
+
+	// Shutdown releases the resources held by the exporter
+	Shutdown(ctx context.Context) error
+}
+
+// New creates the exporter described by the given configuration
+func New(cfg config.ConfigExporter) (Exporter, error) {
+	switch cfg.Type {
+	case "otlp":
+		return NewOTLPExporter(cfg)
+
+	case "jaeger":
+		return NewJaegerExporter(cfg)
+
+	case "zipkin":
+		return NewZipkinExporter(cfg)
+
+	case "file":
+		return NewFileExporter(cfg)
+
+	case "debug":
+		return NewDebugExporter(cfg)
+
+	case "storage":
+		return NewStorageExporter(cfg)
+
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownType, cfg.Type)
+	}
+}
@@ -0,0 +1,55 @@
+package exporter
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tracedock/tracedock/internal/config"
+)
+
+func Test_New(t *testing.T) {
+	t.Run("should create otlp exporter", func(t *testing.T) {
+		exp, err := New(config.ConfigExporter{Type: "otlp", Endpoint: "localhost:4317"})
+
+		assert.NoError(t, err)
+		assert.IsType(t, &OTLPExporter{}, exp)
+	})
+
+	t.Run("should create jaeger exporter", func(t *testing.T) {
+		exp, err := New(config.ConfigExporter{Type: "jaeger", Endpoint: "localhost:14250"})
+
+		assert.NoError(t, err)
+		assert.IsType(t, &JaegerExporter{}, exp)
+	})
+
+	t.Run("should create zipkin exporter", func(t *testing.T) {
+		exp, err := New(config.ConfigExporter{Type: "zipkin", Endpoint: "http://localhost:9411"})
+
+		assert.NoError(t, err)
+		assert.IsType(t, &ZipkinExporter{}, exp)
+	})
+
+	t.Run("should create file exporter", func(t *testing.T) {
+		exp, err := New(config.ConfigExporter{Type: "file", Path: filepath.Join(t.TempDir(), "traces.ndjson")})
+
+		assert.NoError(t, err)
+		assert.IsType(t, &FileExporter{}, exp)
+		assert.NoError(t, exp.Shutdown(context.Background()))
+	})
+
+	t.Run("should create debug exporter", func(t *testing.T) {
+		exp, err := New(config.ConfigExporter{Type: "debug"})
+
+		assert.NoError(t, err)
+		assert.IsType(t, &DebugExporter{}, exp)
+	})
+
+	t.Run("should return error for unknown type", func(t *testing.T) {
+		_, err := New(config.ConfigExporter{Type: "unknown"})
+
+		assert.ErrorIs(t, err, ErrUnknownType)
+	})
+}
@@ -0,0 +1,46 @@
+package exporter
+
+import (
+	"context"
+
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/otlpfile"
+)
+
+// megabyte is the unit of the rotation max size
+const megabyte = 1 << 20
+
+// FileExporter writes trace data to a file so it can be inspected or
+// replayed later, see the otlpfile package for the format
+type FileExporter struct {
+	writer *otlpfile.Writer
+}
+
+// NewFileExporter creates a new file exporter
+func NewFileExporter(cfg config.ConfigExporter) (*FileExporter, error) {
+	writer, err := otlpfile.NewWriter(otlpfile.Options{
+		Path:        cfg.Path,
+		Encoding:    cfg.Encoding,
+		Compression: cfg.Compression,
+		MaxSize:     int64(cfg.Rotation.MaxSize) * megabyte,
+		Interval:    cfg.Rotation.Interval,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileExporter{writer: writer}, nil
+}
+
+// Export writes the given resources as a single record
+func (e *FileExporter) Export(ctx context.Context, rss []*trace.ResourceSpans) error {
+	return e.writer.Write(&tracecollectorv1.ExportTraceServiceRequest{ResourceSpans: rss})
+}
+
+// Shutdown flushes and closes the file
+func (e *FileExporter) Shutdown(ctx context.Context) error {
+	return e.writer.Close()
+}
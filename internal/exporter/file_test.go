@@ -0,0 +1,41 @@
+package exporter
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/otlpfile"
+)
+
+func Test_FileExporter_Export(t *testing.T) {
+	t.Run("should write resources readable by otlpfile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "traces.pb.zst")
+
+		exp, err := NewFileExporter(config.ConfigExporter{Path: path, Encoding: "protobuf", Compression: "zstd"})
+		assert.NoError(t, err)
+
+		assert.NoError(t, exp.Export(context.Background(), resourceSpans))
+		assert.NoError(t, exp.Shutdown(context.Background()))
+
+		r, err := otlpfile.Open(path)
+		assert.NoError(t, err)
+		defer r.Close()
+
+		req, err := r.Next()
+
+		assert.NoError(t, err)
+		assert.Equal(t, otlpfile.EncodingProtobuf, r.Encoding())
+		assert.True(t, proto.Equal(resourceSpans[0], req.ResourceSpans[0]))
+	})
+
+	t.Run("should return error without path", func(t *testing.T) {
+		_, err := NewFileExporter(config.ConfigExporter{})
+
+		assert.ErrorIs(t, err, otlpfile.ErrInvalidFile)
+	})
+}
@@ -0,0 +1,109 @@
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/jaegertracing/jaeger-idl/proto-gen/api_v2"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/tracedock/tracedock/internal/compression"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/gogocodec"
+	"github.com/tracedock/tracedock/internal/translator"
+)
+
+// JaegerExporter sends trace data to a Jaeger collector through its gRPC
+// PostSpans API, one batch for each resource, so some resources may be
+// exported while others fail
+type JaegerExporter struct {
+	timeout time.Duration
+	headers map[string]string
+
+	conn   *grpc.ClientConn
+	client api_v2.CollectorServiceClient
+}
+
+// NewJaegerExporter creates a new Jaeger exporter
+func NewJaegerExporter(cfg config.ConfigExporter) (*JaegerExporter, error) {
+	e := &JaegerExporter{
+		timeout: cfg.Timeout,
+		headers: cfg.Headers,
+	}
+
+	if cfg.Protocol != "" && cfg.Protocol != "grpc" {
+		return nil, fmt.Errorf("unsupported protocol %q for jaeger exporter", cfg.Protocol)
+	}
+
+	if e.timeout <= 0 {
+		e.timeout = DefaultTimeout
+	}
+
+	if err := compression.Validate(cfg.Compression); err != nil {
+		return nil, fmt.Errorf("%w for jaeger exporter", err)
+	}
+
+	creds := credentials.NewTLS(&tls.Config{})
+	if cfg.Insecure {
+		creds = insecure.NewCredentials()
+	}
+
+	conn, err := grpc.NewClient(cfg.Endpoint,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(gogocodec.Codec{})),
+		compressorOption(cfg.Compression),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	e.conn = conn
+	e.client = api_v2.NewCollectorServiceClient(conn)
+
+	return e, nil
+}
+
+// Export sends each of the given resources as a Jaeger batch, returning
+// ResourceErrors when some of them failed
+func (e *JaegerExporter) Export(ctx context.Context, rss []*trace.ResourceSpans) error {
+	var errs ResourceErrors
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	if len(e.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(e.headers))
+	}
+
+	for n, rs := range rss {
+		batch := translator.OTLPToJaeger(rs)
+		if len(batch.Spans) == 0 {
+			continue
+		}
+
+		if _, err := e.client.PostSpans(ctx, &api_v2.PostSpansRequest{Batch: *batch}); err != nil {
+			if errs == nil {
+				errs = make(ResourceErrors, len(rss))
+			}
+
+			errs[n] = err
+		}
+	}
+
+	if errs == nil {
+		return nil
+	}
+
+	return errs
+}
+
+// Shutdown closes the connection with the Jaeger collector
+func (e *JaegerExporter) Shutdown(ctx context.Context) error {
+	return e.conn.Close()
+}
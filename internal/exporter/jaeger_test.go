@@ -0,0 +1,180 @@
+package exporter
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jaegertracing/jaeger-idl/proto-gen/api_v2"
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/gogocodec"
+	"github.com/tracedock/tracedock/internal/translator"
+)
+
+type fakeJaegerCollector struct {
+	requests []*api_v2.PostSpansRequest
+	headers  metadata.MD
+
+	// failing is the service the batches of which are refused
+	failing string
+}
+
+func (c *fakeJaegerCollector) PostSpans(ctx context.Context, req *api_v2.PostSpansRequest) (*api_v2.PostSpansResponse, error) {
+	if c.failing != "" && req.Batch.Process.ServiceName == c.failing {
+		return nil, status.Error(codes.Unavailable, "collector busy")
+	}
+
+	c.requests = append(c.requests, req)
+	c.headers, _ = metadata.FromIncomingContext(ctx)
+
+	return &api_v2.PostSpansResponse{}, nil
+}
+
+func startFakeJaegerCollector(t *testing.T) (*fakeJaegerCollector, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	collector := &fakeJaegerCollector{}
+	server := grpc.NewServer(grpc.ForceServerCodec(gogocodec.Codec{}))
+	api_v2.RegisterCollectorServiceServer(server, collector)
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return collector, listener.Addr().String()
+}
+
+func stringAttr(key, value string) *common.KeyValue {
+	return &common.KeyValue{Key: key, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: value}}}
+}
+
+func Test_NewJaegerExporter(t *testing.T) {
+	t.Run("should return error for unsupported protocol", func(t *testing.T) {
+		_, err := NewJaegerExporter(config.ConfigExporter{Endpoint: "localhost:14250", Protocol: "http"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for unsupported compression", func(t *testing.T) {
+		_, err := NewJaegerExporter(config.ConfigExporter{Endpoint: "localhost:14250", Compression: "brotli"})
+
+		assert.ErrorContains(t, err, "compression")
+	})
+}
+
+func Test_JaegerExporter_Export(t *testing.T) {
+	t.Run("should round trip spans through a Jaeger collector", func(t *testing.T) {
+		collector, addr := startFakeJaegerCollector(t)
+
+		exp, err := NewJaegerExporter(config.ConfigExporter{
+			Endpoint: addr,
+			Insecure: true,
+			Headers:  map[string]string{"x-api-key": "secret"},
+		})
+		assert.NoError(t, err)
+		t.Cleanup(func() { exp.Shutdown(context.Background()) })
+
+		original := &trace.ResourceSpans{
+			Resource: &resource.Resource{Attributes: []*common.KeyValue{
+				stringAttr("service.name", "checkout"),
+				stringAttr("host.name", "node-1"),
+			}},
+			ScopeSpans: []*trace.ScopeSpans{{
+				Scope: &common.InstrumentationScope{Name: "net/http", Version: "1.0.0"},
+				Spans: []*trace.Span{{
+					TraceId:           []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+					SpanId:            []byte{1, 2, 3, 4, 5, 6, 7, 8},
+					ParentSpanId:      []byte{8, 7, 6, 5, 4, 3, 2, 1},
+					TraceState:        "vendor=value",
+					Name:              "GET /cart",
+					Kind:              trace.Span_SPAN_KIND_SERVER,
+					StartTimeUnixNano: 1700000000000000000,
+					EndTimeUnixNano:   1700000001000000000,
+					Attributes: []*common.KeyValue{
+						stringAttr("http.method", "GET"),
+						{Key: "http.status_code", Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 500}}},
+						{Key: "retried", Value: &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: true}}},
+					},
+					Events: []*trace.Span_Event{{
+						TimeUnixNano: 1700000000500000000,
+						Name:         "exception",
+						Attributes:   []*common.KeyValue{stringAttr("exception.message", "boom")},
+					}},
+					Links: []*trace.Span_Link{{
+						TraceId: []byte{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+						SpanId:  []byte{2, 2, 2, 2, 2, 2, 2, 2},
+					}},
+					Status: &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: "internal error"},
+				}},
+			}},
+		}
+
+		assert.NoError(t, exp.Export(context.Background(), []*trace.ResourceSpans{original}))
+
+		assert.Len(t, collector.requests, 1)
+		assert.Equal(t, []string{"secret"}, collector.headers.Get("x-api-key"))
+
+		received := translator.JaegerToOTLP(&collector.requests[0].Batch)
+
+		assert.Len(t, received, 1)
+		assert.True(t, proto.Equal(original, received[0]), "expected %v, got %v", original, received[0])
+	})
+
+	t.Run("should compress the batches", func(t *testing.T) {
+		collector, addr := startFakeJaegerCollector(t)
+
+		exp, err := NewJaegerExporter(config.ConfigExporter{Endpoint: addr, Insecure: true, Compression: "snappy"})
+		assert.NoError(t, err)
+		t.Cleanup(func() { exp.Shutdown(context.Background()) })
+
+		span := &trace.Span{TraceId: make([]byte, 16), SpanId: []byte{1, 1, 1, 1, 1, 1, 1, 1}, Name: "GET /cart"}
+		rss := []*trace.ResourceSpans{{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{span}}}}}
+
+		assert.NoError(t, exp.Export(context.Background(), rss))
+		assert.Len(t, collector.requests, 1)
+		assert.Equal(t, "GET /cart", collector.requests[0].Batch.Spans[0].OperationName)
+	})
+
+	t.Run("should tell which resources failed", func(t *testing.T) {
+		collector, addr := startFakeJaegerCollector(t)
+		collector.failing = "payment"
+
+		exp, err := NewJaegerExporter(config.ConfigExporter{Endpoint: addr, Insecure: true})
+		assert.NoError(t, err)
+		t.Cleanup(func() { exp.Shutdown(context.Background()) })
+
+		var newResource = func(service string) *trace.ResourceSpans {
+			return &trace.ResourceSpans{
+				Resource:   &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", service)}},
+				ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{{TraceId: make([]byte, 16), SpanId: make([]byte, 8)}}}},
+			}
+		}
+
+		err = exp.Export(context.Background(), []*trace.ResourceSpans{newResource("checkout"), newResource("payment")})
+
+		var resourceErrs ResourceErrors
+		assert.ErrorAs(t, err, &resourceErrs)
+		assert.Len(t, resourceErrs, 2)
+		assert.NoError(t, resourceErrs[0])
+		assert.Equal(t, codes.Unavailable, status.Code(resourceErrs[1]))
+		assert.Len(t, collector.requests, 1)
+	})
+
+	t.Run("should return error when the collector is unreachable", func(t *testing.T) {
+		exp, err := NewJaegerExporter(config.ConfigExporter{Endpoint: "127.0.0.1:1", Insecure: true, Timeout: 100 * time.Millisecond})
+		assert.NoError(t, err)
+
+		assert.Error(t, exp.Export(context.Background(), resourceSpans))
+	})
+}
@@ -0,0 +1,207 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/compression"
+	"github.com/tracedock/tracedock/internal/config"
+)
+
+const (
+	// DefaultTimeout is used when the exporter has no timeout configured
+	DefaultTimeout = 10 * time.Second
+
+	tracesPath = "/v1/traces"
+)
+
+// OTLPExporter sends trace data to an OTLP endpoint using gRPC or HTTP,
+// the latter with protobuf or JSON encoding, optionally compressed
+type OTLPExporter struct {
+	protocol    string
+	encoding    string
+	compression string
+	timeout     time.Duration
+	headers     map[string]string
+
+	conn   *grpc.ClientConn
+	client tracecollectorv1.TraceServiceClient
+
+	url        string
+	httpClient *http.Client
+}
+
+// NewOTLPExporter creates a new OTLP exporter
+func NewOTLPExporter(cfg config.ConfigExporter) (*OTLPExporter, error) {
+	e := &OTLPExporter{
+		protocol:    cfg.Protocol,
+		encoding:    cfg.Encoding,
+		compression: cfg.Compression,
+		timeout:     cfg.Timeout,
+		headers:     cfg.Headers,
+	}
+
+	if e.protocol == "" {
+		e.protocol = "grpc"
+	}
+
+	if e.encoding == "" {
+		e.encoding = "protobuf"
+	}
+
+	if e.timeout <= 0 {
+		e.timeout = DefaultTimeout
+	}
+
+	if err := compression.Validate(e.compression); err != nil {
+		return nil, fmt.Errorf("%w for otlp exporter", err)
+	}
+
+	switch e.protocol {
+	case "grpc":
+		creds := credentials.NewTLS(&tls.Config{})
+		if cfg.Insecure {
+			creds = insecure.NewCredentials()
+		}
+
+		conn, err := grpc.NewClient(cfg.Endpoint, grpc.WithTransportCredentials(creds), compressorOption(e.compression))
+		if err != nil {
+			return nil, err
+		}
+
+		e.conn = conn
+		e.client = tracecollectorv1.NewTraceServiceClient(conn)
+
+	case "http":
+		if e.encoding != "protobuf" && e.encoding != "json" {
+			return nil, fmt.Errorf("unsupported encoding %q for otlp exporter", e.encoding)
+		}
+
+		endpoint, err := url.Parse(cfg.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		if endpoint.Path == "" || endpoint.Path == "/" {
+			endpoint.Path = tracesPath
+		}
+
+		e.url = endpoint.String()
+		e.httpClient = &http.Client{}
+
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q for otlp exporter", e.protocol)
+	}
+
+	return e, nil
+}
+
+// Export sends the given resources in a single request
+func (e *OTLPExporter) Export(ctx context.Context, rss []*trace.ResourceSpans) error {
+	var req = &tracecollectorv1.ExportTraceServiceRequest{ResourceSpans: rss}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	if e.protocol == "grpc" {
+		return e.exportWithGRPC(ctx, req)
+	}
+
+	return e.exportWithHTTP(ctx, req)
+}
+
+func (e *OTLPExporter) exportWithGRPC(ctx context.Context, req *tracecollectorv1.ExportTraceServiceRequest) error {
+	if len(e.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(e.headers))
+	}
+
+	_, err := e.client.Export(ctx, req)
+	return err
+}
+
+func (e *OTLPExporter) exportWithHTTP(ctx context.Context, req *tracecollectorv1.ExportTraceServiceRequest) error {
+	var body []byte
+	var err error
+	var contentType string
+
+	if e.encoding == "json" {
+		contentType = "application/json"
+		body, err = protojson.Marshal(req)
+	} else {
+		contentType = "application/x-protobuf"
+		body, err = proto.Marshal(req)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if body, err = compression.Encode(e.compression, body); err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	httpReq.Header.Set("Content-Type", contentType)
+	if e.compression != compression.None {
+		httpReq.Header.Set("Content-Encoding", e.compression)
+	}
+	for key, value := range e.headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	resp, err := e.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp endpoint responded with %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// Shutdown closes the connection with the gRPC endpoint
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	if e.conn != nil {
+		return e.conn.Close()
+	}
+
+	if e.httpClient != nil {
+		e.httpClient.CloseIdleConnections()
+	}
+
+	return nil
+}
+
+// compressorOption makes the gRPC calls use the given compression, the
+// compressors are registered by the compression package
+func compressorOption(name string) grpc.DialOption {
+	if name == compression.None {
+		return grpc.EmptyDialOption{}
+	}
+
+	return grpc.WithDefaultCallOptions(grpc.UseCompressor(name))
+}
@@ -0,0 +1,201 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/compression"
+	"github.com/tracedock/tracedock/internal/config"
+)
+
+type fakeTraceService struct {
+	tracecollectorv1.UnimplementedTraceServiceServer
+
+	requests []*tracecollectorv1.ExportTraceServiceRequest
+	headers  metadata.MD
+}
+
+func (s *fakeTraceService) Export(ctx context.Context, req *tracecollectorv1.ExportTraceServiceRequest) (*tracecollectorv1.ExportTraceServiceResponse, error) {
+	s.requests = append(s.requests, req)
+	s.headers, _ = metadata.FromIncomingContext(ctx)
+
+	return &tracecollectorv1.ExportTraceServiceResponse{}, nil
+}
+
+func startFakeGRPCServer(t *testing.T) (*fakeTraceService, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	service := &fakeTraceService{}
+	server := grpc.NewServer()
+	tracecollectorv1.RegisterTraceServiceServer(server, service)
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return service, listener.Addr().String()
+}
+
+var resourceSpans = []*trace.ResourceSpans{
+	{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{{Name: "GET /"}}}}},
+}
+
+func Test_NewOTLPExporter(t *testing.T) {
+	t.Run("should apply defaults", func(t *testing.T) {
+		exp, err := NewOTLPExporter(config.ConfigExporter{Endpoint: "localhost:4317"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "grpc", exp.protocol)
+		assert.Equal(t, "protobuf", exp.encoding)
+		assert.Equal(t, DefaultTimeout, exp.timeout)
+	})
+
+	t.Run("should append traces path to http endpoint", func(t *testing.T) {
+		exp, err := NewOTLPExporter(config.ConfigExporter{Endpoint: "http://localhost:4318", Protocol: "http"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "http://localhost:4318/v1/traces", exp.url)
+	})
+
+	t.Run("should return error for unsupported protocol", func(t *testing.T) {
+		_, err := NewOTLPExporter(config.ConfigExporter{Endpoint: "localhost:4317", Protocol: "udp"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for unsupported encoding", func(t *testing.T) {
+		_, err := NewOTLPExporter(config.ConfigExporter{Endpoint: "http://localhost:4318", Protocol: "http", Encoding: "xml"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for unsupported compression", func(t *testing.T) {
+		_, err := NewOTLPExporter(config.ConfigExporter{Endpoint: "localhost:4317", Compression: "brotli"})
+
+		assert.ErrorContains(t, err, "compression")
+	})
+}
+
+func Test_OTLPExporter_Export(t *testing.T) {
+	t.Run("should export through gRPC", func(t *testing.T) {
+		service, addr := startFakeGRPCServer(t)
+
+		exp, err := NewOTLPExporter(config.ConfigExporter{
+			Endpoint: addr,
+			Insecure: true,
+			Headers:  map[string]string{"x-api-key": "secret"},
+		})
+		assert.NoError(t, err)
+
+		t.Cleanup(func() { exp.Shutdown(context.Background()) })
+
+		assert.NoError(t, exp.Export(context.Background(), resourceSpans))
+		assert.Len(t, service.requests, 1)
+		assert.True(t, proto.Equal(resourceSpans[0], service.requests[0].ResourceSpans[0]))
+		assert.Equal(t, []string{"secret"}, service.headers.Get("x-api-key"))
+	})
+
+	for _, encoding := range []string{"protobuf", "json"} {
+		t.Run("should export through HTTP with "+encoding, func(t *testing.T) {
+			var received tracecollectorv1.ExportTraceServiceRequest
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+
+				assert.Equal(t, "/v1/traces", r.URL.Path)
+				assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+
+				if r.Header.Get("Content-Type") == "application/json" {
+					assert.NoError(t, protojson.Unmarshal(body, &received))
+				} else {
+					assert.NoError(t, proto.Unmarshal(body, &received))
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			exp, err := NewOTLPExporter(config.ConfigExporter{
+				Endpoint: server.URL,
+				Protocol: "http",
+				Encoding: encoding,
+				Headers:  map[string]string{"x-api-key": "secret"},
+			})
+			assert.NoError(t, err)
+
+			assert.NoError(t, exp.Export(context.Background(), resourceSpans))
+			assert.True(t, proto.Equal(resourceSpans[0], received.ResourceSpans[0]))
+			assert.NoError(t, exp.Shutdown(context.Background()))
+		})
+	}
+
+	for _, name := range compression.Names() {
+		t.Run("should export through gRPC with "+name, func(t *testing.T) {
+			service, addr := startFakeGRPCServer(t)
+
+			exp, err := NewOTLPExporter(config.ConfigExporter{Endpoint: addr, Insecure: true, Compression: name})
+			assert.NoError(t, err)
+
+			t.Cleanup(func() { exp.Shutdown(context.Background()) })
+
+			assert.NoError(t, exp.Export(context.Background(), resourceSpans))
+			assert.Len(t, service.requests, 1)
+			assert.True(t, proto.Equal(resourceSpans[0], service.requests[0].ResourceSpans[0]))
+		})
+
+		t.Run("should export through HTTP with "+name, func(t *testing.T) {
+			var received tracecollectorv1.ExportTraceServiceRequest
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body bytes.Buffer
+
+				assert.Equal(t, name, r.Header.Get("Content-Encoding"))
+				assert.NoError(t, compression.Decode(name, &body, r.Body, 0))
+				assert.NoError(t, proto.Unmarshal(body.Bytes(), &received))
+			}))
+			t.Cleanup(server.Close)
+
+			exp, err := NewOTLPExporter(config.ConfigExporter{Endpoint: server.URL, Protocol: "http", Compression: name})
+			assert.NoError(t, err)
+
+			assert.NoError(t, exp.Export(context.Background(), resourceSpans))
+			assert.True(t, proto.Equal(resourceSpans[0], received.ResourceSpans[0]))
+		})
+	}
+
+	t.Run("should return error when HTTP endpoint fails", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(server.Close)
+
+		exp, err := NewOTLPExporter(config.ConfigExporter{Endpoint: server.URL, Protocol: "http"})
+		assert.NoError(t, err)
+
+		assert.ErrorContains(t, exp.Export(context.Background(), resourceSpans), "503")
+	})
+
+	t.Run("should return error when endpoint times out", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		}))
+		t.Cleanup(server.Close)
+
+		exp, err := NewOTLPExporter(config.ConfigExporter{Endpoint: server.URL, Protocol: "http", Timeout: 10 * time.Millisecond})
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, exp.Export(context.Background(), resourceSpans), context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,46 @@
+package exporter
+
+import (
+	"context"
+
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/storage"
+)
+
+// StorageExporter keeps the recent traces in an embedded store, to be
+// searched through the Jaeger query API
+type StorageExporter struct {
+	store *storage.Store
+}
+
+// NewStorageExporter creates a new storage exporter writing to the directory
+// of the configured path
+func NewStorageExporter(cfg config.ConfigExporter) (*StorageExporter, error) {
+	store, err := storage.Open(storage.Options{
+		Dir:       cfg.Path,
+		Retention: cfg.Retention,
+		MaxSize:   int64(cfg.MaxSize) * megabyte,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &StorageExporter{store: store}, nil
+}
+
+// Store returns the store the traces are written to
+func (e *StorageExporter) Store() *storage.Store {
+	return e.store
+}
+
+// Export writes the spans of the given resources to the store
+func (e *StorageExporter) Export(ctx context.Context, rss []*trace.ResourceSpans) error {
+	return e.store.Write(rss)
+}
+
+// Shutdown closes the store, the traces are kept for the next start
+func (e *StorageExporter) Shutdown(ctx context.Context) error {
+	return e.store.Close()
+}
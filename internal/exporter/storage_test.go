@@ -0,0 +1,39 @@
+package exporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/storage"
+)
+
+func Test_StorageExporter_Export(t *testing.T) {
+	t.Run("should keep the traces in the store once closed", func(t *testing.T) {
+		dir := t.TempDir()
+
+		exp, err := NewStorageExporter(config.ConfigExporter{Path: dir, MaxSize: 10})
+		assert.NoError(t, err)
+
+		assert.NoError(t, exp.Export(context.Background(), resourceSpans))
+		assert.NoError(t, exp.Shutdown(context.Background()))
+
+		store, err := storage.Open(storage.Options{Dir: dir})
+		assert.NoError(t, err)
+		defer store.Close()
+
+		rss, err := store.Trace(storage.TraceID{})
+
+		assert.NoError(t, err)
+		assert.True(t, proto.Equal(resourceSpans[0], rss[0]))
+	})
+
+	t.Run("should return error without path", func(t *testing.T) {
+		_, err := NewStorageExporter(config.ConfigExporter{})
+
+		assert.ErrorIs(t, err, storage.ErrInvalidOptions)
+	})
+}
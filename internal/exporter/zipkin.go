@@ -0,0 +1,146 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/compression"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/translator"
+)
+
+const zipkinSpansPath = "/api/v2/spans"
+
+// ZipkinExporter sends trace data to a Zipkin v2 HTTP endpoint, encoded as
+// JSON or protobuf, optionally compressed
+type ZipkinExporter struct {
+	url         string
+	encoding    string
+	compression string
+	timeout     time.Duration
+	headers     map[string]string
+
+	httpClient *http.Client
+}
+
+// NewZipkinExporter creates a new Zipkin exporter
+func NewZipkinExporter(cfg config.ConfigExporter) (*ZipkinExporter, error) {
+	e := &ZipkinExporter{
+		encoding:    cfg.Encoding,
+		compression: cfg.Compression,
+		timeout:     cfg.Timeout,
+		headers:     cfg.Headers,
+	}
+
+	if cfg.Protocol != "" && cfg.Protocol != "http" {
+		return nil, fmt.Errorf("unsupported protocol %q for zipkin exporter", cfg.Protocol)
+	}
+
+	if e.encoding == "" {
+		e.encoding = "json"
+	}
+
+	if e.encoding != "json" && e.encoding != "protobuf" {
+		return nil, fmt.Errorf("unsupported encoding %q for zipkin exporter", e.encoding)
+	}
+
+	if e.timeout <= 0 {
+		e.timeout = DefaultTimeout
+	}
+
+	if err := compression.Validate(e.compression); err != nil {
+		return nil, fmt.Errorf("%w for zipkin exporter", err)
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = zipkinSpansPath
+	}
+
+	e.url = endpoint.String()
+	e.httpClient = &http.Client{}
+
+	return e, nil
+}
+
+// Export sends the spans of all the given resources in a single request
+func (e *ZipkinExporter) Export(ctx context.Context, rss []*trace.ResourceSpans) error {
+	var spans []*zipkinmodel.SpanModel
+	var body []byte
+	var err error
+	var contentType string
+
+	for _, rs := range rss {
+		spans = append(spans, translator.OTLPToZipkin(rs)...)
+	}
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	if e.encoding == "protobuf" {
+		contentType = "application/x-protobuf"
+		body, err = zipkin_proto3.SpanSerializer{}.Serialize(spans)
+	} else {
+		contentType = "application/json"
+		body, err = json.Marshal(spans)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if body, err = compression.Encode(e.compression, body); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	httpReq.Header.Set("Content-Type", contentType)
+	if e.compression != compression.None {
+		httpReq.Header.Set("Content-Encoding", e.compression)
+	}
+	for key, value := range e.headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	resp, err := e.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("zipkin endpoint responded with %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// Shutdown closes the idle connections with the Zipkin endpoint
+func (e *ZipkinExporter) Shutdown(ctx context.Context) error {
+	e.httpClient.CloseIdleConnections()
+	return nil
+}
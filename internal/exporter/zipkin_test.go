@@ -0,0 +1,174 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/server"
+)
+
+// startFakeZipkinServer starts a Zipkin endpoint backed by the Zipkin
+// receiver, so exported spans are translated back into OTLP
+func startFakeZipkinServer(t *testing.T) (*[]*trace.ResourceSpans, *http.Header, string) {
+	var received []*trace.ResourceSpans
+	var headers http.Header
+
+	receiver := server.NewZipkinServer(config.ConfigReceiverHTTP{})
+	receiver.RegisterTraceIngestor(server.TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+		for _, rs := range batch {
+			received = append(received, rs)
+		}
+
+		return nil
+	}))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		receiver.HandleRequest(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	return &received, &headers, srv.URL
+}
+
+func Test_NewZipkinExporter(t *testing.T) {
+	t.Run("should append spans path to endpoint", func(t *testing.T) {
+		exp, err := NewZipkinExporter(config.ConfigExporter{Endpoint: "http://localhost:9411"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "http://localhost:9411/api/v2/spans", exp.url)
+		assert.Equal(t, "json", exp.encoding)
+	})
+
+	t.Run("should return error for unsupported protocol", func(t *testing.T) {
+		_, err := NewZipkinExporter(config.ConfigExporter{Endpoint: "localhost:9411", Protocol: "grpc"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for unsupported encoding", func(t *testing.T) {
+		_, err := NewZipkinExporter(config.ConfigExporter{Endpoint: "http://localhost:9411", Encoding: "thrift"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for unsupported compression", func(t *testing.T) {
+		_, err := NewZipkinExporter(config.ConfigExporter{Endpoint: "http://localhost:9411", Compression: "brotli"})
+
+		assert.ErrorContains(t, err, "compression")
+	})
+}
+
+func Test_ZipkinExporter_Export(t *testing.T) {
+	original := &trace.ResourceSpans{
+		Resource: &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", "frontend")}},
+		ScopeSpans: []*trace.ScopeSpans{{
+			Scope: &common.InstrumentationScope{Name: "net/http", Version: "1.0.0"},
+			Spans: []*trace.Span{
+				{
+					TraceId:           []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+					SpanId:            []byte{1, 2, 3, 4, 5, 6, 7, 8},
+					ParentSpanId:      []byte{8, 7, 6, 5, 4, 3, 2, 1},
+					TraceState:        "vendor=value",
+					Name:              "get /cart", // zipkin lowercases span names
+					Kind:              trace.Span_SPAN_KIND_CLIENT,
+					StartTimeUnixNano: 1700000000000000000,
+					EndTimeUnixNano:   1700000001000000000,
+					Attributes: []*common.KeyValue{
+						stringAttr("http.method", "GET"),
+						stringAttr("peer.service", "checkout"),
+						stringAttr("net.peer.ip", "10.0.0.1"),
+						{Key: "net.peer.port", Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 8080}}},
+					},
+					Events: []*trace.Span_Event{
+						{TimeUnixNano: 1700000000500000000, Name: "retry"},
+						{
+							TimeUnixNano: 1700000000600000000,
+							Name:         "exception",
+							Attributes:   []*common.KeyValue{stringAttr("exception.message", "boom")},
+						},
+					},
+					Status: &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: "timeout"},
+				},
+				{
+					TraceId:           []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+					SpanId:            []byte{2, 2, 2, 2, 2, 2, 2, 2},
+					Name:              "render",
+					Kind:              trace.Span_SPAN_KIND_INTERNAL,
+					StartTimeUnixNano: 1700000000000000000,
+					EndTimeUnixNano:   1700000000100000000,
+					Status:            &trace.Status{Code: trace.Status_STATUS_CODE_OK},
+				},
+			},
+		}},
+	}
+
+	for _, encoding := range []string{"json", "protobuf"} {
+		t.Run("should round trip spans encoded as "+encoding, func(t *testing.T) {
+			received, headers, url := startFakeZipkinServer(t)
+
+			exp, err := NewZipkinExporter(config.ConfigExporter{
+				Endpoint: url,
+				Encoding: encoding,
+				Headers:  map[string]string{"x-api-key": "secret"},
+			})
+			assert.NoError(t, err)
+			t.Cleanup(func() { exp.Shutdown(context.Background()) })
+
+			assert.NoError(t, exp.Export(context.Background(), []*trace.ResourceSpans{original}))
+
+			assert.Equal(t, "secret", headers.Get("x-api-key"))
+			assert.Len(t, *received, 1)
+			assert.True(t, proto.Equal(original, (*received)[0]), "expected %v, got %v", original, (*received)[0])
+		})
+	}
+
+	t.Run("should compress the request body", func(t *testing.T) {
+		received, headers, url := startFakeZipkinServer(t)
+
+		exp, err := NewZipkinExporter(config.ConfigExporter{Endpoint: url, Compression: "zstd"})
+		assert.NoError(t, err)
+
+		assert.NoError(t, exp.Export(context.Background(), []*trace.ResourceSpans{original}))
+
+		assert.Equal(t, "zstd", headers.Get("Content-Encoding"))
+		assert.Len(t, *received, 1)
+		assert.True(t, proto.Equal(original, (*received)[0]))
+	})
+
+	t.Run("should leave links out", func(t *testing.T) {
+		received, _, url := startFakeZipkinServer(t)
+
+		exp, err := NewZipkinExporter(config.ConfigExporter{Endpoint: url})
+		assert.NoError(t, err)
+
+		span := &trace.Span{TraceId: make([]byte, 16), SpanId: []byte{1, 1, 1, 1, 1, 1, 1, 1}, Links: []*trace.Span_Link{{}}}
+		rss := []*trace.ResourceSpans{{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{span}}}}}
+
+		assert.NoError(t, exp.Export(context.Background(), rss))
+		assert.Empty(t, (*received)[0].ScopeSpans[0].Spans[0].Links)
+	})
+
+	t.Run("should return error for non 2xx responses", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(srv.Close)
+
+		exp, err := NewZipkinExporter(config.ConfigExporter{Endpoint: srv.URL})
+		assert.NoError(t, err)
+
+		err = exp.Export(context.Background(), []*trace.ResourceSpans{original})
+		assert.ErrorContains(t, err, "503")
+	})
+}
@@ -0,0 +1,250 @@
+package expr
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+// fields maps every root to the accessors of its fields, attributes are
+// handled apart as they are indexed by key
+var fields = map[string]map[string]node{
+	"resource": {},
+	"scope": {
+		"name":    scopeField(func(s *common.InstrumentationScope) any { return s.Name }),
+		"version": scopeField(func(s *common.InstrumentationScope) any { return s.Version }),
+	},
+	"span": {
+		"name":           spanField(func(s *trace.Span) any { return s.Name }),
+		"kind":           spanField(func(s *trace.Span) any { return otlputil.SpanKindName(s.Kind) }),
+		"status":         spanField(func(s *trace.Span) any { return otlputil.StatusCodeName(s.Status.GetCode()) }),
+		"status_message": spanField(func(s *trace.Span) any { return s.Status.GetMessage() }),
+		"duration":       spanField(func(s *trace.Span) any { return otlputil.SpanDuration(s) }),
+		"trace_id":       spanField(func(s *trace.Span) any { return hex.EncodeToString(s.TraceId) }),
+		"span_id":        spanField(func(s *trace.Span) any { return hex.EncodeToString(s.SpanId) }),
+		"parent_span_id": spanField(func(s *trace.Span) any { return hex.EncodeToString(s.ParentSpanId) }),
+		"trace_state":    spanField(func(s *trace.Span) any { return s.TraceState }),
+	},
+	"event": {
+		"name": eventField(func(e *trace.Span_Event) any { return e.Name }),
+	},
+	"link": {
+		"trace_id":    linkField(func(l *trace.Span_Link) any { return hex.EncodeToString(l.TraceId) }),
+		"span_id":     linkField(func(l *trace.Span_Link) any { return hex.EncodeToString(l.SpanId) }),
+		"trace_state": linkField(func(l *trace.Span_Link) any { return l.TraceState }),
+	},
+}
+
+type function struct {
+	arity int
+	call  func(args []any) any
+}
+
+var functions = map[string]function{
+	"exists": {1, func(args []any) any { return args[0] != nil }},
+	"lower": {1, func(args []any) any {
+		if s, ok := args[0].(string); ok {
+			return strings.ToLower(s)
+		}
+		return nil
+	}},
+	"startsWith": {2, stringFunction(strings.HasPrefix)},
+	"endsWith":   {2, stringFunction(strings.HasSuffix)},
+	"contains":   {2, stringFunction(strings.Contains)},
+}
+
+func stringFunction(fn func(s, substr string) bool) func(args []any) any {
+	return func(args []any) any {
+		s, ok1 := args[0].(string)
+		substr, ok2 := args[1].(string)
+
+		return ok1 && ok2 && fn(s, substr)
+	}
+}
+
+func scopeField(fn func(*common.InstrumentationScope) any) node {
+	return func(env *Env) any {
+		if env.Scope == nil {
+			return nil
+		}
+		return fn(env.Scope)
+	}
+}
+
+func spanField(fn func(*trace.Span) any) node {
+	return func(env *Env) any {
+		if env.Span == nil {
+			return nil
+		}
+		return fn(env.Span)
+	}
+}
+
+func eventField(fn func(*trace.Span_Event) any) node {
+	return func(env *Env) any {
+		if env.Event == nil {
+			return nil
+		}
+		return fn(env.Event)
+	}
+}
+
+func linkField(fn func(*trace.Span_Link) any) node {
+	return func(env *Env) any {
+		if env.Link == nil {
+			return nil
+		}
+		return fn(env.Link)
+	}
+}
+
+func attributeAccessor(root, key string) node {
+	var attributes func(env *Env) []*common.KeyValue
+
+	switch root {
+	case "resource":
+		attributes = func(env *Env) []*common.KeyValue { return env.Resource.GetAttributes() }
+	case "scope":
+		attributes = func(env *Env) []*common.KeyValue { return env.Scope.GetAttributes() }
+	case "span":
+		attributes = func(env *Env) []*common.KeyValue { return env.Span.GetAttributes() }
+	case "event":
+		attributes = func(env *Env) []*common.KeyValue { return env.Event.GetAttributes() }
+	case "link":
+		attributes = func(env *Env) []*common.KeyValue { return env.Link.GetAttributes() }
+	}
+
+	return func(env *Env) any {
+		for _, kv := range attributes(env) {
+			if kv.Key == key {
+				return attributeValue(kv.Value)
+			}
+		}
+
+		return nil
+	}
+}
+
+func attributeValue(value *common.AnyValue) any {
+	switch v := value.GetValue().(type) {
+	case *common.AnyValue_StringValue:
+		return v.StringValue
+	case *common.AnyValue_BoolValue:
+		return v.BoolValue
+	case *common.AnyValue_IntValue:
+		return v.IntValue
+	case *common.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *common.AnyValue_BytesValue:
+		return string(v.BytesValue)
+	case nil:
+		return nil
+	default:
+		return value.String()
+	}
+}
+
+func truthy(value any) bool {
+	b, ok := value.(bool)
+	return ok && b
+}
+
+// compare applies the comparison operator, missing values and values of
+// incompatible types never satisfy any comparison
+func compare(op tokenKind, a, b any) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	if da, ok := a.(time.Duration); ok {
+		db, ok := b.(time.Duration)
+		return ok && ordered(op, da, db)
+	}
+
+	if _, ok := b.(time.Duration); ok {
+		return false
+	}
+
+	if na, nb, ok := numbers(a, b); ok {
+		return ordered(op, na, nb)
+	}
+
+	switch va := a.(type) {
+	case string:
+		vb, ok := b.(string)
+		return ok && ordered(op, va, vb)
+
+	case bool:
+		vb, ok := b.(bool)
+		return ok && ((op == tokenEq && va == vb) || (op == tokenNeq && va != vb))
+	}
+
+	return false
+}
+
+func ordered[T int64 | float64 | string | time.Duration](op tokenKind, a, b T) bool {
+	switch op {
+	case tokenEq:
+		return a == b
+	case tokenNeq:
+		return a != b
+	case tokenLt:
+		return a < b
+	case tokenLte:
+		return a <= b
+	case tokenGt:
+		return a > b
+	case tokenGte:
+		return a >= b
+	}
+
+	return false
+}
+
+// numbers converts both values to float64 when at least one of them is a
+// number and the other one is a number or a numeric string
+func numbers(a, b any) (float64, float64, bool) {
+	na, aIsNumber := toNumber(a)
+	nb, bIsNumber := toNumber(b)
+
+	if !aIsNumber && !bIsNumber {
+		return 0, 0, false
+	}
+
+	if !aIsNumber {
+		na, aIsNumber = parseNumber(a)
+	}
+
+	if !bIsNumber {
+		nb, bIsNumber = parseNumber(b)
+	}
+
+	return na, nb, aIsNumber && bIsNumber
+}
+
+func toNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+
+	return 0, false
+}
+
+func parseNumber(value any) (float64, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
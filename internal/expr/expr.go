@@ -0,0 +1,89 @@
+package expr
+
+import (
+	"fmt"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// Error is a compilation error pointing to where it happened in the source
+type Error struct {
+	// Pos is the 1-based column where the error was found
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+func errorf(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Env holds the data an expression is evaluated against
+//
+// Fields not set in the environment evaluate as missing values, which never
+// satisfy comparisons and make exists return false.
+type Env struct {
+	Resource *resource.Resource
+	Scope    *common.InstrumentationScope
+	Span     *trace.Span
+	Event    *trace.Span_Event
+	Link     *trace.Span_Link
+}
+
+// Program is a compiled expression ready to be evaluated
+type Program struct {
+	source string
+	root   node
+	roots  map[string]bool
+}
+
+// Compile parses the source into a Program
+//
+// The language supports:
+//
+//   - fields: resource, scope, span, event and link fields, e.g. span.name,
+//     span.duration or span.attributes["http.route"]
+//   - literals: strings, numbers, durations (e.g. 5ms) and booleans
+//   - comparisons: ==, !=, <, <=, >, >=
+//   - regular expressions: =~ and !~ with a string literal pattern, which
+//     must match the whole value
+//   - logical operators: and, or, not (or &&, ||, !)
+//   - functions: exists, startsWith, endsWith, contains and lower
+func Compile(source string) (*Program, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, roots: make(map[string]bool)}
+
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Program{source: source, root: root, roots: p.roots}, nil
+}
+
+// String returns the source the program was compiled from
+func (p *Program) String() string {
+	return p.source
+}
+
+// Uses reports whether the program reads any field of the given root,
+// e.g. span or resource
+func (p *Program) Uses(root string) bool {
+	return p.roots[root]
+}
+
+// Eval evaluates the program against the environment, any result other
+// than the boolean true is considered false
+func (p *Program) Eval(env *Env) bool {
+	result, ok := p.root(env).(bool)
+	return ok && result
+}
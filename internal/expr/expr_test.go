@@ -0,0 +1,139 @@
+package expr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func stringKV(key, value string) *common.KeyValue {
+	return &common.KeyValue{Key: key, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: value}}}
+}
+
+func intKV(key string, value int64) *common.KeyValue {
+	return &common.KeyValue{Key: key, Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: value}}}
+}
+
+var env = &Env{
+	Resource: &resource.Resource{Attributes: []*common.KeyValue{stringKV("service.name", "checkout")}},
+	Scope:    &common.InstrumentationScope{Name: "net/http", Version: "1.0.0"},
+	Span: &trace.Span{
+		Name:              "GET /health",
+		Kind:              trace.Span_SPAN_KIND_SERVER,
+		TraceId:           []byte{0xab, 0xcd},
+		StartTimeUnixNano: 0,
+		EndTimeUnixNano:   uint64(3 * time.Millisecond),
+		Status:            &trace.Status{Code: trace.Status_STATUS_CODE_ERROR},
+		Attributes: []*common.KeyValue{
+			intKV("http.status_code", 503),
+			stringKV("http.route", "/health"),
+			stringKV("retries", "2"),
+		},
+	},
+	Event: &trace.Span_Event{Name: "exception", Attributes: []*common.KeyValue{stringKV("exception.type", "Timeout")}},
+}
+
+func Test_Program_Eval(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected bool
+	}{
+		{`span.name == "GET /health"`, true},
+		{`span.name != 'GET /health'`, false},
+		{`span.kind == "server" and span.status == "error"`, true},
+		{`span.duration < 5ms`, true},
+		{`span.duration >= 1s`, false},
+		{`span.duration == 3ms`, true},
+		{`span.attributes["http.status_code"] >= 500`, true},
+		{`span.attributes["http.status_code"] < 503.5 && span.attributes["http.status_code"] > 500`, true},
+		{`span.attributes["retries"] > 1`, true},
+		{`span.attributes["http.route"] =~ "^/health"`, true},
+		{`span.attributes["http.route"] !~ "^/health"`, false},
+		{`span.name =~ "GET"`, false},
+		{`span.name =~ "GET .*"`, true},
+		{`startsWith(span.name, "GET ")`, true},
+		{`endsWith(span.name, "/ready")`, false},
+		{`contains(lower(span.name), "health")`, true},
+		{`exists(span.attributes["http.route"])`, true},
+		{`not exists(span.attributes["http.target"])`, true},
+		{`!(span.kind == "client") || false`, true},
+		{`span.attributes["missing"] != "x"`, false},
+		{`span.attributes["missing"] < 10`, false},
+		{`span.duration < 5`, false},
+		{`span.name < 5ms`, false},
+		{`span.name == true`, false},
+		{`resource.attributes["service.name"] == "checkout"`, true},
+		{`scope.name == "net/http" and scope.version =~ "1\\..*"`, true},
+		{`event.name == "exception" and event.attributes["exception.type"] == "Timeout"`, true},
+		{`link.trace_id == "abcd"`, false},
+		{`span.trace_id == "abcd"`, true},
+		{`span.name`, false},
+		{`true or span.name == "x" and false`, true},
+		{`(true or false) and false`, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.source, func(t *testing.T) {
+			program, err := Compile(tc.source)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, program.Eval(env))
+		})
+	}
+}
+
+func Test_Program_Eval_MissingData(t *testing.T) {
+	t.Run("should evaluate fields of absent data as missing", func(t *testing.T) {
+		program, err := Compile(`exists(event.name) or span.attributes["a"] == "b" or scope.name == ""`)
+
+		assert.NoError(t, err)
+		assert.False(t, program.Eval(&Env{}))
+	})
+}
+
+func Test_Compile(t *testing.T) {
+	tests := []struct {
+		source string
+		pos    int
+	}{
+		{``, 1},
+		{`span.name ==`, 13},
+		{`span.name == "GET`, 14},
+		{`span.unknown == "x"`, 6},
+		{`trace.name == "x"`, 1},
+		{`span.name =~ "("`, 14},
+		{`span.name =~ span.kind`, 14},
+		{`span.duration < 5parsecs`, 17},
+		{`span.name == "x" span.kind`, 18},
+		{`(span.name == "x"`, 18},
+		{`span.attributes.http`, 16},
+		{`unknown(span.name)`, 1},
+		{`startsWith(span.name)`, 1},
+		{`span.name # "x"`, 11},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.source, func(t *testing.T) {
+			_, err := Compile(tc.source)
+
+			var exprErr *Error
+
+			assert.ErrorAs(t, err, &exprErr)
+			assert.Equal(t, tc.pos, exprErr.Pos, exprErr.Error())
+		})
+	}
+}
+
+func Test_Program_Uses(t *testing.T) {
+	program, err := Compile(`resource.attributes["service.name"] == "checkout" and span.duration > 1s`)
+
+	assert.NoError(t, err)
+	assert.True(t, program.Uses("resource"))
+	assert.True(t, program.Uses("span"))
+	assert.False(t, program.Uses("event"))
+	assert.Equal(t, `resource.attributes["service.name"] == "checkout" and span.duration > 1s`, program.String())
+}
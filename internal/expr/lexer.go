@@ -0,0 +1,222 @@
+package expr
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenDuration
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenDot
+	tokenComma
+	tokenEq
+	tokenNeq
+	tokenLt
+	tokenLte
+	tokenGt
+	tokenGte
+	tokenMatch
+	tokenNotMatch
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenTrue
+	tokenFalse
+)
+
+var keywords = map[string]tokenKind{
+	"and":   tokenAnd,
+	"or":    tokenOr,
+	"not":   tokenNot,
+	"true":  tokenTrue,
+	"false": tokenFalse,
+}
+
+var symbols = []struct {
+	text string
+	kind tokenKind
+}{
+	// longer symbols must come first so they are preferred
+	{"==", tokenEq},
+	{"!=", tokenNeq},
+	{"<=", tokenLte},
+	{">=", tokenGte},
+	{"=~", tokenMatch},
+	{"!~", tokenNotMatch},
+	{"&&", tokenAnd},
+	{"||", tokenOr},
+	{"<", tokenLt},
+	{">", tokenGt},
+	{"!", tokenNot},
+	{"(", tokenLParen},
+	{")", tokenRParen},
+	{"[", tokenLBracket},
+	{"]", tokenRBracket},
+	{".", tokenDot},
+	{",", tokenComma},
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+
+	// value holds the decoded literal for strings, numbers and durations
+	value any
+}
+
+// lex splits the source into tokens, positions are 1-based columns
+func lex(src string) ([]token, error) {
+	var tokens []token
+	var runes = []rune(src)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"' || r == '\'':
+			text, end, err := lexString(runes, i)
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[i:end]), pos: pos, value: text})
+			i = end
+
+		case unicode.IsDigit(r):
+			tok, end, err := lexNumber(runes, i)
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, tok)
+			i = end
+
+		case unicode.IsLetter(r) || r == '_':
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+				end++
+			}
+
+			text := string(runes[i:end])
+			kind, ok := keywords[text]
+			if !ok {
+				kind = tokenIdent
+			}
+
+			tokens = append(tokens, token{kind: kind, text: text, pos: pos})
+			i = end
+
+		default:
+			var matched bool
+
+			for _, s := range symbols {
+				if strings.HasPrefix(string(runes[i:]), s.text) {
+					tokens = append(tokens, token{kind: s.kind, text: s.text, pos: pos})
+					i += len([]rune(s.text))
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return nil, errorf(pos, "unexpected character %q", r)
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(runes) + 1}), nil
+}
+
+func lexString(runes []rune, start int) (string, int, error) {
+	var sb strings.Builder
+	var quote = runes[start]
+
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case quote:
+			return sb.String(), i + 1, nil
+
+		case '\\':
+			if i+1 == len(runes) {
+				return "", 0, errorf(i+1, "unterminated escape sequence")
+			}
+
+			i++
+			switch runes[i] {
+			case 'n':
+				sb.WriteRune('\n')
+			case 't':
+				sb.WriteRune('\t')
+			default:
+				sb.WriteRune(runes[i])
+			}
+
+		default:
+			sb.WriteRune(runes[i])
+		}
+	}
+
+	return "", 0, errorf(start+1, "unterminated string")
+}
+
+func lexNumber(runes []rune, start int) (token, int, error) {
+	var end = start
+	var isDuration bool
+
+	for end < len(runes) {
+		r := runes[end]
+
+		if unicode.IsLetter(r) {
+			isDuration = true
+		} else if !unicode.IsDigit(r) && r != '.' {
+			break
+		}
+
+		end++
+	}
+
+	text := string(runes[start:end])
+	tok := token{text: text, pos: start + 1}
+
+	if isDuration {
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return tok, 0, errorf(start+1, "invalid duration %q", text)
+		}
+
+		tok.kind, tok.value = tokenDuration, duration
+		return tok, end, nil
+	}
+
+	tok.kind = tokenNumber
+
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		tok.value = n
+		return tok, end, nil
+	}
+
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return tok, 0, errorf(start+1, "invalid number %q", text)
+	}
+
+	tok.value = f
+	return tok, end, nil
+}
@@ -0,0 +1,284 @@
+package expr
+
+import (
+	"regexp"
+	"strings"
+)
+
+// node is a compiled piece of an expression
+type node func(env *Env) any
+
+type parser struct {
+	tokens []token
+	pos    int
+	roots  map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, unexpected(tok, what)
+	}
+
+	return tok, nil
+}
+
+func unexpected(tok token, expected string) *Error {
+	if tok.kind == tokenEOF {
+		return errorf(tok.pos, "unexpected end of expression, expected %s", expected)
+	}
+
+	return errorf(tok.pos, "unexpected %q, expected %s", tok.text, expected)
+}
+
+func (p *parser) parse() (node, error) {
+	if p.peek().kind == tokenEOF {
+		return nil, errorf(p.peek().pos, "empty expression")
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, unexpected(tok, "operator")
+	}
+
+	return root, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenOr {
+		p.next()
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(env *Env) any { return truthy(l(env)) || truthy(right(env)) }
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenAnd {
+		p.next()
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(env *Env) any { return truthy(l(env)) && truthy(right(env)) }
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.peek().kind != tokenNot {
+		return p.parseComparison()
+	}
+
+	p.next()
+
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(env *Env) any { return !truthy(operand(env)) }, nil
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	op := p.peek()
+
+	switch op.kind {
+	case tokenMatch, tokenNotMatch:
+		p.next()
+
+		pattern, err := p.expect(tokenString, "regular expression string")
+		if err != nil {
+			return nil, err
+		}
+
+		// anchored like the patterns of the match conditions
+		re, reErr := regexp.Compile("^(?:" + pattern.value.(string) + ")$")
+		if reErr != nil {
+			return nil, errorf(pattern.pos, "invalid regular expression: %v", reErr)
+		}
+
+		negate := op.kind == tokenNotMatch
+
+		return func(env *Env) any {
+			s, ok := left(env).(string)
+			return ok && re.MatchString(s) != negate
+		}, nil
+
+	case tokenEq, tokenNeq, tokenLt, tokenLte, tokenGt, tokenGte:
+		p.next()
+
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(env *Env) any { return compare(op.kind, left(env), right(env)) }, nil
+	}
+
+	return left, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(tokenRParen, "\")\""); err != nil {
+			return nil, err
+		}
+
+		return inner, nil
+
+	case tokenString, tokenNumber, tokenDuration:
+		value := tok.value
+		return func(*Env) any { return value }, nil
+
+	case tokenTrue, tokenFalse:
+		value := tok.kind == tokenTrue
+		return func(*Env) any { return value }, nil
+
+	case tokenIdent:
+		if p.peek().kind == tokenLParen {
+			return p.parseCall(tok)
+		}
+
+		return p.parseField(tok)
+	}
+
+	return nil, unexpected(tok, "value")
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, errorf(name.pos, "unknown function %q", name.text)
+	}
+
+	p.next()
+
+	var args []node
+
+	for p.peek().kind != tokenRParen {
+		if len(args) > 0 {
+			if _, err := p.expect(tokenComma, "\",\" or \")\""); err != nil {
+				return nil, err
+			}
+		}
+
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+	}
+
+	p.next()
+
+	if len(args) != fn.arity {
+		return nil, errorf(name.pos, "function %s expects %d argument(s), got %d", name.text, fn.arity, len(args))
+	}
+
+	return func(env *Env) any {
+		var values = make([]any, len(args))
+		for i, arg := range args {
+			values[i] = arg(env)
+		}
+
+		return fn.call(values)
+	}, nil
+}
+
+func (p *parser) parseField(root token) (node, error) {
+	var path = []string{root.text}
+
+	if _, ok := fields[root.text]; !ok {
+		return nil, errorf(root.pos, "unknown field %q, expected one of resource, scope, span, event or link", root.text)
+	}
+
+	if _, err := p.expect(tokenDot, "\".\""); err != nil {
+		return nil, err
+	}
+
+	name, err := p.expect(tokenIdent, "field name")
+	if err != nil {
+		return nil, err
+	}
+
+	path = append(path, name.text)
+
+	if name.text == "attributes" {
+		if _, err := p.expect(tokenLBracket, "\"[\""); err != nil {
+			return nil, err
+		}
+
+		key, err := p.expect(tokenString, "attribute key string")
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(tokenRBracket, "\"]\""); err != nil {
+			return nil, err
+		}
+
+		p.roots[root.text] = true
+		return attributeAccessor(root.text, key.value.(string)), nil
+	}
+
+	accessor, ok := fields[root.text][name.text]
+	if !ok {
+		return nil, errorf(name.pos, "unknown field %q", strings.Join(path, "."))
+	}
+
+	p.roots[root.text] = true
+	return accessor, nil
+}
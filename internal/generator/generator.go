@@ -0,0 +1,228 @@
+// Package generator builds synthetic traces, used to smoke-test deployments
+// and to load-test the ingestion.
+package generator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	// ScopeName is the instrumentation scope of the generated spans
+	ScopeName = "github.com/tracedock/tracedock/generator"
+
+	// ErrorMessage is the status message of the spans generated as errors
+	ErrorMessage = "synthetic error"
+)
+
+// Options shapes the generated traces
+type Options struct {
+	// Services are the services calling each other, the first one receives
+	// the root span
+	Services []string
+
+	// Depth is the amount of levels of each trace, 1 generates the root only
+	Depth int
+
+	// FanOut is the amount of children of each span but the leaves
+	FanOut int
+
+	// Attributes are added to every span
+	Attributes map[string]string
+
+	// ErrorRate is the probability, between 0 and 1, of each span failing
+	ErrorRate float64
+
+	// Seed makes the generated ids and durations reproducible
+	Seed uint64
+}
+
+// Generator builds traces shaped by its options, it isn't safe for
+// concurrent use
+type Generator struct {
+	opts  Options
+	rand  *rand.Rand
+	attrs []*common.KeyValue
+	now   func() time.Time
+}
+
+// New creates a generator validating the options
+func New(opts Options) (*Generator, error) {
+	if len(opts.Services) == 0 {
+		return nil, fmt.Errorf("at least one service is required")
+	}
+
+	if opts.Depth < 1 {
+		return nil, fmt.Errorf("depth must be at least 1, got %d", opts.Depth)
+	}
+
+	if opts.FanOut < 0 {
+		return nil, fmt.Errorf("fan-out can't be negative, got %d", opts.FanOut)
+	}
+
+	if opts.ErrorRate < 0 || opts.ErrorRate > 1 {
+		return nil, fmt.Errorf("error rate must be between 0 and 1, got %v", opts.ErrorRate)
+	}
+
+	g := &Generator{
+		opts: opts,
+		rand: rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x9e3779b97f4a7c15)),
+		now:  time.Now,
+	}
+
+	keys := make([]string, 0, len(opts.Attributes))
+	for key := range opts.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		g.attrs = append(g.attrs, stringKV(key, opts.Attributes[key]))
+	}
+
+	return g, nil
+}
+
+// SpansPerTrace returns how many spans each trace has
+func (g *Generator) SpansPerTrace() int {
+	var total, level = 0, 1
+
+	for range g.opts.Depth {
+		total += level
+		level *= g.opts.FanOut
+	}
+
+	// calls between services are a client and a server span
+	return total + g.remoteCalls()
+}
+
+func (g *Generator) remoteCalls() int {
+	var calls, level = 0, 1
+
+	if len(g.opts.Services) < 2 {
+		return 0
+	}
+
+	for range g.opts.Depth - 1 {
+		level *= g.opts.FanOut
+		calls += level
+	}
+
+	return calls
+}
+
+// Trace generates a new trace, ending now, with a resource for each service
+func (g *Generator) Trace() []*trace.ResourceSpans {
+	var resources []*trace.ResourceSpans
+	var byService = make(map[string]*trace.ScopeSpans)
+
+	add := func(service string, span *trace.Span) {
+		ss, ok := byService[service]
+		if !ok {
+			ss = &trace.ScopeSpans{Scope: &common.InstrumentationScope{Name: ScopeName}}
+			byService[service] = ss
+			resources = append(resources, &trace.ResourceSpans{
+				Resource:   &resource.Resource{Attributes: []*common.KeyValue{stringKV("service.name", service)}},
+				ScopeSpans: []*trace.ScopeSpans{ss},
+			})
+		}
+
+		ss.Spans = append(ss.Spans, span)
+	}
+
+	traceID := make([]byte, 16)
+	binary.BigEndian.PutUint64(traceID[:8], g.rand.Uint64())
+	binary.BigEndian.PutUint64(traceID[8:], g.rand.Uint64()|1)
+
+	duration := time.Duration(50+g.rand.IntN(450)) * time.Millisecond
+	start := g.now().Add(-duration)
+	service := g.opts.Services[0]
+
+	root := g.span(traceID, nil, "GET /"+service, trace.Span_SPAN_KIND_SERVER, start, duration)
+	add(service, root)
+
+	g.children(traceID, root, 0, 1, start, duration, add)
+
+	return resources
+}
+
+// children generates the children of parent, served by the service at the
+// given index, down to the configured depth
+func (g *Generator) children(traceID []byte, parent *trace.Span, index, level int, start time.Time, duration time.Duration, add func(string, *trace.Span)) {
+	if level >= g.opts.Depth || g.opts.FanOut == 0 {
+		return
+	}
+
+	service := g.opts.Services[index]
+	slot := duration / time.Duration(g.opts.FanOut)
+
+	for n := range g.opts.FanOut {
+		childStart := start.Add(time.Duration(n) * slot)
+		childDuration := slot * time.Duration(50+g.rand.IntN(50)) / 100
+
+		if len(g.opts.Services) < 2 {
+			span := g.span(traceID, parent.SpanId, fmt.Sprintf("work %d", n), trace.Span_SPAN_KIND_INTERNAL, childStart, childDuration)
+			add(service, span)
+
+			g.children(traceID, span, index, level+1, childStart, childDuration, add)
+			continue
+		}
+
+		// the callee is the next service, the last one calls the first
+		callee := (index + 1 + n) % len(g.opts.Services)
+		if callee == index {
+			callee = (callee + 1) % len(g.opts.Services)
+		}
+
+		calleeName := g.opts.Services[callee]
+
+		client := g.span(traceID, parent.SpanId, "call "+calleeName, trace.Span_SPAN_KIND_CLIENT, childStart, childDuration)
+		client.Attributes = append(client.Attributes, stringKV("peer.service", calleeName))
+		add(service, client)
+
+		serverStart := childStart.Add(childDuration / 20)
+		serverDuration := childDuration * 9 / 10
+
+		server := g.span(traceID, client.SpanId, "GET /"+calleeName, trace.Span_SPAN_KIND_SERVER, serverStart, serverDuration)
+		add(calleeName, server)
+
+		g.children(traceID, server, callee, level+1, serverStart, serverDuration, add)
+	}
+}
+
+func (g *Generator) span(traceID, parentID []byte, name string, kind trace.Span_SpanKind, start time.Time, duration time.Duration) *trace.Span {
+	spanID := make([]byte, 8)
+	binary.BigEndian.PutUint64(spanID, g.rand.Uint64()|1)
+
+	span := &trace.Span{
+		TraceId:           traceID,
+		SpanId:            spanID,
+		ParentSpanId:      parentID,
+		Name:              name,
+		Kind:              kind,
+		StartTimeUnixNano: uint64(start.UnixNano()),
+		EndTimeUnixNano:   uint64(start.Add(duration).UnixNano()),
+		Attributes:        append([]*common.KeyValue(nil), g.attrs...),
+	}
+
+	if kind == trace.Span_SPAN_KIND_SERVER {
+		span.Attributes = append(span.Attributes, stringKV("http.request.method", "GET"), stringKV("http.route", name[len("GET "):]))
+	}
+
+	if g.opts.ErrorRate > 0 && g.rand.Float64() < g.opts.ErrorRate {
+		span.Status = &trace.Status{Code: trace.Status_STATUS_CODE_ERROR, Message: ErrorMessage}
+	}
+
+	return span
+}
+
+func stringKV(key, value string) *common.KeyValue {
+	return &common.KeyValue{Key: key, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: value}}}
+}
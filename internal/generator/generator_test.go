@@ -0,0 +1,168 @@
+package generator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func countSpans(rss []*trace.ResourceSpans) (spans, errors int) {
+	for _, rs := range rss {
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				spans++
+				if span.Status.GetCode() == trace.Status_STATUS_CODE_ERROR {
+					errors++
+				}
+			}
+		}
+	}
+
+	return spans, errors
+}
+
+func Test_New(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"no services", Options{Depth: 1}},
+		{"depth below 1", Options{Services: []string{"a"}}},
+		{"negative fan-out", Options{Services: []string{"a"}, Depth: 1, FanOut: -1}},
+		{"error rate above 1", Options{Services: []string{"a"}, Depth: 1, ErrorRate: 1.5}},
+	}
+
+	for _, tt := range tests {
+		t.Run("should return error for "+tt.name, func(t *testing.T) {
+			_, err := New(tt.opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func Test_Generator_Trace(t *testing.T) {
+	t.Run("should generate the calls between services", func(t *testing.T) {
+		gen, err := New(Options{
+			Services:   []string{"frontend", "checkout", "payment"},
+			Depth:      3,
+			FanOut:     2,
+			Attributes: map[string]string{"env": "test"},
+		})
+		assert.NoError(t, err)
+
+		rss := gen.Trace()
+		spans, errors := countSpans(rss)
+
+		// 7 spans in the tree, 6 of them being remote calls with a client span
+		assert.Equal(t, 13, spans)
+		assert.Equal(t, gen.SpansPerTrace(), spans)
+		assert.Zero(t, errors)
+		assert.Len(t, rss, 3)
+		assert.Equal(t, "frontend", rss[0].Resource.Attributes[0].Value.GetStringValue())
+
+		root := rss[0].ScopeSpans[0].Spans[0]
+
+		assert.Empty(t, root.ParentSpanId)
+		assert.Equal(t, trace.Span_SPAN_KIND_SERVER, root.Kind)
+		assert.Equal(t, "GET /frontend", root.Name)
+		assert.Equal(t, "env", root.Attributes[0].Key)
+
+		var byID = make(map[string]*trace.Span)
+		for _, rs := range rss {
+			for _, span := range rs.ScopeSpans[0].Spans {
+				assert.Equal(t, root.TraceId, span.TraceId)
+				byID[string(span.SpanId)] = span
+			}
+		}
+
+		assert.Len(t, byID, spans)
+
+		for _, span := range byID {
+			if span == root {
+				continue
+			}
+
+			parent, ok := byID[string(span.ParentSpanId)]
+			assert.True(t, ok)
+			assert.GreaterOrEqual(t, span.StartTimeUnixNano, parent.StartTimeUnixNano)
+			assert.LessOrEqual(t, span.EndTimeUnixNano, parent.EndTimeUnixNano)
+		}
+	})
+
+	t.Run("should generate internal spans for a single service", func(t *testing.T) {
+		gen, err := New(Options{Services: []string{"monolith"}, Depth: 3, FanOut: 3})
+		assert.NoError(t, err)
+
+		rss := gen.Trace()
+		spans, _ := countSpans(rss)
+
+		assert.Len(t, rss, 1)
+		assert.Equal(t, 13, spans)
+		assert.Equal(t, gen.SpansPerTrace(), spans)
+		assert.Equal(t, trace.Span_SPAN_KIND_INTERNAL, rss[0].ScopeSpans[0].Spans[1].Kind)
+	})
+
+	t.Run("should fail spans following the error rate", func(t *testing.T) {
+		gen, err := New(Options{Services: []string{"a", "b"}, Depth: 2, FanOut: 1, ErrorRate: 1})
+		assert.NoError(t, err)
+
+		spans, errors := countSpans(gen.Trace())
+
+		assert.Equal(t, spans, errors)
+	})
+
+	t.Run("should generate the same traces for the same seed", func(t *testing.T) {
+		var now = time.Unix(1700000000, 0)
+		var traces [][]*trace.ResourceSpans
+
+		for range 2 {
+			gen, err := New(Options{Services: []string{"a", "b"}, Depth: 3, FanOut: 2, ErrorRate: 0.5, Seed: 42})
+			assert.NoError(t, err)
+
+			gen.now = func() time.Time { return now }
+			traces = append(traces, gen.Trace())
+		}
+
+		for n := range traces[0] {
+			assert.True(t, proto.Equal(traces[0][n], traces[1][n]))
+		}
+	})
+}
+
+func Test_Pacer_Wait(t *testing.T) {
+	t.Run("should spread the spans following the rate", func(t *testing.T) {
+		var now = time.Unix(1700000000, 0)
+		var waits []time.Duration
+
+		pacer := NewPacer(100)
+		pacer.now = func() time.Time { return now }
+		pacer.sleep = func(_ context.Context, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		}
+
+		for range 3 {
+			assert.NoError(t, pacer.Wait(context.Background(), 10))
+		}
+
+		assert.Equal(t, []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond}, waits)
+	})
+
+	t.Run("should not wait without a rate", func(t *testing.T) {
+		assert.NoError(t, NewPacer(0).Wait(context.Background(), 1000))
+	})
+
+	t.Run("should return error when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		pacer := NewPacer(1)
+
+		assert.NoError(t, pacer.Wait(context.Background(), 1))
+		assert.ErrorIs(t, pacer.Wait(ctx, 1), context.Canceled)
+	})
+}
@@ -0,0 +1,64 @@
+package generator
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Pacer spreads the sent spans evenly to keep a rate of spans per second,
+// it's safe for concurrent use
+type Pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+}
+
+// NewPacer creates a pacer for the given spans per second, a rate of zero
+// or less doesn't wait at all
+func NewPacer(rate float64) *Pacer {
+	p := &Pacer{now: time.Now, sleep: sleep}
+
+	if rate > 0 {
+		p.interval = time.Duration(float64(time.Second) / rate)
+	}
+
+	return p
+}
+
+// Wait blocks until n more spans can be sent, or the context is done
+func (p *Pacer) Wait(ctx context.Context, n int) error {
+	if p.interval == 0 {
+		return ctx.Err()
+	}
+
+	p.mu.Lock()
+	now := p.now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	at := p.next
+	p.next = p.next.Add(time.Duration(n) * p.interval)
+	p.mu.Unlock()
+
+	return p.sleep(ctx, at.Sub(now))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package gogocodec
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Name is the name the codec is registered as, the same as the default gRPC
+// codec so it's negotiated transparently with peers
+const Name = "proto"
+
+// gogoMessage is implemented by messages generated by gogo/protobuf, such as
+// the Jaeger API, which can't be handled by the default gRPC codec because
+// of their custom types
+type gogoMessage interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+// Codec is a gRPC codec marshaling gogo/protobuf messages with their own
+// generated code and everything else with the standard protobuf library
+type Codec struct{}
+
+// Name returns the name of the codec
+func (Codec) Name() string {
+	return Name
+}
+
+// Marshal returns the wire format of v
+func (Codec) Marshal(v any) ([]byte, error) {
+	switch msg := v.(type) {
+	case gogoMessage:
+		return msg.Marshal()
+	case proto.Message:
+		return proto.Marshal(msg)
+	case protoadapt.MessageV1:
+		return proto.Marshal(protoadapt.MessageV2Of(msg))
+	default:
+		return nil, fmt.Errorf("gogocodec: unsupported message type %T", v)
+	}
+}
+
+// Unmarshal parses the wire format into v
+func (Codec) Unmarshal(data []byte, v any) error {
+	switch msg := v.(type) {
+	case gogoMessage:
+		return msg.Unmarshal(data)
+	case proto.Message:
+		return proto.Unmarshal(data, msg)
+	case protoadapt.MessageV1:
+		return proto.Unmarshal(data, protoadapt.MessageV2Of(msg))
+	default:
+		return fmt.Errorf("gogocodec: unsupported message type %T", v)
+	}
+}
@@ -0,0 +1,47 @@
+package gogocodec
+
+import (
+	"testing"
+
+	model "github.com/jaegertracing/jaeger-idl/model/v1"
+	"github.com/jaegertracing/jaeger-idl/proto-gen/api_v2"
+	"github.com/stretchr/testify/assert"
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func Test_Codec(t *testing.T) {
+	var codec Codec
+
+	t.Run("should round trip gogo messages", func(t *testing.T) {
+		req := &api_v2.PostSpansRequest{Batch: model.Batch{
+			Spans: []*model.Span{{TraceID: model.NewTraceID(1, 2), SpanID: model.NewSpanID(3), OperationName: "GET"}},
+		}}
+
+		data, err := codec.Marshal(req)
+		assert.NoError(t, err)
+
+		var decoded api_v2.PostSpansRequest
+		assert.NoError(t, codec.Unmarshal(data, &decoded))
+		assert.Equal(t, model.NewTraceID(1, 2), decoded.Batch.Spans[0].TraceID)
+		assert.Equal(t, "GET", decoded.Batch.Spans[0].OperationName)
+	})
+
+	t.Run("should round trip standard protobuf messages", func(t *testing.T) {
+		req := &tracecollectorv1.ExportTraceServiceRequest{ResourceSpans: []*trace.ResourceSpans{{SchemaUrl: "schema"}}}
+
+		data, err := codec.Marshal(req)
+		assert.NoError(t, err)
+
+		var decoded tracecollectorv1.ExportTraceServiceRequest
+		assert.NoError(t, codec.Unmarshal(data, &decoded))
+		assert.Equal(t, "schema", decoded.ResourceSpans[0].SchemaUrl)
+	})
+
+	t.Run("should return error for unsupported types", func(t *testing.T) {
+		_, err := codec.Marshal("text")
+
+		assert.Error(t, err)
+		assert.Error(t, codec.Unmarshal(nil, new(string)))
+	})
+}
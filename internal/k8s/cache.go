@@ -0,0 +1,245 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// resyncPeriod is how often the informer replays the cached pods
+	resyncPeriod = 10 * time.Minute
+
+	// syncTimeout limits the wait for the pods to be listed on start
+	syncTimeout = time.Minute
+
+	indexIP  = "ip"
+	indexUID = "uid"
+
+	// labelPodTemplateHash is appended by Deployments to the names of
+	// their ReplicaSets
+	labelPodTemplateHash = "pod-template-hash"
+)
+
+// ErrNotSynced is returned when the pods couldn't be listed in time
+var ErrNotSynced = errors.New("kubernetes pod cache not synced")
+
+// Pod is the metadata of a pod added to the resources it sent
+type Pod struct {
+	Name      string
+	Namespace string
+	UID       string
+	NodeName  string
+	Labels    map[string]string
+
+	// the workloads owning the pod, empty when not owned by them
+	ReplicaSet  string
+	Deployment  string
+	StatefulSet string
+	DaemonSet   string
+	Job         string
+}
+
+// NewClientset connects to the API server with the given kubeconfig or, when
+// empty, with the service account of the pod TraceDock is running in
+func NewClientset(kubeconfig string) (kubernetes.Interface, error) {
+	var cfg *rest.Config
+	var err error
+
+	if kubeconfig == "" {
+		cfg, err = rest.InClusterConfig()
+	} else {
+		cfg, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(cfg)
+}
+
+// PodCache keeps the metadata of the pods watched through an informer,
+// indexed by IP and UID
+type PodCache struct {
+	factory  informers.SharedInformerFactory
+	informer cache.SharedIndexInformer
+}
+
+// NewPodCache creates a cache of the pods of the given namespace, all of them
+// when empty. It's empty until Start is called.
+func NewPodCache(clientset kubernetes.Interface, namespace string) (*PodCache, error) {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, resyncPeriod, informers.WithNamespace(namespace))
+	informer := factory.Core().V1().Pods().Informer()
+
+	if err := informer.SetTransform(trimPod); err != nil {
+		return nil, err
+	}
+
+	if err := informer.AddIndexers(cache.Indexers{indexIP: podIPs, indexUID: podUID}); err != nil {
+		return nil, err
+	}
+
+	return &PodCache{factory: factory, informer: informer}, nil
+}
+
+// Start watches the pods until ctx is done, waiting up to syncTimeout for
+// them to be listed
+func (c *PodCache) Start(ctx context.Context) error {
+	c.factory.Start(ctx.Done())
+
+	syncCtx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(syncCtx.Done(), c.informer.HasSynced) {
+		return ErrNotSynced
+	}
+
+	return nil
+}
+
+// Stop waits for the informer to stop, the context given to Start must be
+// done before
+func (c *PodCache) Stop() {
+	c.factory.Shutdown()
+}
+
+// ByIP returns the pod with the given IP, preferring running pods as the IPs
+// of the ones that completed are reused
+func (c *PodCache) ByIP(ip string) (*Pod, bool) {
+	return c.lookup(indexIP, ip)
+}
+
+// ByUID returns the pod with the given UID
+func (c *PodCache) ByUID(uid string) (*Pod, bool) {
+	return c.lookup(indexUID, uid)
+}
+
+func (c *PodCache) lookup(index, value string) (*Pod, bool) {
+	var found *corev1.Pod
+
+	objs, err := c.informer.GetIndexer().ByIndex(index, value)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, obj := range objs {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			continue
+		}
+
+		if found == nil || pod.Status.Phase == corev1.PodRunning {
+			found = pod
+		}
+	}
+
+	if found == nil {
+		return nil, false
+	}
+
+	return newPod(found), true
+}
+
+// newPod extracts the metadata of the pod, including its owners
+func newPod(pod *corev1.Pod) *Pod {
+	p := &Pod{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		UID:       string(pod.UID),
+		NodeName:  pod.Spec.NodeName,
+		Labels:    pod.Labels,
+	}
+
+	for _, owner := range pod.OwnerReferences {
+		switch owner.Kind {
+		case "ReplicaSet":
+			p.ReplicaSet = owner.Name
+
+			// the deployment isn't watched, its name is the one of the
+			// replica set without the pod template hash
+			if hash := pod.Labels[labelPodTemplateHash]; hash != "" {
+				if name, ok := strings.CutSuffix(owner.Name, "-"+hash); ok {
+					p.Deployment = name
+				}
+			}
+		case "StatefulSet":
+			p.StatefulSet = owner.Name
+		case "DaemonSet":
+			p.DaemonSet = owner.Name
+		case "Job":
+			p.Job = owner.Name
+		}
+	}
+
+	return p
+}
+
+// trimPod keeps only the fields used by the cache, reducing its memory
+func trimPod(obj any) (any, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		// tombstones of deleted pods are kept as they are
+		return obj, nil
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pod.Name,
+			Namespace:       pod.Namespace,
+			UID:             pod.UID,
+			ResourceVersion: pod.ResourceVersion,
+			Labels:          pod.Labels,
+			OwnerReferences: pod.OwnerReferences,
+		},
+		Spec: corev1.PodSpec{
+			NodeName:    pod.Spec.NodeName,
+			HostNetwork: pod.Spec.HostNetwork,
+		},
+		Status: corev1.PodStatus{
+			Phase:  pod.Status.Phase,
+			PodIP:  pod.Status.PodIP,
+			PodIPs: pod.Status.PodIPs,
+		},
+	}, nil
+}
+
+// podIPs indexes the pods by their IPs, leaving out the ones using the host
+// network as their IP is the one of the node
+func podIPs(obj any) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Spec.HostNetwork {
+		return nil, nil
+	}
+
+	var ips []string
+
+	for _, ip := range pod.Status.PodIPs {
+		ips = append(ips, ip.IP)
+	}
+
+	if len(ips) == 0 && pod.Status.PodIP != "" {
+		ips = append(ips, pod.Status.PodIP)
+	}
+
+	return ips, nil
+}
+
+// podUID indexes the pods by their UID
+func podUID(obj any) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil, nil
+	}
+
+	return []string{string(pod.UID)}, nil
+}
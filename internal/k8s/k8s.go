@@ -0,0 +1,127 @@
+// Package k8s enriches the resources with the metadata of the Kubernetes pods
+// that sent them, found by the client IP or an existing k8s.pod.uid
+package k8s
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+// Resource attributes set from the pod metadata, following the OpenTelemetry
+// semantic conventions
+const (
+	AttributePodName         = "k8s.pod.name"
+	AttributePodUID          = "k8s.pod.uid"
+	AttributeNamespaceName   = "k8s.namespace.name"
+	AttributeNodeName        = "k8s.node.name"
+	AttributeReplicaSetName  = "k8s.replicaset.name"
+	AttributeDeploymentName  = "k8s.deployment.name"
+	AttributeStatefulSetName = "k8s.statefulset.name"
+	AttributeDaemonSetName   = "k8s.daemonset.name"
+	AttributeJobName         = "k8s.job.name"
+
+	// AttributePodLabelPrefix prefixes the pod labels, e.g. k8s.pod.label.app
+	AttributePodLabelPrefix = "k8s.pod.label."
+)
+
+// Enricher adds the metadata of the pods to the resources they sent, never
+// replacing the attributes already set
+type Enricher struct {
+	pods *PodCache
+
+	// labels are the pod labels added, all of them when empty
+	labels []string
+}
+
+// NewEnricher creates an enricher of the pods in the configured namespace,
+// watched once Start is called
+func NewEnricher(pods *PodCache, cfg config.ConfigKubernetes) *Enricher {
+	return &Enricher{pods: pods, labels: cfg.Labels}
+}
+
+// Start watches the pods until ctx is done, waiting for them to be listed
+func (e *Enricher) Start(ctx context.Context) error {
+	return e.pods.Start(ctx)
+}
+
+// Stop waits for the pods to stop being watched, the context given to Start
+// must be done before
+func (e *Enricher) Stop() {
+	e.pods.Stop()
+}
+
+// Enrich adds the metadata of the pod that sent the resource, found by its
+// k8s.pod.uid attribute or, when missing, by the client IP carried by ctx.
+// It's safe to call on a nil Enricher, leaving the resource unchanged.
+func (e *Enricher) Enrich(ctx context.Context, rs *trace.ResourceSpans) {
+	var pod *Pod
+	var found bool
+
+	if e == nil || rs == nil {
+		return
+	}
+
+	if value, ok := otlputil.FindAttribute(rs.GetResource().GetAttributes(), AttributePodUID); ok {
+		pod, found = e.pods.ByUID(otlputil.ValueString(value))
+	} else if ip := client.FromContext(ctx).IP(); ip != "" {
+		pod, found = e.pods.ByIP(ip)
+	}
+
+	if !found {
+		return
+	}
+
+	if rs.Resource == nil {
+		rs.Resource = &resource.Resource{}
+	}
+
+	attrs := rs.Resource.Attributes
+
+	for _, attr := range []struct{ key, value string }{
+		{AttributePodName, pod.Name},
+		{AttributePodUID, pod.UID},
+		{AttributeNamespaceName, pod.Namespace},
+		{AttributeNodeName, pod.NodeName},
+		{AttributeReplicaSetName, pod.ReplicaSet},
+		{AttributeDeploymentName, pod.Deployment},
+		{AttributeStatefulSetName, pod.StatefulSet},
+		{AttributeDaemonSetName, pod.DaemonSet},
+		{AttributeJobName, pod.Job},
+	} {
+		attrs = setMissing(attrs, attr.key, attr.value)
+	}
+
+	labels := e.labels
+	if len(labels) == 0 {
+		labels = slices.Sorted(maps.Keys(pod.Labels))
+	}
+
+	for _, key := range labels {
+		attrs = setMissing(attrs, AttributePodLabelPrefix+key, pod.Labels[key])
+	}
+
+	rs.Resource.Attributes = attrs
+}
+
+// setMissing sets the attribute when it isn't set yet and the value isn't
+// empty
+func setMissing(attrs []*common.KeyValue, key, value string) []*common.KeyValue {
+	if value == "" {
+		return attrs
+	}
+
+	if _, ok := otlputil.FindAttribute(attrs, key); ok {
+		return attrs
+	}
+
+	return otlputil.SetAttribute(attrs, key, &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: value}})
+}
@@ -0,0 +1,189 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+func newTestPod(name, uid, ip string, phase corev1.PodPhase, owner metav1.OwnerReference, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "shop",
+			UID:             types.UID(uid),
+			Labels:          labels,
+			OwnerReferences: []metav1.OwnerReference{owner},
+		},
+		Spec:   corev1.PodSpec{NodeName: "node-1"},
+		Status: corev1.PodStatus{Phase: phase, PodIP: ip, PodIPs: []corev1.PodIP{{IP: ip}}},
+	}
+}
+
+// startTestEnricher starts an enricher watching the given pods through a
+// fake clientset
+func startTestEnricher(t *testing.T, cfg config.ConfigKubernetes, pods ...*corev1.Pod) (*Enricher, *fake.Clientset) {
+	var objects []runtime.Object
+
+	for _, pod := range pods {
+		objects = append(objects, pod)
+	}
+
+	clientset := fake.NewClientset(objects...)
+
+	cache, err := NewPodCache(clientset, cfg.Namespace)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	enricher := NewEnricher(cache, cfg)
+
+	assert.NoError(t, enricher.Start(ctx))
+	t.Cleanup(func() {
+		cancel()
+		enricher.Stop()
+	})
+
+	return enricher, clientset
+}
+
+func resourceAttributes(rs *trace.ResourceSpans) map[string]string {
+	attrs := make(map[string]string)
+
+	for _, kv := range rs.GetResource().GetAttributes() {
+		attrs[kv.Key] = otlputil.ValueString(kv.Value)
+	}
+
+	return attrs
+}
+
+func Test_Enricher_Enrich(t *testing.T) {
+	checkout := newTestPod("checkout-7d9f8-x2k4j", "uid-checkout", "10.0.0.5", corev1.PodRunning,
+		metav1.OwnerReference{Kind: "ReplicaSet", Name: "checkout-7d9f8"},
+		map[string]string{"app": "checkout", "team": "payments", "pod-template-hash": "7d9f8"})
+	completed := newTestPod("migrate-abcde", "uid-migrate", "10.0.0.5", corev1.PodSucceeded,
+		metav1.OwnerReference{Kind: "Job", Name: "migrate"}, nil)
+	database := newTestPod("postgres-0", "uid-postgres", "10.0.0.6", corev1.PodRunning,
+		metav1.OwnerReference{Kind: "StatefulSet", Name: "postgres"}, nil)
+	agent := newTestPod("agent-q8z7w", "uid-agent", "10.0.1.1", corev1.PodRunning,
+		metav1.OwnerReference{Kind: "DaemonSet", Name: "agent"}, nil)
+	agent.Spec.HostNetwork = true
+
+	enricher, clientset := startTestEnricher(t, config.ConfigKubernetes{}, checkout, completed, database, agent)
+
+	fromIP := func(ip string) context.Context {
+		return client.NewContext(context.Background(), client.Info{Addr: ip + ":43210"})
+	}
+
+	t.Run("should add the metadata of the running pod with the client IP", func(t *testing.T) {
+		rs := &trace.ResourceSpans{}
+
+		enricher.Enrich(fromIP("10.0.0.5"), rs)
+
+		assert.Equal(t, map[string]string{
+			"k8s.pod.name":                    "checkout-7d9f8-x2k4j",
+			"k8s.pod.uid":                     "uid-checkout",
+			"k8s.namespace.name":              "shop",
+			"k8s.node.name":                   "node-1",
+			"k8s.replicaset.name":             "checkout-7d9f8",
+			"k8s.deployment.name":             "checkout",
+			"k8s.pod.label.app":               "checkout",
+			"k8s.pod.label.team":              "payments",
+			"k8s.pod.label.pod-template-hash": "7d9f8",
+		}, resourceAttributes(rs))
+	})
+
+	t.Run("should find the pod by its uid before the client IP", func(t *testing.T) {
+		rs := &trace.ResourceSpans{Resource: &resource.Resource{Attributes: []*common.KeyValue{
+			{Key: "k8s.pod.uid", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "uid-postgres"}}},
+		}}}
+
+		enricher.Enrich(fromIP("10.0.0.5"), rs)
+
+		assert.Equal(t, "postgres-0", resourceAttributes(rs)["k8s.pod.name"])
+		assert.Equal(t, "postgres", resourceAttributes(rs)["k8s.statefulset.name"])
+	})
+
+	t.Run("should keep the attributes already set", func(t *testing.T) {
+		rs := &trace.ResourceSpans{Resource: &resource.Resource{Attributes: []*common.KeyValue{
+			{Key: "k8s.namespace.name", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "custom"}}},
+		}}}
+
+		enricher.Enrich(fromIP("10.0.0.6"), rs)
+
+		assert.Equal(t, "custom", resourceAttributes(rs)["k8s.namespace.name"])
+		assert.Equal(t, "postgres-0", resourceAttributes(rs)["k8s.pod.name"])
+	})
+
+	t.Run("should leave out pods using the host network", func(t *testing.T) {
+		rs := &trace.ResourceSpans{}
+
+		enricher.Enrich(fromIP("10.0.1.1"), rs)
+
+		assert.Empty(t, resourceAttributes(rs))
+	})
+
+	t.Run("should leave resources from unknown clients unchanged", func(t *testing.T) {
+		rs := &trace.ResourceSpans{}
+
+		enricher.Enrich(fromIP("192.0.2.1"), rs)
+		enricher.Enrich(context.Background(), rs)
+
+		assert.Empty(t, resourceAttributes(rs))
+	})
+
+	t.Run("should see the pods created after starting", func(t *testing.T) {
+		pod := newTestPod("cart-0", "uid-cart", "10.0.0.7", corev1.PodRunning,
+			metav1.OwnerReference{Kind: "StatefulSet", Name: "cart"}, nil)
+
+		_, err := clientset.CoreV1().Pods("shop").Create(context.Background(), pod, metav1.CreateOptions{})
+		assert.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			rs := &trace.ResourceSpans{}
+			enricher.Enrich(fromIP("10.0.0.7"), rs)
+
+			return resourceAttributes(rs)["k8s.pod.name"] == "cart-0"
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("should do nothing when nil", func(t *testing.T) {
+		var enricher *Enricher
+
+		rs := &trace.ResourceSpans{}
+		enricher.Enrich(fromIP("10.0.0.5"), rs)
+
+		assert.Empty(t, resourceAttributes(rs))
+	})
+}
+
+func Test_Enricher_EnrichWithLabels(t *testing.T) {
+	t.Run("should only add the configured labels", func(t *testing.T) {
+		pod := newTestPod("checkout-0", "uid-checkout", "10.0.0.5", corev1.PodRunning,
+			metav1.OwnerReference{Kind: "StatefulSet", Name: "checkout"},
+			map[string]string{"app": "checkout", "team": "payments"})
+
+		enricher, _ := startTestEnricher(t, config.ConfigKubernetes{Labels: []string{"team", "missing"}}, pod)
+
+		rs := &trace.ResourceSpans{}
+		enricher.Enrich(client.NewContext(context.Background(), client.Info{Addr: "10.0.0.5:43210"}), rs)
+
+		attrs := resourceAttributes(rs)
+		assert.Equal(t, "payments", attrs["k8s.pod.label.team"])
+		assert.NotContains(t, attrs, "k8s.pod.label.app")
+		assert.NotContains(t, attrs, "k8s.pod.label.missing")
+	})
+}
@@ -0,0 +1,527 @@
+// Package loadtest measures the ingestion path, sending synthetic traces to
+// the OTLP receivers listening on the loopback, from the same process.
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/generator"
+	"github.com/tracedock/tracedock/internal/server"
+)
+
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+
+	EncodingProtobuf = "protobuf"
+	EncodingJSON     = "json"
+)
+
+// Options describes the load sent to the receiver
+type Options struct {
+	// Protocol selects the receiver, ProtocolGRPC or ProtocolHTTP
+	Protocol string
+
+	// Encoding of the HTTP requests, EncodingProtobuf or EncodingJSON
+	Encoding string
+
+	// Generator shapes the traces of the requests
+	Generator generator.Options
+
+	// TracesPerRequest is the amount of traces sent in each request
+	TracesPerRequest int
+
+	// Payloads is the amount of distinct requests prepared before the run
+	Payloads int
+
+	// Receivers configures the receivers under test
+	Receivers config.ConfigReceivers
+
+	// Workers is the amount of requests sent concurrently
+	Workers int
+
+	// Duration limits how long requests are sent
+	Duration time.Duration
+
+	// Requests limits the amount of requests sent, 0 for no limit
+	Requests int
+}
+
+// Latency holds the percentiles of the request latencies
+type Latency struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// Report holds the results of a run
+type Report struct {
+	Protocol       string        `json:"protocol"`
+	Encoding       string        `json:"encoding,omitempty"`
+	Requests       int64         `json:"requests"`
+	Spans          int64         `json:"spans"`
+	Errors         int64         `json:"errors"`
+	Elapsed        time.Duration `json:"elapsed"`
+	RequestsPerSec float64       `json:"requests_per_second"`
+	SpansPerSec    float64       `json:"spans_per_second"`
+	RequestBytes   int           `json:"request_bytes"`
+	Latency        Latency       `json:"latency"`
+	AllocsPerSpan  float64       `json:"allocs_per_span"`
+	BytesPerSpan   float64       `json:"bytes_per_span"`
+}
+
+// String formats the report as a human readable summary
+func (r *Report) String() string {
+	name := r.Protocol
+	if r.Encoding != "" {
+		name += "/" + r.Encoding
+	}
+
+	return fmt.Sprintf("%s: %d requests (%d failed), %d spans in %s\n"+
+		"  throughput: %.0f req/s, %.0f spans/s, %d bytes per request\n"+
+		"  latency:    p50 %s, p90 %s, p99 %s, max %s\n"+
+		"  memory:     %.1f allocs/span, %.0f B/span\n",
+		name, r.Requests, r.Errors, r.Spans, r.Elapsed.Round(time.Millisecond),
+		r.RequestsPerSec, r.SpansPerSec, r.RequestBytes,
+		r.Latency.P50.Round(time.Microsecond), r.Latency.P90.Round(time.Microsecond),
+		r.Latency.P99.Round(time.Microsecond), r.Latency.Max.Round(time.Microsecond),
+		r.AllocsPerSpan, r.BytesPerSpan)
+}
+
+// exportMethod is the gRPC method receiving the OTLP traces
+const exportMethod = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+
+// calibrationRequests is the amount of requests sent to the sink to measure
+// the allocations of the harness
+const calibrationRequests = 200
+
+// Target sends prepared requests to a receiver listening on the loopback
+type Target struct {
+	Options Options
+
+	// payloads are the encoded requests
+	payloads [][]byte
+	spans    int
+
+	receiver *endpoint
+
+	// sink accepts the same requests as the receiver and discards them, the
+	// allocations of sending them are the ones of the harness
+	sink *endpoint
+}
+
+// endpoint is a server listening on the loopback with a client sending it
+// the prepared requests
+type endpoint struct {
+	send  func(ctx context.Context, payload []byte) error
+	close func()
+}
+
+// NewTarget prepares the payloads and starts the receiver feeding the
+// ingestor, which must be closed once done
+func NewTarget(ingestor server.TraceIngestor, opts Options) (*Target, error) {
+	if opts.Protocol == "" {
+		opts.Protocol = ProtocolGRPC
+	}
+
+	if opts.Encoding == "" {
+		opts.Encoding = EncodingProtobuf
+	}
+
+	if opts.TracesPerRequest <= 0 {
+		opts.TracesPerRequest = 1
+	}
+
+	if opts.Payloads <= 0 {
+		opts.Payloads = 1
+	}
+
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+
+	switch opts.Protocol {
+	case ProtocolGRPC:
+		opts.Encoding = ""
+	case ProtocolHTTP:
+		if opts.Encoding != EncodingProtobuf && opts.Encoding != EncodingJSON {
+			return nil, fmt.Errorf("unsupported encoding %q", opts.Encoding)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", opts.Protocol)
+	}
+
+	t := &Target{Options: opts}
+
+	gen, err := generator.New(opts.Generator)
+	if err != nil {
+		return nil, err
+	}
+
+	t.spans = gen.SpansPerTrace() * opts.TracesPerRequest
+
+	for range opts.Payloads {
+		var rss []*trace.ResourceSpans
+
+		for range opts.TracesPerRequest {
+			rss = append(rss, gen.Trace()...)
+		}
+
+		req := &tracecollectorv1.ExportTraceServiceRequest{ResourceSpans: rss}
+
+		var payload []byte
+		if opts.Encoding == EncodingJSON {
+			payload, err = protojson.Marshal(req)
+		} else {
+			payload, err = proto.Marshal(req)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		t.payloads = append(t.payloads, payload)
+	}
+
+	if opts.Protocol == ProtocolGRPC {
+		receiver := server.NewGRPCServer(opts.Receivers.GRPC)
+		receiver.RegisterTraceIngestor(ingestor)
+
+		t.receiver, err = newGRPCEndpoint(receiver.Serve, receiver.Stop)
+		if err != nil {
+			return nil, err
+		}
+
+		sink := grpc.NewServer(grpc.ForceServerCodec(rawCodec{}), grpc.UnknownServiceHandler(discardStream))
+		t.sink, err = newGRPCEndpoint(sink.Serve, func() error { sink.Stop(); return nil })
+	} else {
+		receiver := server.NewHTTPServer(opts.Receivers.HTTP)
+		receiver.RegisterTraceIngestor(ingestor)
+
+		t.receiver, err = newHTTPEndpoint(receiver.Serve, receiver.Stop, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		sink := &http.Server{Handler: http.HandlerFunc(discardRequest)}
+		t.sink, err = newHTTPEndpoint(sink.Serve, sink.Close, opts)
+	}
+
+	if err != nil {
+		t.receiver.close()
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// newGRPCEndpoint serves a gRPC server on the loopback and connects a client
+// sending the payloads as they are
+func newGRPCEndpoint(serve func(net.Listener) error, stop func() error) (*endpoint, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	go serve(listener)
+
+	conn, err := grpc.NewClient(listener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})),
+	)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+
+	return &endpoint{
+		send: func(ctx context.Context, payload []byte) error {
+			var resp []byte
+			return conn.Invoke(ctx, exportMethod, payload, &resp)
+		},
+		close: func() {
+			conn.Close()
+			stop()
+		},
+	}, nil
+}
+
+// newHTTPEndpoint serves an HTTP server on the loopback and returns a client
+// posting the payloads to the traces path
+func newHTTPEndpoint(serve func(net.Listener) error, stop func() error, opts Options) (*endpoint, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	go serve(listener)
+
+	contentType := "application/x-protobuf"
+	if opts.Encoding == EncodingJSON {
+		contentType = "application/json"
+	}
+
+	url := "http://" + listener.Addr().String() + "/v1/traces"
+	transport := &http.Transport{MaxIdleConnsPerHost: opts.Workers}
+	httpClient := &http.Client{Transport: transport}
+
+	return &endpoint{
+		send: func(ctx context.Context, payload []byte) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+			if err != nil {
+				return err
+			}
+
+			req.Header.Set("Content-Type", contentType)
+
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				return err
+			}
+
+			// the body is drained so the connection is reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return fmt.Errorf("http server responded with %d", resp.StatusCode)
+			}
+
+			return nil
+		},
+		close: func() {
+			transport.CloseIdleConnections()
+			stop()
+		},
+	}, nil
+}
+
+// SpansPerRequest returns the amount of spans in each request
+func (t *Target) SpansPerRequest() int {
+	return t.spans
+}
+
+// RequestBytes returns the average size of the encoded requests
+func (t *Target) RequestBytes() int {
+	var total int
+
+	for _, payload := range t.payloads {
+		total += len(payload)
+	}
+
+	return total / len(t.payloads)
+}
+
+// Send delivers the n-th prepared request to the receiver
+func (t *Target) Send(ctx context.Context, n int) error {
+	return t.receiver.send(ctx, t.payloads[n%len(t.payloads)])
+}
+
+// Close stops the receiver and the sink, closing their connections
+func (t *Target) Close() {
+	t.receiver.close()
+	t.sink.close()
+}
+
+// Run sends requests until the duration elapses, the requests are sent or
+// the context is done, and reports the results
+//
+// The allocations of the client and the bookkeeping happen in the same
+// process, they are measured beforehand sending requests to the sink and
+// subtracted, so only the ones of the receiver and the pipelines remain.
+func (t *Target) Run(ctx context.Context) *Report {
+	calibration := run(ctx, t.sink, t.payloads, t.Options.Workers, 0, calibrationRequests)
+	result := run(ctx, t.receiver, t.payloads, t.Options.Workers, t.Options.Duration, t.Options.Requests)
+
+	report := &Report{
+		Protocol:     t.Options.Protocol,
+		Encoding:     t.Options.Encoding,
+		Requests:     result.requests,
+		Errors:       result.errors,
+		Elapsed:      result.elapsed,
+		RequestBytes: t.RequestBytes(),
+		Latency:      percentiles(result.latencies),
+	}
+
+	report.Spans = (report.Requests - report.Errors) * int64(t.spans)
+
+	// nothing is sent when the context is already done
+	if seconds := report.Elapsed.Seconds(); seconds > 0 {
+		report.RequestsPerSec = float64(report.Requests) / seconds
+		report.SpansPerSec = float64(report.Spans) / seconds
+	}
+
+	if spans := report.Requests * int64(t.spans); spans > 0 {
+		requests := float64(report.Requests)
+
+		mallocs := float64(result.mallocs)
+		bytes := float64(result.bytes)
+
+		if calibration.requests > 0 {
+			mallocs -= float64(calibration.mallocs) / float64(calibration.requests) * requests
+			bytes -= float64(calibration.bytes) / float64(calibration.requests) * requests
+		}
+
+		report.AllocsPerSpan = max(0, mallocs/float64(spans))
+		report.BytesPerSpan = max(0, bytes/float64(spans))
+	}
+
+	return report
+}
+
+// result holds the measurements of sending requests to an endpoint
+type result struct {
+	requests  int64
+	errors    int64
+	elapsed   time.Duration
+	latencies []time.Duration
+	mallocs   uint64
+	bytes     uint64
+}
+
+// run sends the payloads to the endpoint from the given amount of workers,
+// until the duration elapses, the requests are sent or the context is done
+func run(ctx context.Context, target *endpoint, payloads [][]byte, workers int, duration time.Duration, requests int) result {
+	var wg sync.WaitGroup
+	var sent, failed atomic.Int64
+	var before, after runtime.MemStats
+	var latencies = make([][]time.Duration, workers)
+
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	// the duration starts after collecting the garbage, so it isn't counted
+	if duration > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithDeadline(ctx, start.Add(duration))
+		defer cancel()
+	}
+
+	for worker := range workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for ctx.Err() == nil {
+				n := sent.Add(1)
+				if requests > 0 && n > int64(requests) {
+					sent.Add(-1)
+					return
+				}
+
+				requestStart := time.Now()
+				if err := target.send(ctx, payloads[int(n-1)%len(payloads)]); err != nil {
+					failed.Add(1)
+				}
+
+				latencies[worker] = append(latencies[worker], time.Since(requestStart))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	return result{
+		requests:  sent.Load(),
+		errors:    failed.Load(),
+		elapsed:   elapsed,
+		latencies: slices.Concat(latencies...),
+		mallocs:   after.Mallocs - before.Mallocs,
+		bytes:     after.TotalAlloc - before.TotalAlloc,
+	}
+}
+
+// rawCodec is a gRPC codec sending the prepared payloads as they are, so
+// they aren't encoded again by the client, and receiving the messages as
+// bytes, so the sink doesn't decode them
+type rawCodec struct{}
+
+// Name returns the name of the default gRPC codec, so the receiver decodes
+// the messages with its own
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// Marshal returns the payload of v
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	switch payload := v.(type) {
+	case []byte:
+		return payload, nil
+	case *[]byte:
+		return *payload, nil
+	default:
+		return nil, fmt.Errorf("unsupported message type %T", v)
+	}
+}
+
+// Unmarshal copies the data into v
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	payload, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unsupported message type %T", v)
+	}
+
+	*payload = append((*payload)[:0], data...)
+	return nil
+}
+
+// discardStream is the gRPC handler of the sink, receiving any message and
+// responding with an empty one
+func discardStream(_ any, stream grpc.ServerStream) error {
+	var payload []byte
+
+	if err := stream.RecvMsg(&payload); err != nil {
+		return err
+	}
+
+	return stream.SendMsg([]byte(nil))
+}
+
+// discardRequest is the HTTP handler of the sink, reading the whole body and
+// responding with no content
+func discardRequest(w http.ResponseWriter, r *http.Request) {
+	io.Copy(io.Discard, r.Body)
+	w.WriteHeader(http.StatusOK)
+}
+
+// percentiles sorts the latencies and picks the percentiles, using the
+// nearest rank
+func percentiles(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+
+	slices.Sort(latencies)
+
+	rank := func(p float64) time.Duration {
+		index := int(p*float64(len(latencies))+0.5) - 1
+		return latencies[max(0, min(index, len(latencies)-1))]
+	}
+
+	return Latency{P50: rank(0.50), P90: rank(0.90), P99: rank(0.99), Max: latencies[len(latencies)-1]}
+}
@@ -0,0 +1,207 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/generator"
+	"github.com/tracedock/tracedock/internal/logger"
+	"github.com/tracedock/tracedock/internal/orchestrator"
+	"github.com/tracedock/tracedock/internal/server"
+)
+
+// realistic shapes the benchmark traces like a request crossing a few
+// services, about 40 spans of 1KB each
+var realistic = generator.Options{
+	Services:   []string{"frontend", "cart", "checkout", "payment"},
+	Depth:      3,
+	FanOut:     3,
+	Attributes: map[string]string{"deployment.environment": "production", "http.user_agent": "Mozilla/5.0 (X11; Linux x86_64)"},
+	ErrorRate:  0.01,
+	Seed:       1,
+}
+
+// newIngestor returns an ingestor with a pipeline doing the usual work of
+// enriching, renaming and filtering spans
+func newIngestor(tb testing.TB) *orchestrator.Ingestor {
+	cfg := config.NewConfig()
+	cfg.Pipelines = []config.ConfigPipeline{{
+		Name: "default",
+		Rules: []config.ConfigPipelineRules{
+			{Provider: "setter", Set: map[string]string{"tracedock.processed": "true"}},
+			{Provider: "filter", When: `span.name =~ "^GET /(health|ready)"`},
+			{Provider: "setter", When: `span.status == "error"`, Set: map[string]string{"alert": "true"}},
+		},
+	}}
+
+	ingestor, err := orchestrator.NewIngestor(cfg)
+	assert.NoError(tb, err)
+
+	return ingestor
+}
+
+func benchmarkTarget(b *testing.B, opts Options) {
+	// debug logs would be measured instead of the ingestion
+	assert.NoError(b, logger.SetLevel("info"))
+	b.Cleanup(func() { logger.SetLevel("debug") })
+
+	opts.Generator = realistic
+	opts.TracesPerRequest = 5
+	opts.Payloads = 16
+
+	target, err := NewTarget(newIngestor(b), opts)
+	assert.NoError(b, err)
+	b.Cleanup(target.Close)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(target.RequestBytes()))
+	b.ResetTimer()
+
+	var n int
+	for b.Loop() {
+		if err := target.Send(context.Background(), n); err != nil {
+			b.Fatal(err)
+		}
+		n++
+	}
+
+	b.ReportMetric(float64(n*target.SpansPerRequest())/b.Elapsed().Seconds(), "spans/s")
+}
+
+func BenchmarkGRPCServer_Export(b *testing.B) {
+	benchmarkTarget(b, Options{Protocol: ProtocolGRPC})
+}
+
+func BenchmarkHTTPServer_HandleRequest(b *testing.B) {
+	for _, encoding := range []string{EncodingProtobuf, EncodingJSON} {
+		b.Run(encoding, func(b *testing.B) {
+			benchmarkTarget(b, Options{Protocol: ProtocolHTTP, Encoding: encoding})
+		})
+	}
+}
+
+func Test_NewTarget(t *testing.T) {
+	var ingest = server.TraceIngestorFunc(func(context.Context, client.Info, []*trace.ResourceSpans) []error { return nil })
+	var gen = generator.Options{Services: []string{"a"}, Depth: 1}
+
+	t.Run("should return error for unsupported protocols", func(t *testing.T) {
+		_, err := NewTarget(ingest, Options{Protocol: "udp", Generator: gen})
+		assert.ErrorContains(t, err, "protocol")
+	})
+
+	t.Run("should return error for unsupported encodings", func(t *testing.T) {
+		_, err := NewTarget(ingest, Options{Protocol: ProtocolHTTP, Encoding: "xml", Generator: gen})
+		assert.ErrorContains(t, err, "encoding")
+	})
+
+	t.Run("should return error for invalid generator options", func(t *testing.T) {
+		_, err := NewTarget(ingest, Options{})
+		assert.Error(t, err)
+	})
+}
+
+func Test_Target_Run(t *testing.T) {
+	for _, opts := range []Options{
+		{Protocol: ProtocolGRPC},
+		{Protocol: ProtocolHTTP, Encoding: EncodingProtobuf},
+		{Protocol: ProtocolHTTP, Encoding: EncodingJSON},
+	} {
+		t.Run("should send the requests through "+opts.Protocol+" "+opts.Encoding, func(t *testing.T) {
+			var spans int
+
+			opts.Generator = generator.Options{Services: []string{"a", "b"}, Depth: 2, FanOut: 2}
+			opts.TracesPerRequest = 2
+			opts.Payloads = 3
+			opts.Workers = 2
+			opts.Requests = 10
+
+			target, err := NewTarget(server.TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+				for _, rs := range batch {
+					spans += len(rs.ScopeSpans[0].Spans)
+				}
+
+				return nil
+			}), opts)
+			assert.NoError(t, err)
+			defer target.Close()
+
+			target.Options.Workers = 1
+			report := target.Run(context.Background())
+
+			assert.Equal(t, int64(10), report.Requests)
+			assert.Equal(t, int64(0), report.Errors)
+			assert.Equal(t, int64(100), report.Spans)
+			assert.Equal(t, 100, spans)
+			assert.Positive(t, report.SpansPerSec)
+			assert.Positive(t, report.RequestBytes)
+			assert.Positive(t, report.AllocsPerSpan)
+			assert.LessOrEqual(t, report.Latency.P50, report.Latency.Max)
+			assert.Contains(t, report.String(), "10 requests (0 failed), 100 spans")
+		})
+	}
+
+	t.Run("should count the failed requests until the duration elapses", func(t *testing.T) {
+		target, err := NewTarget(server.TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			return slices.Repeat([]error{errors.New("unavailable")}, len(batch))
+		}), Options{Protocol: ProtocolHTTP, Generator: generator.Options{Services: []string{"a"}, Depth: 1}, Workers: 2, Duration: 20 * time.Millisecond})
+		assert.NoError(t, err)
+		defer target.Close()
+
+		report := target.Run(context.Background())
+
+		assert.Positive(t, report.Requests)
+		assert.Equal(t, report.Requests, report.Errors)
+		assert.Zero(t, report.Spans)
+		assert.GreaterOrEqual(t, report.Elapsed, 20*time.Millisecond)
+	})
+
+	t.Run("should report nothing sent when the context is done", func(t *testing.T) {
+		target, err := NewTarget(server.TraceIngestorFunc(func(context.Context, client.Info, []*trace.ResourceSpans) []error {
+			return nil
+		}), Options{Protocol: ProtocolGRPC, Generator: generator.Options{Services: []string{"a"}, Depth: 1}, Workers: 2})
+		assert.NoError(t, err)
+		defer target.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		report := target.Run(ctx)
+
+		assert.Zero(t, report.Requests)
+		assert.Zero(t, report.RequestsPerSec)
+		assert.Zero(t, report.SpansPerSec)
+
+		_, err = json.Marshal(report)
+		assert.NoError(t, err)
+	})
+}
+
+func Test_percentiles(t *testing.T) {
+	t.Run("should pick the nearest rank", func(t *testing.T) {
+		var latencies []time.Duration
+
+		for n := 100; n > 0; n-- {
+			latencies = append(latencies, time.Duration(n)*time.Millisecond)
+		}
+
+		assert.Equal(t, Latency{
+			P50: 50 * time.Millisecond,
+			P90: 90 * time.Millisecond,
+			P99: 99 * time.Millisecond,
+			Max: 100 * time.Millisecond,
+		}, percentiles(latencies))
+	})
+
+	t.Run("should return zero without latencies", func(t *testing.T) {
+		assert.Equal(t, Latency{}, percentiles(nil))
+	})
+}
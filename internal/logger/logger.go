@@ -2,12 +2,36 @@ package logger
 
 import (
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var zaplog *zap.Logger
 
+// level is shared with the logger, so changing it takes effect immediately
+var level = zap.NewAtomicLevelAt(zap.DebugLevel)
+
 func init() {
-	zaplog = zap.Must(zap.NewDevelopment())
+	cfg := zap.NewDevelopmentConfig()
+	cfg.Level = level
+
+	zaplog = zap.Must(cfg.Build())
+}
+
+// SetLevel changes the minimum level logged, e.g. INFO or error
+func SetLevel(name string) error {
+	parsed, err := zapcore.ParseLevel(name)
+	if err != nil {
+		return err
+	}
+
+	level.SetLevel(parsed)
+	return nil
+}
+
+// DebugEnabled tells whether debug messages are logged, to skip building
+// messages that would be discarded
+func DebugEnabled() bool {
+	return level.Enabled(zap.DebugLevel)
 }
 
 func Info(msg string) {
@@ -18,6 +42,10 @@ func Debug(msg string) {
 	zaplog.Debug(msg)
 }
 
+func Warn(msg string) {
+	zaplog.Warn(msg)
+}
+
 func Error(msg string) {
 	zaplog.Error(msg)
 }
@@ -0,0 +1,23 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SetLevel(t *testing.T) {
+	t.Cleanup(func() { SetLevel("debug") })
+
+	t.Run("should change the level logged", func(t *testing.T) {
+		assert.NoError(t, SetLevel("INFO"))
+		assert.False(t, DebugEnabled())
+
+		assert.NoError(t, SetLevel("debug"))
+		assert.True(t, DebugEnabled())
+	})
+
+	t.Run("should return error for unknown levels", func(t *testing.T) {
+		assert.Error(t, SetLevel("verbose"))
+	})
+}
@@ -1,33 +1,234 @@
 package orchestrator
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
 
 	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/tracedock/tracedock/internal/client"
 	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/exporter"
+	"github.com/tracedock/tracedock/internal/k8s"
 	"github.com/tracedock/tracedock/internal/logger"
+	"github.com/tracedock/tracedock/internal/pipeline"
+	"github.com/tracedock/tracedock/internal/servicegraph"
+	"github.com/tracedock/tracedock/internal/storage"
+	"github.com/tracedock/tracedock/internal/tail"
+	"github.com/tracedock/tracedock/internal/validation"
 )
 
 type Ingestor struct {
 	Config *config.Config
+
+	// ServiceGraph is only set when enabled in the configuration
+	ServiceGraph *servicegraph.Graph
+
+	// Tail streams the ingested spans, before and after each pipeline,
+	// nothing is published when unset
+	Tail *tail.Hub
+
+	// Kubernetes adds the metadata of the pods to the resources before
+	// they go through the pipelines, nothing is added when unset
+	Kubernetes *k8s.Enricher
+
+	// Storage is the store of the storage exporter, to be queried, only set
+	// when one is configured
+	Storage *storage.Store
+
+	// Validator checks the resources before anything else, only set when
+	// enabled in the configuration
+	Validator *validation.Validator
+
+	exporters map[string]exporter.Exporter
+	pipelines []*pipeline.Pipeline
 }
 
-func NewIngestor(config *config.Config) *Ingestor {
-	return &Ingestor{config}
+func NewIngestor(config *config.Config) (*Ingestor, error) {
+	ingestor := &Ingestor{
+		Config:    config,
+		exporters: make(map[string]exporter.Exporter),
+	}
+
+	if config.ServiceGraph.Enabled {
+		ingestor.ServiceGraph = servicegraph.NewGraph(config.ServiceGraph)
+	}
+
+	if config.Validation.Enabled {
+		validator, err := validation.NewValidator(config.Validation)
+		if err != nil {
+			return nil, err
+		}
+
+		ingestor.Validator = validator
+	}
+
+	for _, expCfg := range config.Exporters {
+		if _, ok := ingestor.exporters[expCfg.Name]; ok {
+			return nil, fmt.Errorf("exporter %q declared more than once", expCfg.Name)
+		}
+
+		exp, err := exporter.New(expCfg)
+		if err != nil {
+			return nil, fmt.Errorf("exporter %q: %w", expCfg.Name, err)
+		}
+
+		if exp, ok := exp.(*exporter.StorageExporter); ok {
+			if ingestor.Storage != nil {
+				return nil, fmt.Errorf("exporter %q: only one storage exporter can be declared", expCfg.Name)
+			}
+
+			ingestor.Storage = exp.Store()
+		}
+
+		ingestor.exporters[expCfg.Name] = exp
+	}
+
+	for _, pipelineCfg := range config.Pipelines {
+		p, err := pipeline.New(pipelineCfg, ingestor.exporters)
+		if err != nil {
+			return nil, err
+		}
+
+		ingestor.pipelines = append(ingestor.pipelines, p)
+	}
+
+	return ingestor, nil
 }
 
-func (i *Ingestor) IngestTrace(rs *trace.ResourceSpans) error {
-	if rs == nil {
-		return nil
+// IngestTraces runs the resources of a batch through the pipelines together,
+// once validated when enabled, the client info is carried by the context
+// given to the processors
+func (i *Ingestor) IngestTraces(ctx context.Context, info client.Info, batch []*trace.ResourceSpans) []error {
+	var errs []error
+
+	ctx = client.NewContext(ctx, info)
+
+	if i.Validator != nil {
+		batch, errs = i.validate(batch)
 	}
 
-	totalSpans := 0
-	for _, ss := range rs.ScopeSpans {
-		totalSpans += len(ss.Spans)
+	for _, rs := range batch {
+		if rs == nil {
+			continue
+		}
+
+		i.Kubernetes.Enrich(ctx, rs)
+
+		if i.ServiceGraph != nil {
+			i.ServiceGraph.Observe(rs)
+		}
+
+		i.Tail.Publish(tail.StageBefore, "", rs)
 	}
 
-	logger.Debug(fmt.Sprintf("trace with %d spans ingested", totalSpans))
+	if logger.DebugEnabled() {
+		totalSpans := 0
+		for _, rs := range batch {
+			for _, ss := range rs.GetScopeSpans() {
+				totalSpans += len(ss.Spans)
+			}
+		}
+
+		logger.Debug(fmt.Sprintf("%d resources with %d spans ingested", len(batch), totalSpans))
+	}
+
+	for n, p := range i.pipelines {
+		var input = batch
+
+		// every pipeline but the last one works on its own copy, so
+		// changes made by one pipeline aren't seen by the others
+		if n < len(i.pipelines)-1 {
+			input = cloneBatch(batch)
+		}
+
+		for k, thisErr := range p.Process(ctx, input) {
+			if thisErr == nil {
+				continue
+			}
+
+			if errs == nil {
+				errs = make([]error, len(batch))
+			}
+
+			errs[k] = errors.Join(errs[k], fmt.Errorf("pipeline %q: %w", p.Name, thisErr))
+		}
+
+		for _, rs := range input {
+			if rs != nil {
+				i.Tail.Publish(tail.StageAfter, p.Name, rs)
+			}
+		}
+	}
+
+	return errs
+}
+
+// validate checks the resources of the batch, returning their errors by
+// index. The resources rejected, or left without spans, are replaced by nil
+// in the returned batch so they aren't processed.
+func (i *Ingestor) validate(batch []*trace.ResourceSpans) ([]*trace.ResourceSpans, []error) {
+	var valid = batch
+	var errs []error
+
+	for n, rs := range batch {
+		var rejected *validation.RejectedError
+
+		if rs == nil {
+			continue
+		}
+
+		// counted upfront as the validator may drop some of them
+		var spans int
+		for _, ss := range rs.ScopeSpans {
+			spans += len(ss.Spans)
+		}
+
+		err := i.Validator.Validate(rs)
+		if err == nil {
+			continue
+		}
+
+		if errs == nil {
+			valid = slices.Clone(batch)
+			errs = make([]error, len(batch))
+		}
+
+		errs[n] = err
+
+		if !errors.As(err, &rejected) || rejected.Rejected >= spans {
+			valid[n] = nil
+		}
+	}
+
+	return valid, errs
+}
+
+// cloneBatch deep copies the resources of the batch
+func cloneBatch(batch []*trace.ResourceSpans) []*trace.ResourceSpans {
+	clone := make([]*trace.ResourceSpans, len(batch))
+
+	for n, rs := range batch {
+		if rs != nil {
+			clone[n] = proto.Clone(rs).(*trace.ResourceSpans)
+		}
+	}
+
+	return clone
+}
+
+// Shutdown releases the resources held by all the exporters
+func (i *Ingestor) Shutdown(ctx context.Context) error {
+	var err error
+
+	for name, exp := range i.exporters {
+		if thisErr := exp.Shutdown(ctx); thisErr != nil {
+			err = errors.Join(err, fmt.Errorf("exporter %q: %w", name, thisErr))
+		}
+	}
 
-	return nil
+	return err
 }
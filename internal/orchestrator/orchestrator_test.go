@@ -1,21 +1,28 @@
 package orchestrator
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	trace "go.opentelemetry.io/proto/otlp/trace/v1"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/tracedock/tracedock/internal/client"
 	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/otlputil"
+	"github.com/tracedock/tracedock/internal/pipeline"
+	"github.com/tracedock/tracedock/internal/tail"
+	"github.com/tracedock/tracedock/internal/validation"
 )
 
-func Test_Ingestor_IngestTrace(t *testing.T) {
-	var ingestor = NewIngestor(config.NewConfig())
+func Test_Ingestor_IngestTraces(t *testing.T) {
+	var ingestor, _ = NewIngestor(config.NewConfig())
 
 	t.Run("should handle nil ResourceSpans", func(t *testing.T) {
 		var rs *trace.ResourceSpans
 
-		assert.NoError(t, ingestor.IngestTrace(rs))
+		assert.Empty(t, ingestor.IngestTraces(context.Background(), client.Info{}, []*trace.ResourceSpans{rs}))
 	})
 
 	t.Run("should handle nil ScopeSpans", func(t *testing.T) {
@@ -23,6 +30,227 @@ func Test_Ingestor_IngestTrace(t *testing.T) {
 			ScopeSpans: nil,
 		}
 
-		assert.NoError(t, ingestor.IngestTrace(rs))
+		assert.Empty(t, ingestor.IngestTraces(context.Background(), client.Info{}, []*trace.ResourceSpans{rs}))
+	})
+
+	t.Run("should isolate changes made by each pipeline", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.Pipelines = []config.ConfigPipeline{
+			{Name: "first", Rules: []config.ConfigPipelineRules{{Provider: "setter", Set: map[string]string{"first": "yes"}}}},
+			{Name: "second", Rules: []config.ConfigPipelineRules{{Provider: "setter", Set: map[string]string{"second": "yes"}}}},
+		}
+
+		ingestor, err := NewIngestor(cfg)
+		assert.NoError(t, err)
+
+		span := &trace.Span{}
+		rs := &trace.ResourceSpans{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{span}}}}
+
+		assert.Empty(t, ingestor.IngestTraces(context.Background(), client.Info{}, []*trace.ResourceSpans{rs}))
+
+		_, first := otlputil.FindAttribute(span.Attributes, "first")
+		_, second := otlputil.FindAttribute(span.Attributes, "second")
+
+		assert.False(t, first)
+		assert.True(t, second)
+	})
+
+	t.Run("should publish spans before and after each pipeline", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.Pipelines = []config.ConfigPipeline{
+			{Name: "default", Rules: []config.ConfigPipelineRules{{Provider: "setter", Set: map[string]string{"env": "prod"}}}},
+		}
+
+		ingestor, err := NewIngestor(cfg)
+		assert.NoError(t, err)
+
+		ingestor.Tail = tail.NewHub(0)
+
+		sub, err := ingestor.Tail.Subscribe(tail.Options{})
+		assert.NoError(t, err)
+		defer sub.Close()
+
+		rs := &trace.ResourceSpans{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{{Name: "GET"}}}}}
+
+		assert.Empty(t, ingestor.IngestTraces(context.Background(), client.Info{}, []*trace.ResourceSpans{rs}))
+		assert.Len(t, sub.Spans(), 2)
+
+		before, after := <-sub.Spans(), <-sub.Spans()
+
+		assert.Equal(t, tail.StageBefore, before.Stage)
+		assert.Empty(t, before.Attributes)
+		assert.Equal(t, tail.StageAfter, after.Stage)
+		assert.Equal(t, "default", after.Pipeline)
+		assert.Equal(t, "prod", after.Attributes["env"])
+	})
+
+	t.Run("should validate the resources before anything else", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.Validation = config.ConfigValidation{Enabled: true, Policy: validation.PolicyDrop}
+		cfg.Pipelines = []config.ConfigPipeline{
+			{Name: "default", Rules: []config.ConfigPipelineRules{{Provider: "setter", Set: map[string]string{"env": "prod"}}}},
+		}
+
+		ingestor, err := NewIngestor(cfg)
+		assert.NoError(t, err)
+
+		ingestor.Tail = tail.NewHub(0)
+
+		sub, err := ingestor.Tail.Subscribe(tail.Options{})
+		assert.NoError(t, err)
+		defer sub.Close()
+
+		valid := &trace.Span{TraceId: []byte{15: 1}, SpanId: []byte{7: 1}}
+		invalid := &trace.Span{SpanId: []byte{7: 2}}
+
+		partial := &trace.ResourceSpans{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{valid, invalid}}}}
+		rejected := &trace.ResourceSpans{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{invalid}}}}
+		batch := []*trace.ResourceSpans{partial, rejected}
+
+		errs := ingestor.IngestTraces(context.Background(), client.Info{}, batch)
+
+		assert.Len(t, errs, 2)
+		assert.Equal(t, &validation.RejectedError{Rejected: 1, Reasons: []string{"invalid trace ID"}}, errs[0])
+		assert.Equal(t, &validation.RejectedError{Rejected: 1, Reasons: []string{"invalid trace ID"}}, errs[1])
+		assert.Equal(t, []*trace.ResourceSpans{partial, rejected}, batch)
+
+		// only the valid span goes through the pipeline
+		assert.Len(t, sub.Spans(), 2)
+
+		_, ok := otlputil.FindAttribute(valid.Attributes, "env")
+		assert.True(t, ok)
+	})
+
+	t.Run("should not process the rejected resources", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.Validation = config.ConfigValidation{Enabled: true, Policy: validation.PolicyReject}
+		cfg.Pipelines = []config.ConfigPipeline{
+			{Name: "default", Rules: []config.ConfigPipelineRules{{Provider: "setter", Set: map[string]string{"env": "prod"}}}},
+		}
+
+		ingestor, err := NewIngestor(cfg)
+		assert.NoError(t, err)
+
+		valid := &trace.Span{TraceId: []byte{15: 1}, SpanId: []byte{7: 1}}
+		invalid := &trace.Span{SpanId: []byte{7: 2}}
+
+		rejected := &trace.ResourceSpans{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{valid, invalid}}}}
+
+		errs := ingestor.IngestTraces(context.Background(), client.Info{}, []*trace.ResourceSpans{rejected})
+
+		assert.Equal(t, []error{&validation.RejectedError{Rejected: 2, Reasons: []string{"invalid trace ID"}}}, errs)
+		assert.Empty(t, valid.Attributes)
+	})
+}
+
+func Test_NewIngestor(t *testing.T) {
+	t.Run("should not create service graph when disabled", func(t *testing.T) {
+		ingestor, err := NewIngestor(config.NewConfig())
+
+		assert.NoError(t, err)
+		assert.Nil(t, ingestor.ServiceGraph)
+	})
+
+	t.Run("should feed service graph when enabled", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.ServiceGraph = config.ConfigServiceGraph{Enabled: true, Wait: time.Minute}
+
+		ingestor, err := NewIngestor(cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, ingestor.ServiceGraph)
+
+		caller := &trace.ResourceSpans{
+			ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{
+				{TraceId: []byte{1}, SpanId: []byte{2}, Kind: trace.Span_SPAN_KIND_CLIENT},
+			}}},
+		}
+		callee := &trace.ResourceSpans{
+			ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{
+				{TraceId: []byte{1}, SpanId: []byte{3}, ParentSpanId: []byte{2}, Kind: trace.Span_SPAN_KIND_SERVER},
+			}}},
+		}
+
+		assert.Empty(t, ingestor.IngestTraces(context.Background(), client.Info{}, []*trace.ResourceSpans{caller}))
+		assert.Empty(t, ingestor.IngestTraces(context.Background(), client.Info{}, []*trace.ResourceSpans{callee}))
+		assert.Len(t, ingestor.ServiceGraph.Snapshot().Edges, 1)
+	})
+
+	t.Run("should create configured exporters", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.Exporters = []config.ConfigExporter{{Name: "jaeger", Type: "otlp", Endpoint: "localhost:4317", Insecure: true}}
+		cfg.Pipelines = []config.ConfigPipeline{
+			{Name: "main", Rules: []config.ConfigPipelineRules{{Provider: "route", Exporters: []string{"jaeger"}}}},
+		}
+
+		ingestor, err := NewIngestor(cfg)
+
+		assert.NoError(t, err)
+		assert.Contains(t, ingestor.exporters, "jaeger")
+		assert.Len(t, ingestor.pipelines, 1)
+		assert.NoError(t, ingestor.Shutdown(context.Background()))
+	})
+
+	t.Run("should return error for duplicated exporters", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.Exporters = []config.ConfigExporter{
+			{Name: "jaeger", Type: "otlp", Endpoint: "localhost:4317"},
+			{Name: "jaeger", Type: "otlp", Endpoint: "localhost:4317"},
+		}
+
+		_, err := NewIngestor(cfg)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should expose the store of the storage exporter", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.Exporters = []config.ConfigExporter{{Name: "recent", Type: "storage", Path: t.TempDir()}}
+
+		ingestor, err := NewIngestor(cfg)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, ingestor.Storage)
+		assert.NoError(t, ingestor.Shutdown(context.Background()))
+	})
+
+	t.Run("should return error for more than one storage exporter", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.Exporters = []config.ConfigExporter{
+			{Name: "recent", Type: "storage", Path: t.TempDir()},
+			{Name: "archive", Type: "storage", Path: t.TempDir()},
+		}
+
+		_, err := NewIngestor(cfg)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error for unknown validation policy", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.Validation = config.ConfigValidation{Enabled: true, Policy: "ignore"}
+
+		_, err := NewIngestor(cfg)
+
+		assert.ErrorIs(t, err, validation.ErrUnknownPolicy)
+	})
+
+	t.Run("should return error for unknown exporter type", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.Exporters = []config.ConfigExporter{{Name: "jaeger", Type: "unknown"}}
+
+		_, err := NewIngestor(cfg)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when pipeline references unknown exporter", func(t *testing.T) {
+		cfg := config.NewConfig()
+		cfg.Pipelines = []config.ConfigPipeline{
+			{Name: "main", Rules: []config.ConfigPipelineRules{{Provider: "route", Exporters: []string{"jaeger"}}}},
+		}
+
+		_, err := NewIngestor(cfg)
+
+		assert.ErrorIs(t, err, pipeline.ErrUnknownExporter)
 	})
 }
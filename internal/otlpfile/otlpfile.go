@@ -0,0 +1,61 @@
+// Package otlpfile reads and writes files holding captured trace data, each
+// record being an ExportTraceServiceRequest encoded either as a line of OTLP
+// JSON or as protobuf prefixed by its length as a 4 bytes big endian integer.
+// Files may be compressed with zstd as a whole.
+package otlpfile
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+
+	CompressionNone = ""
+	CompressionZstd = "zstd"
+)
+
+var (
+	// ErrInvalidFile is returned when a file can't be decoded as captured
+	// trace data
+	ErrInvalidFile = errors.New("invalid trace file")
+)
+
+// zstdMagic starts every zstd frame, it's used to detect compressed files
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Options describes how captured trace data is written
+type Options struct {
+	Path        string
+	Encoding    string
+	Compression string
+
+	// MaxSize rotates the file once it reaches the given bytes
+	MaxSize int64
+
+	// Interval rotates the file once it's open for the given time
+	Interval time.Duration
+}
+
+func (o *Options) validate() error {
+	if o.Path == "" {
+		return fmt.Errorf("%w: path is required", ErrInvalidFile)
+	}
+
+	if o.Encoding == "" {
+		o.Encoding = EncodingJSON
+	}
+
+	if o.Encoding != EncodingJSON && o.Encoding != EncodingProtobuf {
+		return fmt.Errorf("%w: unsupported encoding %q", ErrInvalidFile, o.Encoding)
+	}
+
+	if o.Compression != CompressionNone && o.Compression != CompressionZstd {
+		return fmt.Errorf("%w: unsupported compression %q", ErrInvalidFile, o.Compression)
+	}
+
+	return nil
+}
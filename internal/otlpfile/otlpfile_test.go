@@ -0,0 +1,304 @@
+package otlpfile
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func newRequest(name string, end time.Time) *tracecollectorv1.ExportTraceServiceRequest {
+	return &tracecollectorv1.ExportTraceServiceRequest{ResourceSpans: []*trace.ResourceSpans{{
+		ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{{Name: name, EndTimeUnixNano: uint64(end.UnixNano())}}}},
+	}}}
+}
+
+func readAll(t *testing.T, path string) []*tracecollectorv1.ExportTraceServiceRequest {
+	var requests []*tracecollectorv1.ExportTraceServiceRequest
+
+	r, err := Open(path)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	defer r.Close()
+
+	for {
+		req, err := r.Next()
+		if err == io.EOF {
+			return requests
+		}
+
+		assert.NoError(t, err)
+		requests = append(requests, req)
+	}
+}
+
+func Test_Writer(t *testing.T) {
+	var start = time.Unix(1700000000, 0)
+
+	for _, encoding := range []string{EncodingJSON, EncodingProtobuf} {
+		for _, compression := range []string{CompressionNone, CompressionZstd} {
+			t.Run("should round trip "+encoding+" records compressed with "+compression, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "traces.data")
+
+				w, err := NewWriter(Options{Path: path, Encoding: encoding, Compression: compression})
+				assert.NoError(t, err)
+
+				assert.NoError(t, w.Write(newRequest("first", start)))
+				assert.NoError(t, w.Write(newRequest("second", start)))
+				assert.NoError(t, w.Close())
+
+				r, err := Open(path)
+				assert.NoError(t, err)
+				assert.Equal(t, encoding, r.Encoding())
+				r.Close()
+
+				requests := readAll(t, path)
+
+				assert.Len(t, requests, 2)
+				assert.True(t, proto.Equal(newRequest("second", start), requests[1]))
+			})
+		}
+	}
+
+	t.Run("should append to existing files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "traces.ndjson.zst")
+
+		for _, name := range []string{"first", "second"} {
+			w, err := NewWriter(Options{Path: path, Compression: CompressionZstd})
+			assert.NoError(t, err)
+			assert.NoError(t, w.Write(newRequest(name, start)))
+			assert.NoError(t, w.Close())
+		}
+
+		assert.Len(t, readAll(t, path), 2)
+	})
+
+	t.Run("should rotate files by size", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "traces.ndjson")
+
+		w, err := NewWriter(Options{Path: path, MaxSize: 1})
+		assert.NoError(t, err)
+
+		now := start
+		w.now = func() time.Time { return now }
+		w.opened = now
+
+		assert.NoError(t, w.Write(newRequest("first", start)))
+		now = now.Add(time.Second)
+		assert.NoError(t, w.Write(newRequest("second", start)))
+		assert.NoError(t, w.Close())
+
+		rotated := filepath.Join(dir, "traces-"+start.UTC().Format("20060102T150405.000")+".ndjson")
+
+		assert.Len(t, readAll(t, rotated), 1)
+		assert.Equal(t, "second", readAll(t, path)[0].ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+	})
+
+	t.Run("should keep writing when rotating fails", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "traces.ndjson")
+		rotated := filepath.Join(dir, "traces-"+start.UTC().Format("20060102T150405.000")+".ndjson")
+
+		w, err := NewWriter(Options{Path: path, MaxSize: 1, Compression: CompressionZstd})
+		assert.NoError(t, err)
+
+		w.now = func() time.Time { return start }
+		w.opened = start
+		w.rename = func(string, string) error { return os.ErrPermission }
+
+		assert.NoError(t, w.Write(newRequest("first", start)))
+		assert.NoError(t, w.Write(newRequest("second", start)))
+
+		w.rename = os.Rename
+
+		assert.NoError(t, w.Write(newRequest("third", start)))
+		assert.NoError(t, w.Close())
+
+		assert.Len(t, readAll(t, rotated), 2)
+		assert.Equal(t, "second", readAll(t, rotated)[1].ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+		assert.Equal(t, "third", readAll(t, path)[0].ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+	})
+
+	t.Run("should not replace the files rotated within the same millisecond", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "traces.ndjson")
+
+		w, err := NewWriter(Options{Path: path, MaxSize: 1})
+		assert.NoError(t, err)
+
+		w.now = func() time.Time { return start }
+		w.opened = start
+
+		for _, name := range []string{"first", "second", "third"} {
+			assert.NoError(t, w.Write(newRequest(name, start)))
+		}
+		assert.NoError(t, w.Close())
+
+		rotated := filepath.Join(dir, "traces-"+start.UTC().Format("20060102T150405.000")+"-1.ndjson")
+
+		entries, _ := os.ReadDir(dir)
+
+		assert.Len(t, entries, 3)
+		assert.Equal(t, "second", readAll(t, rotated)[0].ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+	})
+
+	t.Run("should rotate files by time", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "traces.ndjson")
+
+		w, err := NewWriter(Options{Path: path, Interval: time.Minute})
+		assert.NoError(t, err)
+
+		now := start
+		w.now = func() time.Time { return now }
+		w.opened = now
+
+		assert.NoError(t, w.Write(newRequest("first", start)))
+		now = now.Add(30 * time.Second)
+		assert.NoError(t, w.Write(newRequest("second", start)))
+		now = now.Add(30 * time.Second)
+		assert.NoError(t, w.Write(newRequest("third", start)))
+		assert.NoError(t, w.Close())
+
+		entries, _ := os.ReadDir(dir)
+
+		assert.Len(t, entries, 2)
+		assert.Len(t, readAll(t, path), 1)
+	})
+
+	t.Run("should return error for invalid options", func(t *testing.T) {
+		_, err := NewWriter(Options{})
+		assert.ErrorIs(t, err, ErrInvalidFile)
+
+		_, err = NewWriter(Options{Path: "traces", Encoding: "xml"})
+		assert.ErrorIs(t, err, ErrInvalidFile)
+
+		_, err = NewWriter(Options{Path: "traces", Compression: "lz4"})
+		assert.ErrorIs(t, err, ErrInvalidFile)
+	})
+
+	t.Run("should return error writing after close", func(t *testing.T) {
+		w, err := NewWriter(Options{Path: filepath.Join(t.TempDir(), "traces")})
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		assert.ErrorIs(t, w.Write(newRequest("first", start)), os.ErrClosed)
+	})
+}
+
+func Test_Reader(t *testing.T) {
+	t.Run("should skip blank lines", func(t *testing.T) {
+		r, err := NewReader(strings.NewReader("{}\n\n{}\n"))
+		assert.NoError(t, err)
+
+		_, err = r.Next()
+		assert.NoError(t, err)
+		_, err = r.Next()
+		assert.NoError(t, err)
+		_, err = r.Next()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("should return error for truncated protobuf records", func(t *testing.T) {
+		r, err := NewReader(strings.NewReader("\x00\x00\x00\x10abc"))
+		assert.NoError(t, err)
+
+		_, err = r.Next()
+		assert.ErrorIs(t, err, ErrInvalidFile)
+	})
+
+	t.Run("should return error for malformed JSON", func(t *testing.T) {
+		r, err := NewReader(strings.NewReader("{\n"))
+		assert.NoError(t, err)
+
+		_, err = r.Next()
+		assert.ErrorIs(t, err, ErrInvalidFile)
+	})
+
+	t.Run("should return EOF for empty files", func(t *testing.T) {
+		r, err := NewReader(strings.NewReader(""))
+		assert.NoError(t, err)
+
+		_, err = r.Next()
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
+func Test_Replay(t *testing.T) {
+	var start = time.Unix(1700000000, 0)
+	var original = sleep
+
+	t.Cleanup(func() { sleep = original })
+
+	writeFile := func(t *testing.T) string {
+		path := filepath.Join(t.TempDir(), "traces.ndjson")
+
+		w, err := NewWriter(Options{Path: path})
+		assert.NoError(t, err)
+
+		w.Write(newRequest("first", start))
+		w.Write(newRequest("second", start.Add(4*time.Second)))
+		w.Write(newRequest("late", start.Add(time.Second)))
+		w.Write(newRequest("third", start.Add(6*time.Second)))
+		w.Close()
+
+		return path
+	}
+
+	tests := []struct {
+		name           string
+		speed          float64
+		expectedSleeps []time.Duration
+	}{
+		{name: "should keep the original pace", speed: 1, expectedSleeps: []time.Duration{4 * time.Second, 2 * time.Second}},
+		{name: "should accelerate the pace", speed: 2, expectedSleeps: []time.Duration{2 * time.Second, time.Second}},
+		{name: "should not wait without speed", speed: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var sleeps []time.Duration
+			var sent []string
+
+			sleep = func(ctx context.Context, d time.Duration) error {
+				sleeps = append(sleeps, d)
+				return nil
+			}
+
+			r, err := Open(writeFile(t))
+			assert.NoError(t, err)
+			defer r.Close()
+
+			err = Replay(context.Background(), r, tc.speed, func(req *tracecollectorv1.ExportTraceServiceRequest) error {
+				sent = append(sent, req.ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+				return nil
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"first", "second", "late", "third"}, sent)
+			assert.Equal(t, tc.expectedSleeps, sleeps)
+		})
+	}
+
+	t.Run("should stop when sending fails", func(t *testing.T) {
+		r, err := Open(writeFile(t))
+		assert.NoError(t, err)
+		defer r.Close()
+
+		err = Replay(context.Background(), r, 0, func(*tracecollectorv1.ExportTraceServiceRequest) error {
+			return assert.AnError
+		})
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
@@ -0,0 +1,217 @@
+package otlpfile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxRecordSize protects the reader from allocating absurd amounts of
+// memory when a protobuf file is corrupted
+const maxRecordSize = 256 << 20
+
+// Reader reads the records of a file written by Writer, detecting its
+// encoding and compression
+type Reader struct {
+	r        *bufio.Reader
+	closers  []func() error
+	encoding string
+}
+
+// Open opens the file at the given path for reading
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	r.closers = append(r.closers, file.Close)
+
+	return r, nil
+}
+
+// NewReader creates a reader of the records in r
+func NewReader(r io.Reader) (*Reader, error) {
+	var reader = &Reader{r: bufio.NewReader(r)}
+
+	if magic, _ := reader.r.Peek(len(zstdMagic)); bytes.Equal(magic, zstdMagic) {
+		decoder, err := zstd.NewReader(reader.r)
+		if err != nil {
+			return nil, err
+		}
+
+		reader.r = bufio.NewReader(decoder)
+		reader.closers = append(reader.closers, func() error { decoder.Close(); return nil })
+	}
+
+	// a JSON record starts with a brace while a protobuf length would need
+	// to be over 2GB to start with the same byte
+	first, err := reader.r.Peek(1)
+	switch {
+	case errors.Is(err, io.EOF):
+	case err != nil:
+		return nil, err
+	case first[0] == '{':
+		reader.encoding = EncodingJSON
+	default:
+		reader.encoding = EncodingProtobuf
+	}
+
+	return reader, nil
+}
+
+// Encoding returns the detected encoding of the records
+func (r *Reader) Encoding() string {
+	return r.encoding
+}
+
+// Next returns the next record, or io.EOF when there are no more records
+func (r *Reader) Next() (*tracecollectorv1.ExportTraceServiceRequest, error) {
+	var req = &tracecollectorv1.ExportTraceServiceRequest{}
+
+	if r.encoding == EncodingJSON {
+		line, err := r.nextLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := protojson.Unmarshal(line, req); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+
+		return req, nil
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(r.r, size[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: truncated record", ErrInvalidFile)
+		}
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(size[:])
+	if length > maxRecordSize {
+		return nil, fmt.Errorf("%w: record of %d bytes", ErrInvalidFile, length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r.r, payload); err != nil {
+		return nil, fmt.Errorf("%w: truncated record", ErrInvalidFile)
+	}
+
+	if err := proto.Unmarshal(payload, req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	return req, nil
+}
+
+func (r *Reader) nextLine() ([]byte, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+
+		if len(line) > 0 {
+			return line, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close releases the file and decompressor held by the reader
+func (r *Reader) Close() error {
+	var err error
+
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		err = errors.Join(err, r.closers[i]())
+	}
+
+	return err
+}
+
+// RecordTime returns when the request was captured, taken as the latest end
+// time among its spans, as records don't carry the time they were received
+func RecordTime(req *tracecollectorv1.ExportTraceServiceRequest) time.Time {
+	var latest uint64
+
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				latest = max(latest, span.EndTimeUnixNano)
+			}
+		}
+	}
+
+	if latest == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(latest))
+}
+
+// sleep waits for the given duration unless the context is done first
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Replay sends every record of the reader, waiting between them the time
+// that passed between their captures divided by speed, a speed of 2 replays
+// twice as fast while zero sends them without waiting
+func Replay(ctx context.Context, r *Reader, speed float64, send func(*tracecollectorv1.ExportTraceServiceRequest) error) error {
+	var previous time.Time
+
+	for {
+		req, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// records out of order are sent right away
+		if current := RecordTime(req); current.After(previous) {
+			if speed > 0 && !previous.IsZero() {
+				if err := sleep(ctx, time.Duration(float64(current.Sub(previous))/speed)); err != nil {
+					return err
+				}
+			}
+
+			previous = current
+		}
+
+		if err := send(req); err != nil {
+			return err
+		}
+	}
+}
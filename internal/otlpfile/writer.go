@@ -0,0 +1,256 @@
+package otlpfile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/logger"
+)
+
+// countingWriter keeps track of the bytes written to the file
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Writer appends records to a file, rotating it by size and time
+//
+// Rotated files are renamed with the time they were opened, e.g.
+// traces.ndjson becomes traces-20060102T150405.000.ndjson, followed by a
+// counter when that name is taken, and the writing continues at the
+// configured path.
+type Writer struct {
+	mu   sync.Mutex
+	opts Options
+
+	file   *os.File
+	count  *countingWriter
+	zstd   *zstd.Encoder
+	out    io.Writer
+	opened time.Time
+
+	now    func() time.Time
+	rename func(oldpath, newpath string) error
+}
+
+// NewWriter creates a writer, opening or creating the file at the path
+func NewWriter(opts Options) (*Writer, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	w := &Writer{opts: opts, now: time.Now, rename: os.Rename}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write appends the request as a single record
+func (w *Writer) Write(req *tracecollectorv1.ExportTraceServiceRequest) error {
+	var record []byte
+	var err error
+
+	if w.opts.Encoding == EncodingJSON {
+		if record, err = protojson.Marshal(req); err == nil {
+			record = append(record, '\n')
+		}
+	} else {
+		var payload []byte
+		if payload, err = proto.Marshal(req); err == nil {
+			record = binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(payload)), uint32(len(payload)))
+			record = append(record, payload...)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+
+	// the record is written even when closing the rotated file failed
+	var rotateErr error
+
+	if w.shouldRotate() {
+		rotateErr = w.rotate()
+	}
+
+	if _, err := w.out.Write(record); err != nil {
+		return err
+	}
+
+	// each record is flushed so the file is readable while being written
+	if w.zstd != nil {
+		if err := w.zstd.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return rotateErr
+}
+
+// Close flushes and closes the file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.close()
+}
+
+// open opens the file at the path, the writer is only changed once it
+// succeeds so it keeps writing to the previous file otherwise
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	count := &countingWriter{w: file, n: info.Size()}
+	out := io.Writer(count)
+
+	var encoder *zstd.Encoder
+
+	// appending a new frame to an existing zstd file keeps it valid
+	if w.opts.Compression == CompressionZstd {
+		if encoder, err = zstd.NewWriter(count); err != nil {
+			file.Close()
+			return err
+		}
+		out = encoder
+	}
+
+	w.file = file
+	w.count = count
+	w.zstd = encoder
+	w.out = out
+	w.opened = w.now()
+
+	return nil
+}
+
+func (w *Writer) close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	var err error
+
+	if w.zstd != nil {
+		err = w.zstd.Close()
+		w.zstd = nil
+	}
+
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	w.file = nil
+
+	return err
+}
+
+func (w *Writer) shouldRotate() bool {
+	if w.opts.MaxSize > 0 && w.count.n >= w.opts.MaxSize {
+		return true
+	}
+
+	return w.opts.Interval > 0 && w.now().Sub(w.opened) >= w.opts.Interval
+}
+
+// rotate moves the file aside and opens a new one at the path. The current
+// file stays open until the new one is, so when rotating fails the error is
+// logged, the writer keeps appending to it and the next write tries again.
+// Only the errors closing the rotated file are returned.
+func (w *Writer) rotate() error {
+	rotated := rotatedPath(w.opts.Path, w.opened, 0)
+
+	// rotations within the same millisecond would replace each other
+	for n := 1; exists(rotated); n++ {
+		rotated = rotatedPath(w.opts.Path, w.opened, n)
+	}
+
+	if err := w.rename(w.opts.Path, rotated); err != nil {
+		logger.Error(fmt.Sprintf("error rotating %s: %v", w.opts.Path, err))
+		return nil
+	}
+
+	file, encoder := w.file, w.zstd
+
+	if err := w.open(); err != nil {
+		// moved back, so the records keep going to the path
+		w.rename(rotated, w.opts.Path)
+		logger.Error(fmt.Sprintf("error rotating %s: %v", w.opts.Path, err))
+		return nil
+	}
+
+	var err error
+
+	if encoder != nil {
+		err = encoder.Close()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("closing rotated %s: %w", rotated, err)
+	}
+
+	return nil
+}
+
+// rotatedPath inserts the time, and the counter when positive, before the
+// extensions of the file name
+func rotatedPath(path string, t time.Time, n int) string {
+	dir, base := filepath.Split(path)
+	name, ext, _ := strings.Cut(base, ".")
+
+	if ext != "" {
+		ext = "." + ext
+	}
+
+	suffix := t.UTC().Format("20060102T150405.000")
+	if n > 0 {
+		suffix += "-" + strconv.Itoa(n)
+	}
+
+	return filepath.Join(dir, name+"-"+suffix+ext)
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
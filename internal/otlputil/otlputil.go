@@ -0,0 +1,117 @@
+// Package otlputil holds helpers reading and changing the OTLP trace data,
+// shared by the packages processing, exporting and storing it
+package otlputil
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	// AttributeServiceName is the resource attribute naming the service
+	AttributeServiceName = "service.name"
+
+	// UnknownService is the name used for resources without service.name
+	UnknownService = "unknown_service"
+)
+
+// FindAttribute returns the value of the attribute with the given key
+func FindAttribute(attrs []*common.KeyValue, key string) (*common.AnyValue, bool) {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// SetAttribute replaces the value of the attribute with the given key,
+// appending it when it doesn't exist yet
+func SetAttribute(attrs []*common.KeyValue, key string, value *common.AnyValue) []*common.KeyValue {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			kv.Value = value
+			return attrs
+		}
+	}
+
+	return append(attrs, &common.KeyValue{Key: key, Value: value})
+}
+
+// DeleteAttribute removes the attribute with the given key
+func DeleteAttribute(attrs []*common.KeyValue, key string) []*common.KeyValue {
+	for i, kv := range attrs {
+		if kv.Key == key {
+			return append(attrs[:i], attrs[i+1:]...)
+		}
+	}
+
+	return attrs
+}
+
+// ValueString returns the textual representation of an attribute value
+func ValueString(value *common.AnyValue) string {
+	switch v := value.GetValue().(type) {
+	case *common.AnyValue_StringValue:
+		return v.StringValue
+	case *common.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *common.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *common.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'f', -1, 64)
+	case *common.AnyValue_BytesValue:
+		return string(v.BytesValue)
+	case *common.AnyValue_ArrayValue:
+		var values []string
+		for _, item := range v.ArrayValue.GetValues() {
+			values = append(values, ValueString(item))
+		}
+		return "[" + strings.Join(values, ",") + "]"
+	case *common.AnyValue_KvlistValue:
+		var values []string
+		for _, kv := range v.KvlistValue.GetValues() {
+			values = append(values, kv.Key+":"+ValueString(kv.Value))
+		}
+		return "{" + strings.Join(values, ",") + "}"
+	default:
+		return ""
+	}
+}
+
+// ServiceName returns the service.name of the resource, UnknownService when
+// it's missing or empty
+func ServiceName(rs *trace.ResourceSpans) string {
+	if value, ok := FindAttribute(rs.GetResource().GetAttributes(), AttributeServiceName); ok {
+		if name := ValueString(value); name != "" {
+			return name
+		}
+	}
+
+	return UnknownService
+}
+
+// SpanKindName returns the lowercase name of the span kind, e.g. server
+func SpanKindName(kind trace.Span_SpanKind) string {
+	return strings.ToLower(strings.TrimPrefix(kind.String(), "SPAN_KIND_"))
+}
+
+// StatusCodeName returns the lowercase name of the status code, e.g. error
+func StatusCodeName(code trace.Status_StatusCode) string {
+	return strings.ToLower(strings.TrimPrefix(code.String(), "STATUS_CODE_"))
+}
+
+// SpanDuration returns how long the span lasted, zero when it ends before
+// it starts
+func SpanDuration(span *trace.Span) time.Duration {
+	if span.EndTimeUnixNano < span.StartTimeUnixNano {
+		return 0
+	}
+
+	return time.Duration(span.EndTimeUnixNano - span.StartTimeUnixNano)
+}
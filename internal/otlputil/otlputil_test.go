@@ -0,0 +1,90 @@
+package otlputil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func stringValue(s string) *common.AnyValue {
+	return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: s}}
+}
+
+func Test_ValueString(t *testing.T) {
+	tests := []struct {
+		value    *common.AnyValue
+		expected string
+	}{
+		{stringValue("text"), "text"},
+		{&common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: true}}, "true"},
+		{&common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 42}}, "42"},
+		{&common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: 1.5}}, "1.5"},
+		{&common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{
+			Values: []*common.AnyValue{stringValue("a"), stringValue("b")},
+		}}}, "[a,b]"},
+		{&common.AnyValue{Value: &common.AnyValue_KvlistValue{KvlistValue: &common.KeyValueList{
+			Values: []*common.KeyValue{{Key: "key", Value: stringValue("value")}},
+		}}}, "{key:value}"},
+		{nil, ""},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, ValueString(tc.value))
+	}
+}
+
+func Test_SetAttribute(t *testing.T) {
+	t.Run("should replace the value of existing attributes", func(t *testing.T) {
+		attrs := []*common.KeyValue{{Key: "env", Value: stringValue("dev")}}
+
+		attrs = SetAttribute(attrs, "env", stringValue("prod"))
+
+		assert.Len(t, attrs, 1)
+		assert.Equal(t, "prod", attrs[0].Value.GetStringValue())
+	})
+
+	t.Run("should append missing attributes", func(t *testing.T) {
+		attrs := SetAttribute(nil, "env", stringValue("prod"))
+
+		assert.Equal(t, []*common.KeyValue{{Key: "env", Value: stringValue("prod")}}, attrs)
+	})
+}
+
+func Test_DeleteAttribute(t *testing.T) {
+	attrs := []*common.KeyValue{{Key: "env", Value: stringValue("dev")}, {Key: "team", Value: stringValue("core")}}
+
+	attrs = DeleteAttribute(attrs, "env")
+	attrs = DeleteAttribute(attrs, "missing")
+
+	assert.Equal(t, []*common.KeyValue{{Key: "team", Value: stringValue("core")}}, attrs)
+}
+
+func Test_ServiceName(t *testing.T) {
+	t.Run("should return the service name of the resource", func(t *testing.T) {
+		rs := &trace.ResourceSpans{Resource: &resource.Resource{Attributes: []*common.KeyValue{
+			{Key: AttributeServiceName, Value: stringValue("checkout")},
+		}}}
+
+		assert.Equal(t, "checkout", ServiceName(rs))
+	})
+
+	t.Run("should return unknown_service without service name", func(t *testing.T) {
+		assert.Equal(t, UnknownService, ServiceName(&trace.ResourceSpans{}))
+		assert.Equal(t, UnknownService, ServiceName(&trace.ResourceSpans{Resource: &resource.Resource{Attributes: []*common.KeyValue{
+			{Key: AttributeServiceName, Value: stringValue("")},
+		}}}))
+	})
+}
+
+func Test_SpanDuration(t *testing.T) {
+	assert.Equal(t, int64(5), SpanDuration(&trace.Span{StartTimeUnixNano: 10, EndTimeUnixNano: 15}).Nanoseconds())
+	assert.Zero(t, SpanDuration(&trace.Span{StartTimeUnixNano: 15, EndTimeUnixNano: 10}))
+}
+
+func Test_Names(t *testing.T) {
+	assert.Equal(t, "server", SpanKindName(trace.Span_SPAN_KIND_SERVER))
+	assert.Equal(t, "error", StatusCodeName(trace.Status_STATUS_CODE_ERROR))
+}
@@ -0,0 +1,197 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/metrics"
+)
+
+const (
+	// DefaultDedupWindow is how long the spans are remembered when no window
+	// is configured, covering the retries of the OTLP exporters
+	DefaultDedupWindow = 5 * time.Minute
+
+	// DefaultDedupMaxItems is the number of spans remembered when no maximum
+	// is configured
+	DefaultDedupMaxItems = 100000
+)
+
+var duplicateSpans = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "pipeline",
+	Name:      "duplicate_spans_total",
+	Help:      "Total of duplicate spans dropped by dedup rules",
+}, []string{"pipeline", "rule"})
+
+func init() {
+	metrics.MustRegister(duplicateSpans)
+}
+
+// spanKey identifies a span by its trace and span IDs
+type spanKey [24]byte
+
+func newSpanKey(span *trace.Span) (spanKey, bool) {
+	var key spanKey
+
+	if len(span.TraceId) == 0 || len(span.SpanId) == 0 {
+		return key, false
+	}
+
+	copy(key[:16], span.TraceId)
+	copy(key[16:], span.SpanId)
+
+	return key, true
+}
+
+// dedupRule drops the matching spans already seen within the window, e.g.
+// sent again by clients retrying a request that partially failed
+//
+// Spans are remembered in two generations of half the window and half the
+// maximum each, the oldest generation being forgotten when the newest one is
+// full or older than half the window. Memory stays bounded at the cost of a
+// shorter window under high cardinality. Scopes left without spans are
+// removed, and a resource left without scopes doesn't go further in the
+// pipeline.
+//
+// The spans of the resources failing further in the pipeline, e.g. because
+// an exporter is down, are forgotten so their retries aren't dropped.
+type dedupRule struct {
+	matcher    *Matcher
+	duplicates prometheus.Counter
+
+	mu       sync.Mutex
+	window   time.Duration
+	capacity int
+	current  map[spanKey]struct{}
+	previous map[spanKey]struct{}
+	rotated  time.Time
+	now      func() time.Time
+
+	// pending are the spans remembered for the resources still going
+	// through the pipeline
+	pending map[*trace.ResourceSpans][]spanKey
+}
+
+func newDedupRule(cfg config.ConfigPipelineRules, matcher *Matcher, pipeline, rule string) *dedupRule {
+	var r = &dedupRule{
+		matcher:    matcher,
+		duplicates: duplicateSpans.WithLabelValues(pipeline, rule),
+		window:     cfg.Window,
+		capacity:   cfg.MaxItems / 2,
+		current:    make(map[spanKey]struct{}),
+		now:        time.Now,
+		pending:    make(map[*trace.ResourceSpans][]spanKey),
+	}
+
+	if r.window <= 0 {
+		r.window = DefaultDedupWindow
+	}
+
+	if r.capacity <= 0 {
+		r.capacity = DefaultDedupMaxItems / 2
+	}
+
+	r.rotated = r.now()
+
+	return r
+}
+
+func (r *dedupRule) Apply(ctx context.Context, rs *trace.ResourceSpans) (bool, error) {
+	var duplicates int
+	var remembered []spanKey
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, ss := range rs.ScopeSpans {
+		var kept = ss.Spans[:0]
+
+		for _, span := range ss.Spans {
+			// spans without IDs can't be told apart
+			if key, ok := newSpanKey(span); ok && r.matcher.MatchSpan(rs, ss, span) {
+				if r.seen(key) {
+					duplicates++
+					continue
+				}
+
+				remembered = append(remembered, key)
+			}
+
+			kept = append(kept, span)
+		}
+
+		clear(ss.Spans[len(kept):])
+		ss.Spans = kept
+	}
+
+	if len(remembered) > 0 {
+		r.pending[rs] = remembered
+	}
+
+	r.duplicates.Add(float64(duplicates))
+
+	return pruneEmpty(rs), nil
+}
+
+// Finish forgets the spans of the resources that failed, so they are
+// accepted when retried
+func (r *dedupRule) Finish(batch []*trace.ResourceSpans, errs []error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for n, rs := range batch {
+		keys, ok := r.pending[rs]
+		if !ok {
+			continue
+		}
+
+		delete(r.pending, rs)
+
+		if n >= len(errs) || errs[n] == nil {
+			continue
+		}
+
+		for _, key := range keys {
+			delete(r.current, key)
+			delete(r.previous, key)
+		}
+	}
+}
+
+// seen reports whether the span was already seen, remembering it otherwise
+func (r *dedupRule) seen(key spanKey) bool {
+	if now := r.now(); now.Sub(r.rotated) >= r.window/2 || len(r.current) >= r.capacity {
+		r.rotate(now)
+	}
+
+	if _, ok := r.current[key]; ok {
+		return true
+	}
+
+	if _, ok := r.previous[key]; ok {
+		return true
+	}
+
+	r.current[key] = struct{}{}
+
+	return false
+}
+
+// rotate starts a new generation, forgetting the oldest one, or both when
+// nothing was seen for the whole window
+func (r *dedupRule) rotate(now time.Time) {
+	if now.Sub(r.rotated) >= r.window {
+		r.previous = nil
+	} else {
+		r.previous = r.current
+	}
+
+	r.current = make(map[spanKey]struct{}, len(r.previous))
+	r.rotated = now
+}
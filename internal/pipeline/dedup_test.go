@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/exporter"
+)
+
+func newDedup(t *testing.T, cfg config.ConfigPipelineRules) *dedupRule {
+	matcher, err := NewMatcher(cfg.Match, cfg.Missing, cfg.When)
+	assert.NoError(t, err)
+
+	return newDedupRule(cfg, matcher, t.Name(), "0")
+}
+
+func newIdentifiedSpan(traceID, spanID byte) *trace.Span {
+	return &trace.Span{TraceId: []byte{15: traceID}, SpanId: []byte{7: spanID}}
+}
+
+func Test_DedupRule(t *testing.T) {
+	t.Run("should drop the spans already seen", func(t *testing.T) {
+		rule := newDedup(t, config.ConfigPipelineRules{})
+
+		first := newResourceSpans(nil, newIdentifiedSpan(1, 1), newIdentifiedSpan(1, 2), newIdentifiedSpan(1, 1))
+		retried := newResourceSpans(nil, newIdentifiedSpan(1, 2), newIdentifiedSpan(2, 2))
+
+		next, err := rule.Apply(context.Background(), first)
+		assert.NoError(t, err)
+		assert.True(t, next)
+		assert.Equal(t, []*trace.Span{newIdentifiedSpan(1, 1), newIdentifiedSpan(1, 2)}, first.ScopeSpans[0].Spans)
+
+		next, err = rule.Apply(context.Background(), retried)
+		assert.NoError(t, err)
+		assert.True(t, next)
+		assert.Equal(t, []*trace.Span{newIdentifiedSpan(2, 2)}, retried.ScopeSpans[0].Spans)
+
+		assert.Equal(t, 2.0, testutil.ToFloat64(rule.duplicates))
+	})
+
+	t.Run("should stop resources left without spans", func(t *testing.T) {
+		rule := newDedup(t, config.ConfigPipelineRules{})
+
+		_, err := rule.Apply(context.Background(), newResourceSpans(nil, newIdentifiedSpan(1, 1)))
+		assert.NoError(t, err)
+
+		rs := newResourceSpans(nil, newIdentifiedSpan(1, 1))
+		next, err := rule.Apply(context.Background(), rs)
+
+		assert.NoError(t, err)
+		assert.False(t, next)
+		assert.Empty(t, rs.ScopeSpans)
+	})
+
+	t.Run("should keep spans not matching or without IDs", func(t *testing.T) {
+		rule := newDedup(t, config.ConfigPipelineRules{When: `span.name == "GET /"`})
+
+		unnamed := newIdentifiedSpan(1, 1)
+		anonymous := &trace.Span{Name: "GET /"}
+		rs := newResourceSpans(nil, unnamed, unnamed, anonymous, anonymous)
+
+		_, err := rule.Apply(context.Background(), rs)
+
+		assert.NoError(t, err)
+		assert.Len(t, rs.ScopeSpans[0].Spans, 4)
+	})
+
+	t.Run("should forget the spans after the window", func(t *testing.T) {
+		now := time.Now()
+		rule := newDedup(t, config.ConfigPipelineRules{Window: time.Minute})
+		rule.now = func() time.Time { return now }
+
+		seen := func(span *trace.Span) bool {
+			rs := newResourceSpans(nil, span)
+			next, _ := rule.Apply(context.Background(), rs)
+			return !next
+		}
+
+		assert.False(t, seen(newIdentifiedSpan(1, 1)))
+
+		now = now.Add(40 * time.Second)
+		assert.True(t, seen(newIdentifiedSpan(1, 1)))
+		assert.False(t, seen(newIdentifiedSpan(1, 2)))
+
+		now = now.Add(40 * time.Second)
+		assert.False(t, seen(newIdentifiedSpan(1, 1)))
+		assert.True(t, seen(newIdentifiedSpan(1, 2)))
+
+		now = now.Add(2 * time.Minute)
+		assert.False(t, seen(newIdentifiedSpan(1, 2)))
+	})
+
+	t.Run("should remember at most the maximum of spans", func(t *testing.T) {
+		rule := newDedup(t, config.ConfigPipelineRules{MaxItems: 100})
+
+		for i := range 1000 {
+			_, err := rule.Apply(context.Background(), newResourceSpans(nil, newIdentifiedSpan(byte(i>>8), byte(i))))
+			assert.NoError(t, err)
+		}
+
+		assert.LessOrEqual(t, len(rule.current)+len(rule.previous), 100)
+
+		rs := newResourceSpans(nil, newIdentifiedSpan(0, 0), newIdentifiedSpan(3, 231))
+		_, err := rule.Apply(context.Background(), rs)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*trace.Span{newIdentifiedSpan(0, 0)}, rs.ScopeSpans[0].Spans)
+	})
+	t.Run("should deliver the spans retried after a failing export", func(t *testing.T) {
+		vendor := &fakeExporter{err: assert.AnError}
+		p, err := New(config.ConfigPipeline{Name: "dedup", Rules: []config.ConfigPipelineRules{
+			{Provider: "dedup"},
+			{Provider: "route", Exporters: []string{"vendor"}},
+		}}, map[string]exporter.Exporter{"vendor": vendor})
+		assert.NoError(t, err)
+
+		err = process(context.Background(), p, newResourceSpans(nil, newIdentifiedSpan(1, 1)))
+		assert.ErrorIs(t, err, assert.AnError)
+
+		vendor.err = nil
+
+		err = process(context.Background(), p, newResourceSpans(nil, newIdentifiedSpan(1, 1)))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, vendor.calls)
+
+		err = process(context.Background(), p, newResourceSpans(nil, newIdentifiedSpan(1, 1)))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, vendor.calls)
+	})
+}
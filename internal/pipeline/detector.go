@@ -0,0 +1,248 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+var (
+	// ErrUnknownDetector is returned when a detector rule uses a detector
+	// that isn't available
+	ErrUnknownDetector = errors.New("unknown detector")
+)
+
+// Paths the container ID is read from, the cgroup of the process for cgroup
+// v1 and its mounts for cgroup v2
+const (
+	cgroupPath    = "/proc/self/cgroup"
+	mountinfoPath = "/proc/self/mountinfo"
+)
+
+// defaultDetectors are used when a rule lists none, the attributes given
+// through the environment taking precedence
+var defaultDetectors = []string{"env", "host", "os", "container"}
+
+// detectors describe where TraceDock runs, they are run once when first used
+// and cached as it doesn't change while running
+var detectors = map[string]func() []attribute{
+	"env":       sync.OnceValue(detectEnv),
+	"host":      sync.OnceValue(detectHost),
+	"os":        sync.OnceValue(detectOS),
+	"container": sync.OnceValue(detectContainer),
+}
+
+// containerIDPattern matches the container ID ending a cgroup path, e.g.
+// /docker/<id> or /kubepods/.../cri-containerd-<id>.scope
+var containerIDPattern = regexp.MustCompile(`([0-9a-f]{64})(?:\.scope)?$`)
+
+// mountedContainerIDPattern matches the container ID in the files Docker
+// mounts into its containers, e.g. /var/lib/docker/containers/<id>/hostname
+var mountedContainerIDPattern = regexp.MustCompile(`/containers/([0-9a-f]{64})/`)
+
+// attribute is a detected resource attribute
+type attribute struct {
+	key   string
+	value string
+}
+
+// detectorRule adds the attributes of the host TraceDock runs on to the
+// matching resources, keeping the ones already set unless overriding
+type detectorRule struct {
+	matcher    *Matcher
+	attributes []attribute
+	override   bool
+}
+
+func newDetectorRule(cfg config.ConfigPipelineRules, matcher *Matcher) (*detectorRule, error) {
+	if matcher.SpanLevel() {
+		return nil, fmt.Errorf("%w: detector rules can only inspect resources", ErrInvalidMatch)
+	}
+
+	var r = &detectorRule{matcher: matcher, override: cfg.Override}
+
+	names := cfg.Detectors
+	if len(names) == 0 {
+		names = defaultDetectors
+	}
+
+	for _, name := range names {
+		detect, ok := detectors[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownDetector, name)
+		}
+
+		// the first detector providing an attribute wins
+		for _, attr := range detect() {
+			if !r.has(attr.key) {
+				r.attributes = append(r.attributes, attr)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+func (r *detectorRule) has(key string) bool {
+	for _, attr := range r.attributes {
+		if attr.key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *detectorRule) Apply(ctx context.Context, rs *trace.ResourceSpans) (bool, error) {
+	if len(r.attributes) == 0 || !r.matcher.MatchResource(rs) {
+		return true, nil
+	}
+
+	if rs.Resource == nil {
+		rs.Resource = &resource.Resource{}
+	}
+
+	for _, attr := range r.attributes {
+		if _, ok := otlputil.FindAttribute(rs.Resource.Attributes, attr.key); ok && !r.override {
+			continue
+		}
+
+		// values are created for each resource as later rules may change them
+		rs.Resource.Attributes = otlputil.SetAttribute(rs.Resource.Attributes, attr.key, &common.AnyValue{
+			Value: &common.AnyValue_StringValue{StringValue: attr.value},
+		})
+	}
+
+	return true, nil
+}
+
+// detectEnv returns the attributes given in OTEL_RESOURCE_ATTRIBUTES
+func detectEnv() []attribute {
+	return parseResourceAttributes(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"))
+}
+
+// parseResourceAttributes parses a list of key=value pairs separated by
+// commas, with percent encoded values, skipping the invalid ones
+//
+// For more details: https://opentelemetry.io/docs/specs/otel/resource/sdk/#specifying-resource-information-via-an-environment-variable
+func parseResourceAttributes(env string) []attribute {
+	var attrs []attribute
+
+	for pair := range strings.SplitSeq(env, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+
+		if !ok || key == "" {
+			continue
+		}
+
+		decoded, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+
+		attrs = append(attrs, attribute{key: key, value: decoded})
+	}
+
+	return attrs
+}
+
+func detectHost() []attribute {
+	attrs := []attribute{{key: "host.arch", value: hostArch(runtime.GOARCH)}}
+
+	if name, err := os.Hostname(); err == nil && name != "" {
+		attrs = append(attrs, attribute{key: "host.name", value: name})
+	}
+
+	return attrs
+}
+
+// hostArch maps the Go architectures to the host.arch values of the
+// semantic conventions
+func hostArch(arch string) string {
+	switch arch {
+	case "386":
+		return "x86"
+	case "arm":
+		return "arm32"
+	default:
+		return arch
+	}
+}
+
+func detectOS() []attribute {
+	return []attribute{{key: "os.type", value: runtime.GOOS}}
+}
+
+func detectContainer() []attribute {
+	for _, source := range []struct {
+		path  string
+		parse func(io.Reader) string
+	}{
+		{cgroupPath, containerIDFromCgroup},
+		{mountinfoPath, containerIDFromMountinfo},
+	} {
+		file, err := os.Open(source.path)
+		if err != nil {
+			continue
+		}
+
+		id := source.parse(file)
+		file.Close()
+
+		if id != "" {
+			return []attribute{{key: "container.id", value: id}}
+		}
+	}
+
+	return nil
+}
+
+// containerIDFromCgroup reads the container ID from the cgroup paths of the
+// process, only set with cgroup v1 or without a cgroup namespace
+func containerIDFromCgroup(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		// hierarchy-ID:controllers:path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) < 3 {
+			continue
+		}
+
+		if match := containerIDPattern.FindStringSubmatch(parts[2]); match != nil {
+			return match[1]
+		}
+	}
+
+	return ""
+}
+
+// containerIDFromMountinfo reads the container ID from the mounts of the
+// process, for cgroup v2 where the cgroup path is hidden
+func containerIDFromMountinfo(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		if match := mountedContainerIDPattern.FindStringSubmatch(scanner.Text()); match != nil {
+			return match[1]
+		}
+	}
+
+	return ""
+}
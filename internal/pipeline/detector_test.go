@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+const testContainerID = "3f4ae8f5c2a9a1b0e6f3a2c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4"
+
+func Test_newDetectorRule(t *testing.T) {
+	t.Run("should detect with all detectors by default", func(t *testing.T) {
+		rule, err := newDetectorRule(config.ConfigPipelineRules{}, &Matcher{})
+		assert.NoError(t, err)
+
+		assert.True(t, rule.has("os.type"))
+		assert.True(t, rule.has("host.arch"))
+	})
+
+	t.Run("should only use the listed detectors", func(t *testing.T) {
+		rule, err := newDetectorRule(config.ConfigPipelineRules{Detectors: []string{"os"}}, &Matcher{})
+		assert.NoError(t, err)
+
+		assert.Equal(t, []attribute{{key: "os.type", value: runtime.GOOS}}, rule.attributes)
+	})
+
+	t.Run("should return error for unknown detectors", func(t *testing.T) {
+		_, err := newDetectorRule(config.ConfigPipelineRules{Detectors: []string{"gcp"}}, &Matcher{})
+
+		assert.ErrorIs(t, err, ErrUnknownDetector)
+	})
+
+	t.Run("should return error when matching spans", func(t *testing.T) {
+		_, err := New(config.ConfigPipeline{Name: "main", Rules: []config.ConfigPipelineRules{{
+			Provider: "detector",
+			Match:    map[string]map[string]string{"attributes": {"http.route": ".*"}},
+		}}}, nil)
+
+		assert.ErrorIs(t, err, ErrInvalidMatch)
+	})
+}
+
+func Test_DetectorRule_Apply(t *testing.T) {
+	var detected = []attribute{{key: "host.name", value: "collector-0"}, {key: "os.type", value: "linux"}}
+
+	t.Run("should keep the attributes already set", func(t *testing.T) {
+		rule := &detectorRule{matcher: &Matcher{}, attributes: detected}
+		rs := newResourceSpans(map[string]string{"host.name": "checkout-1"})
+
+		next, err := rule.Apply(context.Background(), rs)
+
+		assert.NoError(t, err)
+		assert.True(t, next)
+		assert.Equal(t, "checkout-1", resourceAttribute(rs, "host.name"))
+		assert.Equal(t, "linux", resourceAttribute(rs, "os.type"))
+	})
+
+	t.Run("should override the attributes already set", func(t *testing.T) {
+		rule := &detectorRule{matcher: &Matcher{}, attributes: detected, override: true}
+		rs := newResourceSpans(map[string]string{"host.name": "checkout-1"})
+
+		_, err := rule.Apply(context.Background(), rs)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "collector-0", resourceAttribute(rs, "host.name"))
+	})
+
+	t.Run("should add the attributes to resources without any", func(t *testing.T) {
+		rule := &detectorRule{matcher: &Matcher{}, attributes: detected}
+		rs := &trace.ResourceSpans{}
+
+		_, err := rule.Apply(context.Background(), rs)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "collector-0", resourceAttribute(rs, "host.name"))
+	})
+
+	t.Run("should only change the matching resources", func(t *testing.T) {
+		matcher, err := NewMatcher(map[string]map[string]string{"resource": {"service.name": "checkout"}}, nil, "")
+		assert.NoError(t, err)
+
+		rule := &detectorRule{matcher: matcher, attributes: detected}
+		rs := newResourceSpans(map[string]string{"service.name": "cart"})
+
+		_, err = rule.Apply(context.Background(), rs)
+
+		assert.NoError(t, err)
+		assert.Empty(t, resourceAttribute(rs, "host.name"))
+	})
+}
+
+func Test_parseResourceAttributes(t *testing.T) {
+	t.Run("should parse the pairs decoding their values", func(t *testing.T) {
+		attrs := parseResourceAttributes(" deployment.environment=prod, region = eu%2Cwest ,invalid,=empty,team=a%ZZ")
+
+		assert.Equal(t, []attribute{
+			{key: "deployment.environment", value: "prod"},
+			{key: "region", value: "eu,west"},
+		}, attrs)
+	})
+
+	t.Run("should return nothing when unset", func(t *testing.T) {
+		assert.Empty(t, parseResourceAttributes(""))
+	})
+}
+
+func Test_containerIDFromCgroup(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cgroup string
+		id     string
+	}{
+		{"docker", "12:pids:/docker/" + testContainerID + "\n11:cpu:/docker/" + testContainerID, testContainerID},
+		{"kubernetes", "0::/kubepods.slice/kubepods-pod1.slice/cri-containerd-" + testContainerID + ".scope", testContainerID},
+		{"cgroup namespace", "0::/", ""},
+		{"host", "0::/user.slice/user-1000.slice/session-2.scope", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run("should read the container ID with "+tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.id, containerIDFromCgroup(strings.NewReader(tc.cgroup)))
+		})
+	}
+}
+
+func Test_containerIDFromMountinfo(t *testing.T) {
+	t.Run("should read the container ID from the mounted files", func(t *testing.T) {
+		mountinfo := "613 612 0:56 / / rw,relatime - overlay overlay rw\n" +
+			"625 612 254:1 /var/lib/docker/containers/" + testContainerID + "/hostname /etc/hostname rw,relatime - ext4 /dev/vda1 rw\n"
+
+		assert.Equal(t, testContainerID, containerIDFromMountinfo(strings.NewReader(mountinfo)))
+	})
+
+	t.Run("should return nothing outside containers", func(t *testing.T) {
+		assert.Empty(t, containerIDFromMountinfo(strings.NewReader("22 1 254:1 / / rw,relatime - ext4 /dev/vda1 rw\n")))
+	})
+}
+
+func resourceAttribute(rs *trace.ResourceSpans, key string) string {
+	value, _ := otlputil.FindAttribute(rs.GetResource().GetAttributes(), key)
+	return otlputil.ValueString(value)
+}
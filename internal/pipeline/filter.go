@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/metrics"
+)
+
+var droppedItems = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "pipeline",
+	Name:      "dropped_items_total",
+	Help:      "Total of items dropped by filter rules",
+}, []string{"pipeline", "rule", "item"})
+
+func init() {
+	metrics.MustRegister(droppedItems)
+}
+
+// filterRule removes the matching items from the resource
+//
+// Depending on what it drops, the rule removes whole resources, spans or
+// the events and links of spans. When pruning, scopes left without spans
+// are removed as well, and a resource left without scopes doesn't go
+// further in the pipeline.
+type filterRule struct {
+	matcher *Matcher
+	drop    string
+	prune   bool
+	dropped prometheus.Counter
+}
+
+func newFilterRule(cfg config.ConfigPipelineRules, matcher *Matcher, pipeline, rule string) (*filterRule, error) {
+	var r = &filterRule{matcher: matcher, drop: cfg.Drop, prune: cfg.Prune}
+
+	if r.drop == "" {
+		r.drop = "spans"
+	}
+
+	switch r.drop {
+	case "resources":
+		if matcher.SpanLevel() {
+			return nil, fmt.Errorf("%w: dropping resources can only inspect resources", ErrInvalidMatch)
+		}
+
+	case "spans", "events", "links":
+
+	default:
+		return nil, fmt.Errorf("unknown item to drop %q", r.drop)
+	}
+
+	r.dropped = droppedItems.WithLabelValues(pipeline, rule, r.drop)
+
+	return r, nil
+}
+
+func (r *filterRule) Apply(ctx context.Context, rs *trace.ResourceSpans) (bool, error) {
+	var dropped int
+
+	if r.drop == "resources" {
+		if !r.matcher.MatchResource(rs) {
+			return true, nil
+		}
+
+		r.dropped.Inc()
+		rs.ScopeSpans = nil
+
+		return false, nil
+	}
+
+	for _, ss := range rs.ScopeSpans {
+		switch r.drop {
+		case "spans":
+			var kept = ss.Spans[:0]
+
+			for _, span := range ss.Spans {
+				if r.matcher.MatchSpan(rs, ss, span) {
+					dropped++
+					continue
+				}
+
+				kept = append(kept, span)
+			}
+
+			clear(ss.Spans[len(kept):])
+			ss.Spans = kept
+
+		case "events":
+			for _, span := range ss.Spans {
+				var kept = span.Events[:0]
+
+				for _, event := range span.Events {
+					if r.matcher.MatchEvent(rs, ss, span, event) {
+						dropped++
+						continue
+					}
+
+					kept = append(kept, event)
+				}
+
+				clear(span.Events[len(kept):])
+				span.Events = kept
+			}
+
+		case "links":
+			for _, span := range ss.Spans {
+				var kept = span.Links[:0]
+
+				for _, link := range span.Links {
+					if r.matcher.MatchLink(rs, ss, span, link) {
+						dropped++
+						continue
+					}
+
+					kept = append(kept, link)
+				}
+
+				clear(span.Links[len(kept):])
+				span.Links = kept
+			}
+		}
+	}
+
+	r.dropped.Add(float64(dropped))
+
+	if r.prune {
+		return pruneEmpty(rs), nil
+	}
+
+	return true, nil
+}
+
+// pruneEmpty removes the scopes without spans, reporting whether the
+// resource still has any scope left
+func pruneEmpty(rs *trace.ResourceSpans) bool {
+	var kept = rs.ScopeSpans[:0]
+
+	for _, ss := range rs.ScopeSpans {
+		if len(ss.Spans) > 0 {
+			kept = append(kept, ss)
+		}
+	}
+
+	clear(rs.ScopeSpans[len(kept):])
+	rs.ScopeSpans = kept
+
+	return len(rs.ScopeSpans) > 0
+}
@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+)
+
+func newFilter(t *testing.T, cfg config.ConfigPipelineRules) *filterRule {
+	matcher, err := NewMatcher(cfg.Match, cfg.Missing, cfg.When)
+	assert.NoError(t, err)
+
+	rule, err := newFilterRule(cfg, matcher, t.Name(), "0")
+	assert.NoError(t, err)
+
+	return rule
+}
+
+func Test_FilterRule(t *testing.T) {
+	t.Run("should drop matching spans", func(t *testing.T) {
+		rule := newFilter(t, config.ConfigPipelineRules{
+			When: `span.name =~ "^GET /(health|ready)$" and span.duration < 5ms`,
+		})
+
+		health := &trace.Span{Name: "GET /health", EndTimeUnixNano: uint64(time.Millisecond)}
+		slowHealth := &trace.Span{Name: "GET /health", EndTimeUnixNano: uint64(time.Second)}
+		users := &trace.Span{Name: "GET /users"}
+		rs := newResourceSpans(nil, health, slowHealth, users)
+
+		next, err := rule.Apply(context.Background(), rs)
+
+		assert.NoError(t, err)
+		assert.True(t, next)
+		assert.Equal(t, []*trace.Span{slowHealth, users}, rs.ScopeSpans[0].Spans)
+		assert.Equal(t, 1.0, testutil.ToFloat64(rule.dropped))
+	})
+
+	t.Run("should drop matching events and links", func(t *testing.T) {
+		events := newFilter(t, config.ConfigPipelineRules{Drop: "events", When: `event.name == "cache.miss"`})
+		links := newFilter(t, config.ConfigPipelineRules{Drop: "links", When: `link.trace_state == "internal"`})
+
+		span := &trace.Span{
+			Events: []*trace.Span_Event{{Name: "cache.miss"}, {Name: "exception"}, {Name: "cache.miss"}},
+			Links:  []*trace.Span_Link{{TraceState: "internal"}, {}},
+		}
+		rs := newResourceSpans(nil, span)
+
+		_, err := events.Apply(context.Background(), rs)
+		assert.NoError(t, err)
+
+		_, err = links.Apply(context.Background(), rs)
+		assert.NoError(t, err)
+
+		assert.Len(t, span.Events, 1)
+		assert.Equal(t, "exception", span.Events[0].Name)
+		assert.Len(t, span.Links, 1)
+		assert.Equal(t, 2.0, testutil.ToFloat64(events.dropped))
+		assert.Equal(t, 1.0, testutil.ToFloat64(links.dropped))
+	})
+
+	t.Run("should drop whole resources", func(t *testing.T) {
+		rule := newFilter(t, config.ConfigPipelineRules{
+			Drop:  "resources",
+			Match: map[string]map[string]string{"resource": {"service.name": "kube-probe"}},
+		})
+
+		probe := newResourceSpans(map[string]string{"service.name": "kube-probe"}, &trace.Span{})
+		checkout := newResourceSpans(map[string]string{"service.name": "checkout"}, &trace.Span{})
+
+		next, err := rule.Apply(context.Background(), probe)
+		assert.NoError(t, err)
+		assert.False(t, next)
+		assert.Empty(t, probe.ScopeSpans)
+
+		next, err = rule.Apply(context.Background(), checkout)
+		assert.NoError(t, err)
+		assert.True(t, next)
+		assert.Len(t, checkout.ScopeSpans, 1)
+
+		assert.Equal(t, 1.0, testutil.ToFloat64(rule.dropped))
+	})
+
+	t.Run("should prune empty scopes and resources", func(t *testing.T) {
+		rule := newFilter(t, config.ConfigPipelineRules{
+			Prune: true,
+			Match: map[string]map[string]string{"span": {"kind": "internal"}},
+		})
+
+		rs := newResourceSpans(nil, &trace.Span{Kind: trace.Span_SPAN_KIND_INTERNAL})
+		rs.ScopeSpans = append(rs.ScopeSpans, &trace.ScopeSpans{Spans: []*trace.Span{{Kind: trace.Span_SPAN_KIND_SERVER}}})
+
+		next, err := rule.Apply(context.Background(), rs)
+		assert.NoError(t, err)
+		assert.True(t, next)
+		assert.Len(t, rs.ScopeSpans, 1)
+
+		rs = newResourceSpans(nil, &trace.Span{Kind: trace.Span_SPAN_KIND_INTERNAL})
+
+		next, err = rule.Apply(context.Background(), rs)
+		assert.NoError(t, err)
+		assert.False(t, next)
+		assert.Empty(t, rs.ScopeSpans)
+	})
+
+	t.Run("should keep empty scopes without pruning", func(t *testing.T) {
+		rule := newFilter(t, config.ConfigPipelineRules{})
+
+		rs := newResourceSpans(nil, &trace.Span{})
+
+		next, err := rule.Apply(context.Background(), rs)
+		assert.NoError(t, err)
+		assert.True(t, next)
+		assert.Len(t, rs.ScopeSpans, 1)
+		assert.Empty(t, rs.ScopeSpans[0].Spans)
+	})
+}
+
+func Test_NewFilterRule(t *testing.T) {
+	t.Run("should return error for unknown item", func(t *testing.T) {
+		_, err := newFilterRule(config.ConfigPipelineRules{Drop: "scopes"}, &Matcher{}, "main", "0")
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should return error when dropping resources by span conditions", func(t *testing.T) {
+		matcher, _ := NewMatcher(nil, nil, `span.name == "x"`)
+
+		_, err := newFilterRule(config.ConfigPipelineRules{Drop: "resources"}, matcher, "main", "0")
+
+		assert.ErrorIs(t, err, ErrInvalidMatch)
+	})
+
+	t.Run("should label dropped items with the rule name", func(t *testing.T) {
+		p, err := New(config.ConfigPipeline{
+			Name:  "labels",
+			Rules: []config.ConfigPipelineRules{{Name: "health-checks", Provider: "filter"}},
+		}, nil)
+		assert.NoError(t, err)
+
+		assert.NoError(t, process(context.Background(), p, newResourceSpans(nil, &trace.Span{})))
+		assert.Equal(t, 1.0, testutil.ToFloat64(droppedItems.WithLabelValues("labels", "health-checks", "spans")))
+	})
+}
@@ -0,0 +1,268 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/expr"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+var (
+	// ErrInvalidMatch is returned when a rule has an unknown
+	// or malformed match condition
+	ErrInvalidMatch = errors.New("invalid match condition")
+)
+
+type durationCondition struct {
+	operator string
+	value    time.Duration
+}
+
+// Matcher decides whether a rule applies to a resource or a span based on
+// the match and missing conditions of the rule
+//
+// Match conditions are grouped by what they inspect:
+//
+//   - resource: resource attributes
+//   - scope: instrumentation scope name and version
+//   - attributes: span attributes
+//   - span: span name, kind and status
+//   - duration: span duration compared with lt, lte, gt and gte
+//
+// Except for duration, values are regular expressions that must match the
+// whole field. Missing conditions accept the resource and attributes groups
+// and require the listed keys to be absent. Conditions not expressible as
+// maps can be written in the expression language through when.
+type Matcher struct {
+	resource   map[string]*regexp.Regexp
+	scope      map[string]*regexp.Regexp
+	attributes map[string]*regexp.Regexp
+	span       map[string]*regexp.Regexp
+	duration   []durationCondition
+
+	missingResource   []string
+	missingAttributes []string
+
+	when          *expr.Program
+	whenSpanLevel bool
+}
+
+// NewMatcher compiles the given match, missing and when conditions
+func NewMatcher(match, missing map[string]map[string]string, when string) (*Matcher, error) {
+	var err error
+	var m = &Matcher{}
+
+	if when != "" {
+		if m.when, err = expr.Compile(when); err != nil {
+			return nil, fmt.Errorf("%w: when: %w", ErrInvalidMatch, err)
+		}
+
+		m.whenSpanLevel = m.when.Uses("scope") || m.when.Uses("span") ||
+			m.when.Uses("event") || m.when.Uses("link")
+	}
+
+	for group, conditions := range match {
+		switch group {
+		case "resource":
+			m.resource, err = compileConditions(group, conditions)
+		case "scope":
+			m.scope, err = compileConditions(group, conditions)
+		case "attributes":
+			m.attributes, err = compileConditions(group, conditions)
+		case "span":
+			m.span, err = compileConditions(group, conditions)
+		case "duration":
+			m.duration, err = compileDurations(conditions)
+		default:
+			err = fmt.Errorf("%w: unknown group %q", ErrInvalidMatch, group)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for group, keys := range missing {
+		switch group {
+		case "resource":
+			m.missingResource = mapKeys(keys)
+		case "attributes":
+			m.missingAttributes = mapKeys(keys)
+		default:
+			return nil, fmt.Errorf("%w: unknown missing group %q", ErrInvalidMatch, group)
+		}
+	}
+
+	return m, nil
+}
+
+// SpanLevel reports whether the matcher has conditions on scopes or spans
+func (m *Matcher) SpanLevel() bool {
+	return len(m.scope) > 0 || len(m.attributes) > 0 || len(m.span) > 0 ||
+		len(m.duration) > 0 || len(m.missingAttributes) > 0 || m.whenSpanLevel
+}
+
+// MatchResource reports whether the resource conditions are satisfied
+func (m *Matcher) MatchResource(rs *trace.ResourceSpans) bool {
+	var attrs []*common.KeyValue
+
+	if rs.Resource != nil {
+		attrs = rs.Resource.Attributes
+	}
+
+	if m.when != nil && !m.whenSpanLevel && !m.when.Eval(&expr.Env{Resource: rs.Resource}) {
+		return false
+	}
+
+	return matchAttributes(m.resource, attrs) && allMissing(m.missingResource, attrs)
+}
+
+// MatchSpan reports whether all the conditions are satisfied by the span
+func (m *Matcher) MatchSpan(rs *trace.ResourceSpans, ss *trace.ScopeSpans, span *trace.Span) bool {
+	return m.match(rs, ss, span, &expr.Env{Resource: rs.Resource, Scope: ss.Scope, Span: span})
+}
+
+// MatchEvent reports whether all the conditions are satisfied by the span
+// owning the event, with the event available to the when expression
+func (m *Matcher) MatchEvent(rs *trace.ResourceSpans, ss *trace.ScopeSpans, span *trace.Span, event *trace.Span_Event) bool {
+	return m.match(rs, ss, span, &expr.Env{Resource: rs.Resource, Scope: ss.Scope, Span: span, Event: event})
+}
+
+// MatchLink reports whether all the conditions are satisfied by the span
+// owning the link, with the link available to the when expression
+func (m *Matcher) MatchLink(rs *trace.ResourceSpans, ss *trace.ScopeSpans, span *trace.Span, link *trace.Span_Link) bool {
+	return m.match(rs, ss, span, &expr.Env{Resource: rs.Resource, Scope: ss.Scope, Span: span, Link: link})
+}
+
+func (m *Matcher) match(rs *trace.ResourceSpans, ss *trace.ScopeSpans, span *trace.Span, env *expr.Env) bool {
+	if !m.MatchResource(rs) {
+		return false
+	}
+
+	if len(m.scope) > 0 {
+		var scope = ss.GetScope()
+
+		if !matchFields(m.scope, map[string]string{"name": scope.GetName(), "version": scope.GetVersion()}) {
+			return false
+		}
+	}
+
+	if len(m.span) > 0 {
+		fields := map[string]string{
+			"name":   span.Name,
+			"kind":   otlputil.SpanKindName(span.Kind),
+			"status": otlputil.StatusCodeName(span.Status.GetCode()),
+		}
+
+		if !matchFields(m.span, fields) {
+			return false
+		}
+	}
+
+	if !matchDurations(m.duration, otlputil.SpanDuration(span)) {
+		return false
+	}
+
+	if m.whenSpanLevel && !m.when.Eval(env) {
+		return false
+	}
+
+	return matchAttributes(m.attributes, span.Attributes) && allMissing(m.missingAttributes, span.Attributes)
+}
+
+func compileConditions(group string, conditions map[string]string) (map[string]*regexp.Regexp, error) {
+	var compiled = make(map[string]*regexp.Regexp, len(conditions))
+
+	for key, pattern := range conditions {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s.%s: %v", ErrInvalidMatch, group, key, err)
+		}
+
+		compiled[key] = re
+	}
+
+	return compiled, nil
+}
+
+func compileDurations(conditions map[string]string) ([]durationCondition, error) {
+	var compiled []durationCondition
+
+	for operator, value := range conditions {
+		switch operator {
+		case "lt", "lte", "gt", "gte":
+		default:
+			return nil, fmt.Errorf("%w: unknown duration operator %q", ErrInvalidMatch, operator)
+		}
+
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: duration.%s: %v", ErrInvalidMatch, operator, err)
+		}
+
+		compiled = append(compiled, durationCondition{operator, duration})
+	}
+
+	return compiled, nil
+}
+
+func matchAttributes(conditions map[string]*regexp.Regexp, attrs []*common.KeyValue) bool {
+	for key, re := range conditions {
+		value, ok := otlputil.FindAttribute(attrs, key)
+		if !ok || !re.MatchString(otlputil.ValueString(value)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchFields(conditions map[string]*regexp.Regexp, fields map[string]string) bool {
+	for key, re := range conditions {
+		if !re.MatchString(fields[key]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchDurations(conditions []durationCondition, duration time.Duration) bool {
+	for _, c := range conditions {
+		switch {
+		case c.operator == "lt" && !(duration < c.value),
+			c.operator == "lte" && !(duration <= c.value),
+			c.operator == "gt" && !(duration > c.value),
+			c.operator == "gte" && !(duration >= c.value):
+			return false
+		}
+	}
+
+	return true
+}
+
+func allMissing(keys []string, attrs []*common.KeyValue) bool {
+	for _, key := range keys {
+		if _, ok := otlputil.FindAttribute(attrs, key); ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func mapKeys(m map[string]string) []string {
+	var keys = make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func Test_NewMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		match   map[string]map[string]string
+		missing map[string]map[string]string
+		when    string
+	}{
+		{name: "should return error for unknown group", match: map[string]map[string]string{"unknown": {}}},
+		{name: "should return error for invalid regex", match: map[string]map[string]string{"resource": {"k": "("}}},
+		{name: "should return error for unknown duration operator", match: map[string]map[string]string{"duration": {"eq": "1s"}}},
+		{name: "should return error for invalid duration", match: map[string]map[string]string{"duration": {"lt": "fast"}}},
+		{name: "should return error for unknown missing group", missing: map[string]map[string]string{"span": {}}},
+		{name: "should return error for invalid expression", when: `span.name ==`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewMatcher(tc.match, tc.missing, tc.when)
+
+			assert.ErrorIs(t, err, ErrInvalidMatch)
+		})
+	}
+}
+
+func Test_Matcher_MatchSpan(t *testing.T) {
+	var rs = newResourceSpans(map[string]string{"service.name": "checkout"})
+	var ss = &trace.ScopeSpans{Scope: &common.InstrumentationScope{Name: "redis", Version: "1.2.0"}}
+	var span = &trace.Span{
+		Name:              "HGETALL",
+		Kind:              trace.Span_SPAN_KIND_CLIENT,
+		StartTimeUnixNano: 0,
+		EndTimeUnixNano:   uint64(50 * time.Millisecond),
+		Status:            &trace.Status{Code: trace.Status_STATUS_CODE_ERROR},
+		Attributes: []*common.KeyValue{
+			{Key: "db.system", Value: stringValue("redis")},
+			{Key: "db.statement", Value: stringValue("HGETALL users")},
+			{Key: "db.index", Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 3}}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		match    map[string]map[string]string
+		missing  map[string]map[string]string
+		when     string
+		expected bool
+	}{
+		{name: "should match without conditions", expected: true},
+		{name: "should match resource attributes", match: map[string]map[string]string{"resource": {"service.name": "check.*"}}, expected: true},
+		{name: "should match the whole value", match: map[string]map[string]string{"resource": {"service.name": "check"}}, expected: false},
+		{name: "should match scope", match: map[string]map[string]string{"scope": {"name": "redis", "version": "1\\..*"}}, expected: true},
+		{name: "should match span fields", match: map[string]map[string]string{"span": {"name": "HGETALL", "kind": "client", "status": "error"}}, expected: true},
+		{name: "should not match other span kind", match: map[string]map[string]string{"span": {"kind": "server"}}, expected: false},
+		{name: "should match span attributes", match: map[string]map[string]string{"attributes": {"db.system": "redis", "db.statement": "^HGETALL.*"}}, expected: true},
+		{name: "should match non-string attributes", match: map[string]map[string]string{"attributes": {"db.index": "3"}}, expected: true},
+		{name: "should not match absent attributes", match: map[string]map[string]string{"attributes": {"http.route": ".*"}}, expected: false},
+		{name: "should match duration", match: map[string]map[string]string{"duration": {"lt": "100ms", "gte": "50ms"}}, expected: true},
+		{name: "should not match duration out of range", match: map[string]map[string]string{"duration": {"gt": "50ms"}}, expected: false},
+		{name: "should match missing attributes", missing: map[string]map[string]string{"attributes": {"http.route": ""}}, expected: true},
+		{name: "should not match present attributes", missing: map[string]map[string]string{"attributes": {"db.system": ""}}, expected: false},
+		{name: "should not match present resource attributes", missing: map[string]map[string]string{"resource": {"service.name": ""}}, expected: false},
+		{name: "should match expression", when: `span.duration < 100ms and span.attributes["db.index"] >= 3`, expected: true},
+		{name: "should not match false expression", when: `span.kind == "server"`, expected: false},
+		{name: "should match resource expression", when: `startsWith(resource.attributes["service.name"], "check")`, expected: true},
+		{name: "should combine expression and maps", match: map[string]map[string]string{"span": {"kind": "server"}}, when: `true`, expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			matcher, err := NewMatcher(tc.match, tc.missing, tc.when)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, matcher.MatchSpan(rs, ss, span))
+		})
+	}
+}
+
+func Test_Matcher_SpanLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		match    map[string]map[string]string
+		when     string
+		expected bool
+	}{
+		{name: "should be resource level without conditions", expected: false},
+		{name: "should be resource level with resource conditions", match: map[string]map[string]string{"resource": {"k": "v"}}, expected: false},
+		{name: "should be span level with span conditions", match: map[string]map[string]string{"span": {"name": "x"}}, expected: true},
+		{name: "should be resource level with resource expression", when: `resource.attributes["k"] == "v"`, expected: false},
+		{name: "should be span level with span expression", when: `span.name == "x"`, expected: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			matcher, err := NewMatcher(tc.match, nil, tc.when)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, matcher.SpanLevel())
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/exporter"
+)
+
+var (
+	// ErrUnknownProvider is returned when a rule uses a provider
+	// that isn't available
+	ErrUnknownProvider = errors.New("unknown provider")
+
+	// ErrUnknownExporter is returned when a rule references an
+	// exporter that isn't configured
+	ErrUnknownExporter = errors.New("unknown exporter")
+
+	// ErrUnusedOption is returned when a rule sets an option its provider
+	// doesn't use
+	ErrUnusedOption = errors.New("option not used by the provider")
+)
+
+// providerOptions are the options each provider uses, besides the match,
+// missing and when conditions shared by all of them
+var providerOptions = map[string][]string{
+	"setter":    {"set"},
+	"filter":    {"drop", "prune"},
+	"transform": {"transform"},
+	"route":     {"tenant", "exporters", "continue"},
+	"detector":  {"detectors", "override"},
+	"dedup":     {"window", "max_items"},
+}
+
+// Rule is a single step of a pipeline
+type Rule interface {
+	// Apply runs the rule against the resource, returning false when the
+	// resource must not go further in the pipeline
+	Apply(ctx context.Context, rs *trace.ResourceSpans) (bool, error)
+}
+
+// BatchRule is a rule applied to all the resources of a batch at once, so it
+// can work across them
+type BatchRule interface {
+	Rule
+
+	// ApplyBatch runs the rule against the resources, returning for each one
+	// whether it goes further in the pipeline and its error, by index
+	ApplyBatch(ctx context.Context, batch []*trace.ResourceSpans) ([]bool, []error)
+}
+
+// FinishRule is a rule told how the resources it applied to ended up, so it
+// can undo what it did for the ones failing further in the pipeline
+type FinishRule interface {
+	Rule
+
+	// Finish is called once the pipeline is done with the batch, with the
+	// errors of the resources by index, nil when none of them failed
+	Finish(batch []*trace.ResourceSpans, errs []error)
+}
+
+// Pipeline applies its rules sequentially to the ingested resources
+type Pipeline struct {
+	Name  string
+	rules []Rule
+}
+
+// New creates a pipeline from its configuration, resolving the exporters
+// referenced by the rules by name
+func New(cfg config.ConfigPipeline, exporters map[string]exporter.Exporter) (*Pipeline, error) {
+	var p = &Pipeline{Name: cfg.Name}
+
+	for i, ruleCfg := range cfg.Rules {
+		name := ruleCfg.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+
+		rule, err := newRule(ruleCfg, exporters, cfg.Name, name)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %q rule %d: %w", cfg.Name, i, err)
+		}
+
+		p.rules = append(p.rules, rule)
+	}
+
+	return p, nil
+}
+
+func newRule(cfg config.ConfigPipelineRules, exporters map[string]exporter.Exporter, pipeline, name string) (Rule, error) {
+	matcher, err := NewMatcher(cfg.Match, cfg.Missing, cfg.When)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkOptions(cfg); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Provider {
+	case "setter":
+		return newSetterRule(cfg, matcher), nil
+
+	case "filter":
+		return newFilterRule(cfg, matcher, pipeline, name)
+
+	case "transform":
+		return newTransformRule(cfg, matcher)
+
+	case "route":
+		return newRouteRule(cfg, matcher, exporters)
+
+	case "detector":
+		return newDetectorRule(cfg, matcher)
+
+	case "dedup":
+		return newDedupRule(cfg, matcher, pipeline, name), nil
+
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, cfg.Provider)
+	}
+}
+
+// checkOptions returns ErrUnusedOption, wrapped, when the rule sets options
+// its provider doesn't use, as they would be silently ignored
+func checkOptions(cfg config.ConfigPipelineRules) error {
+	allowed, ok := providerOptions[cfg.Provider]
+	if !ok {
+		return nil
+	}
+
+	for _, option := range []struct {
+		name string
+		set  bool
+	}{
+		{"set", len(cfg.Set) > 0},
+		{"transform", len(cfg.Transform) > 0},
+		{"drop", cfg.Drop != ""},
+		{"prune", cfg.Prune},
+		{"tenant", cfg.Tenant != ""},
+		{"exporters", len(cfg.Exporters) > 0},
+		{"continue", cfg.Continue},
+		{"detectors", len(cfg.Detectors) > 0},
+		{"override", cfg.Override},
+		{"window", cfg.Window != 0},
+		{"max_items", cfg.MaxItems != 0},
+	} {
+		if option.set && !slices.Contains(allowed, option.name) {
+			return fmt.Errorf("%w: %s for %s rules", ErrUnusedOption, option.name, cfg.Provider)
+		}
+	}
+
+	return nil
+}
+
+// Process applies the rules to the resources of a batch until one of them
+// stops or fails each resource, returning their errors by index, nil when
+// none of them failed
+func (p *Pipeline) Process(ctx context.Context, batch []*trace.ResourceSpans) []error {
+	var errs []error
+
+	// indexes of the resources still going through the pipeline
+	active := make([]int, 0, len(batch))
+
+	for n, rs := range batch {
+		if rs != nil {
+			active = append(active, n)
+		}
+	}
+
+	for _, rule := range p.rules {
+		if len(active) == 0 {
+			break
+		}
+
+		next, ruleErrs := applyRule(ctx, rule, batch, active)
+		remaining := active[:0]
+
+		for k, n := range active {
+			if ruleErrs[k] != nil {
+				if errs == nil {
+					errs = make([]error, len(batch))
+				}
+
+				errs[n] = ruleErrs[k]
+				continue
+			}
+
+			if next[k] {
+				remaining = append(remaining, n)
+			}
+		}
+
+		active = remaining
+	}
+
+	for _, rule := range p.rules {
+		if finishRule, ok := rule.(FinishRule); ok {
+			finishRule.Finish(batch, errs)
+		}
+	}
+
+	return errs
+}
+
+// applyRule applies the rule to the active resources of the batch, all of
+// them at once when it's a BatchRule
+func applyRule(ctx context.Context, rule Rule, batch []*trace.ResourceSpans, active []int) ([]bool, []error) {
+	if batchRule, ok := rule.(BatchRule); ok {
+		resources := make([]*trace.ResourceSpans, len(active))
+
+		for k, n := range active {
+			resources[k] = batch[n]
+		}
+
+		return batchRule.ApplyBatch(ctx, resources)
+	}
+
+	next := make([]bool, len(active))
+	errs := make([]error, len(active))
+
+	for k, n := range active {
+		next[k], errs[k] = rule.Apply(ctx, batch[n])
+	}
+
+	return next, errs
+}
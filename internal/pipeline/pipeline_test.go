@@ -0,0 +1,166 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/exporter"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+type fakeExporter struct {
+	exported []*trace.ResourceSpans
+	calls    int
+	err      error
+}
+
+func (e *fakeExporter) Export(ctx context.Context, rss []*trace.ResourceSpans) error {
+	e.calls++
+	e.exported = append(e.exported, rss...)
+	return e.err
+}
+
+func (e *fakeExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func stringValue(value string) *common.AnyValue {
+	return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: value}}
+}
+
+func newResourceSpans(resourceAttrs map[string]string, spans ...*trace.Span) *trace.ResourceSpans {
+	var attrs []*common.KeyValue
+
+	for key, value := range resourceAttrs {
+		attrs = append(attrs, &common.KeyValue{Key: key, Value: stringValue(value)})
+	}
+
+	return &trace.ResourceSpans{
+		Resource:   &resource.Resource{Attributes: attrs},
+		ScopeSpans: []*trace.ScopeSpans{{Spans: spans}},
+	}
+}
+
+// process runs the resource alone through the pipeline, returning its error
+func process(ctx context.Context, p *Pipeline, rs *trace.ResourceSpans) error {
+	return errors.Join(p.Process(ctx, []*trace.ResourceSpans{rs})...)
+}
+
+func Test_New(t *testing.T) {
+	t.Run("should return error for unknown provider", func(t *testing.T) {
+		_, err := New(config.ConfigPipeline{
+			Name:  "main",
+			Rules: []config.ConfigPipelineRules{{Provider: "unknown"}},
+		}, nil)
+
+		assert.ErrorIs(t, err, ErrUnknownProvider)
+	})
+
+	t.Run("should return error for invalid match", func(t *testing.T) {
+		_, err := New(config.ConfigPipeline{
+			Name: "main",
+			Rules: []config.ConfigPipelineRules{{
+				Provider: "setter",
+				Match:    map[string]map[string]string{"attributes": {"http.route": "("}},
+			}},
+		}, nil)
+
+		assert.ErrorIs(t, err, ErrInvalidMatch)
+	})
+
+	t.Run("should return error for options not used by the provider", func(t *testing.T) {
+		_, err := New(config.ConfigPipeline{
+			Name: "main",
+			Rules: []config.ConfigPipelineRules{{
+				Provider: "setter",
+				Set:      map[string]string{"env": "prod"},
+				Override: true,
+			}},
+		}, nil)
+
+		assert.ErrorIs(t, err, ErrUnusedOption)
+		assert.ErrorContains(t, err, "override for setter rules")
+	})
+}
+
+func Test_Pipeline_Process(t *testing.T) {
+	t.Run("should apply rules in order until one stops the resource", func(t *testing.T) {
+		var first, second = &fakeExporter{}, &fakeExporter{}
+		var exporters = map[string]exporter.Exporter{"first": first, "second": second}
+
+		p, err := New(config.ConfigPipeline{
+			Name: "main",
+			Rules: []config.ConfigPipelineRules{
+				{Provider: "setter", Set: map[string]string{"env": "prod"}},
+				{Provider: "route", Exporters: []string{"first"}},
+				{Provider: "route", Exporters: []string{"second"}},
+			},
+		}, exporters)
+		assert.NoError(t, err)
+
+		rs := newResourceSpans(nil, &trace.Span{})
+
+		assert.NoError(t, process(context.Background(), p, rs))
+		assert.Len(t, first.exported, 1)
+		assert.Empty(t, second.exported)
+
+		value, ok := otlputil.FindAttribute(first.exported[0].ScopeSpans[0].Spans[0].Attributes, "env")
+		assert.True(t, ok)
+		assert.Equal(t, "prod", value.GetStringValue())
+	})
+
+	t.Run("should return error from rules", func(t *testing.T) {
+		var failing = &fakeExporter{err: assert.AnError}
+
+		p, err := New(config.ConfigPipeline{
+			Name:  "main",
+			Rules: []config.ConfigPipelineRules{{Provider: "route", Exporters: []string{"failing"}}},
+		}, map[string]exporter.Exporter{"failing": failing})
+		assert.NoError(t, err)
+
+		assert.ErrorIs(t, process(context.Background(), p, newResourceSpans(nil)), assert.AnError)
+	})
+
+	t.Run("should return the errors of the resources by index", func(t *testing.T) {
+		var failing, healthy = &fakeExporter{err: assert.AnError}, &fakeExporter{}
+
+		p, err := New(config.ConfigPipeline{
+			Name: "main",
+			Rules: []config.ConfigPipelineRules{
+				{Provider: "route", Tenant: "acme", Exporters: []string{"failing"}},
+				{Provider: "setter", Set: map[string]string{"env": "prod"}},
+				{Provider: "route", Exporters: []string{"healthy"}},
+			},
+		}, map[string]exporter.Exporter{"failing": failing, "healthy": healthy})
+		assert.NoError(t, err)
+
+		errs := p.Process(context.Background(), []*trace.ResourceSpans{
+			newResourceSpans(nil, &trace.Span{}),
+			newResourceSpans(map[string]string{AttributeTenant: "acme"}, &trace.Span{}),
+			nil,
+		})
+
+		assert.Len(t, errs, 3)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], assert.AnError)
+		assert.NoError(t, errs[2])
+		assert.Len(t, healthy.exported, 1)
+	})
+
+	t.Run("should return no errors when all resources are processed", func(t *testing.T) {
+		p, err := New(config.ConfigPipeline{
+			Name:  "main",
+			Rules: []config.ConfigPipelineRules{{Provider: "setter", Set: map[string]string{"env": "prod"}}},
+		}, nil)
+		assert.NoError(t, err)
+
+		assert.Nil(t, p.Process(context.Background(), []*trace.ResourceSpans{newResourceSpans(nil), newResourceSpans(nil)}))
+	})
+}
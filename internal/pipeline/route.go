@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/exporter"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+// AttributeTenant is the resource attribute identifying the tenant
+// owning the data
+const AttributeTenant = "tenant.id"
+
+// routeRule sends the matching resources to its exporters
+//
+// Routes are evaluated in order and the first one matching stops the
+// pipeline, unless it is set to continue, which allows duplicating data
+// to multiple destinations. A route without conditions matches everything
+// so it works as the default route when placed last.
+type routeRule struct {
+	matcher   *Matcher
+	tenant    string
+	names     []string
+	exporters []exporter.Exporter
+	cont      bool
+}
+
+func newRouteRule(cfg config.ConfigPipelineRules, matcher *Matcher, exporters map[string]exporter.Exporter) (*routeRule, error) {
+	if matcher.SpanLevel() {
+		return nil, fmt.Errorf("%w: route rules can only inspect resources", ErrInvalidMatch)
+	}
+
+	var r = &routeRule{matcher: matcher, tenant: cfg.Tenant, cont: cfg.Continue}
+
+	for _, name := range cfg.Exporters {
+		exp, ok := exporters[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownExporter, name)
+		}
+
+		r.names = append(r.names, name)
+		r.exporters = append(r.exporters, exp)
+	}
+
+	return r, nil
+}
+
+func (r *routeRule) Apply(ctx context.Context, rs *trace.ResourceSpans) (bool, error) {
+	next, errs := r.ApplyBatch(ctx, []*trace.ResourceSpans{rs})
+	return next[0], errs[0]
+}
+
+// ApplyBatch exports the matching resources of the batch together, with a
+// single call to each exporter, failing the ones the export failed for
+func (r *routeRule) ApplyBatch(ctx context.Context, batch []*trace.ResourceSpans) ([]bool, []error) {
+	var matched []*trace.ResourceSpans
+	var indexes []int
+
+	next := make([]bool, len(batch))
+	errs := make([]error, len(batch))
+
+	for n, rs := range batch {
+		if !r.matcher.MatchResource(rs) || !r.matchTenant(ctx, rs) {
+			next[n] = true
+			continue
+		}
+
+		next[n] = r.cont
+		matched = append(matched, rs)
+		indexes = append(indexes, n)
+	}
+
+	if len(matched) == 0 {
+		return next, errs
+	}
+
+	for k, err := range r.export(ctx, matched) {
+		errs[indexes[k]] = err
+	}
+
+	return next, errs
+}
+
+// export sends the resources to every exporter, returning their errors by
+// index. An export fails all the resources, unless the exporter tells which
+// ones failed with exporter.ResourceErrors.
+func (r *routeRule) export(ctx context.Context, rss []*trace.ResourceSpans) []error {
+	var errs = make([]error, len(rss))
+
+	for i, exp := range r.exporters {
+		var resourceErrs exporter.ResourceErrors
+
+		err := exp.Export(ctx, rss)
+		if err == nil {
+			continue
+		}
+
+		if !errors.As(err, &resourceErrs) || len(resourceErrs) != len(rss) {
+			resourceErrs = nil
+		}
+
+		for n := range rss {
+			thisErr := err
+			if resourceErrs != nil {
+				thisErr = resourceErrs[n]
+			}
+
+			if thisErr != nil {
+				errs[n] = errors.Join(errs[n], fmt.Errorf("exporter %q: %w", r.names[i], thisErr))
+			}
+		}
+	}
+
+	return errs
+}
+
+// matchTenant compares the tenant.id attribute of the resource or, when
+// missing, the tenant the client sent the data for
+func (r *routeRule) matchTenant(ctx context.Context, rs *trace.ResourceSpans) bool {
+	if r.tenant == "" {
+		return true
+	}
+
+	if value, ok := otlputil.FindAttribute(rs.GetResource().GetAttributes(), AttributeTenant); ok {
+		return otlputil.ValueString(value) == r.tenant
+	}
+
+	return client.FromContext(ctx).Tenant == r.tenant
+}
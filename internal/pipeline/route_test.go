@@ -0,0 +1,169 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/exporter"
+)
+
+func Test_RouteRule(t *testing.T) {
+	var newPipeline = func(t *testing.T, exporters map[string]exporter.Exporter, rules ...config.ConfigPipelineRules) *Pipeline {
+		p, err := New(config.ConfigPipeline{Name: "routes", Rules: rules}, exporters)
+		assert.NoError(t, err)
+
+		return p
+	}
+
+	t.Run("should route by resource attributes with a default route", func(t *testing.T) {
+		var vendor, jaeger = &fakeExporter{}, &fakeExporter{}
+
+		p := newPipeline(t, map[string]exporter.Exporter{"vendor": vendor, "jaeger": jaeger},
+			config.ConfigPipelineRules{
+				Provider:  "route",
+				Match:     map[string]map[string]string{"resource": {"deployment.environment": "prod"}},
+				Exporters: []string{"vendor"},
+			},
+			config.ConfigPipelineRules{Provider: "route", Exporters: []string{"jaeger"}},
+		)
+
+		assert.NoError(t, process(context.Background(), p, newResourceSpans(map[string]string{"deployment.environment": "prod"})))
+		assert.NoError(t, process(context.Background(), p, newResourceSpans(map[string]string{"deployment.environment": "preprod"})))
+		assert.NoError(t, process(context.Background(), p, newResourceSpans(nil)))
+
+		assert.Len(t, vendor.exported, 1)
+		assert.Len(t, jaeger.exported, 2)
+	})
+
+	t.Run("should route by tenant", func(t *testing.T) {
+		var acme = &fakeExporter{}
+
+		p := newPipeline(t, map[string]exporter.Exporter{"acme": acme},
+			config.ConfigPipelineRules{Provider: "route", Tenant: "acme", Exporters: []string{"acme"}},
+		)
+
+		assert.NoError(t, process(context.Background(), p, newResourceSpans(map[string]string{AttributeTenant: "acme"})))
+		assert.NoError(t, process(context.Background(), p, newResourceSpans(map[string]string{AttributeTenant: "other"})))
+
+		assert.Len(t, acme.exported, 1)
+	})
+
+	t.Run("should route by the client tenant when the resource has none", func(t *testing.T) {
+		var acme = &fakeExporter{}
+
+		p := newPipeline(t, map[string]exporter.Exporter{"acme": acme},
+			config.ConfigPipelineRules{Provider: "route", Tenant: "acme", Exporters: []string{"acme"}},
+		)
+
+		fromAcme := client.NewContext(context.Background(), client.Info{Tenant: "acme"})
+
+		assert.NoError(t, process(fromAcme, p, newResourceSpans(nil)))
+		assert.NoError(t, process(fromAcme, p, newResourceSpans(map[string]string{AttributeTenant: "other"})))
+		assert.NoError(t, process(context.Background(), p, newResourceSpans(nil)))
+
+		assert.Len(t, acme.exported, 1)
+	})
+
+	t.Run("should route by expression", func(t *testing.T) {
+		var legacy = &fakeExporter{}
+
+		p := newPipeline(t, map[string]exporter.Exporter{"legacy": legacy},
+			config.ConfigPipelineRules{
+				Provider:  "route",
+				When:      `resource.attributes["telemetry.sdk.version"] =~ "0\\..*"`,
+				Exporters: []string{"legacy"},
+			},
+		)
+
+		assert.NoError(t, process(context.Background(), p, newResourceSpans(map[string]string{"telemetry.sdk.version": "0.9.1"})))
+		assert.NoError(t, process(context.Background(), p, newResourceSpans(map[string]string{"telemetry.sdk.version": "1.2.0"})))
+
+		assert.Len(t, legacy.exported, 1)
+	})
+
+	t.Run("should duplicate to multiple destinations", func(t *testing.T) {
+		var vendor, jaeger, archive = &fakeExporter{}, &fakeExporter{}, &fakeExporter{}
+
+		p := newPipeline(t, map[string]exporter.Exporter{"vendor": vendor, "jaeger": jaeger, "archive": archive},
+			config.ConfigPipelineRules{Provider: "route", Exporters: []string{"vendor", "jaeger"}, Continue: true},
+			config.ConfigPipelineRules{Provider: "route", Exporters: []string{"archive"}},
+		)
+
+		assert.NoError(t, process(context.Background(), p, newResourceSpans(nil)))
+
+		assert.Len(t, vendor.exported, 1)
+		assert.Len(t, jaeger.exported, 1)
+		assert.Len(t, archive.exported, 1)
+	})
+
+	t.Run("should export to all destinations even when one fails", func(t *testing.T) {
+		var failing, jaeger = &fakeExporter{err: assert.AnError}, &fakeExporter{}
+
+		p := newPipeline(t, map[string]exporter.Exporter{"failing": failing, "jaeger": jaeger},
+			config.ConfigPipelineRules{Provider: "route", Exporters: []string{"failing", "jaeger"}},
+		)
+
+		assert.ErrorIs(t, process(context.Background(), p, newResourceSpans(nil)), assert.AnError)
+		assert.Len(t, jaeger.exported, 1)
+	})
+
+	t.Run("should only fail the resources the exporter failed for", func(t *testing.T) {
+		var jaeger = &fakeExporter{err: exporter.ResourceErrors{nil, assert.AnError}}
+
+		p := newPipeline(t, map[string]exporter.Exporter{"jaeger": jaeger},
+			config.ConfigPipelineRules{Provider: "route", Exporters: []string{"jaeger"}},
+		)
+
+		errs := p.Process(context.Background(), []*trace.ResourceSpans{newResourceSpans(nil), newResourceSpans(nil)})
+
+		assert.Len(t, errs, 2)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], assert.AnError)
+		assert.ErrorContains(t, errs[1], `exporter "jaeger"`)
+	})
+
+	t.Run("should export the matching resources of a batch together", func(t *testing.T) {
+		var acme, others = &fakeExporter{}, &fakeExporter{}
+
+		p := newPipeline(t, map[string]exporter.Exporter{"acme": acme, "others": others},
+			config.ConfigPipelineRules{Provider: "route", Tenant: "acme", Exporters: []string{"acme"}},
+			config.ConfigPipelineRules{Provider: "route", Exporters: []string{"others"}},
+		)
+
+		errs := p.Process(context.Background(), []*trace.ResourceSpans{
+			newResourceSpans(map[string]string{AttributeTenant: "acme"}),
+			newResourceSpans(nil),
+			newResourceSpans(map[string]string{AttributeTenant: "acme"}),
+			newResourceSpans(nil),
+		})
+
+		assert.Nil(t, errs)
+		assert.Equal(t, 1, acme.calls)
+		assert.Len(t, acme.exported, 2)
+		assert.Equal(t, 1, others.calls)
+		assert.Len(t, others.exported, 2)
+	})
+
+	t.Run("should return error when matching spans", func(t *testing.T) {
+		_, err := New(config.ConfigPipeline{Name: "routes", Rules: []config.ConfigPipelineRules{{
+			Provider: "route",
+			Match:    map[string]map[string]string{"attributes": {"http.route": ".*"}},
+		}}}, nil)
+
+		assert.ErrorIs(t, err, ErrInvalidMatch)
+	})
+
+	t.Run("should return error when expression inspects spans", func(t *testing.T) {
+		_, err := New(config.ConfigPipeline{Name: "routes", Rules: []config.ConfigPipelineRules{{
+			Provider: "route",
+			When:     `span.name == "GET /"`,
+		}}}, nil)
+
+		assert.ErrorIs(t, err, ErrInvalidMatch)
+	})
+}
@@ -0,0 +1,39 @@
+package pipeline
+
+import (
+	"context"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+// setterRule assigns literal values to the attributes of the matching spans
+type setterRule struct {
+	matcher *Matcher
+	set     map[string]string
+}
+
+func newSetterRule(cfg config.ConfigPipelineRules, matcher *Matcher) *setterRule {
+	return &setterRule{matcher: matcher, set: cfg.Set}
+}
+
+func (r *setterRule) Apply(ctx context.Context, rs *trace.ResourceSpans) (bool, error) {
+	for _, ss := range rs.ScopeSpans {
+		for _, span := range ss.Spans {
+			if !r.matcher.MatchSpan(rs, ss, span) {
+				continue
+			}
+
+			for key, value := range r.set {
+				span.Attributes = otlputil.SetAttribute(span.Attributes, key, &common.AnyValue{
+					Value: &common.AnyValue_StringValue{StringValue: value},
+				})
+			}
+		}
+	}
+
+	return true, nil
+}
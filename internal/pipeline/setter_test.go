@@ -0,0 +1,32 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+)
+
+func Test_SetterRule(t *testing.T) {
+	t.Run("should set attributes only on matching spans", func(t *testing.T) {
+		matcher, err := NewMatcher(map[string]map[string]string{"span": {"kind": "server"}}, nil, "")
+		assert.NoError(t, err)
+
+		rule := newSetterRule(config.ConfigPipelineRules{Set: map[string]string{"team": "payments"}}, matcher)
+
+		server := &trace.Span{Kind: trace.Span_SPAN_KIND_SERVER, Attributes: []*common.KeyValue{{Key: "team", Value: stringValue("core")}}}
+		client := &trace.Span{Kind: trace.Span_SPAN_KIND_CLIENT}
+
+		next, err := rule.Apply(context.Background(), newResourceSpans(nil, server, client))
+
+		assert.NoError(t, err)
+		assert.True(t, next)
+		assert.Len(t, server.Attributes, 1)
+		assert.Equal(t, "payments", server.Attributes[0].Value.GetStringValue())
+		assert.Empty(t, client.Attributes)
+	})
+}
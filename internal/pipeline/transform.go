@@ -0,0 +1,356 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+var (
+	// ErrInvalidTransform is returned when a transform function is
+	// unknown or misses required arguments
+	ErrInvalidTransform = errors.New("invalid transform")
+)
+
+// target is what a transform function changes
+type target struct {
+	rs   *trace.ResourceSpans
+	ss   *trace.ScopeSpans
+	span *trace.Span
+}
+
+type transformFunc func(t target)
+
+// field references a value inside a target, written as span.name or
+// <root>.attributes.<key> where root is resource, scope or span
+type field struct {
+	root string
+	key  string
+}
+
+func parseField(ref string) (field, error) {
+	if ref == "span.name" {
+		return field{root: "span"}, nil
+	}
+
+	for _, root := range []string{"resource", "scope", "span"} {
+		if key, ok := strings.CutPrefix(ref, root+".attributes."); ok && key != "" {
+			return field{root: root, key: key}, nil
+		}
+	}
+
+	return field{}, fmt.Errorf("%w: unknown field %q", ErrInvalidTransform, ref)
+}
+
+func (f field) attributes(t target) *[]*common.KeyValue {
+	switch f.root {
+	case "resource":
+		if t.rs.Resource == nil {
+			return nil
+		}
+		return &t.rs.Resource.Attributes
+	case "scope":
+		if t.ss.Scope == nil {
+			return nil
+		}
+		return &t.ss.Scope.Attributes
+	default:
+		return &t.span.Attributes
+	}
+}
+
+func (f field) get(t target) (*common.AnyValue, bool) {
+	if f.key == "" {
+		return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: t.span.Name}}, true
+	}
+
+	attrs := f.attributes(t)
+	if attrs == nil {
+		return nil, false
+	}
+
+	return otlputil.FindAttribute(*attrs, f.key)
+}
+
+func (f field) set(t target, value *common.AnyValue) {
+	if f.key == "" {
+		t.span.Name = otlputil.ValueString(value)
+		return
+	}
+
+	attrs := f.attributes(t)
+	if attrs == nil {
+		return
+	}
+
+	*attrs = otlputil.SetAttribute(*attrs, f.key, value)
+}
+
+func (f field) delete(t target) {
+	if f.key == "" {
+		return
+	}
+
+	attrs := f.attributes(t)
+	if attrs == nil {
+		return
+	}
+
+	*attrs = otlputil.DeleteAttribute(*attrs, f.key)
+}
+
+// transformRule changes the matching spans applying its functions in order
+type transformRule struct {
+	matcher   *Matcher
+	functions []transformFunc
+}
+
+func newTransformRule(cfg config.ConfigPipelineRules, matcher *Matcher) (*transformRule, error) {
+	var r = &transformRule{matcher: matcher}
+
+	for i, fnCfg := range cfg.Transform {
+		fn, err := newTransformFunc(fnCfg)
+		if err != nil {
+			return nil, fmt.Errorf("transform %d: %w", i, err)
+		}
+
+		r.functions = append(r.functions, fn)
+	}
+
+	return r, nil
+}
+
+func (r *transformRule) Apply(ctx context.Context, rs *trace.ResourceSpans) (bool, error) {
+	for _, ss := range rs.ScopeSpans {
+		for _, span := range ss.Spans {
+			if !r.matcher.MatchSpan(rs, ss, span) {
+				continue
+			}
+
+			for _, fn := range r.functions {
+				fn(target{rs, ss, span})
+			}
+		}
+	}
+
+	return true, nil
+}
+
+func newTransformFunc(cfg config.ConfigTransform) (transformFunc, error) {
+	switch cfg.Function {
+	case "set":
+		return newSetFunc(cfg)
+	case "copy":
+		return newCopyFunc(cfg, false)
+	case "rename":
+		return newCopyFunc(cfg, true)
+	case "delete":
+		return newDeleteFunc(cfg)
+	case "truncate":
+		return newTruncateFunc(cfg)
+	case "convert":
+		return newConvertFunc(cfg)
+	case "rename_span":
+		return newRenameSpanFunc(cfg)
+	case "set_status":
+		return newSetStatusFunc(cfg)
+	case "limit_attributes":
+		return newLimitAttributesFunc(cfg)
+	case "limit_events":
+		return newLimitEventsFunc(cfg)
+	default:
+		return nil, fmt.Errorf("%w: unknown function %q", ErrInvalidTransform, cfg.Function)
+	}
+}
+
+func newSetFunc(cfg config.ConfigTransform) (transformFunc, error) {
+	f, err := parseField(cfg.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(t target) {
+		f.set(t, &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: cfg.Value}})
+	}, nil
+}
+
+func newCopyFunc(cfg config.ConfigTransform, move bool) (transformFunc, error) {
+	from, err := parseField(cfg.From)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseField(cfg.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(t target) {
+		value, ok := from.get(t)
+		if !ok {
+			return
+		}
+
+		// the value is cloned, as it's shared otherwise and changing one of
+		// the fields later would change both
+		to.set(t, proto.Clone(value).(*common.AnyValue))
+
+		if move {
+			from.delete(t)
+		}
+	}, nil
+}
+
+func newDeleteFunc(cfg config.ConfigTransform) (transformFunc, error) {
+	f, err := parseField(cfg.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.key == "" {
+		return nil, fmt.Errorf("%w: only attributes can be deleted", ErrInvalidTransform)
+	}
+
+	return f.delete, nil
+}
+
+func newTruncateFunc(cfg config.ConfigTransform) (transformFunc, error) {
+	f, err := parseField(cfg.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Length <= 0 {
+		return nil, fmt.Errorf("%w: truncate requires a positive length", ErrInvalidTransform)
+	}
+
+	return func(t target) {
+		value, ok := f.get(t)
+		if !ok {
+			return
+		}
+
+		s, isString := value.Value.(*common.AnyValue_StringValue)
+		if !isString || utf8.RuneCountInString(s.StringValue) <= cfg.Length {
+			return
+		}
+
+		f.set(t, &common.AnyValue{Value: &common.AnyValue_StringValue{
+			StringValue: string([]rune(s.StringValue)[:cfg.Length]),
+		}})
+	}, nil
+}
+
+func newConvertFunc(cfg config.ConfigTransform) (transformFunc, error) {
+	f, err := parseField(cfg.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.key == "" {
+		return nil, fmt.Errorf("%w: only attributes can be converted", ErrInvalidTransform)
+	}
+
+	var convert func(string) (*common.AnyValue, error)
+
+	switch cfg.Type {
+	case "string":
+		convert = func(s string) (*common.AnyValue, error) {
+			return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: s}}, nil
+		}
+	case "int":
+		convert = func(s string) (*common.AnyValue, error) {
+			n, err := strconv.ParseInt(s, 10, 64)
+			return &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: n}}, err
+		}
+	case "double":
+		convert = func(s string) (*common.AnyValue, error) {
+			n, err := strconv.ParseFloat(s, 64)
+			return &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: n}}, err
+		}
+	case "bool":
+		convert = func(s string) (*common.AnyValue, error) {
+			b, err := strconv.ParseBool(s)
+			return &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: b}}, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidTransform, cfg.Type)
+	}
+
+	return func(t target) {
+		value, ok := f.get(t)
+		if !ok {
+			return
+		}
+
+		// values that can't be converted are kept as they are
+		if converted, err := convert(otlputil.ValueString(value)); err == nil {
+			f.set(t, converted)
+		}
+	}, nil
+}
+
+func newRenameSpanFunc(cfg config.ConfigTransform) (transformFunc, error) {
+	if cfg.Pattern == "" {
+		return nil, fmt.Errorf("%w: rename_span requires a pattern", ErrInvalidTransform)
+	}
+
+	re, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: pattern: %v", ErrInvalidTransform, err)
+	}
+
+	return func(t target) {
+		if re.MatchString(t.span.Name) {
+			t.span.Name = re.ReplaceAllString(t.span.Name, cfg.Replacement)
+		}
+	}, nil
+}
+
+func newSetStatusFunc(cfg config.ConfigTransform) (transformFunc, error) {
+	code, ok := trace.Status_StatusCode_value["STATUS_CODE_"+strings.ToUpper(cfg.Code)]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown status code %q", ErrInvalidTransform, cfg.Code)
+	}
+
+	return func(t target) {
+		t.span.Status = &trace.Status{Code: trace.Status_StatusCode(code), Message: cfg.Message}
+	}, nil
+}
+
+func newLimitAttributesFunc(cfg config.ConfigTransform) (transformFunc, error) {
+	if cfg.Limit <= 0 {
+		return nil, fmt.Errorf("%w: limit must be positive", ErrInvalidTransform)
+	}
+
+	return func(t target) {
+		if dropped := len(t.span.Attributes) - cfg.Limit; dropped > 0 {
+			t.span.Attributes = t.span.Attributes[:cfg.Limit]
+			t.span.DroppedAttributesCount += uint32(dropped)
+		}
+	}, nil
+}
+
+func newLimitEventsFunc(cfg config.ConfigTransform) (transformFunc, error) {
+	if cfg.Limit <= 0 {
+		return nil, fmt.Errorf("%w: limit must be positive", ErrInvalidTransform)
+	}
+
+	return func(t target) {
+		if dropped := len(t.span.Events) - cfg.Limit; dropped > 0 {
+			t.span.Events = t.span.Events[:cfg.Limit]
+			t.span.DroppedEventsCount += uint32(dropped)
+		}
+	}, nil
+}
@@ -0,0 +1,199 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+func applyTransform(t *testing.T, rs *trace.ResourceSpans, when string, functions ...config.ConfigTransform) {
+	matcher, err := NewMatcher(nil, nil, when)
+	assert.NoError(t, err)
+
+	rule, err := newTransformRule(config.ConfigPipelineRules{Transform: functions}, matcher)
+	assert.NoError(t, err)
+
+	next, err := rule.Apply(context.Background(), rs)
+	assert.NoError(t, err)
+	assert.True(t, next)
+}
+
+func Test_TransformRule(t *testing.T) {
+	t.Run("should copy resource attributes", func(t *testing.T) {
+		rs := newResourceSpans(map[string]string{"k8s.pod.labels.app": "checkout"}, &trace.Span{})
+
+		applyTransform(t, rs, "", config.ConfigTransform{
+			Function: "copy",
+			From:     "resource.attributes.k8s.pod.labels.app",
+			To:       "resource.attributes.service.name",
+		})
+
+		_, source := otlputil.FindAttribute(rs.Resource.Attributes, "k8s.pod.labels.app")
+		value, _ := otlputil.FindAttribute(rs.Resource.Attributes, "service.name")
+
+		assert.True(t, source)
+		assert.Equal(t, "checkout", value.GetStringValue())
+	})
+
+	t.Run("should copy values apart from their source", func(t *testing.T) {
+		list := &common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{
+			Values: []*common.AnyValue{stringValue("a")},
+		}}}
+		span := &trace.Span{Attributes: []*common.KeyValue{{Key: "source", Value: list}}}
+
+		applyTransform(t, newResourceSpans(nil, span), "", config.ConfigTransform{
+			Function: "copy",
+			From:     "span.attributes.source",
+			To:       "span.attributes.target",
+		})
+
+		list.GetArrayValue().Values[0] = stringValue("b")
+
+		value, ok := otlputil.FindAttribute(span.Attributes, "target")
+		assert.True(t, ok)
+		assert.Equal(t, "a", value.GetArrayValue().Values[0].GetStringValue())
+	})
+
+	t.Run("should rename attributes across fields", func(t *testing.T) {
+		span := &trace.Span{Attributes: []*common.KeyValue{{Key: "http.target", Value: stringValue("/users")}}}
+		rs := newResourceSpans(nil, span)
+
+		applyTransform(t, rs, "",
+			config.ConfigTransform{Function: "rename", From: "span.attributes.http.target", To: "span.attributes.url.path"},
+			config.ConfigTransform{Function: "copy", From: "span.attributes.url.path", To: "span.name"},
+		)
+
+		assert.Len(t, span.Attributes, 1)
+		assert.Equal(t, "url.path", span.Attributes[0].Key)
+		assert.Equal(t, "/users", span.Name)
+	})
+
+	t.Run("should ignore missing source attributes", func(t *testing.T) {
+		span := &trace.Span{}
+
+		applyTransform(t, newResourceSpans(nil, span), "", config.ConfigTransform{
+			Function: "rename", From: "span.attributes.a", To: "span.attributes.b",
+		})
+
+		assert.Empty(t, span.Attributes)
+	})
+
+	t.Run("should set, delete and truncate attributes", func(t *testing.T) {
+		span := &trace.Span{Attributes: []*common.KeyValue{
+			{Key: "db.statement", Value: stringValue("SELECT ñ FROM users")},
+			{Key: "password", Value: stringValue("secret")},
+		}}
+
+		applyTransform(t, newResourceSpans(nil, span), "",
+			config.ConfigTransform{Function: "delete", Field: "span.attributes.password"},
+			config.ConfigTransform{Function: "truncate", Field: "span.attributes.db.statement", Length: 8},
+			config.ConfigTransform{Function: "set", Field: "span.attributes.normalized", Value: "true"},
+		)
+
+		assert.Len(t, span.Attributes, 2)
+		assert.Equal(t, "SELECT ñ", span.Attributes[0].Value.GetStringValue())
+		assert.Equal(t, "normalized", span.Attributes[1].Key)
+	})
+
+	t.Run("should convert attribute types", func(t *testing.T) {
+		span := &trace.Span{Attributes: []*common.KeyValue{
+			{Key: "http.status_code", Value: stringValue("503")},
+			{Key: "sampled", Value: stringValue("true")},
+			{Key: "ratio", Value: stringValue("0.5")},
+			{Key: "port", Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 8080}}},
+			{Key: "invalid", Value: stringValue("abc")},
+		}}
+
+		applyTransform(t, newResourceSpans(nil, span), "",
+			config.ConfigTransform{Function: "convert", Field: "span.attributes.http.status_code", Type: "int"},
+			config.ConfigTransform{Function: "convert", Field: "span.attributes.sampled", Type: "bool"},
+			config.ConfigTransform{Function: "convert", Field: "span.attributes.ratio", Type: "double"},
+			config.ConfigTransform{Function: "convert", Field: "span.attributes.port", Type: "string"},
+			config.ConfigTransform{Function: "convert", Field: "span.attributes.invalid", Type: "int"},
+		)
+
+		assert.Equal(t, int64(503), span.Attributes[0].Value.GetIntValue())
+		assert.True(t, span.Attributes[1].Value.GetBoolValue())
+		assert.Equal(t, 0.5, span.Attributes[2].Value.GetDoubleValue())
+		assert.Equal(t, "8080", span.Attributes[3].Value.GetStringValue())
+		assert.Equal(t, "abc", span.Attributes[4].Value.GetStringValue())
+	})
+
+	t.Run("should rename spans by pattern and set status", func(t *testing.T) {
+		span := &trace.Span{Name: "GET /users/42"}
+
+		applyTransform(t, newResourceSpans(nil, span), "",
+			config.ConfigTransform{Function: "rename_span", Pattern: `^(GET|POST) /users/\d+$`, Replacement: "$1 /users/{id}"},
+			config.ConfigTransform{Function: "set_status", Code: "error", Message: "normalized"},
+		)
+
+		assert.Equal(t, "GET /users/{id}", span.Name)
+		assert.Equal(t, trace.Status_STATUS_CODE_ERROR, span.Status.Code)
+		assert.Equal(t, "normalized", span.Status.Message)
+	})
+
+	t.Run("should limit attributes and events", func(t *testing.T) {
+		span := &trace.Span{
+			Attributes: []*common.KeyValue{{Key: "a"}, {Key: "b"}, {Key: "c"}},
+			Events:     []*trace.Span_Event{{Name: "a"}, {Name: "b"}},
+		}
+
+		applyTransform(t, newResourceSpans(nil, span), "",
+			config.ConfigTransform{Function: "limit_attributes", Limit: 1},
+			config.ConfigTransform{Function: "limit_events", Limit: 1},
+		)
+
+		assert.Len(t, span.Attributes, 1)
+		assert.Equal(t, uint32(2), span.DroppedAttributesCount)
+		assert.Len(t, span.Events, 1)
+		assert.Equal(t, uint32(1), span.DroppedEventsCount)
+	})
+
+	t.Run("should only transform matching spans", func(t *testing.T) {
+		server := &trace.Span{Name: "server", Kind: trace.Span_SPAN_KIND_SERVER}
+		client := &trace.Span{Name: "client", Kind: trace.Span_SPAN_KIND_CLIENT}
+
+		applyTransform(t, newResourceSpans(nil, server, client), `span.kind == "server"`,
+			config.ConfigTransform{Function: "set", Field: "span.name", Value: "renamed"},
+		)
+
+		assert.Equal(t, "renamed", server.Name)
+		assert.Equal(t, "client", client.Name)
+	})
+}
+
+func Test_NewTransformRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		function config.ConfigTransform
+	}{
+		{name: "should return error for unknown function", function: config.ConfigTransform{Function: "unknown"}},
+		{name: "should return error for unknown field", function: config.ConfigTransform{Function: "set", Field: "span.unknown"}},
+		{name: "should return error for empty attribute key", function: config.ConfigTransform{Function: "delete", Field: "span.attributes."}},
+		{name: "should return error for deleting span name", function: config.ConfigTransform{Function: "delete", Field: "span.name"}},
+		{name: "should return error for truncate without length", function: config.ConfigTransform{Function: "truncate", Field: "span.name"}},
+		{name: "should return error for unknown type", function: config.ConfigTransform{Function: "convert", Field: "span.attributes.a", Type: "date"}},
+		{name: "should return error for converting span name", function: config.ConfigTransform{Function: "convert", Field: "span.name", Type: "int"}},
+		{name: "should return error for invalid pattern", function: config.ConfigTransform{Function: "rename_span", Pattern: "("}},
+		{name: "should return error for unknown status", function: config.ConfigTransform{Function: "set_status", Code: "failed"}},
+		{name: "should return error for empty pattern", function: config.ConfigTransform{Function: "rename_span", Replacement: "renamed"}},
+		{name: "should return error for negative limit", function: config.ConfigTransform{Function: "limit_events", Limit: -1}},
+		{name: "should return error for zero attributes limit", function: config.ConfigTransform{Function: "limit_attributes"}},
+		{name: "should return error for zero events limit", function: config.ConfigTransform{Function: "limit_events"}},
+		{name: "should return error for invalid copy target", function: config.ConfigTransform{Function: "copy", From: "span.name", To: "link.name"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newTransformRule(config.ConfigPipelineRules{Transform: []config.ConfigTransform{tc.function}}, &Matcher{})
+
+			assert.ErrorIs(t, err, ErrInvalidTransform)
+		})
+	}
+}
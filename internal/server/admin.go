@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/tracedock/tracedock/internal/logger"
+)
+
+// AdminServer implements Server interface exposing operational endpoints
+// such as metrics and the service graph over HTTP
+//
+// It doesn't ingest any trace data, so registering a TraceIngestor is a no-op.
+type AdminServer struct {
+	httpServer *http.Server
+	mux        *http.ServeMux
+
+	// cancel ends the requests still running when stopping, such as the
+	// tail streams which would otherwise hold the shutdown
+	cancel context.CancelFunc
+}
+
+// NewAdminServer creates a new admin server
+func NewAdminServer() *AdminServer {
+	return &AdminServer{mux: http.NewServeMux()}
+}
+
+// Handle registers the handler for the given pattern
+func (s *AdminServer) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// ServeHTTP dispatches the request to the handler registered for its path
+func (s *AdminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Start the admin server
+func (s *AdminServer) Start(addr string) error {
+	logger.Info(fmt.Sprintf("starting admin server at %s", addr))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.cancel = cancel
+	s.httpServer = &http.Server{
+		Addr:        addr,
+		Handler:     s.mux,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// Stop the admin server
+func (s *AdminServer) Stop() error {
+	if s.httpServer == nil {
+		return nil
+	}
+
+	s.cancel()
+
+	background := context.Background()
+	ctx, cancel := context.WithTimeout(background, 5*time.Second)
+
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+// RegisterTraceIngestor does nothing as the admin server doesn't receive
+// trace data
+func (s *AdminServer) RegisterTraceIngestor(TraceIngestor) {}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AdminServer_Start(t *testing.T) {
+	t.Run("should start server without a registered ingestor", func(t *testing.T) {
+		var done = make(chan error)
+		var addr = "127.0.0.1:0"
+
+		server := NewAdminServer()
+
+		go func() {
+			done <- server.Start(addr)
+		}()
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+
+		case <-time.After(100 * time.Millisecond):
+			assert.NoError(t, server.Stop())
+		}
+	})
+
+	t.Run("should return no error when stopping a server never started", func(t *testing.T) {
+		assert.NoError(t, NewAdminServer().Stop())
+	})
+}
+
+func Test_AdminServer_Handle(t *testing.T) {
+	t.Run("should dispatch requests to the registered handler", func(t *testing.T) {
+		server := NewAdminServer()
+		server.Handle("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("should return 404 for unknown paths", func(t *testing.T) {
+		server := NewAdminServer()
+
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
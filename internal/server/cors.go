@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tracedock/tracedock/internal/config"
+)
+
+// corsHeaders are always allowed, the browsers send them when posting traces
+var corsHeaders = []string{"Content-Type", "Content-Encoding"}
+
+// cors allows the browsers of the configured origins to post traces, e.g.
+// from RUM SDKs, answering their preflight requests
+type cors struct {
+	origins []string
+	headers string
+	maxAge  string
+}
+
+// newCORS creates the CORS policy of the given config, origins may have
+// wildcards, e.g. https://*.example.com, * allows any origin and no origin is
+// allowed by default
+func newCORS(cfg config.ConfigReceiverHTTPCORS) *cors {
+	c := &cors{
+		origins: cfg.AllowedOrigins,
+		headers: strings.Join(slices.Concat(corsHeaders, cfg.AllowedHeaders), ", "),
+	}
+
+	if cfg.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	return c
+}
+
+// allowed reports whether the origin matches one of the allowed origins
+func (c *cors) allowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+
+	for _, pattern := range c.origins {
+		// path.Match doesn't let * match the slashes of the scheme
+		if pattern == "*" {
+			return true
+		}
+
+		if match, _ := path.Match(pattern, origin); match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setHeaders lets the origin of the request read the response, when it's
+// allowed
+func (c *cors) setHeaders(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+
+	w.Header().Add("Vary", "Origin")
+
+	if c.allowed(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+}
+
+// preflight answers the OPTIONS requests sent by browsers before posting,
+// with 403 for the origins that aren't allowed
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request) {
+	c.setHeaders(w, r)
+
+	if !c.allowed(r.Header.Get("Origin")) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Methods", http.MethodPost)
+	w.Header().Set("Access-Control-Allow-Headers", c.headers)
+
+	if c.maxAge != "" {
+		w.Header().Set("Access-Control-Max-Age", c.maxAge)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/compression"
+)
+
+const (
+	// DefaultMaxBodySize limits the request bodies, after decompression,
+	// when no limit is configured
+	DefaultMaxBodySize = 20 << 20
+
+	// maxPooledBufferSize keeps the buffers grown by unusually large bodies
+	// out of the pool, so they are released
+	maxPooledBufferSize = 4 << 20
+)
+
+// errBodyTooLarge is returned when a request body exceeds the max body size
+var errBodyTooLarge = errors.New("request body too large")
+
+var (
+	bufferPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+	exportRequestPool = sync.Pool{New: func() any { return new(tracecollectorv1.ExportTraceServiceRequest) }}
+)
+
+// readBody reads the request body, decompressing it following its
+// Content-Encoding, into a pooled buffer which must be given back with
+// releaseBuffer
+//
+// Bodies larger than maxSize, before or after decompression, return
+// errBodyTooLarge without being read whole into memory, and unknown encodings
+// return compression.ErrUnsupported.
+func readBody(r *http.Request, maxSize int64) (*bytes.Buffer, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBodySize
+	}
+
+	encoding := contentEncoding(r.Header.Get("Content-Encoding"))
+	if err := compression.Validate(encoding); err != nil {
+		return nil, err
+	}
+
+	if r.ContentLength > maxSize {
+		return nil, errBodyTooLarge
+	}
+
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+
+	if err := compression.Decode(encoding, buf, io.LimitReader(r.Body, maxSize+1), maxSize); err != nil {
+		releaseBuffer(buf)
+
+		if errors.Is(err, compression.ErrTooLarge) {
+			return nil, errBodyTooLarge
+		}
+
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// contentEncoding returns the compression of a Content-Encoding header
+func contentEncoding(header string) string {
+	switch value := strings.ToLower(strings.TrimSpace(header)); value {
+	case "", "identity":
+		return compression.None
+	case "x-gzip":
+		return compression.Gzip
+	default:
+		return value
+	}
+}
+
+func releaseBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+
+	bufferPool.Put(buf)
+}
+
+// acquireExportRequest returns an empty request from the pool, it must be
+// given back with releaseExportRequest once ingested
+func acquireExportRequest() *tracecollectorv1.ExportTraceServiceRequest {
+	return exportRequestPool.Get().(*tracecollectorv1.ExportTraceServiceRequest)
+}
+
+func releaseExportRequest(req *tracecollectorv1.ExportTraceServiceRequest) {
+	proto.Reset(req)
+	exportRequestPool.Put(req)
+}
+
+// bodyStatus returns the status responding a failure to read the body
+func bodyStatus(err error) int {
+	if errors.Is(err, errBodyTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	if errors.Is(err, compression.ErrUnsupported) {
+		return http.StatusUnsupportedMediaType
+	}
+
+	return http.StatusBadRequest
+}
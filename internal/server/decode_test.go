@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+
+	gzw := gzip.NewWriter(&buf)
+	gzw.Write(data)
+	gzw.Close()
+
+	return buf.Bytes()
+}
+
+func Test_readBody(t *testing.T) {
+	t.Run("should read plain and gzip bodies", func(t *testing.T) {
+		for _, encoding := range []string{"", "gzip"} {
+			body := []byte("payload")
+			if encoding == "gzip" {
+				body = gzipped(body)
+			}
+
+			// twice, so pooled buffers and readers are reused
+			for range 2 {
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+				req.Header.Set("Content-Encoding", encoding)
+
+				buf, err := readBody(req, 64)
+
+				assert.NoError(t, err)
+				assert.Equal(t, "payload", buf.String())
+
+				releaseBuffer(buf)
+			}
+		}
+	})
+
+	t.Run("should reject bodies declaring a larger content length", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789"))
+
+		_, err := readBody(req, 5)
+
+		assert.ErrorIs(t, err, errBodyTooLarge)
+	})
+
+	t.Run("should reject larger bodies without content length", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789"))
+		req.ContentLength = -1
+
+		_, err := readBody(req, 5)
+
+		assert.ErrorIs(t, err, errBodyTooLarge)
+	})
+
+	t.Run("should reject bodies larger once decompressed", func(t *testing.T) {
+		body := gzipped(bytes.Repeat([]byte("a"), 1<<20))
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", "gzip")
+
+		_, err := readBody(req, 1<<16)
+
+		assert.Less(t, len(body), 1<<16)
+		assert.ErrorIs(t, err, errBodyTooLarge)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, bodyStatus(err))
+	})
+
+	t.Run("should return error for invalid gzip bodies", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not gzip"))
+		req.Header.Set("Content-Encoding", "gzip")
+
+		_, err := readBody(req, 0)
+
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusBadRequest, bodyStatus(err))
+	})
+}
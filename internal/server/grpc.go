@@ -2,12 +2,17 @@ package server
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net"
 
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
 	"github.com/tracedock/tracedock/internal/logger"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+
+	// registers the compressors, so compressed requests are accepted
+	_ "github.com/tracedock/tracedock/internal/compression"
 
 	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 )
@@ -23,13 +28,26 @@ import (
 type GRPCServer struct {
 	server        *grpc.Server
 	traceIngestor TraceIngestor
+
+	// socketPermissions are applied to Unix domain sockets, in octal
+	socketPermissions string
+
+	metadata clientMetadata
+
 	tracecollectorv1.UnimplementedTraceServiceServer
 }
 
-// NewGRPCServer creates a new gRPC server
-func NewGRPCServer() *GRPCServer {
+// DefaultMaxRecvMsgSize is the size limit of the received messages when none
+// is configured, the same of the HTTP request bodies
+const DefaultMaxRecvMsgSize = DefaultMaxBodySize
+
+// NewGRPCServer creates a new gRPC server, the message size is configured in
+// megabytes and defaults to DefaultMaxRecvMsgSize
+func NewGRPCServer(cfg config.ConfigReceiverGRPC) *GRPCServer {
 	grpcServer := &GRPCServer{
-		server: grpc.NewServer(),
+		server:            grpc.NewServer(serverOptions(cfg)...),
+		socketPermissions: cfg.SocketPermissions,
+		metadata:          newClientMetadata(client.ProtocolOTLPGRPC, cfg.IncludeHeaders, cfg.TenantHeader),
 	}
 
 	tracecollectorv1.RegisterTraceServiceServer(grpcServer.server, grpcServer)
@@ -40,19 +58,16 @@ func NewGRPCServer() *GRPCServer {
 // Export implements the interface UnimplementedTraceServiceServer that allows it
 // to process incoming trace data
 func (s *GRPCServer) Export(ctx context.Context, req *tracecollectorv1.ExportTraceServiceRequest) (*tracecollectorv1.ExportTraceServiceResponse, error) {
-	var err error
-
 	if s.traceIngestor == nil {
 		return nil, ErrNoIngestorRegistered
 	}
 
-	for _, resource := range req.GetResourceSpans() {
-		if thisErr := s.traceIngestor(resource); thisErr != nil {
-			err = errors.Join(err, thisErr)
-		}
+	partial, err := ingest(ctx, s.traceIngestor, s.metadata.fromGRPC(ctx), req.GetResourceSpans())
+	if err != nil {
+		return nil, err
 	}
 
-	return &tracecollectorv1.ExportTraceServiceResponse{}, err
+	return &tracecollectorv1.ExportTraceServiceResponse{PartialSuccess: partial}, nil
 }
 
 // Start the gRPC server
@@ -63,7 +78,7 @@ func (s *GRPCServer) Start(addr string) error {
 		return ErrNoIngestorRegistered
 	}
 
-	listener, err := net.Listen("tcp", addr)
+	listener, err := listen(addr, s.socketPermissions)
 	if err != nil {
 		return err
 	}
@@ -71,6 +86,16 @@ func (s *GRPCServer) Start(addr string) error {
 	return s.server.Serve(listener)
 }
 
+// Serve the gRPC server on an existing listener, which is closed when the
+// server stops
+func (s *GRPCServer) Serve(listener net.Listener) error {
+	if s.traceIngestor == nil {
+		return ErrNoIngestorRegistered
+	}
+
+	return s.server.Serve(listener)
+}
+
 // Stop the gRPC server
 func (s *GRPCServer) Stop() error {
 	s.server.GracefulStop()
@@ -82,3 +107,33 @@ func (s *GRPCServer) Stop() error {
 func (s *GRPCServer) RegisterTraceIngestor(ingestor TraceIngestor) {
 	s.traceIngestor = ingestor
 }
+
+// serverOptions translates the receiver config into gRPC server options,
+// zero values keep the gRPC defaults except for the message size
+func serverOptions(cfg config.ConfigReceiverGRPC) []grpc.ServerOption {
+	maxRecvMsgSize := cfg.MaxRecvMsgSize << 20
+	if maxRecvMsgSize <= 0 {
+		maxRecvMsgSize = DefaultMaxRecvMsgSize
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(maxRecvMsgSize),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:                  cfg.Keepalive.Time,
+			Timeout:               cfg.Keepalive.Timeout,
+			MaxConnectionIdle:     cfg.Keepalive.MaxConnectionIdle,
+			MaxConnectionAge:      cfg.Keepalive.MaxConnectionAge,
+			MaxConnectionAgeGrace: cfg.Keepalive.MaxConnectionAgeGrace,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             cfg.Keepalive.EnforcementPolicy.MinTime,
+			PermitWithoutStream: cfg.Keepalive.EnforcementPolicy.PermitWithoutStream,
+		}),
+	}
+
+	if cfg.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(cfg.MaxConcurrentStreams))
+	}
+
+	return opts
+}
@@ -2,16 +2,53 @@ package server
 
 import (
 	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/compression"
+	"github.com/tracedock/tracedock/internal/config"
 )
 
+// startGRPCServer serves the given server on a random port, returning a
+// client connected to it
+func startGRPCServer(t *testing.T, server *GRPCServer) tracecollectorv1.TraceServiceClient {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	go server.server.Serve(listener)
+	t.Cleanup(func() { server.Stop() })
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return tracecollectorv1.NewTraceServiceClient(conn)
+}
+
+// exportRequestOfSize builds an export request of roughly size bytes
+func exportRequestOfSize(size int) *tracecollectorv1.ExportTraceServiceRequest {
+	return &tracecollectorv1.ExportTraceServiceRequest{ResourceSpans: []*trace.ResourceSpans{{
+		ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{{Name: strings.Repeat("a", size)}}}},
+	}}}
+}
+
 func TestNewGRPCServer(t *testing.T) {
-	server := NewGRPCServer()
+	server := NewGRPCServer(config.ConfigReceiverGRPC{})
 
 	assert.NotNil(t, server)
 	assert.NotNil(t, server.server)
@@ -19,7 +56,7 @@ func TestNewGRPCServer(t *testing.T) {
 
 func Test_GRPCServer_Start(t *testing.T) {
 	t.Run("should return error when no ingestor is registered", func(t *testing.T) {
-		server := NewGRPCServer()
+		server := NewGRPCServer(config.ConfigReceiverGRPC{})
 		err := server.Start(":8080")
 
 		assert.Error(t, err)
@@ -30,9 +67,9 @@ func Test_GRPCServer_Start(t *testing.T) {
 		var addr = "0.0.0.0:8081"
 		var done = make(chan error)
 
-		var ingestor = func(*trace.ResourceSpans) error { return nil }
+		var ingestor = TraceIngestorFunc(func(context.Context, client.Info, []*trace.ResourceSpans) []error { return nil })
 
-		server := NewGRPCServer()
+		server := NewGRPCServer(config.ConfigReceiverGRPC{})
 		server.RegisterTraceIngestor(ingestor)
 
 		go func() {
@@ -53,7 +90,7 @@ func Test_GRPCServer_Start(t *testing.T) {
 
 func Test_GRPCServer_Export(t *testing.T) {
 	t.Run("should return error when no ingestor is registered", func(t *testing.T) {
-		server := NewGRPCServer()
+		server := NewGRPCServer(config.ConfigReceiverGRPC{})
 
 		req := &tracecollectorv1.ExportTraceServiceRequest{
 			ResourceSpans: []*trace.ResourceSpans{
@@ -68,12 +105,15 @@ func Test_GRPCServer_Export(t *testing.T) {
 	t.Run("should process traces successfully", func(t *testing.T) {
 		var processedTraces []*trace.ResourceSpans
 
-		ingestor := func(resource *trace.ResourceSpans) error {
-			processedTraces = append(processedTraces, resource)
+		ingestor := TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			for _, resource := range batch {
+				processedTraces = append(processedTraces, resource)
+			}
+
 			return nil
-		}
+		})
 
-		server := NewGRPCServer()
+		server := NewGRPCServer(config.ConfigReceiverGRPC{})
 		server.RegisterTraceIngestor(ingestor)
 
 		req := &tracecollectorv1.ExportTraceServiceRequest{
@@ -87,15 +127,73 @@ func Test_GRPCServer_Export(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.NotNil(t, resp)
+		assert.Nil(t, resp.PartialSuccess)
 		assert.Len(t, processedTraces, 2)
 	})
 
-	t.Run("should return error when ingestor fails", func(t *testing.T) {
-		ingestor := func(resource *trace.ResourceSpans) error {
-			return assert.AnError
+	t.Run("should ingest the resources of the request together", func(t *testing.T) {
+		var batches int
+
+		server := NewGRPCServer(config.ConfigReceiverGRPC{})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(context.Context, client.Info, []*trace.ResourceSpans) []error {
+			batches++
+			return nil
+		}))
+
+		_, err := server.Export(context.Background(), exportRequestOfSize(1<<10))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, batches)
+	})
+
+	t.Run("should return the spans of the failed resources as partial success", func(t *testing.T) {
+		server := NewGRPCServer(config.ConfigReceiverGRPC{})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			return []error{nil, assert.AnError}
+		}))
+
+		req := &tracecollectorv1.ExportTraceServiceRequest{
+			ResourceSpans: []*trace.ResourceSpans{
+				{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{{}}}}},
+				{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{{}, {}}}}},
+			},
 		}
 
-		server := NewGRPCServer()
+		resp, err := server.Export(context.Background(), req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), resp.PartialSuccess.RejectedSpans)
+		assert.Equal(t, assert.AnError.Error(), resp.PartialSuccess.ErrorMessage)
+	})
+
+	t.Run("should pass the client info to the ingestor", func(t *testing.T) {
+		var info client.Info
+
+		server := NewGRPCServer(config.ConfigReceiverGRPC{
+			IncludeHeaders: []string{"X-Team"},
+			TenantHeader:   "X-Scope-OrgID",
+		})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, received client.Info, _ []*trace.ResourceSpans) []error {
+			info = received
+			return nil
+		}))
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-team", "payments", "x-scope-orgid", "acme")
+		_, err := startGRPCServer(t, server).Export(ctx, exportRequestOfSize(1))
+
+		assert.NoError(t, err)
+		assert.Equal(t, client.ProtocolOTLPGRPC, info.Protocol)
+		assert.Equal(t, "127.0.0.1", info.IP())
+		assert.Equal(t, map[string]string{"x-team": "payments"}, info.Headers)
+		assert.Equal(t, "acme", info.Tenant)
+	})
+
+	t.Run("should return error when ingestor fails", func(t *testing.T) {
+		ingestor := TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			return slices.Repeat([]error{assert.AnError}, len(batch))
+		})
+
+		server := NewGRPCServer(config.ConfigReceiverGRPC{})
 		server.RegisterTraceIngestor(ingestor)
 
 		req := &tracecollectorv1.ExportTraceServiceRequest{
@@ -105,6 +203,137 @@ func Test_GRPCServer_Export(t *testing.T) {
 		}
 
 		_, err := server.Export(context.Background(), req)
-		assert.Error(t, err)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("should return invalid argument to the client when all resources are invalid", func(t *testing.T) {
+		server := NewGRPCServer(config.ConfigReceiverGRPC{})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			return slices.Repeat([]error{status.Error(codes.InvalidArgument, "invalid spans")}, len(batch))
+		}))
+
+		_, err := startGRPCServer(t, server).Export(context.Background(), &tracecollectorv1.ExportTraceServiceRequest{
+			ResourceSpans: []*trace.ResourceSpans{{}},
+		})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Contains(t, status.Convert(err).Message(), "invalid spans")
+	})
+
+	t.Run("should return invalid argument when the resource is rejected", func(t *testing.T) {
+		server := NewGRPCServer(config.ConfigReceiverGRPC{})
+		server.RegisterTraceIngestor(newRejectingIngestor(t))
+
+		_, err := startGRPCServer(t, server).Export(context.Background(), &tracecollectorv1.ExportTraceServiceRequest{
+			ResourceSpans: []*trace.ResourceSpans{
+				{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{validSpan(), invalidSpan()}}}},
+			},
+		})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Contains(t, status.Convert(err).Message(), "invalid spans: invalid trace ID")
+	})
+
+	t.Run("should return the spans of the rejected resources as partial success", func(t *testing.T) {
+		server := NewGRPCServer(config.ConfigReceiverGRPC{})
+		server.RegisterTraceIngestor(newRejectingIngestor(t))
+
+		resp, err := startGRPCServer(t, server).Export(context.Background(), &tracecollectorv1.ExportTraceServiceRequest{
+			ResourceSpans: []*trace.ResourceSpans{
+				{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{validSpan()}}}},
+				{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{validSpan(), invalidSpan()}}}},
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), resp.PartialSuccess.RejectedSpans)
+		assert.Equal(t, "invalid spans: invalid trace ID", resp.PartialSuccess.ErrorMessage)
+	})
+}
+
+func Test_GRPCServer_MaxRecvMsgSize(t *testing.T) {
+	t.Run("should accept messages above the gRPC default by default", func(t *testing.T) {
+		var received int
+
+		server := NewGRPCServer(config.ConfigReceiverGRPC{})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			received += len(batch)
+			return nil
+		}))
+
+		client := startGRPCServer(t, server)
+
+		_, err := client.Export(context.Background(), exportRequestOfSize(8<<20))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, received)
+	})
+
+	t.Run("should reject messages above the configured size", func(t *testing.T) {
+		var received int
+
+		server := NewGRPCServer(config.ConfigReceiverGRPC{MaxRecvMsgSize: 1})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			received += len(batch)
+			return nil
+		}))
+
+		client := startGRPCServer(t, server)
+
+		_, err := client.Export(context.Background(), exportRequestOfSize(2<<20))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		_, err = client.Export(context.Background(), exportRequestOfSize(512<<10))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, received)
+	})
+
+	t.Run("should reject compressed messages above the configured size once decompressed", func(t *testing.T) {
+		for _, name := range compression.Names() {
+			server := NewGRPCServer(config.ConfigReceiverGRPC{MaxRecvMsgSize: 1})
+			server.RegisterTraceIngestor(ingestor)
+
+			_, err := startGRPCServer(t, server).Export(context.Background(), exportRequestOfSize(2<<20), grpc.UseCompressor(name))
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err), name)
+		}
+	})
+}
+
+func Test_serverOptions(t *testing.T) {
+	t.Run("should only limit the concurrent streams when configured", func(t *testing.T) {
+		defaults := serverOptions(config.ConfigReceiverGRPC{})
+		limited := serverOptions(config.ConfigReceiverGRPC{MaxConcurrentStreams: 10})
+
+		assert.Len(t, limited, len(defaults)+1)
+	})
+}
+
+func Test_GRPCServer_StartWithUnixSocket(t *testing.T) {
+	t.Run("should serve on Unix sockets, removing them on stop", func(t *testing.T) {
+		var received int
+
+		path := filepath.Join(t.TempDir(), "tracedock.sock")
+
+		server := NewGRPCServer(config.ConfigReceiverGRPC{})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			received += len(batch)
+			return nil
+		}))
+
+		done := make(chan error)
+		go func() { done <- server.Start("unix://" + path) }()
+
+		conn, err := grpc.NewClient("unix://"+path, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		assert.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		_, err = tracecollectorv1.NewTraceServiceClient(conn).Export(context.Background(), exportRequestOfSize(1), grpc.WaitForReady(true))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, received)
+
+		assert.NoError(t, server.Stop())
+		assert.NoError(t, <-done)
+
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err), "expected the socket to be removed")
 	})
 }
@@ -1,22 +1,24 @@
 package server
 
 import (
-	"compress/gzip"
 	"context"
 	"fmt"
-	"io"
+	"net"
 	"net/http"
-	"regexp"
 	"strings"
 	"time"
 
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
 	"github.com/tracedock/tracedock/internal/logger"
-	prototrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
-	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
 
+// tracesPath is where the OTLP traces are posted
+const tracesPath = "/v1/traces"
+
 // HTTPServer implements Server interface for the HTTP protocol
 //
 // Notice: It isn't implementing 100% of the OpenTelemetry HTTP specification
@@ -30,11 +32,35 @@ import (
 type HTTPServer struct {
 	httpServer    *http.Server
 	traceIngestor TraceIngestor
+
+	cfg      config.ConfigReceiverHTTP
+	handler  http.Handler
+	cors     *cors
+	metadata clientMetadata
+
+	// maxBodySize limits the request bodies, in bytes after decompression
+	maxBodySize int64
 }
 
-// NewHTTPServer creates a new HTTP server
-func NewHTTPServer() *HTTPServer {
-	return &HTTPServer{}
+// NewHTTPServer creates a new HTTP server, the body size is configured in
+// megabytes and defaults to DefaultMaxBodySize, the header size in kilobytes
+func NewHTTPServer(cfg config.ConfigReceiverHTTP) *HTTPServer {
+	s := &HTTPServer{
+		cfg:         cfg,
+		cors:        newCORS(cfg.CORS),
+		metadata:    newClientMetadata(client.ProtocolOTLPHTTP, cfg.IncludeHeaders, cfg.TenantHeader),
+		maxBodySize: maxBodySize(cfg),
+	}
+
+	mux := http.NewServeMux()
+	for _, pattern := range []string{tracesPath, tracesPath + "/{$}"} {
+		mux.HandleFunc(http.MethodPost+" "+pattern, s.handleTraces)
+		mux.HandleFunc(http.MethodOptions+" "+pattern, s.cors.preflight)
+	}
+
+	s.handler = mux
+
+	return s
 }
 
 // Start the HTTP server
@@ -45,18 +71,67 @@ func (s *HTTPServer) Start(addr string) error {
 		return ErrNoIngestorRegistered
 	}
 
-	s.httpServer = &http.Server{
-		Addr:    addr,
-		Handler: http.HandlerFunc(s.HandleRequest),
+	listener, err := listen(addr, s.cfg.SocketPermissions)
+	if err != nil {
+		return err
+	}
+
+	return s.serve(listener, addr)
+}
+
+// Serve the HTTP server on an existing listener, which is closed when the
+// server stops
+func (s *HTTPServer) Serve(listener net.Listener) error {
+	if s.traceIngestor == nil {
+		return ErrNoIngestorRegistered
 	}
 
-	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	return s.serve(listener, listener.Addr().String())
+}
+
+// serve the HTTP server on the listener, addr being the one it was given
+func (s *HTTPServer) serve(listener net.Listener, addr string) error {
+	s.httpServer = newHTTPServer(s.cfg, addr, s.handler)
+
+	if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 		return err
 	}
 
 	return nil
 }
 
+// newHTTPServer creates the http.Server of the HTTP receivers, listening at
+// addr with the configured timeouts and header size, zero values leave them
+// unlimited. Besides HTTP/1, it serves HTTP/2 over cleartext (h2c) to clients
+// with prior knowledge.
+func newHTTPServer(cfg config.ConfigReceiverHTTP, addr string, handler http.Handler) *http.Server {
+	var protocols http.Protocols
+
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		Protocols:         &protocols,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderSize << 10,
+	}
+}
+
+// maxBodySize returns the body size limit of the HTTP receivers, configured
+// in megabytes, in bytes
+func maxBodySize(cfg config.ConfigReceiverHTTP) int64 {
+	if size := int64(cfg.MaxBodySize) << 20; size > 0 {
+		return size
+	}
+
+	return DefaultMaxBodySize
+}
+
 // Stop the HTTP server
 func (s *HTTPServer) Stop() error {
 	if s.httpServer == nil {
@@ -76,23 +151,17 @@ func (s *HTTPServer) RegisterTraceIngestor(ingestor TraceIngestor) {
 	s.traceIngestor = ingestor
 }
 
-// HandleRequest handles incoming HTTP requests in order to get trace ingested
+// HandleRequest handles incoming HTTP requests in order to get trace ingested,
+// routing them to the traces endpoint
 func (s *HTTPServer) HandleRequest(w http.ResponseWriter, r *http.Request) {
-	var contentType = r.Header.Get("Content-Type")
-
-	w.Header().Set("Content-Type", contentType)
+	s.handler.ServeHTTP(w, r)
+}
 
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return
-	}
+// handleTraces ingests the posted traces according to their content type
+func (s *HTTPServer) handleTraces(w http.ResponseWriter, r *http.Request) {
+	s.cors.setHeaders(w, r)
 
-	if match, _ := regexp.MatchString("^/v1/traces(/)?$", r.URL.Path); !match {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
-	switch contentType {
+	switch mediaType(r.Header.Get("Content-Type")) {
 	case "application/json":
 		s.HandleRequestWithJSON(w, r)
 		return
@@ -109,56 +178,52 @@ func (s *HTTPServer) HandleRequest(w http.ResponseWriter, r *http.Request) {
 
 // HandleRequestWithJSON handles requests with content-type equals application/json
 func (s *HTTPServer) HandleRequestWithJSON(w http.ResponseWriter, r *http.Request) {
-	var resourceSpans trace.ResourceSpans
+	s.handleExportRequest(w, r, "application/json", protojson.Unmarshal, protojson.Marshal)
+}
 
-	reqBody, err := io.ReadAll(r.Body)
+// HandleRequestWithJSON handles requests with content-type equals application/protobuf
+func (s *HTTPServer) HandleRequestWithProtobuf(w http.ResponseWriter, r *http.Request) {
+	s.handleExportRequest(w, r, "application/x-protobuf", proto.Unmarshal, proto.Marshal)
+}
+
+// handleExportRequest decodes the body with the given function and ingests
+// its resources, responding with an export response of the same content
+// type. The body and request are pooled so the ingestor must not keep them
+// after returning.
+func (s *HTTPServer) handleExportRequest(w http.ResponseWriter, r *http.Request, contentType string, unmarshal func([]byte, proto.Message) error, marshal func(proto.Message) ([]byte, error)) {
+	body, err := readBody(r, s.maxBodySize)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(bodyStatus(err))
 		return
 	}
+	defer releaseBuffer(body)
+
+	req := acquireExportRequest()
+	defer releaseExportRequest(req)
 
-	if err := protojson.Unmarshal(reqBody, &resourceSpans); err != nil {
+	if err := unmarshal(body.Bytes(), req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	if err := s.traceIngestor(&resourceSpans); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	partial, err := ingest(r.Context(), s.traceIngestor, s.metadata.fromHTTP(r), req.ResourceSpans)
+	if err != nil {
+		w.WriteHeader(httpStatus(err))
 		return
 	}
-}
 
-// HandleRequestWithJSON handles requests with content-type equals application/protobuf
-func (s *HTTPServer) HandleRequestWithProtobuf(w http.ResponseWriter, r *http.Request) {
-	var reqBodyReader = r.Body
-	var resourceSpans prototrace.ExportTraceServiceRequest
-	var contentEncoding = r.Header.Get("Content-Encoding")
-
-	if strings.Contains(contentEncoding, "gzip") {
-		gzr, err := gzip.NewReader(r.Body)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		defer gzr.Close()
-		reqBodyReader = gzr
-	}
-
-	reqBody, err := io.ReadAll(reqBodyReader)
+	resp, err := marshal(&tracecollectorv1.ExportTraceServiceResponse{PartialSuccess: partial})
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	if err := proto.Unmarshal(reqBody, &resourceSpans); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(resp)
+}
 
-	for _, rs := range resourceSpans.ResourceSpans {
-		if err := s.traceIngestor(rs); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-	}
+// mediaType returns the content type without its parameters, e.g. charset
+func mediaType(contentType string) string {
+	value, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(strings.ToLower(value))
 }
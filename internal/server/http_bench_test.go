@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/generator"
+)
+
+// newBenchmarkPayload returns an encoded request of 10 traces with 13 spans
+func newBenchmarkPayload(b *testing.B, compress bool) []byte {
+	gen, err := generator.New(generator.Options{Services: []string{"frontend", "checkout", "payment"}, Depth: 3, FanOut: 2, Seed: 1})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	req := &tracecollectorv1.ExportTraceServiceRequest{}
+	for range 10 {
+		req.ResourceSpans = append(req.ResourceSpans, gen.Trace()...)
+	}
+
+	payload, err := proto.Marshal(req)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if !compress {
+		return payload
+	}
+
+	var buf bytes.Buffer
+
+	gzw := gzip.NewWriter(&buf)
+	gzw.Write(payload)
+	gzw.Close()
+
+	return buf.Bytes()
+}
+
+func Benchmark_HTTPServer_HandleRequestWithProtobuf(b *testing.B) {
+	for _, compress := range []bool{false, true} {
+		name := "plain"
+		if compress {
+			name = "gzip"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			payload := newBenchmarkPayload(b, compress)
+
+			server := NewHTTPServer(config.ConfigReceiverHTTP{})
+			server.RegisterTraceIngestor(TraceIngestorFunc(func(context.Context, client.Info, []*trace.ResourceSpans) []error { return nil }))
+
+			b.ReportAllocs()
+			b.SetBytes(int64(len(payload)))
+
+			for b.Loop() {
+				req := httptest.NewRequest(http.MethodPost, "/v1/traces", bytes.NewReader(payload))
+				req.Header.Set("Content-Type", "application/x-protobuf")
+				if compress {
+					req.Header.Set("Content-Encoding", "gzip")
+				}
+
+				w := httptest.NewRecorder()
+				server.HandleRequestWithProtobuf(w, req)
+
+				if w.Code != http.StatusOK {
+					b.Fatalf("unexpected status %d", w.Code)
+				}
+			}
+		})
+	}
+}
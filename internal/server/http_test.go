@@ -1,24 +1,42 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	collector "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/compression"
+	"github.com/tracedock/tracedock/internal/config"
 )
 
 var addr string = "0.0.0.0:8080"
 
-var ingestor = func(traces *trace.ResourceSpans) error {
+var ingestor = TraceIngestorFunc(func(context.Context, client.Info, []*trace.ResourceSpans) []error {
 	return nil
-}
+})
 
 func Test_HTTPServer_Start(t *testing.T) {
 	t.Run("should return error when no ingestor is registered", func(t *testing.T) {
-		server := NewHTTPServer()
+		server := NewHTTPServer(config.ConfigReceiverHTTP{})
 
 		t.Cleanup(func() {
 			server.Stop()
@@ -28,7 +46,7 @@ func Test_HTTPServer_Start(t *testing.T) {
 	})
 
 	t.Run("should return error when server fails to start", func(t *testing.T) {
-		server := NewHTTPServer()
+		server := NewHTTPServer(config.ConfigReceiverHTTP{})
 		server.RegisterTraceIngestor(ingestor)
 
 		t.Cleanup(func() {
@@ -47,7 +65,7 @@ func Test_HTTPServer_Start(t *testing.T) {
 		var done = make(chan error)
 		var addr string = "0.0.0.0:0"
 
-		server := NewHTTPServer()
+		server := NewHTTPServer(config.ConfigReceiverHTTP{})
 		server.RegisterTraceIngestor(ingestor)
 
 		t.Cleanup(func() {
@@ -103,15 +121,22 @@ func Test_HTTPServer_HandleRequest(t *testing.T) {
 			urlPath:        "/v1/invalid",
 			expectedStatus: 404,
 		},
+		{
+			name:           "should return 404 for paths below the traces path",
+			method:         "POST",
+			contentType:    "application/json",
+			urlPath:        "/v1/traces/invalid",
+			expectedStatus: 404,
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			server := NewHTTPServer()
+			server := NewHTTPServer(config.ConfigReceiverHTTP{})
 
-			server.RegisterTraceIngestor(func(*trace.ResourceSpans) error {
+			server.RegisterTraceIngestor(TraceIngestorFunc(func(context.Context, client.Info, []*trace.ResourceSpans) []error {
 				return nil
-			})
+			}))
 
 			req := httptest.NewRequest(tc.method, tc.urlPath, nil)
 			req.Header.Set("Content-Type", tc.contentType)
@@ -123,7 +148,407 @@ func Test_HTTPServer_HandleRequest(t *testing.T) {
 			defer resp.Body.Close()
 
 			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
-			assert.Equal(t, tc.contentType, resp.Header.Get("Content-Type"))
+			assert.NotEqual(t, tc.contentType, resp.Header.Get("Content-Type"))
+		})
+	}
+}
+
+func Test_HTTPServer_HandleRequestWithJSON(t *testing.T) {
+	t.Run("should ingest each resource of the export request", func(t *testing.T) {
+		var received []string
+
+		server := NewHTTPServer(config.ConfigReceiverHTTP{})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			for _, rs := range batch {
+				received = append(received, rs.ScopeSpans[0].Spans[0].Name)
+			}
+
+			return nil
+		}))
+
+		body := `{"resourceSpans":[{"scopeSpans":[{"spans":[{"name":"first"}]}]},{"scopeSpans":[{"spans":[{"name":"second"}]}]}]}`
+
+		req := httptest.NewRequest("POST", "/v1/traces", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, []string{"first", "second"}, received)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, "{}", w.Body.String())
+	})
+
+	t.Run("should respond with the partial success of the request", func(t *testing.T) {
+		server := NewHTTPServer(config.ConfigReceiverHTTP{})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			return []error{assert.AnError, nil}
+		}))
+
+		body := `{"resourceSpans":[{"scopeSpans":[{"spans":[{"name":"first"}]}]},{"scopeSpans":[{"spans":[{"name":"second"}]}]}]}`
+
+		req := httptest.NewRequest("POST", "/v1/traces", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.JSONEq(t, `{"partialSuccess":{"rejectedSpans":"1","errorMessage":"`+assert.AnError.Error()+`"}}`, w.Body.String())
+	})
+
+	for _, tc := range []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"should return 503 when no resource is ingested", assert.AnError, http.StatusServiceUnavailable},
+		{"should return 400 when all resources are invalid", status.Error(codes.InvalidArgument, "invalid spans"), http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewHTTPServer(config.ConfigReceiverHTTP{})
+			server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+				return slices.Repeat([]error{tc.err}, len(batch))
+			}))
+
+			req := httptest.NewRequest("POST", "/v1/traces", strings.NewReader(`{"resourceSpans":[{}]}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			server.HandleRequest(w, req)
+
+			assert.Equal(t, tc.expected, w.Code)
+		})
+	}
+
+	for _, tc := range []struct {
+		name      string
+		resources []*trace.ResourceSpans
+		expected  int
+		response  string
+	}{
+		{
+			"should return 400 when the resource is rejected",
+			[]*trace.ResourceSpans{
+				{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{validSpan(), invalidSpan()}}}},
+			},
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"should respond with the spans of the rejected resources",
+			[]*trace.ResourceSpans{
+				{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{validSpan()}}}},
+				{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{validSpan(), invalidSpan()}}}},
+			},
+			http.StatusOK,
+			`{"partialSuccess":{"rejectedSpans":"2","errorMessage":"invalid spans: invalid trace ID"}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewHTTPServer(config.ConfigReceiverHTTP{})
+			server.RegisterTraceIngestor(newRejectingIngestor(t))
+
+			body, err := protojson.Marshal(&collector.ExportTraceServiceRequest{ResourceSpans: tc.resources})
+			assert.NoError(t, err)
+
+			req := httptest.NewRequest("POST", "/v1/traces", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			server.HandleRequest(w, req)
+
+			assert.Equal(t, tc.expected, w.Code)
+
+			if tc.response != "" {
+				assert.JSONEq(t, tc.response, w.Body.String())
+			}
 		})
 	}
+
+	t.Run("should pass the client info to the ingestor", func(t *testing.T) {
+		var info client.Info
+
+		server := NewHTTPServer(config.ConfigReceiverHTTP{
+			IncludeHeaders: []string{"User-Agent", "X-Missing"},
+			TenantHeader:   "X-Scope-OrgID",
+		})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, received client.Info, _ []*trace.ResourceSpans) []error {
+			info = received
+			return nil
+		}))
+
+		req := httptest.NewRequest("POST", "/v1/traces", strings.NewReader(`{"resourceSpans":[{}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "checkout/1.0")
+		req.Header.Set("X-Scope-OrgID", "acme")
+		req.RemoteAddr = "10.0.0.5:43210"
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, client.Info{
+			Protocol: client.ProtocolOTLPHTTP,
+			Addr:     "10.0.0.5:43210",
+			Headers:  map[string]string{"user-agent": "checkout/1.0"},
+			Tenant:   "acme",
+		}, info)
+	})
+
+	t.Run("should accept the traces path with a trailing slash and charset", func(t *testing.T) {
+		server := NewHTTPServer(config.ConfigReceiverHTTP{})
+		server.RegisterTraceIngestor(ingestor)
+
+		req := httptest.NewRequest("POST", "/v1/traces/", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, 200, w.Code)
+	})
+}
+
+func Test_HTTPServer_HandleRequestWithCORS(t *testing.T) {
+	server := NewHTTPServer(config.ConfigReceiverHTTP{CORS: config.ConfigReceiverHTTPCORS{
+		AllowedOrigins: []string{"https://*.example.com"},
+		AllowedHeaders: []string{"x-api-key"},
+		MaxAge:         time.Hour,
+	}})
+	server.RegisterTraceIngestor(ingestor)
+
+	t.Run("should answer the preflight of allowed origins", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/v1/traces", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, 204, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "POST", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Content-Type, Content-Encoding, x-api-key", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "3600", w.Header().Get("Access-Control-Max-Age"))
+	})
+
+	t.Run("should return 403 to the preflight of other origins", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/v1/traces", nil)
+		req.Header.Set("Origin", "https://example.org")
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, 403, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("should let allowed origins read the response", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/v1/traces", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Origin", "https://app.example.com")
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	})
+}
+
+func Test_HTTPServer_HandleRequestWithAnyOrigin(t *testing.T) {
+	t.Run("should answer the preflight of any origin", func(t *testing.T) {
+		server := NewHTTPServer(config.ConfigReceiverHTTP{CORS: config.ConfigReceiverHTTPCORS{AllowedOrigins: []string{"*"}}})
+		server.RegisterTraceIngestor(ingestor)
+
+		req := httptest.NewRequest("OPTIONS", "/v1/traces", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, 204, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
+
+func Test_newHTTPServer(t *testing.T) {
+	serve := func(t *testing.T, cfg config.ConfigReceiverHTTP) string {
+		server := NewHTTPServer(cfg)
+		server.RegisterTraceIngestor(ingestor)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		httpServer := newHTTPServer(cfg, "", server.handler)
+		go httpServer.Serve(listener)
+		t.Cleanup(func() { httpServer.Close() })
+
+		return listener.Addr().String()
+	}
+
+	t.Run("should close connections not sending the headers in time", func(t *testing.T) {
+		addr := serve(t, config.ConfigReceiverHTTP{ReadHeaderTimeout: 50 * time.Millisecond})
+
+		conn, err := net.Dial("tcp", addr)
+		assert.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		_, err = conn.Write([]byte("POST /v1/traces HTTP/1.1\r\nHost: localhost\r\n"))
+		assert.NoError(t, err)
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = io.ReadAll(conn)
+		assert.NoError(t, err, "expected the server to close the connection")
+	})
+
+	t.Run("should return 431 for headers above the max header size", func(t *testing.T) {
+		addr := serve(t, config.ConfigReceiverHTTP{MaxHeaderSize: 1})
+
+		req, _ := http.NewRequest("POST", "http://"+addr+"/v1/traces", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-padding", strings.Repeat("a", 8<<10))
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, resp.StatusCode)
+	})
+}
+
+func Test_HTTPServer_HandleRequestWithCompression(t *testing.T) {
+	export := &collector.ExportTraceServiceRequest{ResourceSpans: []*trace.ResourceSpans{
+		{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{{Name: "first"}}}}},
+	}}
+
+	for _, contentType := range []string{"application/json", "application/x-protobuf"} {
+		var body []byte
+
+		if contentType == "application/json" {
+			body, _ = protojson.Marshal(export)
+		} else {
+			body, _ = proto.Marshal(export)
+		}
+
+		for _, name := range compression.Names() {
+			t.Run("should decode "+contentType+" bodies compressed with "+name, func(t *testing.T) {
+				var received []string
+
+				server := NewHTTPServer(config.ConfigReceiverHTTP{})
+				server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+					for _, rs := range batch {
+						received = append(received, rs.ScopeSpans[0].Spans[0].Name)
+					}
+
+					return nil
+				}))
+
+				compressed, err := compression.Encode(name, body)
+				assert.NoError(t, err)
+
+				req := httptest.NewRequest("POST", "/v1/traces", bytes.NewReader(compressed))
+				req.Header.Set("Content-Type", contentType)
+				req.Header.Set("Content-Encoding", name)
+				w := httptest.NewRecorder()
+
+				server.HandleRequest(w, req)
+
+				assert.Equal(t, 200, w.Code)
+				assert.Equal(t, []string{"first"}, received)
+			})
+		}
+	}
+
+	t.Run("should return 415 for unsupported encodings", func(t *testing.T) {
+		server := NewHTTPServer(config.ConfigReceiverHTTP{})
+		server.RegisterTraceIngestor(ingestor)
+
+		req := httptest.NewRequest("POST", "/v1/traces", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "br")
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, 415, w.Code)
+	})
+}
+
+func Test_HTTPServer_HandleRequestWithProtobuf(t *testing.T) {
+	t.Run("should return 413 for bodies above the max body size", func(t *testing.T) {
+		var called bool
+
+		server := NewHTTPServer(config.ConfigReceiverHTTP{MaxBodySize: 1})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(context.Context, client.Info, []*trace.ResourceSpans) []error {
+			called = true
+			return nil
+		}))
+
+		req := httptest.NewRequest("POST", "/v1/traces", strings.NewReader(strings.Repeat("a", 2<<20)))
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, 413, w.Code)
+		assert.False(t, called)
+	})
+}
+
+func Test_HTTPServer_StartWithUnixSocket(t *testing.T) {
+	t.Run("should serve HTTP/1 and h2c on Unix sockets", func(t *testing.T) {
+		var received int
+
+		path := filepath.Join(t.TempDir(), "tracedock.sock")
+
+		server := NewHTTPServer(config.ConfigReceiverHTTP{SocketPermissions: "0660"})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			received += len(batch)
+			return nil
+		}))
+
+		go server.Start("unix://" + path)
+
+		assert.Eventually(t, func() bool {
+			_, err := os.Stat(path)
+			return err == nil
+		}, time.Second, 10*time.Millisecond)
+
+		for _, h2c := range []bool{false, true} {
+			var protocols http.Protocols
+
+			protocols.SetHTTP1(!h2c)
+			protocols.SetUnencryptedHTTP2(h2c)
+
+			client := &http.Client{Transport: &http.Transport{
+				Protocols: &protocols,
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", path)
+				},
+			}}
+
+			resp, err := client.Post("http://tracedock/v1/traces", "application/json", strings.NewReader(`{"resourceSpans":[{}]}`))
+			assert.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, 200, resp.StatusCode)
+			assert.Equal(t, h2c, resp.ProtoMajor == 2)
+		}
+
+		assert.Equal(t, 2, received)
+
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o660), info.Mode().Perm())
+
+		assert.NoError(t, server.Stop())
+
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err), "expected the socket to be removed")
+	})
 }
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/jaegertracing/jaeger-idl/proto-gen/api_v2"
+	"github.com/jaegertracing/jaeger-idl/thrift-gen/jaeger"
+	"google.golang.org/grpc"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/gogocodec"
+	"github.com/tracedock/tracedock/internal/logger"
+	"github.com/tracedock/tracedock/internal/translator"
+)
+
+// JaegerGRPCServer implements Server interface receiving spans through the
+// Jaeger collector gRPC API, translating them into OTLP
+type JaegerGRPCServer struct {
+	server        *grpc.Server
+	traceIngestor TraceIngestor
+
+	// socketPermissions are applied to Unix domain sockets, in octal
+	socketPermissions string
+
+	metadata clientMetadata
+}
+
+// NewJaegerGRPCServer creates a new Jaeger gRPC server, sharing the options of
+// the OTLP gRPC receiver
+func NewJaegerGRPCServer(cfg config.ConfigReceiverGRPC) *JaegerGRPCServer {
+	opts := append(serverOptions(cfg), grpc.ForceServerCodec(gogocodec.Codec{}))
+
+	grpcServer := &JaegerGRPCServer{
+		server:            grpc.NewServer(opts...),
+		socketPermissions: cfg.SocketPermissions,
+		metadata:          newClientMetadata(client.ProtocolJaegerGRPC, cfg.IncludeHeaders, cfg.TenantHeader),
+	}
+
+	api_v2.RegisterCollectorServiceServer(grpcServer.server, grpcServer)
+
+	return grpcServer
+}
+
+// PostSpans implements the Jaeger CollectorServiceServer interface ingesting
+// the spans of the batch
+func (s *JaegerGRPCServer) PostSpans(ctx context.Context, req *api_v2.PostSpansRequest) (*api_v2.PostSpansResponse, error) {
+	if s.traceIngestor == nil {
+		return nil, ErrNoIngestorRegistered
+	}
+
+	// the Jaeger API has no partial success, the rejected spans are only
+	// logged so the batch isn't retried
+	partial, err := ingest(ctx, s.traceIngestor, s.metadata.fromGRPC(ctx), translator.JaegerToOTLP(&req.Batch))
+	if err != nil {
+		return nil, err
+	}
+
+	logPartialSuccess(partial)
+
+	return &api_v2.PostSpansResponse{}, nil
+}
+
+// Start the Jaeger gRPC server
+func (s *JaegerGRPCServer) Start(addr string) error {
+	logger.Info(fmt.Sprintf("starting Jaeger gRPC server at %s", addr))
+
+	if s.traceIngestor == nil {
+		return ErrNoIngestorRegistered
+	}
+
+	listener, err := listen(addr, s.socketPermissions)
+	if err != nil {
+		return err
+	}
+
+	return s.server.Serve(listener)
+}
+
+// Stop the Jaeger gRPC server
+func (s *JaegerGRPCServer) Stop() error {
+	s.server.GracefulStop()
+	return nil
+}
+
+// RegisterTraceIngestor registers a TraceIngestor function that will process all the
+// incoming trace data
+func (s *JaegerGRPCServer) RegisterTraceIngestor(ingestor TraceIngestor) {
+	s.traceIngestor = ingestor
+}
+
+// JaegerHTTPServer implements Server interface receiving Thrift encoded
+// batches through the Jaeger collector HTTP API, translating them into OTLP
+type JaegerHTTPServer struct {
+	httpServer    *http.Server
+	traceIngestor TraceIngestor
+	metadata      clientMetadata
+
+	cfg config.ConfigReceiverHTTP
+
+	// maxBodySize limits the request bodies, in bytes after decompression
+	maxBodySize int64
+}
+
+// NewJaegerHTTPServer creates a new Jaeger HTTP server, sharing the settings
+// of the OTLP HTTP receiver but CORS and the client metadata
+func NewJaegerHTTPServer(cfg config.ConfigReceiverHTTP) *JaegerHTTPServer {
+	return &JaegerHTTPServer{
+		metadata:    newClientMetadata(client.ProtocolJaegerHTTP, nil, ""),
+		cfg:         cfg,
+		maxBodySize: maxBodySize(cfg),
+	}
+}
+
+// Start the Jaeger HTTP server
+func (s *JaegerHTTPServer) Start(addr string) error {
+	logger.Info(fmt.Sprintf("starting Jaeger HTTP server at %s", addr))
+
+	if s.traceIngestor == nil {
+		return ErrNoIngestorRegistered
+	}
+
+	s.httpServer = newHTTPServer(s.cfg, addr, http.HandlerFunc(s.HandleRequest))
+
+	listener, err := listen(addr, s.cfg.SocketPermissions)
+	if err != nil {
+		return err
+	}
+
+	if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// Stop the Jaeger HTTP server
+func (s *JaegerHTTPServer) Stop() error {
+	if s.httpServer == nil {
+		return nil
+	}
+
+	background := context.Background()
+	ctx, cancel := context.WithTimeout(background, 5*time.Second)
+
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+// RegisterTraceIngestor registers a TraceIngestor function that will process all the
+// incoming trace data
+func (s *JaegerHTTPServer) RegisterTraceIngestor(ingestor TraceIngestor) {
+	s.traceIngestor = ingestor
+}
+
+// HandleRequest handles incoming Thrift batches, responding 202 once they are
+// ingested
+func (s *JaegerHTTPServer) HandleRequest(w http.ResponseWriter, r *http.Request) {
+	var batch jaeger.Batch
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Path != "/api/traces" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch mediaType(r.Header.Get("Content-Type")) {
+	case "application/x-thrift", "application/vnd.apache.thrift.binary":
+	default:
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := readBody(r, s.maxBodySize)
+	if err != nil {
+		w.WriteHeader(bodyStatus(err))
+		return
+	}
+	defer releaseBuffer(body)
+
+	if err := thrift.NewTDeserializer().Read(r.Context(), &batch, body.Bytes()); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	partial, err := ingest(r.Context(), s.traceIngestor, s.metadata.fromHTTP(r), translator.JaegerThriftToOTLP(&batch))
+	if err != nil {
+		w.WriteHeader(httpStatus(err))
+		return
+	}
+
+	logPartialSuccess(partial)
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	model "github.com/jaegertracing/jaeger-idl/model/v1"
+	"github.com/jaegertracing/jaeger-idl/proto-gen/api_v2"
+	"github.com/jaegertracing/jaeger-idl/thrift-gen/jaeger"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/gogocodec"
+)
+
+func Test_JaegerGRPCServer_PostSpans(t *testing.T) {
+	t.Run("should return error when no ingestor is registered", func(t *testing.T) {
+		server := NewJaegerGRPCServer(config.ConfigReceiverGRPC{})
+
+		_, err := server.PostSpans(context.Background(), &api_v2.PostSpansRequest{})
+		assert.Equal(t, ErrNoIngestorRegistered, err)
+		assert.Equal(t, ErrNoIngestorRegistered, server.Start("127.0.0.1:0"))
+	})
+
+	t.Run("should ingest spans sent by a Jaeger client", func(t *testing.T) {
+		var received []*trace.ResourceSpans
+
+		server := NewJaegerGRPCServer(config.ConfigReceiverGRPC{})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			for _, rs := range batch {
+				received = append(received, rs)
+			}
+
+			return nil
+		}))
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		go server.server.Serve(listener)
+		t.Cleanup(func() { server.Stop() })
+
+		conn, err := grpc.NewClient(listener.Addr().String(),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithDefaultCallOptions(grpc.ForceCodec(gogocodec.Codec{})),
+		)
+		assert.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		_, err = api_v2.NewCollectorServiceClient(conn).PostSpans(context.Background(), &api_v2.PostSpansRequest{
+			Batch: model.Batch{
+				Process: &model.Process{ServiceName: "checkout"},
+				Spans:   []*model.Span{{TraceID: model.NewTraceID(0, 1), SpanID: model.NewSpanID(2), OperationName: "GET"}},
+			},
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, received, 1)
+		assert.Equal(t, "GET", received[0].ScopeSpans[0].Spans[0].Name)
+	})
+
+	t.Run("should return error when ingestor fails", func(t *testing.T) {
+		server := NewJaegerGRPCServer(config.ConfigReceiverGRPC{})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			return slices.Repeat([]error{assert.AnError}, len(batch))
+		}))
+
+		_, err := server.PostSpans(context.Background(), &api_v2.PostSpansRequest{
+			Batch: model.Batch{Spans: []*model.Span{{}}},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func Test_JaegerHTTPServer_HandleRequest(t *testing.T) {
+	body, err := thrift.NewTSerializer().Write(context.Background(), &jaeger.Batch{
+		Process: &jaeger.Process{ServiceName: "checkout"},
+		Spans:   []*jaeger.Span{{TraceIdLow: 1, SpanId: 2, OperationName: "GET"}},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		method         string
+		urlPath        string
+		contentType    string
+		body           []byte
+		expectedStatus int
+		expectedSpans  int
+	}{
+		{
+			name:           "should ingest thrift batches",
+			method:         http.MethodPost,
+			urlPath:        "/api/traces",
+			contentType:    "application/x-thrift",
+			body:           body,
+			expectedStatus: http.StatusAccepted,
+			expectedSpans:  1,
+		},
+		{
+			name:           "should ingest thrift batches with the binary content type",
+			method:         http.MethodPost,
+			urlPath:        "/api/traces",
+			contentType:    "application/vnd.apache.thrift.binary",
+			body:           body,
+			expectedStatus: http.StatusAccepted,
+			expectedSpans:  1,
+		},
+		{
+			name:           "should return 400 for malformed body",
+			method:         http.MethodPost,
+			urlPath:        "/api/traces",
+			contentType:    "application/x-thrift",
+			body:           []byte{0xff},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should return 415 for invalid content-type",
+			method:         http.MethodPost,
+			urlPath:        "/api/traces",
+			contentType:    "application/json",
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "should return 405 for invalid method",
+			method:         http.MethodGet,
+			urlPath:        "/api/traces",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "should return 404 for inexistent path",
+			method:         http.MethodPost,
+			urlPath:        "/api/spans",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var spans int
+
+			server := NewJaegerHTTPServer(config.ConfigReceiverHTTP{})
+			server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+				for _, rs := range batch {
+					spans += len(rs.ScopeSpans[0].Spans)
+				}
+
+				return nil
+			}))
+
+			req := httptest.NewRequest(tc.method, tc.urlPath, bytes.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+
+			server.HandleRequest(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedSpans, spans)
+		})
+	}
+
+	t.Run("should return 413 for bodies above the configured max body size", func(t *testing.T) {
+		server := NewJaegerHTTPServer(config.ConfigReceiverHTTP{MaxBodySize: 1})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(context.Context, client.Info, []*trace.ResourceSpans) []error { return nil }))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/traces", bytes.NewReader(make([]byte, 1<<20+1)))
+		req.Header.Set("Content-Type", "application/x-thrift")
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// unixScheme prefixes the addresses of Unix domain sockets, e.g.
+// unix:///var/run/tracedock.sock
+const unixScheme = "unix://"
+
+// listen listens at the given address, either a TCP host:port or a Unix
+// domain socket path prefixed by unix://. Sockets left behind by a previous
+// run are removed, the permissions are applied to the new one, in octal, and
+// it is removed again when the listener is closed.
+func listen(addr string, permissions string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixScheme)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	mode, err := socketMode(permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and isn't a socket", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+
+	return listener, nil
+}
+
+// socketMode parses the octal permissions of a socket, e.g. 0660, leaving
+// the ones given by the umask when empty
+func socketMode(permissions string) (fs.FileMode, error) {
+	if permissions == "" {
+		return 0, nil
+	}
+
+	mode, err := strconv.ParseUint(permissions, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid socket permissions %q", permissions)
+	}
+
+	return fs.FileMode(mode), nil
+}
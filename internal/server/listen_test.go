@@ -0,0 +1,64 @@
+package server
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_listen(t *testing.T) {
+	t.Run("should listen on TCP addresses", func(t *testing.T) {
+		listener, err := listen("127.0.0.1:0", "")
+		assert.NoError(t, err)
+		defer listener.Close()
+
+		assert.Equal(t, "tcp", listener.Addr().Network())
+	})
+
+	t.Run("should listen on Unix sockets with the given permissions", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tracedock.sock")
+
+		listener, err := listen("unix://"+path, "0600")
+		assert.NoError(t, err)
+
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, fs.ModeSocket, info.Mode().Type())
+		assert.Equal(t, fs.FileMode(0o600), info.Mode().Perm())
+
+		assert.NoError(t, listener.Close())
+
+		_, err = os.Stat(path)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("should replace sockets left behind", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tracedock.sock")
+
+		stale, err := net.Listen("unix", path)
+		assert.NoError(t, err)
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		stale.Close()
+
+		listener, err := listen("unix://"+path, "")
+		assert.NoError(t, err)
+		defer listener.Close()
+	})
+
+	t.Run("should not replace files that aren't sockets", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "tracedock.sock")
+		assert.NoError(t, os.WriteFile(path, nil, 0o600))
+
+		_, err := listen("unix://"+path, "")
+		assert.ErrorContains(t, err, "isn't a socket")
+	})
+
+	t.Run("should return error for invalid permissions", func(t *testing.T) {
+		_, err := listen("unix://"+filepath.Join(t.TempDir(), "tracedock.sock"), "rw-rw----")
+		assert.ErrorContains(t, err, "invalid socket permissions")
+	})
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/tracedock/tracedock/internal/client"
+)
+
+// clientMetadata builds the client.Info of the received requests, keeping the
+// configured headers and reading the tenant from one of them
+type clientMetadata struct {
+	protocol     string
+	headers      []string
+	tenantHeader string
+}
+
+// newClientMetadata creates the builder of the client info of a protocol,
+// header names are case insensitive
+func newClientMetadata(protocol string, headers []string, tenantHeader string) clientMetadata {
+	m := clientMetadata{protocol: protocol, tenantHeader: strings.ToLower(tenantHeader)}
+
+	for _, name := range headers {
+		m.headers = append(m.headers, strings.ToLower(name))
+	}
+
+	return m
+}
+
+// fromHTTP returns the info of the client sending the HTTP request
+func (m clientMetadata) fromHTTP(r *http.Request) client.Info {
+	return m.build(r.RemoteAddr, r.Header.Get)
+}
+
+// fromGRPC returns the info of the gRPC peer, read from the context of the
+// call
+func (m clientMetadata) fromGRPC(ctx context.Context) client.Info {
+	var addr string
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	return m.build(addr, func(name string) string {
+		if values := md.Get(name); len(values) > 0 {
+			return values[0]
+		}
+
+		return ""
+	})
+}
+
+func (m clientMetadata) build(addr string, header func(string) string) client.Info {
+	info := client.Info{Protocol: m.protocol, Addr: addr}
+
+	for _, name := range m.headers {
+		if value := header(name); value != "" {
+			if info.Headers == nil {
+				info.Headers = make(map[string]string, len(m.headers))
+			}
+
+			info.Headers[name] = value
+		}
+	}
+
+	if m.tenantHeader != "" {
+		info.Tenant = header(m.tenantHeader)
+	}
+
+	return info
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/tracedock/tracedock/internal/client"
+)
+
+func Test_clientMetadata_fromHTTP(t *testing.T) {
+	t.Run("should keep the configured headers regardless of their case", func(t *testing.T) {
+		m := newClientMetadata(client.ProtocolOTLPHTTP, []string{"X-Team", "x-missing"}, "X-Scope-OrgID")
+
+		req := httptest.NewRequest("POST", "/v1/traces", nil)
+		req.Header.Set("x-team", "payments")
+		req.Header.Set("X-Other", "ignored")
+		req.Header.Set("X-SCOPE-ORGID", "acme")
+		req.RemoteAddr = "10.0.0.5:43210"
+
+		assert.Equal(t, client.Info{
+			Protocol: client.ProtocolOTLPHTTP,
+			Addr:     "10.0.0.5:43210",
+			Headers:  map[string]string{"x-team": "payments"},
+			Tenant:   "acme",
+		}, m.fromHTTP(req))
+	})
+
+	t.Run("should leave out headers and tenant when not configured", func(t *testing.T) {
+		m := newClientMetadata(client.ProtocolZipkin, nil, "")
+
+		req := httptest.NewRequest("POST", "/api/v2/spans", nil)
+		req.Header.Set("X-Scope-OrgID", "acme")
+		req.RemoteAddr = "10.0.0.5:43210"
+
+		assert.Equal(t, client.Info{Protocol: client.ProtocolZipkin, Addr: "10.0.0.5:43210"}, m.fromHTTP(req))
+	})
+}
+
+func Test_clientMetadata_fromGRPC(t *testing.T) {
+	t.Run("should read the peer address and metadata", func(t *testing.T) {
+		m := newClientMetadata(client.ProtocolJaegerGRPC, []string{"User-Agent"}, "X-Scope-OrgID")
+
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 43210}})
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("user-agent", "grpc-go", "x-scope-orgid", "acme"))
+
+		assert.Equal(t, client.Info{
+			Protocol: client.ProtocolJaegerGRPC,
+			Addr:     "10.0.0.5:43210",
+			Headers:  map[string]string{"user-agent": "grpc-go"},
+			Tenant:   "acme",
+		}, m.fromGRPC(ctx))
+	})
+
+	t.Run("should return only the protocol without peer and metadata", func(t *testing.T) {
+		m := newClientMetadata(client.ProtocolOTLPGRPC, []string{"user-agent"}, "x-scope-orgid")
+
+		assert.Equal(t, client.Info{Protocol: client.ProtocolOTLPGRPC}, m.fromGRPC(context.Background()))
+	})
+}
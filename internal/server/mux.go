@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tracedock/tracedock/internal/logger"
+)
+
+// MuxServer implements Server interface serving OTLP over gRPC and HTTP on a
+// single address, HTTP/2 requests with the application/grpc content type are
+// dispatched to the gRPC server and the others to the HTTP server
+//
+// Notice: gRPC requests are served by the HTTP/2 implementation of net/http,
+// so the keepalive and concurrent streams options of the gRPC server don't
+// apply, the HTTP timeouts do instead.
+type MuxServer struct {
+	grpcServer *GRPCServer
+	httpServer *HTTPServer
+
+	server *http.Server
+}
+
+// NewMuxServer creates a server multiplexing the given gRPC and HTTP servers,
+// which must not be started themselves
+func NewMuxServer(grpcServer *GRPCServer, httpServer *HTTPServer) *MuxServer {
+	return &MuxServer{
+		grpcServer: grpcServer,
+		httpServer: httpServer,
+	}
+}
+
+// Start the multiplexed server, listening with the HTTP server settings
+func (s *MuxServer) Start(addr string) error {
+	logger.Info(fmt.Sprintf("starting gRPC and HTTP server at %s", addr))
+
+	if s.grpcServer.traceIngestor == nil || s.httpServer.traceIngestor == nil {
+		return ErrNoIngestorRegistered
+	}
+
+	s.server = newHTTPServer(s.httpServer.cfg, addr, s)
+
+	listener, err := listen(addr, s.httpServer.cfg.SocketPermissions)
+	if err != nil {
+		return err
+	}
+
+	if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// Stop the multiplexed server
+func (s *MuxServer) Stop() error {
+	if s.server == nil {
+		return nil
+	}
+
+	background := context.Background()
+	ctx, cancel := context.WithTimeout(background, 5*time.Second)
+
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// RegisterTraceIngestor registers a TraceIngestor function that will process all the
+// incoming trace data of both servers
+func (s *MuxServer) RegisterTraceIngestor(ingestor TraceIngestor) {
+	s.grpcServer.RegisterTraceIngestor(ingestor)
+	s.httpServer.RegisterTraceIngestor(ingestor)
+}
+
+// ServeHTTP dispatches gRPC requests to the gRPC server and the others to the
+// HTTP server
+func (s *MuxServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		s.grpcServer.server.ServeHTTP(w, r)
+		return
+	}
+
+	s.httpServer.HandleRequest(w, r)
+}
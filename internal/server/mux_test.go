@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+)
+
+func Test_MuxServer_Start(t *testing.T) {
+	t.Run("should return error when no ingestor is registered", func(t *testing.T) {
+		server := NewMuxServer(NewGRPCServer(config.ConfigReceiverGRPC{}), NewHTTPServer(config.ConfigReceiverHTTP{}))
+
+		assert.Equal(t, ErrNoIngestorRegistered, server.Start("127.0.0.1:0"))
+	})
+
+	t.Run("should serve gRPC and HTTP on the same address", func(t *testing.T) {
+		var received []string
+
+		path := filepath.Join(t.TempDir(), "tracedock.sock")
+
+		server := NewMuxServer(NewGRPCServer(config.ConfigReceiverGRPC{}), NewHTTPServer(config.ConfigReceiverHTTP{}))
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			for _, rs := range batch {
+				received = append(received, rs.ScopeSpans[0].Spans[0].Name)
+			}
+
+			return nil
+		}))
+
+		done := make(chan error)
+		go func() { done <- server.Start("unix://" + path) }()
+
+		assert.Eventually(t, func() bool {
+			_, err := os.Stat(path)
+			return err == nil
+		}, time.Second, 10*time.Millisecond)
+
+		conn, err := grpc.NewClient("unix://"+path, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		assert.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		_, err = tracecollectorv1.NewTraceServiceClient(conn).Export(context.Background(), &tracecollectorv1.ExportTraceServiceRequest{
+			ResourceSpans: []*trace.ResourceSpans{{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{{Name: "grpc"}}}}}},
+		})
+		assert.NoError(t, err)
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		}}
+
+		body := `{"resourceSpans":[{"scopeSpans":[{"spans":[{"name":"http"}]}]}]}`
+
+		resp, err := client.Post("http://tracedock/v1/traces", "application/json", strings.NewReader(body))
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, []string{"grpc", "http"}, received)
+
+		assert.NoError(t, server.Stop())
+		assert.NoError(t, <-done)
+	})
+}
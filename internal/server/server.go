@@ -1,9 +1,20 @@
 package server
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
 
+	tracecollectorv1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/logger"
 )
 
 var (
@@ -12,17 +23,164 @@ var (
 	ErrNoIngestorRegistered = errors.New("no trace ingestor registered")
 )
 
-// TraceIngestor is the function signature for processing trace data
-type TraceIngestor func(*trace.ResourceSpans) error
+// TraceIngestor processes the trace data received by the servers, along with
+// the information about the client that sent it
+//
+// Receivers may reuse the resources once IngestTraces returns, so they must
+// not be kept after that, proto.Clone them instead.
+type TraceIngestor interface {
+	// IngestTraces ingests all the resources received in a request at once,
+	// returning the error of each of them by index, nil when all of them
+	// were ingested
+	IngestTraces(ctx context.Context, info client.Info, batch []*trace.ResourceSpans) []error
+}
+
+// PartialError is implemented by the errors of the resources only ingested in
+// part, telling how many of their spans were rejected
+type PartialError interface {
+	error
+
+	// RejectedSpans returns the number of spans of the resource rejected
+	RejectedSpans() int
+}
+
+// TraceIngestorFunc adapts a function into a TraceIngestor
+type TraceIngestorFunc func(ctx context.Context, info client.Info, batch []*trace.ResourceSpans) []error
+
+// IngestTraces calls f(ctx, info, batch)
+func (f TraceIngestorFunc) IngestTraces(ctx context.Context, info client.Info, batch []*trace.ResourceSpans) []error {
+	return f(ctx, info, batch)
+}
+
+// ingest passes the batch to the ingestor, returning the partial success of
+// the resources it couldn't ingest, all their spans being rejected unless the
+// error is a PartialError rejecting only some of them.
+//
+// When none of the resources were ingested it fails instead, so the client
+// retries the whole batch without duplicating data. The status is
+// InvalidArgument when all of them were invalid, which clients don't retry,
+// and Unavailable otherwise.
+func ingest(ctx context.Context, ingestor TraceIngestor, info client.Info, batch []*trace.ResourceSpans) (*tracecollectorv1.ExportTracePartialSuccess, error) {
+	var partial tracecollectorv1.ExportTracePartialSuccess
+	var messages []string
+	var failed int
+
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	// spans are counted upfront as the ingestor may remove some of them
+	counts := make([]int, len(batch))
+	for n, rs := range batch {
+		counts[n] = spanCount(rs)
+	}
+
+	errs := ingestor.IngestTraces(ctx, info, batch)
+
+	for n, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		// only trusted as is, joined with other errors the resource failed
+		// after some of its spans were rejected
+		if partialErr, ok := err.(PartialError); ok && partialErr.RejectedSpans() < counts[n] {
+			partial.RejectedSpans += int64(partialErr.RejectedSpans())
+		} else {
+			failed++
+			partial.RejectedSpans += int64(counts[n])
+		}
+
+		// resources usually fail for the same reason, e.g. an exporter down
+		if message := err.Error(); !slices.Contains(messages, message) {
+			messages = append(messages, message)
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	partial.ErrorMessage = strings.Join(messages, "; ")
+
+	if failed == len(batch) {
+		code := codes.InvalidArgument
+
+		for _, err := range errs {
+			if errorCode(err) != codes.InvalidArgument {
+				code = codes.Unavailable
+			}
+		}
+
+		return nil, status.Error(code, partial.ErrorMessage)
+	}
+
+	return &partial, nil
+}
+
+// errorCode returns the gRPC code of the error of a resource, InvalidArgument
+// when it, or all the errors it joins, carry that code and Unavailable
+// otherwise, as exporters failing usually recover
+func errorCode(err error) codes.Code {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			if errorCode(err) != codes.InvalidArgument {
+				return codes.Unavailable
+			}
+		}
+
+		return codes.InvalidArgument
+	}
+
+	if status.Code(err) == codes.InvalidArgument {
+		return codes.InvalidArgument
+	}
+
+	return codes.Unavailable
+}
+
+// httpStatus returns the HTTP status of an error returned by ingest
+func httpStatus(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// logPartialSuccess warns about the spans rejected by receivers that can't
+// tell their clients
+func logPartialSuccess(partial *tracecollectorv1.ExportTracePartialSuccess) {
+	if partial == nil {
+		return
+	}
+
+	logger.Warn(fmt.Sprintf("%d spans rejected: %s", partial.RejectedSpans, partial.ErrorMessage))
+}
+
+// spanCount returns the number of spans of the resource
+func spanCount(rs *trace.ResourceSpans) int {
+	var count int
+
+	for _, ss := range rs.GetScopeSpans() {
+		count += len(ss.GetSpans())
+	}
+
+	return count
+}
 
 // Server defines the interface for the trace server
 type Server interface {
-	// Start the server
+	// Start the server listening at addr, a TCP host:port or, for the
+	// receivers supporting it, a Unix domain socket as unix:///path
 	Start(addr string) error
 
 	// Stop the server
 	Stop() error
 
-	// RegisterTraceIngestor registers function for processing trace data
+	// RegisterTraceIngestor registers the ingestor processing the trace data
 	RegisterTraceIngestor(TraceIngestor)
 }
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/orchestrator"
+	"github.com/tracedock/tracedock/internal/validation"
+)
+
+// partialError is the error of a resource only ingested in part
+type partialError struct {
+	rejected int
+}
+
+func (e *partialError) Error() string {
+	return "invalid spans"
+}
+
+func (e *partialError) RejectedSpans() int {
+	return e.rejected
+}
+
+// newRejectingIngestor returns an ingestor rejecting the resources with
+// invalid spans, validSpan and invalidSpan returning spans of both kinds
+func newRejectingIngestor(t *testing.T) *orchestrator.Ingestor {
+	cfg := config.NewConfig()
+	cfg.Validation = config.ConfigValidation{Enabled: true, Policy: validation.PolicyReject}
+	cfg.Pipelines = []config.ConfigPipeline{
+		{Name: "default", Rules: []config.ConfigPipelineRules{{Provider: "setter", Set: map[string]string{"env": "prod"}}}},
+	}
+
+	ingestor, err := orchestrator.NewIngestor(cfg)
+	assert.NoError(t, err)
+
+	return ingestor
+}
+
+func validSpan() *trace.Span {
+	return &trace.Span{TraceId: []byte{15: 1}, SpanId: []byte{7: 1}}
+}
+
+func invalidSpan() *trace.Span {
+	return &trace.Span{SpanId: []byte{7: 2}}
+}
+
+func Test_ingest(t *testing.T) {
+	var newResource = func(spans int) *trace.ResourceSpans {
+		return &trace.ResourceSpans{ScopeSpans: []*trace.ScopeSpans{{Spans: make([]*trace.Span, spans)}}}
+	}
+
+	var failing = func(errs ...error) TraceIngestor {
+		return TraceIngestorFunc(func(context.Context, client.Info, []*trace.ResourceSpans) []error {
+			return errs
+		})
+	}
+
+	batch := []*trace.ResourceSpans{newResource(1), newResource(2), newResource(3)}
+
+	t.Run("should return nothing when all resources are ingested", func(t *testing.T) {
+		partial, err := ingest(context.Background(), failing(), client.Info{}, batch)
+
+		assert.NoError(t, err)
+		assert.Nil(t, partial)
+	})
+
+	t.Run("should not call the ingestor with an empty batch", func(t *testing.T) {
+		partial, err := ingest(context.Background(), nil, client.Info{}, nil)
+
+		assert.NoError(t, err)
+		assert.Nil(t, partial)
+	})
+
+	t.Run("should reject the spans of the failed resources", func(t *testing.T) {
+		unavailable := errors.New("exporter unavailable")
+
+		partial, err := ingest(context.Background(), failing(unavailable, nil, unavailable), client.Info{}, batch)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), partial.RejectedSpans)
+		assert.Equal(t, "exporter unavailable", partial.ErrorMessage)
+	})
+
+	t.Run("should only reject the spans told by partial errors", func(t *testing.T) {
+		invalid := &partialError{rejected: 1}
+
+		partial, err := ingest(context.Background(), failing(invalid, invalid, fmt.Errorf("pipeline: %w", invalid)), client.Info{}, batch)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), partial.RejectedSpans)
+		assert.Equal(t, "invalid spans; pipeline: invalid spans", partial.ErrorMessage)
+	})
+
+	t.Run("should count the spans before they are ingested", func(t *testing.T) {
+		batch := []*trace.ResourceSpans{newResource(2), newResource(1)}
+
+		partial, err := ingest(context.Background(), TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			batch[0].ScopeSpans = nil
+			return []error{assert.AnError, nil}
+		}), client.Info{}, batch)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), partial.RejectedSpans)
+	})
+
+	t.Run("should fail as unavailable when no resource is ingested", func(t *testing.T) {
+		_, err := ingest(context.Background(), failing(assert.AnError, errors.New("invalid"), assert.AnError), client.Info{}, batch)
+
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, assert.AnError.Error()+"; invalid", status.Convert(err).Message())
+	})
+
+	t.Run("should fail as invalid argument when all resources are invalid", func(t *testing.T) {
+		invalid := status.Error(codes.InvalidArgument, "invalid spans")
+
+		_, err := ingest(context.Background(), failing(
+			invalid,
+			fmt.Errorf("pipeline: %w", invalid),
+			errors.Join(invalid, fmt.Errorf("pipeline: %w", invalid)),
+		), client.Info{}, batch)
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("should fail as unavailable when invalid resources also failed otherwise", func(t *testing.T) {
+		invalid := status.Error(codes.InvalidArgument, "invalid spans")
+
+		_, err := ingest(context.Background(), failing(invalid, invalid, errors.Join(invalid, assert.AnError)), client.Info{}, batch)
+
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+func Test_httpStatus(t *testing.T) {
+	assert.Equal(t, http.StatusBadRequest, httpStatus(status.Error(codes.InvalidArgument, "invalid")))
+	assert.Equal(t, http.StatusServiceUnavailable, httpStatus(status.Error(codes.Unavailable, "unavailable")))
+	assert.Equal(t, http.StatusInternalServerError, httpStatus(assert.AnError))
+}
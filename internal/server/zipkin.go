@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/logger"
+	"github.com/tracedock/tracedock/internal/translator"
+)
+
+// ZipkinServer implements Server interface receiving spans through the Zipkin
+// v2 HTTP API, both in JSON and protobuf, translating them into OTLP
+//
+// For more details: https://zipkin.io/zipkin-api/#/default/post_spans
+type ZipkinServer struct {
+	httpServer    *http.Server
+	traceIngestor TraceIngestor
+	metadata      clientMetadata
+
+	cfg config.ConfigReceiverHTTP
+
+	// maxBodySize limits the request bodies, in bytes after decompression
+	maxBodySize int64
+}
+
+// NewZipkinServer creates a new Zipkin server, sharing the settings of the
+// OTLP HTTP receiver but CORS and the client metadata
+func NewZipkinServer(cfg config.ConfigReceiverHTTP) *ZipkinServer {
+	return &ZipkinServer{
+		metadata:    newClientMetadata(client.ProtocolZipkin, nil, ""),
+		cfg:         cfg,
+		maxBodySize: maxBodySize(cfg),
+	}
+}
+
+// Start the Zipkin server
+func (s *ZipkinServer) Start(addr string) error {
+	logger.Info(fmt.Sprintf("starting Zipkin server at %s", addr))
+
+	if s.traceIngestor == nil {
+		return ErrNoIngestorRegistered
+	}
+
+	s.httpServer = newHTTPServer(s.cfg, addr, http.HandlerFunc(s.HandleRequest))
+
+	listener, err := listen(addr, s.cfg.SocketPermissions)
+	if err != nil {
+		return err
+	}
+
+	if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// Stop the Zipkin server
+func (s *ZipkinServer) Stop() error {
+	if s.httpServer == nil {
+		return nil
+	}
+
+	background := context.Background()
+	ctx, cancel := context.WithTimeout(background, 5*time.Second)
+
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+// RegisterTraceIngestor registers a TraceIngestor function that will process all the
+// incoming trace data
+func (s *ZipkinServer) RegisterTraceIngestor(ingestor TraceIngestor) {
+	s.traceIngestor = ingestor
+}
+
+// HandleRequest handles incoming Zipkin spans, responding 202 once they are
+// ingested
+func (s *ZipkinServer) HandleRequest(w http.ResponseWriter, r *http.Request) {
+	var spans []*zipkinmodel.SpanModel
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Path != "/api/v2/spans" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	body, err := readBody(r, s.maxBodySize)
+	if err != nil {
+		w.WriteHeader(bodyStatus(err))
+		return
+	}
+	defer releaseBuffer(body)
+
+	// Zipkin reporters may omit the content type, JSON is the default
+	switch mediaType(r.Header.Get("Content-Type")) {
+	case "", "application/json":
+		err = json.Unmarshal(body.Bytes(), &spans)
+	case "application/x-protobuf":
+		spans, err = zipkin_proto3.ParseSpans(body.Bytes(), false)
+	default:
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	partial, err := ingest(r.Context(), s.traceIngestor, s.metadata.fromHTTP(r), translator.ZipkinToOTLP(spans))
+	if err != nil {
+		w.WriteHeader(httpStatus(err))
+		return
+	}
+
+	logPartialSuccess(partial)
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	"github.com/stretchr/testify/assert"
+
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/client"
+	"github.com/tracedock/tracedock/internal/config"
+)
+
+const zipkinJSON = `[{
+	"traceId": "0000000000000001",
+	"id": "0000000000000002",
+	"name": "get /cart",
+	"kind": "SERVER",
+	"timestamp": 1700000000000000,
+	"duration": 1000,
+	"localEndpoint": {"serviceName": "checkout"},
+	"tags": {"http.method": "GET"}
+}]`
+
+func Test_ZipkinServer_Start(t *testing.T) {
+	t.Run("should return error when no ingestor is registered", func(t *testing.T) {
+		server := NewZipkinServer(config.ConfigReceiverHTTP{})
+
+		assert.Equal(t, ErrNoIngestorRegistered, server.Start("127.0.0.1:0"))
+		assert.NoError(t, server.Stop())
+	})
+}
+
+func Test_ZipkinServer_HandleRequest(t *testing.T) {
+	protoBody, err := zipkin_proto3.SpanSerializer{}.Serialize([]*zipkinmodel.SpanModel{{
+		SpanContext:   zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{Low: 1}, ID: 2},
+		Name:          "get /cart",
+		LocalEndpoint: &zipkinmodel.Endpoint{ServiceName: "checkout"},
+	}})
+	assert.NoError(t, err)
+
+	var gzipBody bytes.Buffer
+	gzw := gzip.NewWriter(&gzipBody)
+	gzw.Write([]byte(zipkinJSON))
+	gzw.Close()
+
+	tests := []struct {
+		name            string
+		method          string
+		urlPath         string
+		contentType     string
+		contentEncoding string
+		body            []byte
+		expectedStatus  int
+		expectedSpans   int
+	}{
+		{
+			name:           "should ingest JSON spans",
+			method:         http.MethodPost,
+			urlPath:        "/api/v2/spans",
+			contentType:    "application/json; charset=utf-8",
+			body:           []byte(zipkinJSON),
+			expectedStatus: http.StatusAccepted,
+			expectedSpans:  1,
+		},
+		{
+			name:           "should ingest JSON spans without content type",
+			method:         http.MethodPost,
+			urlPath:        "/api/v2/spans",
+			body:           []byte(zipkinJSON),
+			expectedStatus: http.StatusAccepted,
+			expectedSpans:  1,
+		},
+		{
+			name:            "should ingest gzip compressed spans",
+			method:          http.MethodPost,
+			urlPath:         "/api/v2/spans",
+			contentType:     "application/json",
+			contentEncoding: "gzip",
+			body:            gzipBody.Bytes(),
+			expectedStatus:  http.StatusAccepted,
+			expectedSpans:   1,
+		},
+		{
+			name:           "should ingest protobuf spans",
+			method:         http.MethodPost,
+			urlPath:        "/api/v2/spans",
+			contentType:    "application/x-protobuf",
+			body:           protoBody,
+			expectedStatus: http.StatusAccepted,
+			expectedSpans:  1,
+		},
+		{
+			name:           "should return 400 for malformed body",
+			method:         http.MethodPost,
+			urlPath:        "/api/v2/spans",
+			contentType:    "application/json",
+			body:           []byte("{"),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "should return 415 for invalid content-type",
+			method:         http.MethodPost,
+			urlPath:        "/api/v2/spans",
+			contentType:    "text/plain",
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "should return 405 for invalid method",
+			method:         http.MethodGet,
+			urlPath:        "/api/v2/spans",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "should return 404 for inexistent path",
+			method:         http.MethodPost,
+			urlPath:        "/api/v1/spans",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var spans int
+
+			server := NewZipkinServer(config.ConfigReceiverHTTP{})
+			server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+				for _, rs := range batch {
+					spans += len(rs.ScopeSpans[0].Spans)
+				}
+
+				return nil
+			}))
+
+			req := httptest.NewRequest(tc.method, tc.urlPath, bytes.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			req.Header.Set("Content-Encoding", tc.contentEncoding)
+			w := httptest.NewRecorder()
+
+			server.HandleRequest(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedSpans, spans)
+		})
+	}
+
+	t.Run("should return 503 when ingestor fails", func(t *testing.T) {
+		server := NewZipkinServer(config.ConfigReceiverHTTP{})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(_ context.Context, _ client.Info, batch []*trace.ResourceSpans) []error {
+			return slices.Repeat([]error{assert.AnError}, len(batch))
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v2/spans", bytes.NewReader([]byte(zipkinJSON)))
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+
+	t.Run("should return 413 for bodies above the configured max body size", func(t *testing.T) {
+		server := NewZipkinServer(config.ConfigReceiverHTTP{MaxBodySize: 1})
+		server.RegisterTraceIngestor(TraceIngestorFunc(func(context.Context, client.Info, []*trace.ResourceSpans) []error { return nil }))
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v2/spans", bytes.NewReader(make([]byte, 1<<20+1)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		server.HandleRequest(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+}
@@ -0,0 +1,333 @@
+package servicegraph
+
+import (
+	"container/list"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/metrics"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+const (
+	// AttributeServiceName is the resource attribute identifying a service
+	AttributeServiceName = otlputil.AttributeServiceName
+
+	// AttributePeerService is the span attribute naming the remote service
+	// a client span is calling
+	AttributePeerService = "peer.service"
+
+	// UnknownService is the name used for resources without service.name
+	UnknownService = otlputil.UnknownService
+)
+
+// Node is a service present in the graph
+type Node struct {
+	Name string `json:"name"`
+}
+
+// Edge is a connection between a caller and a callee service
+type Edge struct {
+	Client       string  `json:"client"`
+	Server       string  `json:"server"`
+	Requests     uint64  `json:"requests"`
+	Failures     uint64  `json:"failures"`
+	LatencyAvgMs float64 `json:"latency_avg_ms"`
+	LatencyMaxMs float64 `json:"latency_max_ms"`
+
+	// Virtual is true when the server side was never observed and the
+	// callee was inferred from the peer.service attribute
+	Virtual bool `json:"virtual"`
+
+	latencySum time.Duration
+	latencyMax time.Duration
+}
+
+// Snapshot is the state of the graph at a given moment
+type Snapshot struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+type edgeKey struct {
+	client string
+	server string
+}
+
+// half holds the spans observed for a single call while waiting for
+// both the client and the server side to arrive
+type half struct {
+	key     string
+	arrival time.Time
+	element *list.Element
+
+	client        string
+	server        string
+	peerService   string
+	clientLatency time.Duration
+	serverLatency time.Duration
+	failed        bool
+	hasClient     bool
+	hasServer     bool
+}
+
+// Graph builds the dependencies between services by pairing client and
+// server spans of the same call
+//
+// Both halves can arrive in any order as long as they are no more than
+// the configured wait apart. Expired pairs with a client side carrying
+// peer.service are recorded as virtual edges, other ones are discarded.
+type Graph struct {
+	mu       sync.Mutex
+	wait     time.Duration
+	maxItems int
+	now      func() time.Time
+
+	pending map[string]*half
+	arrival *list.List
+	edges   map[edgeKey]*Edge
+
+	requests *prometheus.CounterVec
+	failures *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	expired  prometheus.Counter
+}
+
+// NewGraph creates a new service graph
+func NewGraph(cfg config.ConfigServiceGraph) *Graph {
+	labels := []string{"client", "server"}
+
+	return &Graph{
+		wait:     cfg.Wait,
+		maxItems: cfg.MaxItems,
+		now:      time.Now,
+		pending:  make(map[string]*half),
+		arrival:  list.New(),
+		edges:    make(map[edgeKey]*Edge),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "servicegraph",
+			Name:      "requests_total",
+			Help:      "Total of requests between two services",
+		}, labels),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "servicegraph",
+			Name:      "failed_requests_total",
+			Help:      "Total of failed requests between two services",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "servicegraph",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of the requests between two services",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		expired: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: "servicegraph",
+			Name:      "expired_edges_total",
+			Help:      "Total of calls whose client and server spans weren't paired in time",
+		}),
+	}
+}
+
+// Observe inspects the spans of the given resource looking for client
+// and server sides of calls between services
+func (g *Graph) Observe(rs *trace.ResourceSpans) {
+	if rs == nil {
+		return
+	}
+
+	service := otlputil.ServiceName(rs)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	g.expire(now)
+
+	for _, ss := range rs.ScopeSpans {
+		for _, span := range ss.Spans {
+			g.observeSpan(now, service, span)
+		}
+	}
+}
+
+func (g *Graph) observeSpan(now time.Time, service string, span *trace.Span) {
+	var key string
+
+	switch span.Kind {
+	case trace.Span_SPAN_KIND_CLIENT, trace.Span_SPAN_KIND_PRODUCER:
+		key = callKey(span.TraceId, span.SpanId)
+
+	case trace.Span_SPAN_KIND_SERVER, trace.Span_SPAN_KIND_CONSUMER:
+		if len(span.ParentSpanId) == 0 {
+			return
+		}
+		key = callKey(span.TraceId, span.ParentSpanId)
+
+	default:
+		return
+	}
+
+	h, ok := g.pending[key]
+	if !ok {
+		if g.maxItems > 0 && len(g.pending) >= g.maxItems {
+			g.evict(g.arrival.Front().Value.(*half))
+		}
+
+		h = &half{key: key, arrival: now}
+		h.element = g.arrival.PushBack(h)
+		g.pending[key] = h
+	}
+
+	latency := otlputil.SpanDuration(span)
+	failed := span.Status != nil && span.Status.Code == trace.Status_STATUS_CODE_ERROR
+
+	if span.Kind == trace.Span_SPAN_KIND_CLIENT || span.Kind == trace.Span_SPAN_KIND_PRODUCER {
+		h.hasClient = true
+		h.client = service
+		h.clientLatency = latency
+		if value, ok := otlputil.FindAttribute(span.Attributes, AttributePeerService); ok {
+			h.peerService = value.GetStringValue()
+		}
+	} else {
+		h.hasServer = true
+		h.server = service
+		h.serverLatency = latency
+	}
+
+	h.failed = h.failed || failed
+
+	if h.hasClient && h.hasServer {
+		g.remove(h)
+		g.record(h.client, h.server, h.clientLatency, h.failed, false)
+	}
+}
+
+// expire evicts all the pending calls older than the configured wait
+func (g *Graph) expire(now time.Time) {
+	for e := g.arrival.Front(); e != nil; e = g.arrival.Front() {
+		h := e.Value.(*half)
+		if now.Sub(h.arrival) < g.wait {
+			return
+		}
+
+		g.evict(h)
+	}
+}
+
+func (g *Graph) evict(h *half) {
+	g.remove(h)
+	g.expired.Inc()
+
+	if h.hasClient && h.peerService != "" {
+		g.record(h.client, h.peerService, h.clientLatency, h.failed, true)
+	}
+}
+
+func (g *Graph) remove(h *half) {
+	g.arrival.Remove(h.element)
+	delete(g.pending, h.key)
+}
+
+func (g *Graph) record(client, server string, latency time.Duration, failed, virtual bool) {
+	key := edgeKey{client, server}
+
+	edge, ok := g.edges[key]
+	if !ok {
+		edge = &Edge{Client: client, Server: server, Virtual: virtual}
+		g.edges[key] = edge
+	}
+
+	edge.Virtual = edge.Virtual && virtual
+	edge.Requests++
+	edge.latencySum += latency
+	edge.latencyMax = max(edge.latencyMax, latency)
+
+	g.requests.WithLabelValues(client, server).Inc()
+	g.latency.WithLabelValues(client, server).Observe(latency.Seconds())
+
+	if failed {
+		edge.Failures++
+		g.failures.WithLabelValues(client, server).Inc()
+	}
+}
+
+// Snapshot returns the current nodes and edges of the graph
+func (g *Graph) Snapshot() Snapshot {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.expire(g.now())
+
+	var snapshot = Snapshot{Nodes: []Node{}, Edges: []Edge{}}
+	var nodes = make(map[string]struct{})
+
+	for _, edge := range g.edges {
+		e := *edge
+		e.LatencyAvgMs = float64(edge.latencySum.Microseconds()) / float64(edge.Requests) / 1000
+		e.LatencyMaxMs = float64(edge.latencyMax.Microseconds()) / 1000
+
+		snapshot.Edges = append(snapshot.Edges, e)
+		nodes[edge.Client] = struct{}{}
+		nodes[edge.Server] = struct{}{}
+	}
+
+	for name := range nodes {
+		snapshot.Nodes = append(snapshot.Nodes, Node{Name: name})
+	}
+
+	sort.Slice(snapshot.Nodes, func(i, j int) bool {
+		return snapshot.Nodes[i].Name < snapshot.Nodes[j].Name
+	})
+
+	sort.Slice(snapshot.Edges, func(i, j int) bool {
+		if snapshot.Edges[i].Client != snapshot.Edges[j].Client {
+			return snapshot.Edges[i].Client < snapshot.Edges[j].Client
+		}
+		return snapshot.Edges[i].Server < snapshot.Edges[j].Server
+	})
+
+	return snapshot
+}
+
+// ServeHTTP responds with the current graph encoded as JSON
+func (g *Graph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(g.Snapshot())
+}
+
+// Describe implements prometheus.Collector
+func (g *Graph) Describe(ch chan<- *prometheus.Desc) {
+	g.requests.Describe(ch)
+	g.failures.Describe(ch)
+	g.latency.Describe(ch)
+	g.expired.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (g *Graph) Collect(ch chan<- prometheus.Metric) {
+	g.requests.Collect(ch)
+	g.failures.Collect(ch)
+	g.latency.Collect(ch)
+	g.expired.Collect(ch)
+}
+
+func callKey(traceID, spanID []byte) string {
+	return hex.EncodeToString(traceID) + hex.EncodeToString(spanID)
+}
@@ -0,0 +1,188 @@
+package servicegraph
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/config"
+)
+
+func newResourceSpans(service string, spans ...*trace.Span) *trace.ResourceSpans {
+	return &trace.ResourceSpans{
+		Resource: &resource.Resource{
+			Attributes: []*common.KeyValue{
+				{Key: AttributeServiceName, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: service}}},
+			},
+		},
+		ScopeSpans: []*trace.ScopeSpans{{Spans: spans}},
+	}
+}
+
+func newClientSpan(spanID byte, duration time.Duration) *trace.Span {
+	return &trace.Span{
+		TraceId:           []byte{1},
+		SpanId:            []byte{spanID},
+		Kind:              trace.Span_SPAN_KIND_CLIENT,
+		StartTimeUnixNano: 1000,
+		EndTimeUnixNano:   1000 + uint64(duration),
+	}
+}
+
+func newServerSpan(parentID byte) *trace.Span {
+	return &trace.Span{
+		TraceId:      []byte{1},
+		SpanId:       []byte{parentID + 100},
+		ParentSpanId: []byte{parentID},
+		Kind:         trace.Span_SPAN_KIND_SERVER,
+	}
+}
+
+func newGraph(now *time.Time) *Graph {
+	graph := NewGraph(config.ConfigServiceGraph{Wait: 10 * time.Second, MaxItems: 2})
+	graph.now = func() time.Time { return *now }
+
+	return graph
+}
+
+func Test_Graph_Observe(t *testing.T) {
+	t.Run("should handle nil ResourceSpans", func(t *testing.T) {
+		var now = time.Now()
+		graph := newGraph(&now)
+
+		graph.Observe(nil)
+
+		assert.Empty(t, graph.Snapshot().Edges)
+	})
+
+	t.Run("should pair client and server spans", func(t *testing.T) {
+		var now = time.Now()
+		graph := newGraph(&now)
+
+		graph.Observe(newResourceSpans("frontend", newClientSpan(1, 20*time.Millisecond)))
+		graph.Observe(newResourceSpans("backend", newServerSpan(1)))
+
+		snapshot := graph.Snapshot()
+
+		assert.Equal(t, []Node{{Name: "backend"}, {Name: "frontend"}}, snapshot.Nodes)
+		assert.Len(t, snapshot.Edges, 1)
+		assert.Equal(t, "frontend", snapshot.Edges[0].Client)
+		assert.Equal(t, "backend", snapshot.Edges[0].Server)
+		assert.Equal(t, uint64(1), snapshot.Edges[0].Requests)
+		assert.Equal(t, 20.0, snapshot.Edges[0].LatencyAvgMs)
+		assert.False(t, snapshot.Edges[0].Virtual)
+		assert.Equal(t, 1.0, testutil.ToFloat64(graph.requests.WithLabelValues("frontend", "backend")))
+	})
+
+	t.Run("should pair spans arriving out of order", func(t *testing.T) {
+		var now = time.Now()
+		graph := newGraph(&now)
+
+		graph.Observe(newResourceSpans("backend", newServerSpan(1)))
+		now = now.Add(5 * time.Second)
+		graph.Observe(newResourceSpans("frontend", newClientSpan(1, time.Millisecond)))
+
+		assert.Len(t, graph.Snapshot().Edges, 1)
+	})
+
+	t.Run("should count failures from either side", func(t *testing.T) {
+		var now = time.Now()
+		graph := newGraph(&now)
+
+		server := newServerSpan(1)
+		server.Status = &trace.Status{Code: trace.Status_STATUS_CODE_ERROR}
+
+		graph.Observe(newResourceSpans("frontend", newClientSpan(1, time.Millisecond)))
+		graph.Observe(newResourceSpans("backend", server))
+
+		assert.Equal(t, uint64(1), graph.Snapshot().Edges[0].Failures)
+		assert.Equal(t, 1.0, testutil.ToFloat64(graph.failures.WithLabelValues("frontend", "backend")))
+	})
+
+	t.Run("should not pair halves further apart than the wait", func(t *testing.T) {
+		var now = time.Now()
+		graph := newGraph(&now)
+
+		graph.Observe(newResourceSpans("frontend", newClientSpan(1, time.Millisecond)))
+		now = now.Add(11 * time.Second)
+		graph.Observe(newResourceSpans("backend", newServerSpan(1)))
+
+		assert.Empty(t, graph.Snapshot().Edges)
+		assert.Equal(t, 1.0, testutil.ToFloat64(graph.expired))
+	})
+
+	t.Run("should create virtual edge from peer.service when server never arrives", func(t *testing.T) {
+		var now = time.Now()
+		graph := newGraph(&now)
+
+		client := newClientSpan(1, time.Millisecond)
+		client.Attributes = []*common.KeyValue{
+			{Key: AttributePeerService, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "redis"}}},
+		}
+
+		graph.Observe(newResourceSpans("frontend", client))
+		now = now.Add(11 * time.Second)
+
+		snapshot := graph.Snapshot()
+
+		assert.Len(t, snapshot.Edges, 1)
+		assert.Equal(t, "redis", snapshot.Edges[0].Server)
+		assert.True(t, snapshot.Edges[0].Virtual)
+	})
+
+	t.Run("should evict oldest pending call when max items is reached", func(t *testing.T) {
+		var now = time.Now()
+		graph := newGraph(&now)
+
+		graph.Observe(newResourceSpans("frontend", newClientSpan(1, 0), newClientSpan(2, 0), newClientSpan(3, 0)))
+
+		assert.Len(t, graph.pending, 2)
+		assert.NotContains(t, graph.pending, callKey([]byte{1}, []byte{1}))
+	})
+
+	t.Run("should use unknown service when resource has no name", func(t *testing.T) {
+		var now = time.Now()
+		graph := newGraph(&now)
+
+		graph.Observe(&trace.ResourceSpans{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{newClientSpan(1, 0)}}}})
+		graph.Observe(newResourceSpans("backend", newServerSpan(1)))
+
+		assert.Equal(t, UnknownService, graph.Snapshot().Edges[0].Client)
+	})
+}
+
+func Test_Graph_ServeHTTP(t *testing.T) {
+	t.Run("should respond with the graph as JSON", func(t *testing.T) {
+		var now = time.Now()
+		var snapshot Snapshot
+
+		graph := newGraph(&now)
+		graph.Observe(newResourceSpans("frontend", newClientSpan(1, time.Millisecond)))
+		graph.Observe(newResourceSpans("backend", newServerSpan(1)))
+
+		w := httptest.NewRecorder()
+		graph.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/servicegraph", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &snapshot))
+		assert.Len(t, snapshot.Edges, 1)
+	})
+
+	t.Run("should return 405 for invalid method", func(t *testing.T) {
+		var now = time.Now()
+
+		w := httptest.NewRecorder()
+		newGraph(&now).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/servicegraph", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
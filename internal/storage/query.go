@@ -0,0 +1,343 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/jaegertracing/jaeger-idl/model/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/translator"
+)
+
+// Handler serves the stored traces through the HTTP API of Jaeger query, so
+// the Jaeger UI can be pointed at it
+//
+// For more details: https://www.jaegertracing.io/docs/latest/apis/#http-json-internal
+type Handler struct {
+	store *Store
+	mux   *http.ServeMux
+}
+
+// response is the envelope of all the responses of the API
+type response struct {
+	Data   any             `json:"data"`
+	Total  int             `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+	Errors []responseError `json:"errors"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"msg"`
+}
+
+// jsonTrace is a trace the way the Jaeger UI reads it, its spans referencing
+// the processes they belong to
+type jsonTrace struct {
+	TraceID   string                 `json:"traceID"`
+	Spans     []jsonSpan             `json:"spans"`
+	Processes map[string]jsonProcess `json:"processes"`
+	Warnings  []string               `json:"warnings"`
+}
+
+type jsonSpan struct {
+	TraceID       string          `json:"traceID"`
+	SpanID        string          `json:"spanID"`
+	Flags         uint32          `json:"flags,omitempty"`
+	OperationName string          `json:"operationName"`
+	References    []jsonReference `json:"references"`
+	StartTime     uint64          `json:"startTime"`
+	Duration      uint64          `json:"duration"`
+	Tags          []jsonKeyValue  `json:"tags"`
+	Logs          []jsonLog       `json:"logs"`
+	ProcessID     string          `json:"processID"`
+	Warnings      []string        `json:"warnings"`
+}
+
+type jsonReference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type jsonKeyValue struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+type jsonLog struct {
+	Timestamp uint64         `json:"timestamp"`
+	Fields    []jsonKeyValue `json:"fields"`
+}
+
+type jsonProcess struct {
+	ServiceName string         `json:"serviceName"`
+	Tags        []jsonKeyValue `json:"tags"`
+}
+
+// NewHandler creates the handler of the query API of the store, serving the
+// paths under /api/
+func NewHandler(store *Store) *Handler {
+	h := &Handler{store: store, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("GET /api/services", h.services)
+	h.mux.HandleFunc("GET /api/services/{service}/operations", h.operations)
+	h.mux.HandleFunc("GET /api/operations", h.operations)
+	h.mux.HandleFunc("GET /api/traces", h.search)
+	h.mux.HandleFunc("GET /api/traces/{traceID}", h.trace)
+	h.mux.HandleFunc("GET /api/dependencies", h.dependencies)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) services(w http.ResponseWriter, r *http.Request) {
+	services := h.store.Services()
+	writeResponse(w, http.StatusOK, response{Data: services, Total: len(services)})
+}
+
+// operations lists the operations of the service, given in the path or, for
+// newer versions of the UI, in the query
+func (h *Handler) operations(w http.ResponseWriter, r *http.Request) {
+	service := r.PathValue("service")
+	if service == "" {
+		service = r.URL.Query().Get("service")
+	}
+
+	names := h.store.Operations(service)
+
+	// the newer versions of the UI expect objects, with the span kind left
+	// empty as it isn't indexed
+	if r.PathValue("service") == "" {
+		type operation struct {
+			Name     string `json:"name"`
+			SpanKind string `json:"spanKind"`
+		}
+
+		operations := make([]operation, 0, len(names))
+		for _, name := range names {
+			operations = append(operations, operation{Name: name})
+		}
+
+		writeResponse(w, http.StatusOK, response{Data: operations, Total: len(operations)})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, response{Data: names, Total: len(names)})
+}
+
+func (h *Handler) trace(w http.ResponseWriter, r *http.Request) {
+	id, err := ParseTraceID(r.PathValue("traceID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rss, err := h.store.Trace(id)
+	if errors.Is(err, ErrTraceNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, response{Data: []jsonTrace{toJSONTrace(id, rss)}, Total: 1})
+}
+
+func (h *Handler) search(w http.ResponseWriter, r *http.Request) {
+	var traces = []jsonTrace{}
+
+	q, err := parseQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	for _, id := range h.store.Search(q) {
+		rss, err := h.store.Trace(id)
+
+		// the trace may have expired since it was found
+		if errors.Is(err, ErrTraceNotFound) {
+			continue
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		traces = append(traces, toJSONTrace(id, rss))
+	}
+
+	writeResponse(w, http.StatusOK, response{Data: traces, Total: len(traces)})
+}
+
+// dependencies responds with no dependencies, they are served by the service
+// graph on /servicegraph
+func (h *Handler) dependencies(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, http.StatusOK, response{Data: []any{}})
+}
+
+// parseQuery reads the search parameters of the UI, the times being in
+// microseconds and the durations in the format of time.ParseDuration
+func parseQuery(r *http.Request) (Query, error) {
+	var q Query
+
+	params := r.URL.Query()
+	q.Service = params.Get("service")
+	q.Operation = params.Get("operation")
+
+	for name, value := range map[string]*time.Time{"start": &q.StartMin, "end": &q.StartMax} {
+		if param := params.Get(name); param != "" {
+			micros, err := strconv.ParseInt(param, 10, 64)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s %q", name, param)
+			}
+			*value = time.UnixMicro(micros)
+		}
+	}
+
+	for name, value := range map[string]*time.Duration{"minDuration": &q.DurationMin, "maxDuration": &q.DurationMax} {
+		if param := params.Get(name); param != "" {
+			duration, err := time.ParseDuration(param)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s %q", name, param)
+			}
+			*value = duration
+		}
+	}
+
+	if param := params.Get("limit"); param != "" {
+		limit, err := strconv.Atoi(param)
+		if err != nil {
+			return q, fmt.Errorf("invalid limit %q", param)
+		}
+		q.Limit = limit
+	}
+
+	// tags are given as a JSON object, or as key:value pairs by older UIs
+	if param := params.Get("tags"); param != "" {
+		if err := json.Unmarshal([]byte(param), &q.Tags); err != nil {
+			return q, fmt.Errorf("invalid tags %q", param)
+		}
+	}
+
+	for _, tag := range params["tag"] {
+		key, value, ok := strings.Cut(tag, ":")
+		if !ok {
+			return q, fmt.Errorf("invalid tag %q", tag)
+		}
+
+		if q.Tags == nil {
+			q.Tags = make(map[string]string)
+		}
+		q.Tags[key] = value
+	}
+
+	return q, nil
+}
+
+// toJSONTrace converts the resources of the trace the way the Jaeger exporter
+// does, each resource being a process
+func toJSONTrace(id TraceID, rss []*trace.ResourceSpans) jsonTrace {
+	converted := jsonTrace{
+		TraceID:   model.NewTraceID(traceIDParts(id)).String(),
+		Spans:     []jsonSpan{},
+		Processes: make(map[string]jsonProcess),
+	}
+
+	for i, rs := range rss {
+		batch := translator.OTLPToJaeger(rs)
+		processID := "p" + strconv.Itoa(i+1)
+
+		converted.Processes[processID] = jsonProcess{
+			ServiceName: batch.Process.ServiceName,
+			Tags:        toJSONKeyValues(batch.Process.Tags),
+		}
+
+		for _, span := range batch.Spans {
+			converted.Spans = append(converted.Spans, toJSONSpan(span, processID))
+		}
+	}
+
+	return converted
+}
+
+func toJSONSpan(span *model.Span, processID string) jsonSpan {
+	converted := jsonSpan{
+		TraceID:       span.TraceID.String(),
+		SpanID:        span.SpanID.String(),
+		Flags:         uint32(span.Flags),
+		OperationName: span.OperationName,
+		References:    []jsonReference{},
+		StartTime:     model.TimeAsEpochMicroseconds(span.StartTime),
+		Duration:      model.DurationAsMicroseconds(span.Duration),
+		Tags:          toJSONKeyValues(span.Tags),
+		Logs:          []jsonLog{},
+		ProcessID:     processID,
+	}
+
+	for _, ref := range span.References {
+		converted.References = append(converted.References, jsonReference{
+			RefType: ref.RefType.String(),
+			TraceID: ref.TraceID.String(),
+			SpanID:  ref.SpanID.String(),
+		})
+	}
+
+	for _, log := range span.Logs {
+		converted.Logs = append(converted.Logs, jsonLog{
+			Timestamp: model.TimeAsEpochMicroseconds(log.Timestamp),
+			Fields:    toJSONKeyValues(log.Fields),
+		})
+	}
+
+	return converted
+}
+
+func toJSONKeyValues(kvs []model.KeyValue) []jsonKeyValue {
+	converted := make([]jsonKeyValue, 0, len(kvs))
+
+	for _, kv := range kvs {
+		switch kv.VType {
+		case model.BoolType:
+			converted = append(converted, jsonKeyValue{Key: kv.Key, Type: "bool", Value: kv.VBool})
+		case model.Int64Type:
+			converted = append(converted, jsonKeyValue{Key: kv.Key, Type: "int64", Value: kv.VInt64})
+		case model.Float64Type:
+			converted = append(converted, jsonKeyValue{Key: kv.Key, Type: "float64", Value: kv.VFloat64})
+		case model.BinaryType:
+			converted = append(converted, jsonKeyValue{Key: kv.Key, Type: "binary", Value: kv.VBinary})
+		default:
+			converted = append(converted, jsonKeyValue{Key: kv.Key, Type: "string", Value: kv.VStr})
+		}
+	}
+
+	return converted
+}
+
+// traceIDParts splits the trace ID the way Jaeger stores it
+func traceIDParts(id TraceID) (uint64, uint64) {
+	return binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeResponse(w, status, response{Errors: []responseError{{Code: status, Message: err.Error()}}})
+}
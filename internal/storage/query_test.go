@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// get requests the handler, decoding the data of the response into data
+func get(t *testing.T, h http.Handler, target string, data any) (int, response) {
+	resp := response{Data: data}
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+
+	return rec.Code, resp
+}
+
+func Test_Handler(t *testing.T) {
+	store := openTestStore(t, Options{})
+	handler := NewHandler(store)
+
+	parent := newSpan(1, 1, "POST /checkout", epoch, 2*time.Second, stringAttribute("http.status_code", "500"))
+	child := newSpan(1, 2, "charge", epoch.Add(time.Millisecond), time.Second)
+	child.ParentSpanId = parent.SpanId
+
+	assert.NoError(t, store.Write([]*trace.ResourceSpans{
+		newResourceSpans("checkout", parent),
+		newResourceSpans("payments", child),
+		newResourceSpans("payments", newSpan(2, 3, "refund", epoch.Add(time.Hour), time.Second)),
+	}))
+
+	t.Run("should list the services", func(t *testing.T) {
+		var services []string
+
+		code, resp := get(t, handler, "/api/services", &services)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"checkout", "payments"}, services)
+		assert.Equal(t, 2, resp.Total)
+	})
+
+	t.Run("should list the operations of the service", func(t *testing.T) {
+		var names []string
+		var operations []map[string]string
+
+		get(t, handler, "/api/services/payments/operations", &names)
+		get(t, handler, "/api/operations?service=payments", &operations)
+
+		assert.Equal(t, []string{"charge", "refund"}, names)
+		assert.Equal(t, []map[string]string{{"name": "charge", "spanKind": ""}, {"name": "refund", "spanKind": ""}}, operations)
+	})
+
+	t.Run("should return the trace by ID", func(t *testing.T) {
+		var traces []jsonTrace
+
+		code, _ := get(t, handler, "/api/traces/1", &traces)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Len(t, traces, 1)
+		assert.Equal(t, "0000000000000001", traces[0].TraceID)
+		assert.Equal(t, map[string]string{"p1": "checkout", "p2": "payments"}, map[string]string{
+			"p1": traces[0].Processes["p1"].ServiceName,
+			"p2": traces[0].Processes["p2"].ServiceName,
+		})
+
+		span := traces[0].Spans[1]
+		assert.Equal(t, "0000000000000002", span.SpanID)
+		assert.Equal(t, "charge", span.OperationName)
+		assert.Equal(t, uint64(epoch.Add(time.Millisecond).UnixMicro()), span.StartTime)
+		assert.Equal(t, uint64(time.Second.Microseconds()), span.Duration)
+		assert.Equal(t, "p2", span.ProcessID)
+		assert.Equal(t, []jsonReference{{RefType: "CHILD_OF", TraceID: "0000000000000001", SpanID: "0000000000000001"}}, span.References)
+		assert.Contains(t, traces[0].Spans[0].Tags, jsonKeyValue{Key: "http.status_code", Type: "string", Value: "500"})
+	})
+
+	t.Run("should search the traces", func(t *testing.T) {
+		var traces []jsonTrace
+
+		params := url.Values{
+			"service":     {"checkout"},
+			"tags":        {`{"http.status_code":"500"}`},
+			"start":       {"1704067200000000"},
+			"end":         {"1704070800000000"},
+			"minDuration": {"1s"},
+			"limit":       {"20"},
+		}
+
+		code, resp := get(t, handler, "/api/traces?"+params.Encode(), &traces)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 1, resp.Total)
+		assert.Equal(t, "0000000000000001", traces[0].TraceID)
+		assert.Len(t, traces[0].Spans, 2)
+	})
+
+	t.Run("should search with the tags of older UIs", func(t *testing.T) {
+		var traces []jsonTrace
+
+		get(t, handler, "/api/traces?tag=http.status_code:404", &traces)
+
+		assert.Empty(t, traces)
+	})
+
+	t.Run("should respond with errors", func(t *testing.T) {
+		code, resp := get(t, handler, "/api/traces/42", nil)
+
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Equal(t, []responseError{{Code: http.StatusNotFound, Message: "trace not found"}}, resp.Errors)
+
+		code, _ = get(t, handler, "/api/traces/xyz", nil)
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		code, _ = get(t, handler, "/api/traces?minDuration=fast", nil)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
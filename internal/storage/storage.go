@@ -0,0 +1,745 @@
+// Package storage keeps the recent traces on disk, in segments removed once
+// older than the retention or over the size cap, and indexes them in memory
+// to be searched through the Jaeger query API
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+const (
+	// DefaultRetention is how long the traces are kept when no retention
+	// is configured
+	DefaultRetention = 24 * time.Hour
+
+	// DefaultMaxSize is the size cap of the stored traces, in bytes, when
+	// none is configured
+	DefaultMaxSize = 1 << 30
+
+	// DefaultLimit is the number of traces found by a search without limit
+	DefaultLimit = 20
+
+	// segments is the number of parts the retention and the size cap are
+	// split into, the oldest part being removed at once
+	segments = 10
+
+	// headerSize is the size of the length preceding each record
+	headerSize = 4
+
+	// maxRecordSize protects the store from allocating absurd amounts of
+	// memory when loading a corrupted segment
+	maxRecordSize = 256 << 20
+
+	segmentExt = ".seg"
+)
+
+var (
+	// ErrInvalidOptions is returned when opening a store with invalid
+	// options
+	ErrInvalidOptions = errors.New("invalid storage options")
+
+	// ErrTraceNotFound is returned when the trace isn't stored, or not
+	// anymore
+	ErrTraceNotFound = errors.New("trace not found")
+
+	// ErrInvalidTraceID is returned when parsing a trace ID that isn't
+	// made of up to 32 hex digits
+	ErrInvalidTraceID = errors.New("invalid trace ID")
+)
+
+// Options of the store, zero values use the defaults
+type Options struct {
+	// Dir is the directory the segments are written to
+	Dir string
+
+	// Retention is how long the traces are kept after being written
+	Retention time.Duration
+
+	// MaxSize caps the size of the segments, in bytes
+	MaxSize int64
+}
+
+// TraceID identifies a trace, shorter IDs being right aligned
+type TraceID [16]byte
+
+// NewTraceID returns the trace ID of the given bytes
+func NewTraceID(b []byte) TraceID {
+	var id TraceID
+
+	if len(b) > len(id) {
+		b = b[len(b)-len(id):]
+	}
+
+	copy(id[len(id)-len(b):], b)
+
+	return id
+}
+
+// ParseTraceID parses a trace ID written in hex, such as the 16 digits IDs of
+// older Jaeger clients
+func ParseTraceID(s string) (TraceID, error) {
+	if s == "" || len(s) > 2*len(TraceID{}) {
+		return TraceID{}, fmt.Errorf("%w: %q", ErrInvalidTraceID, s)
+	}
+
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return TraceID{}, fmt.Errorf("%w: %q", ErrInvalidTraceID, s)
+	}
+
+	return NewTraceID(b), nil
+}
+
+// String returns the trace ID in hex
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Query selects the traces with at least one span matching all of its
+// conditions, zero values matching everything
+type Query struct {
+	Service   string
+	Operation string
+
+	// Tags must all be set on the span or its resource
+	Tags map[string]string
+
+	StartMin time.Time
+	StartMax time.Time
+
+	DurationMin time.Duration
+	DurationMax time.Duration
+
+	// Limit is the maximum of traces found, the most recent ones
+	Limit int
+}
+
+// Store keeps the traces in append-only segment files, the ones of the
+// written spans being indexed in memory
+type Store struct {
+	mu sync.RWMutex
+
+	dir       string
+	retention time.Duration
+	maxSize   int64
+
+	// segmentDuration and segmentSize limit the active segment before a
+	// new one is started
+	segmentDuration time.Duration
+	segmentSize     int64
+
+	// segments are sorted from the oldest to the active one
+	segments []*segment
+	traces   map[TraceID]*traceEntry
+
+	// services counts the spans of each operation by service
+	services map[string]map[string]int
+
+	now  func() time.Time
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// segment is a file of records, each one holding the spans of a trace
+type segment struct {
+	path      string
+	file      *os.File
+	size      int64
+	created   time.Time
+	lastWrite time.Time
+
+	// traces are the ones with spans in the segment
+	traces map[TraceID]struct{}
+}
+
+// location is where a record is stored
+type location struct {
+	segment *segment
+	offset  int64
+	length  int
+}
+
+type traceEntry struct {
+	records []location
+	spans   []spanEntry
+}
+
+// spanEntry is what searches need to know about a span
+type spanEntry struct {
+	segment   *segment
+	service   string
+	operation string
+	start     time.Time
+	duration  time.Duration
+	tags      map[string]string
+}
+
+// Open opens the store in the given directory, creating it when needed and
+// indexing the segments already written
+func Open(opts Options) (*Store, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("%w: dir is required", ErrInvalidOptions)
+	}
+
+	s := &Store{
+		dir:       opts.Dir,
+		retention: opts.Retention,
+		maxSize:   opts.MaxSize,
+		traces:    make(map[TraceID]*traceEntry),
+		services:  make(map[string]map[string]int),
+		now:       time.Now,
+		done:      make(chan struct{}),
+	}
+
+	if s.retention <= 0 {
+		s.retention = DefaultRetention
+	}
+
+	if s.maxSize <= 0 {
+		s.maxSize = DefaultMaxSize
+	}
+
+	s.segmentDuration = s.retention / segments
+	s.segmentSize = s.maxSize / segments
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	if err := s.load(); err != nil {
+		s.closeSegments()
+		return nil, err
+	}
+
+	s.expire()
+
+	s.wg.Add(1)
+	go s.janitor()
+
+	return s, nil
+}
+
+// load indexes the segments found in the directory, from the oldest
+func (s *Store) load() error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+
+	// segment names are their creation time, so they sort by age
+	slices.Sort(paths)
+
+	for _, path := range paths {
+		seg, err := s.loadSegment(path)
+		if err != nil {
+			return fmt.Errorf("loading segment %s: %w", filepath.Base(path), err)
+		}
+
+		s.segments = append(s.segments, seg)
+	}
+
+	return nil
+}
+
+// loadSegment indexes the records of a segment, truncating the last one when
+// it was left incomplete, e.g. by a crash
+func (s *Store) loadSegment(path string) (*segment, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	nanos, _ := strconv.ParseInt(strings.TrimSuffix(filepath.Base(path), segmentExt), 10, 64)
+
+	seg := &segment{
+		path:      path,
+		file:      file,
+		created:   time.Unix(0, nanos),
+		lastWrite: info.ModTime(),
+		traces:    make(map[TraceID]struct{}),
+	}
+
+	var header [headerSize]byte
+	var data trace.TracesData
+
+	for {
+		if _, err := file.ReadAt(header[:], seg.size); err != nil {
+			break
+		}
+
+		// the rest of the segment is corrupted, as the writing was
+		// interrupted, and truncated below
+		length := int(binary.BigEndian.Uint32(header[:]))
+		if length > maxRecordSize || seg.size+headerSize+int64(length) > info.Size() {
+			break
+		}
+
+		buf := make([]byte, length)
+
+		if _, err := file.ReadAt(buf, seg.size+headerSize); err != nil {
+			break
+		}
+
+		if err := proto.Unmarshal(buf, &data); err != nil {
+			break
+		}
+
+		s.index(seg, location{segment: seg, offset: seg.size, length: length}, data.ResourceSpans)
+		seg.size += headerSize + int64(length)
+	}
+
+	if seg.size < info.Size() {
+		if err := file.Truncate(seg.size); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return seg, nil
+}
+
+// Write stores the spans of the resources, grouped by trace
+func (s *Store) Write(rss []*trace.ResourceSpans) error {
+	traces := splitByTrace(rss)
+	if len(traces) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seg, err := s.activeSegment()
+	if err != nil {
+		return err
+	}
+
+	for _, data := range traces {
+		payload, err := proto.Marshal(data)
+		if err != nil {
+			return err
+		}
+
+		record := make([]byte, headerSize, headerSize+len(payload))
+		binary.BigEndian.PutUint32(record, uint32(len(payload)))
+		record = append(record, payload...)
+
+		if _, err := seg.file.WriteAt(record, seg.size); err != nil {
+			return err
+		}
+
+		s.index(seg, location{segment: seg, offset: seg.size, length: len(payload)}, data.ResourceSpans)
+		seg.size += int64(len(record))
+	}
+
+	seg.lastWrite = s.now()
+
+	// the size cap may be reached sooner than the retention
+	s.expire()
+
+	return nil
+}
+
+// activeSegment returns the segment written to, starting a new one when the
+// last one is too old or large
+func (s *Store) activeSegment() (*segment, error) {
+	now := s.now()
+
+	if n := len(s.segments); n > 0 {
+		last := s.segments[n-1]
+
+		if now.Sub(last.created) < s.segmentDuration && last.size < s.segmentSize {
+			return last, nil
+		}
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d%s", now.UnixNano(), segmentExt))
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := &segment{path: path, file: file, created: now, lastWrite: now, traces: make(map[TraceID]struct{})}
+	s.segments = append(s.segments, seg)
+
+	return seg, nil
+}
+
+// index adds the spans of a record to the index, they all belong to the
+// same trace
+func (s *Store) index(seg *segment, loc location, rss []*trace.ResourceSpans) {
+	var entry *traceEntry
+
+	for _, rs := range rss {
+		service := otlputil.ServiceName(rs)
+		resourceTags := tags(rs.GetResource().GetAttributes())
+
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				id := NewTraceID(span.TraceId)
+
+				if entry == nil {
+					if entry = s.traces[id]; entry == nil {
+						entry = &traceEntry{}
+						s.traces[id] = entry
+					}
+
+					entry.records = append(entry.records, loc)
+					seg.traces[id] = struct{}{}
+				}
+
+				spanTags := tags(span.Attributes)
+				for key, value := range resourceTags {
+					if _, ok := spanTags[key]; !ok {
+						spanTags[key] = value
+					}
+				}
+
+				entry.spans = append(entry.spans, spanEntry{
+					segment:   seg,
+					service:   service,
+					operation: span.Name,
+					start:     time.Unix(0, int64(span.StartTimeUnixNano)),
+					duration:  otlputil.SpanDuration(span),
+					tags:      spanTags,
+				})
+
+				if s.services[service] == nil {
+					s.services[service] = make(map[string]int)
+				}
+
+				s.services[service][span.Name]++
+			}
+		}
+	}
+}
+
+// Trace returns the resources holding the spans of the trace
+func (s *Store) Trace(id TraceID) ([]*trace.ResourceSpans, error) {
+	var rss []*trace.ResourceSpans
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.traces[id]
+	if !ok {
+		return nil, ErrTraceNotFound
+	}
+
+	for _, loc := range entry.records {
+		var data trace.TracesData
+
+		buf := make([]byte, loc.length)
+
+		if _, err := loc.segment.file.ReadAt(buf, loc.offset+headerSize); err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if err := proto.Unmarshal(buf, &data); err != nil {
+			return nil, err
+		}
+
+		rss = append(rss, data.ResourceSpans...)
+	}
+
+	return rss, nil
+}
+
+// Search returns the IDs of the most recent traces matching the query
+func (s *Store) Search(q Query) []TraceID {
+	type found struct {
+		id    TraceID
+		start time.Time
+	}
+
+	var results []found
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for id, entry := range s.traces {
+		if !slices.ContainsFunc(entry.spans, q.match) {
+			continue
+		}
+
+		start := entry.spans[0].start
+		for _, span := range entry.spans[1:] {
+			if span.start.Before(start) {
+				start = span.start
+			}
+		}
+
+		results = append(results, found{id: id, start: start})
+	}
+
+	slices.SortFunc(results, func(a, b found) int {
+		return b.start.Compare(a.start)
+	})
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	ids := make([]TraceID, 0, min(limit, len(results)))
+
+	for _, result := range results[:min(limit, len(results))] {
+		ids = append(ids, result.id)
+	}
+
+	return ids
+}
+
+func (q Query) match(span spanEntry) bool {
+	switch {
+	case q.Service != "" && span.service != q.Service:
+		return false
+	case q.Operation != "" && span.operation != q.Operation:
+		return false
+	case !q.StartMin.IsZero() && span.start.Before(q.StartMin):
+		return false
+	case !q.StartMax.IsZero() && span.start.After(q.StartMax):
+		return false
+	case q.DurationMin > 0 && span.duration < q.DurationMin:
+		return false
+	case q.DurationMax > 0 && span.duration > q.DurationMax:
+		return false
+	}
+
+	for key, value := range q.Tags {
+		if span.tags[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Services returns the names of the services with stored spans
+func (s *Store) Services() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.services))
+	for name := range s.services {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// Operations returns the names of the operations of the service
+func (s *Store) Operations(service string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.services[service]))
+	for name := range s.services[service] {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// Size returns the size of the stored traces, in bytes
+func (s *Store) Size() int64 {
+	var size int64
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, seg := range s.segments {
+		size += seg.size
+	}
+
+	return size
+}
+
+// janitor removes the expired segments, even when nothing is written
+func (s *Store) janitor() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(min(max(s.segmentDuration, time.Second), time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.expire()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// expire removes the segments not written within the retention, then the
+// oldest ones until the size cap is met
+func (s *Store) expire() {
+	var size int64
+
+	deadline := s.now().Add(-s.retention)
+
+	for len(s.segments) > 0 && s.segments[0].lastWrite.Before(deadline) {
+		s.remove(s.segments[0])
+	}
+
+	for _, seg := range s.segments {
+		size += seg.size
+	}
+
+	// the active segment is kept, it's capped by the segment size
+	for len(s.segments) > 1 && size > s.maxSize {
+		size -= s.segments[0].size
+		s.remove(s.segments[0])
+	}
+}
+
+// remove deletes the oldest segment along with its spans from the index
+func (s *Store) remove(seg *segment) {
+	s.segments = s.segments[1:]
+
+	for id := range seg.traces {
+		entry := s.traces[id]
+
+		entry.records = slices.DeleteFunc(entry.records, func(loc location) bool {
+			return loc.segment == seg
+		})
+
+		entry.spans = slices.DeleteFunc(entry.spans, func(span spanEntry) bool {
+			if span.segment != seg {
+				return false
+			}
+
+			if operations := s.services[span.service]; operations[span.operation] <= 1 {
+				delete(operations, span.operation)
+
+				if len(operations) == 0 {
+					delete(s.services, span.service)
+				}
+			} else {
+				operations[span.operation]--
+			}
+
+			return true
+		})
+
+		if len(entry.records) == 0 {
+			delete(s.traces, id)
+		}
+	}
+
+	seg.file.Close()
+	os.Remove(seg.path)
+}
+
+// Close stops removing the expired segments and closes their files
+func (s *Store) Close() error {
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closeSegments()
+}
+
+func (s *Store) closeSegments() error {
+	var err error
+
+	for _, seg := range s.segments {
+		err = errors.Join(err, seg.file.Close())
+	}
+
+	return err
+}
+
+// splitByTrace groups the spans of the resources by trace, keeping their
+// resource and scope
+func splitByTrace(rss []*trace.ResourceSpans) []*trace.TracesData {
+	var ordered []*trace.TracesData
+
+	traces := make(map[TraceID]*trace.TracesData)
+
+	for _, rs := range rss {
+		resources := make(map[TraceID]*trace.ResourceSpans)
+
+		for _, ss := range rs.ScopeSpans {
+			scopes := make(map[TraceID]*trace.ScopeSpans)
+
+			for _, span := range ss.Spans {
+				id := NewTraceID(span.TraceId)
+
+				scoped, ok := scopes[id]
+				if !ok {
+					scoped = &trace.ScopeSpans{Scope: ss.Scope, SchemaUrl: ss.SchemaUrl}
+					scopes[id] = scoped
+
+					resource, ok := resources[id]
+					if !ok {
+						resource = &trace.ResourceSpans{Resource: rs.Resource, SchemaUrl: rs.SchemaUrl}
+						resources[id] = resource
+
+						data, ok := traces[id]
+						if !ok {
+							data = &trace.TracesData{}
+							traces[id] = data
+							ordered = append(ordered, data)
+						}
+
+						data.ResourceSpans = append(data.ResourceSpans, resource)
+					}
+
+					resource.ScopeSpans = append(resource.ScopeSpans, scoped)
+				}
+
+				scoped.Spans = append(scoped.Spans, span)
+			}
+		}
+	}
+
+	return ordered
+}
+
+// tags returns the attributes as strings, the way they are searched
+func tags(attrs []*common.KeyValue) map[string]string {
+	tags := make(map[string]string, len(attrs))
+
+	for _, kv := range attrs {
+		tags[kv.Key] = otlputil.ValueString(kv.Value)
+	}
+
+	return tags
+}
@@ -0,0 +1,325 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func stringAttribute(key, value string) *common.KeyValue {
+	return &common.KeyValue{Key: key, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: value}}}
+}
+
+// newResourceSpans returns a resource of the service holding the spans
+func newResourceSpans(service string, spans ...*trace.Span) *trace.ResourceSpans {
+	return &trace.ResourceSpans{
+		Resource: &resource.Resource{Attributes: []*common.KeyValue{
+			stringAttribute("service.name", service),
+			stringAttribute("deployment.environment", "production"),
+		}},
+		ScopeSpans: []*trace.ScopeSpans{{Spans: spans}},
+	}
+}
+
+func newSpan(traceID, spanID byte, name string, start time.Time, duration time.Duration, attrs ...*common.KeyValue) *trace.Span {
+	return &trace.Span{
+		TraceId:           []byte{15: traceID},
+		SpanId:            []byte{7: spanID},
+		Name:              name,
+		StartTimeUnixNano: uint64(start.UnixNano()),
+		EndTimeUnixNano:   uint64(start.Add(duration).UnixNano()),
+		Attributes:        attrs,
+	}
+}
+
+func openTestStore(t *testing.T, opts Options) *Store {
+	if opts.Dir == "" {
+		opts.Dir = t.TempDir()
+	}
+
+	store, err := Open(opts)
+	assert.NoError(t, err)
+
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func Test_Store_Trace(t *testing.T) {
+	store := openTestStore(t, Options{})
+
+	assert.NoError(t, store.Write([]*trace.ResourceSpans{
+		newResourceSpans("checkout",
+			newSpan(1, 1, "POST /checkout", epoch, time.Second),
+			newSpan(2, 2, "GET /cart", epoch, time.Second),
+		),
+		newResourceSpans("payments", newSpan(1, 3, "charge", epoch, time.Second)),
+	}))
+
+	t.Run("should return the spans of the trace grouped by resource", func(t *testing.T) {
+		rss, err := store.Trace(NewTraceID([]byte{1}))
+
+		assert.NoError(t, err)
+		assert.Len(t, rss, 2)
+		assert.True(t, proto.Equal(newResourceSpans("checkout", newSpan(1, 1, "POST /checkout", epoch, time.Second)), rss[0]))
+		assert.True(t, proto.Equal(newResourceSpans("payments", newSpan(1, 3, "charge", epoch, time.Second)), rss[1]))
+	})
+
+	t.Run("should gather the spans written separately", func(t *testing.T) {
+		assert.NoError(t, store.Write([]*trace.ResourceSpans{
+			newResourceSpans("cart", newSpan(2, 4, "SELECT", epoch, time.Millisecond)),
+		}))
+
+		rss, err := store.Trace(NewTraceID([]byte{2}))
+
+		assert.NoError(t, err)
+		assert.Len(t, rss, 2)
+		assert.Equal(t, "GET /cart", rss[0].ScopeSpans[0].Spans[0].Name)
+		assert.Equal(t, "SELECT", rss[1].ScopeSpans[0].Spans[0].Name)
+	})
+
+	t.Run("should return error for unknown traces", func(t *testing.T) {
+		_, err := store.Trace(NewTraceID([]byte{9}))
+
+		assert.ErrorIs(t, err, ErrTraceNotFound)
+	})
+}
+
+func Test_Store_Search(t *testing.T) {
+	store := openTestStore(t, Options{})
+
+	assert.NoError(t, store.Write([]*trace.ResourceSpans{
+		newResourceSpans("checkout",
+			newSpan(1, 1, "POST /checkout", epoch, 2*time.Second, stringAttribute("http.status_code", "500")),
+			newSpan(2, 2, "POST /checkout", epoch.Add(time.Minute), 100*time.Millisecond),
+		),
+		newResourceSpans("payments",
+			newSpan(1, 3, "charge", epoch.Add(time.Millisecond), time.Second),
+			newSpan(3, 4, "refund", epoch.Add(time.Hour), 10*time.Millisecond,
+				&common.KeyValue{Key: "retry.delay", Value: &common.AnyValue{Value: &common.AnyValue_DoubleValue{DoubleValue: 0.000001}}},
+				&common.KeyValue{Key: "labels", Value: &common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{
+					Values: []*common.AnyValue{{Value: &common.AnyValue_StringValue{StringValue: "a"}}, {Value: &common.AnyValue_StringValue{StringValue: "b"}}},
+				}}}},
+			),
+		),
+	}))
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []TraceID
+	}{
+		{
+			name:     "should return the most recent traces first",
+			query:    Query{},
+			expected: []TraceID{NewTraceID([]byte{3}), NewTraceID([]byte{2}), NewTraceID([]byte{1})},
+		},
+		{
+			name:     "should search by service",
+			query:    Query{Service: "payments"},
+			expected: []TraceID{NewTraceID([]byte{3}), NewTraceID([]byte{1})},
+		},
+		{
+			name:     "should search by operation of the service",
+			query:    Query{Service: "checkout", Operation: "POST /checkout"},
+			expected: []TraceID{NewTraceID([]byte{2}), NewTraceID([]byte{1})},
+		},
+		{
+			name:     "should search by span and resource attributes",
+			query:    Query{Tags: map[string]string{"http.status_code": "500", "deployment.environment": "production"}},
+			expected: []TraceID{NewTraceID([]byte{1})},
+		},
+		{
+			name:     "should search by attributes of other types",
+			query:    Query{Tags: map[string]string{"retry.delay": "0.000001", "labels": "[a,b]"}},
+			expected: []TraceID{NewTraceID([]byte{3})},
+		},
+		{
+			name:     "should match all the conditions on the same span",
+			query:    Query{Service: "payments", Tags: map[string]string{"http.status_code": "500"}},
+			expected: []TraceID{},
+		},
+		{
+			name:     "should search by duration",
+			query:    Query{DurationMin: 50 * time.Millisecond, DurationMax: time.Second},
+			expected: []TraceID{NewTraceID([]byte{2}), NewTraceID([]byte{1})},
+		},
+		{
+			name:     "should search by start time",
+			query:    Query{StartMin: epoch.Add(time.Second), StartMax: epoch.Add(time.Hour)},
+			expected: []TraceID{NewTraceID([]byte{3}), NewTraceID([]byte{2})},
+		},
+		{
+			name:     "should limit the traces found",
+			query:    Query{Limit: 1},
+			expected: []TraceID{NewTraceID([]byte{3})},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, store.Search(tt.query))
+		})
+	}
+}
+
+func Test_Store_Services(t *testing.T) {
+	t.Run("should list the services and their operations", func(t *testing.T) {
+		store := openTestStore(t, Options{})
+
+		assert.NoError(t, store.Write([]*trace.ResourceSpans{
+			newResourceSpans("payments", newSpan(1, 1, "refund", epoch, time.Second), newSpan(1, 2, "charge", epoch, time.Second)),
+			newResourceSpans("checkout", newSpan(1, 3, "POST /checkout", epoch, time.Second)),
+			{ScopeSpans: []*trace.ScopeSpans{{Spans: []*trace.Span{newSpan(1, 4, "unnamed", epoch, time.Second)}}}},
+		}))
+
+		assert.Equal(t, []string{"checkout", "payments", "unknown_service"}, store.Services())
+		assert.Equal(t, []string{"charge", "refund"}, store.Operations("payments"))
+		assert.Empty(t, store.Operations("unknown"))
+	})
+}
+
+func Test_Store_Expire(t *testing.T) {
+	t.Run("should remove the traces once past the retention", func(t *testing.T) {
+		now := epoch
+		store := openTestStore(t, Options{Retention: time.Hour})
+		store.now = func() time.Time { return now }
+
+		assert.NoError(t, store.Write([]*trace.ResourceSpans{newResourceSpans("checkout", newSpan(1, 1, "GET /", epoch, time.Second))}))
+
+		now = now.Add(30 * time.Minute)
+		assert.NoError(t, store.Write([]*trace.ResourceSpans{newResourceSpans("payments", newSpan(2, 2, "charge", now, time.Second))}))
+
+		now = now.Add(45 * time.Minute)
+		assert.NoError(t, store.Write([]*trace.ResourceSpans{newResourceSpans("payments", newSpan(3, 3, "charge", now, time.Second))}))
+
+		_, err := store.Trace(NewTraceID([]byte{1}))
+		assert.ErrorIs(t, err, ErrTraceNotFound)
+
+		_, err = store.Trace(NewTraceID([]byte{2}))
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{"payments"}, store.Services())
+
+		paths, _ := filepath.Glob(filepath.Join(store.dir, "*"+segmentExt))
+		assert.Len(t, paths, 2)
+	})
+
+	t.Run("should remove the oldest traces over the size cap", func(t *testing.T) {
+		store := openTestStore(t, Options{MaxSize: 10_000})
+
+		for i := range 100 {
+			name := string(make([]byte, 100))
+			assert.NoError(t, store.Write([]*trace.ResourceSpans{newResourceSpans("checkout", newSpan(byte(i), 1, name, epoch, time.Second))}))
+		}
+
+		assert.LessOrEqual(t, store.Size(), int64(10_000))
+
+		_, err := store.Trace(NewTraceID([]byte{0}))
+		assert.ErrorIs(t, err, ErrTraceNotFound)
+
+		_, err = store.Trace(NewTraceID([]byte{99}))
+		assert.NoError(t, err)
+	})
+}
+
+func Test_Open(t *testing.T) {
+	t.Run("should index the traces already written", func(t *testing.T) {
+		dir := t.TempDir()
+
+		store, err := Open(Options{Dir: dir})
+		assert.NoError(t, err)
+		assert.NoError(t, store.Write([]*trace.ResourceSpans{newResourceSpans("checkout", newSpan(1, 1, "GET /", time.Now(), time.Second))}))
+		assert.NoError(t, store.Close())
+
+		store = openTestStore(t, Options{Dir: dir})
+
+		rss, err := store.Trace(NewTraceID([]byte{1}))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "GET /", rss[0].ScopeSpans[0].Spans[0].Name)
+		assert.Equal(t, []string{"checkout"}, store.Services())
+	})
+
+	t.Run("should truncate an incomplete record", func(t *testing.T) {
+		dir := t.TempDir()
+
+		store, err := Open(Options{Dir: dir})
+		assert.NoError(t, err)
+		assert.NoError(t, store.Write([]*trace.ResourceSpans{newResourceSpans("checkout", newSpan(1, 1, "GET /", time.Now(), time.Second))}))
+		size := store.Size()
+		assert.NoError(t, store.Close())
+
+		paths, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+		file, err := os.OpenFile(paths[0], os.O_APPEND|os.O_WRONLY, 0)
+		assert.NoError(t, err)
+		file.Write([]byte{0, 0, 1, 0, 42})
+		file.Close()
+
+		store = openTestStore(t, Options{Dir: dir})
+
+		assert.Equal(t, size, store.Size())
+		assert.Len(t, store.Search(Query{}), 1)
+	})
+
+	t.Run("should stop loading at records larger than the limit", func(t *testing.T) {
+		dir := t.TempDir()
+
+		store, err := Open(Options{Dir: dir})
+		assert.NoError(t, err)
+		assert.NoError(t, store.Write([]*trace.ResourceSpans{newResourceSpans("checkout", newSpan(1, 1, "GET /", time.Now(), time.Second))}))
+		size := store.Size()
+		assert.NoError(t, store.Close())
+
+		paths, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+		file, err := os.OpenFile(paths[0], os.O_APPEND|os.O_WRONLY, 0)
+		assert.NoError(t, err)
+		file.Write([]byte{0xff, 0xff, 0xff, 0xff, 42})
+		file.Close()
+
+		store = openTestStore(t, Options{Dir: dir})
+
+		assert.Equal(t, size, store.Size())
+		assert.Len(t, store.Search(Query{}), 1)
+	})
+
+	t.Run("should return error without dir", func(t *testing.T) {
+		_, err := Open(Options{})
+
+		assert.ErrorIs(t, err, ErrInvalidOptions)
+	})
+}
+
+func Test_ParseTraceID(t *testing.T) {
+	t.Run("should parse 32 digits IDs", func(t *testing.T) {
+		id, err := ParseTraceID("0102030405060708090a0b0c0d0e0f10")
+
+		assert.NoError(t, err)
+		assert.Equal(t, TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}, id)
+	})
+
+	t.Run("should right align shorter IDs", func(t *testing.T) {
+		id, err := ParseTraceID("abc")
+
+		assert.NoError(t, err)
+		assert.Equal(t, NewTraceID([]byte{0x0a, 0xbc}), id)
+		assert.Equal(t, "00000000000000000000000000000abc", id.String())
+	})
+
+	t.Run("should return error for invalid IDs", func(t *testing.T) {
+		for _, s := range []string{"", "xyz", "0102030405060708090a0b0c0d0e0f1011"} {
+			_, err := ParseTraceID(s)
+
+			assert.ErrorIs(t, err, ErrInvalidTraceID)
+		}
+	})
+}
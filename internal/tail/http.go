@@ -0,0 +1,96 @@
+package tail
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// droppedInterval is how often subscribers are told about dropped spans
+const droppedInterval = time.Second
+
+// Dropped is sent to subscribers when spans were left out of the stream
+type Dropped struct {
+	Count int64 `json:"count"`
+}
+
+// ServeHTTP streams the matching spans as server-sent events, each span is a
+// "span" event and the spans left out are reported with "dropped" events
+//
+// The query parameters filter, stage and rate fill the subscription Options.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var opts Options
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	opts.Filter = query.Get("filter")
+	opts.Stage = query.Get("stage")
+
+	if rate := query.Get("rate"); rate != "" {
+		value, err := strconv.Atoi(rate)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid rate %q", rate), http.StatusBadRequest)
+			return
+		}
+		opts.Rate = value
+	}
+
+	sub, err := h.Subscribe(opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(droppedInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case span := <-sub.Spans():
+			if writeEvent(w, "span", span) != nil {
+				return
+			}
+		case <-ticker.C:
+			count := sub.Dropped()
+			if count == 0 {
+				continue
+			}
+
+			if writeEvent(w, "dropped", Dropped{Count: count}) != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, encoded)
+	return err
+}
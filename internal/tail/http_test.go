@@ -0,0 +1,65 @@
+package tail
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Hub_ServeHTTP(t *testing.T) {
+	t.Run("should return 405 for other methods", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewHub(0).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/tail", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+
+	t.Run("should return 400 for invalid options", func(t *testing.T) {
+		for _, query := range []string{"filter=span.name+%3D%3D", "stage=during", "rate=fast"} {
+			w := httptest.NewRecorder()
+			NewHub(0).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tail?"+query, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	t.Run("should stream the matching spans as events", func(t *testing.T) {
+		hub := NewHub(0)
+
+		srv := httptest.NewServer(hub)
+		t.Cleanup(srv.Close)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/tail?filter="+url.QueryEscape(`span.name == "GET"`), nil)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		assert.Eventually(t, func() bool { return hub.active.Load() == 1 }, time.Second, time.Millisecond)
+
+		hub.Publish(StageBefore, "", newResourceSpans("POST", "GET"))
+
+		reader := bufio.NewReader(resp.Body)
+
+		event, _ := reader.ReadString('\n')
+		data, _ := reader.ReadString('\n')
+
+		assert.Equal(t, "event: span\n", event)
+		assert.True(t, strings.HasPrefix(data, "data: {"))
+		assert.Contains(t, data, `"name":"GET"`)
+
+		cancel()
+		assert.Eventually(t, func() bool { return hub.active.Load() == 0 }, time.Second, time.Millisecond)
+	})
+}
@@ -0,0 +1,255 @@
+// Package tail streams the spans flowing through a running instance to
+// subscribers, such as the tracedock tail command, without affecting the
+// ingestion: matching spans are copied when published and dropped whenever a
+// subscriber can't keep up or exceeds its rate.
+package tail
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/expr"
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+const (
+	// StageBefore is when spans are received, before any pipeline
+	StageBefore = "before"
+
+	// StageAfter is when spans were processed by a pipeline
+	StageAfter = "after"
+
+	// DefaultMaxRate caps the spans per second sent to each subscriber
+	DefaultMaxRate = 1000
+
+	// bufferSize is the amount of spans waiting to be sent to a subscriber
+	bufferSize = 256
+)
+
+// Span is the copy of a span sent to subscribers
+type Span struct {
+	Stage        string            `json:"stage"`
+	Pipeline     string            `json:"pipeline,omitempty"`
+	Time         time.Time         `json:"time"`
+	Service      string            `json:"service"`
+	TraceID      string            `json:"trace_id"`
+	SpanID       string            `json:"span_id"`
+	ParentSpanID string            `json:"parent_span_id,omitempty"`
+	Name         string            `json:"name"`
+	Kind         string            `json:"kind"`
+	Status       string            `json:"status"`
+	Message      string            `json:"status_message,omitempty"`
+	Duration     time.Duration     `json:"duration"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// Options selects what a subscriber receives
+type Options struct {
+	// Filter is an expression spans must satisfy, empty matches all
+	Filter string
+
+	// Stage is StageBefore, StageAfter or empty for both
+	Stage string
+
+	// Rate is the maximum spans per second, capped by the hub max rate
+	Rate int
+}
+
+// Subscription receives the spans published while it's open
+type Subscription struct {
+	hub     *Hub
+	filter  *expr.Program
+	stage   string
+	limiter *limiter
+	spans   chan Span
+	dropped atomic.Int64
+}
+
+// Spans returns the channel the matching spans are sent to
+func (s *Subscription) Spans() <-chan Span {
+	return s.spans
+}
+
+// Dropped returns and resets the amount of spans dropped since the last
+// call, either by the rate cap or because the subscriber was too slow
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+// Close stops receiving spans
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+}
+
+// Hub publishes spans to its subscriptions
+type Hub struct {
+	mu            sync.RWMutex
+	maxRate       int
+	subscriptions map[*Subscription]struct{}
+
+	// active avoids taking the lock while nobody is subscribed
+	active atomic.Int32
+
+	now func() time.Time
+}
+
+// NewHub creates a hub capping the rate of each subscriber to maxRate
+// spans per second
+func NewHub(maxRate int) *Hub {
+	if maxRate <= 0 {
+		maxRate = DefaultMaxRate
+	}
+
+	return &Hub{
+		maxRate:       maxRate,
+		subscriptions: make(map[*Subscription]struct{}),
+		now:           time.Now,
+	}
+}
+
+// Subscribe opens a subscription with the given options
+func (h *Hub) Subscribe(opts Options) (*Subscription, error) {
+	var err error
+
+	sub := &Subscription{hub: h, stage: opts.Stage, spans: make(chan Span, bufferSize)}
+
+	switch opts.Stage {
+	case "", StageBefore, StageAfter:
+	default:
+		return nil, fmt.Errorf("unknown stage %q", opts.Stage)
+	}
+
+	if opts.Filter != "" {
+		if sub.filter, err = expr.Compile(opts.Filter); err != nil {
+			return nil, fmt.Errorf("filter: %w", err)
+		}
+	}
+
+	rate := opts.Rate
+	if rate <= 0 || rate > h.maxRate {
+		rate = h.maxRate
+	}
+
+	sub.limiter = newLimiter(rate, h.now())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.subscriptions[sub] = struct{}{}
+	h.active.Add(1)
+
+	return sub, nil
+}
+
+func (h *Hub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscriptions[sub]; ok {
+		delete(h.subscriptions, sub)
+		h.active.Add(-1)
+	}
+}
+
+// Publish copies the spans matching each subscription, it never blocks so
+// the ingestion isn't slowed down by subscribers
+func (h *Hub) Publish(stage, pipelineName string, rs *trace.ResourceSpans) {
+	if h == nil || h.active.Load() == 0 || rs == nil {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	now := h.now()
+
+	for sub := range h.subscriptions {
+		if sub.stage != "" && sub.stage != stage {
+			continue
+		}
+
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				if sub.filter != nil && !sub.filter.Eval(&expr.Env{Resource: rs.Resource, Scope: ss.Scope, Span: span}) {
+					continue
+				}
+
+				if !sub.limiter.allow(now) {
+					sub.dropped.Add(1)
+					continue
+				}
+
+				select {
+				case sub.spans <- newSpan(stage, pipelineName, now, rs, span):
+				default:
+					sub.dropped.Add(1)
+				}
+			}
+		}
+	}
+}
+
+func newSpan(stage, pipelineName string, now time.Time, rs *trace.ResourceSpans, span *trace.Span) Span {
+	s := Span{
+		Stage:      stage,
+		Pipeline:   pipelineName,
+		Time:       now,
+		TraceID:    hex.EncodeToString(span.TraceId),
+		SpanID:     hex.EncodeToString(span.SpanId),
+		Name:       span.Name,
+		Kind:       otlputil.SpanKindName(span.Kind),
+		Status:     otlputil.StatusCodeName(span.Status.GetCode()),
+		Message:    span.Status.GetMessage(),
+		Duration:   otlputil.SpanDuration(span),
+		Attributes: make(map[string]string, len(span.Attributes)),
+	}
+
+	if len(span.ParentSpanId) > 0 {
+		s.ParentSpanID = hex.EncodeToString(span.ParentSpanId)
+	}
+
+	if value, ok := otlputil.FindAttribute(rs.GetResource().GetAttributes(), "service.name"); ok {
+		s.Service = otlputil.ValueString(value)
+	}
+
+	for _, kv := range span.Attributes {
+		s.Attributes[kv.Key] = otlputil.ValueString(kv.Value)
+	}
+
+	return s
+}
+
+// limiter is a token bucket allowing rate events per second with bursts of
+// up to rate events
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rate int, now time.Time) *limiter {
+	return &limiter{rate: float64(rate), tokens: float64(rate), last: now}
+}
+
+func (l *limiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = min(l.rate, l.tokens+elapsed.Seconds()*l.rate)
+		l.last = now
+	}
+
+	if l.tokens < 1 {
+		return false
+	}
+
+	l.tokens--
+	return true
+}
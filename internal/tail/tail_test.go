@@ -0,0 +1,149 @@
+package tail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func newResourceSpans(names ...string) *trace.ResourceSpans {
+	var spans []*trace.Span
+
+	for _, name := range names {
+		spans = append(spans, &trace.Span{
+			TraceId:           []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			SpanId:            []byte{1, 2, 3, 4, 5, 6, 7, 8},
+			Name:              name,
+			Kind:              trace.Span_SPAN_KIND_SERVER,
+			StartTimeUnixNano: 1000,
+			EndTimeUnixNano:   1000 + uint64(time.Millisecond),
+			Attributes: []*common.KeyValue{
+				{Key: "http.route", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "/cart"}}},
+			},
+		})
+	}
+
+	return &trace.ResourceSpans{
+		Resource: &resource.Resource{Attributes: []*common.KeyValue{
+			{Key: "service.name", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "checkout"}}},
+		}},
+		ScopeSpans: []*trace.ScopeSpans{{Spans: spans}},
+	}
+}
+
+func Test_Hub_Subscribe(t *testing.T) {
+	t.Run("should return error for invalid filters", func(t *testing.T) {
+		_, err := NewHub(0).Subscribe(Options{Filter: "span.name =="})
+		assert.ErrorContains(t, err, "filter")
+	})
+
+	t.Run("should return error for unknown stages", func(t *testing.T) {
+		_, err := NewHub(0).Subscribe(Options{Stage: "during"})
+		assert.ErrorContains(t, err, "unknown stage")
+	})
+}
+
+func Test_Hub_Publish(t *testing.T) {
+	t.Run("should do nothing for a nil hub", func(t *testing.T) {
+		var hub *Hub
+
+		assert.NotPanics(t, func() { hub.Publish(StageBefore, "", newResourceSpans("GET")) })
+	})
+
+	t.Run("should send copies of the matching spans", func(t *testing.T) {
+		hub := NewHub(0)
+
+		sub, err := hub.Subscribe(Options{Filter: `span.name == "GET"`})
+		assert.NoError(t, err)
+		defer sub.Close()
+
+		hub.Publish(StageAfter, "default", newResourceSpans("GET", "POST"))
+
+		assert.Len(t, sub.Spans(), 1)
+
+		span := <-sub.Spans()
+
+		assert.Equal(t, StageAfter, span.Stage)
+		assert.Equal(t, "default", span.Pipeline)
+		assert.Equal(t, "checkout", span.Service)
+		assert.Equal(t, "GET", span.Name)
+		assert.Equal(t, "server", span.Kind)
+		assert.Equal(t, "unset", span.Status)
+		assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", span.TraceID)
+		assert.Equal(t, time.Millisecond, span.Duration)
+		assert.Equal(t, map[string]string{"http.route": "/cart"}, span.Attributes)
+	})
+
+	t.Run("should only send spans of the subscribed stage", func(t *testing.T) {
+		hub := NewHub(0)
+
+		sub, err := hub.Subscribe(Options{Stage: StageBefore})
+		assert.NoError(t, err)
+		defer sub.Close()
+
+		hub.Publish(StageAfter, "default", newResourceSpans("GET"))
+		hub.Publish(StageBefore, "", newResourceSpans("GET"))
+
+		assert.Len(t, sub.Spans(), 1)
+		assert.Equal(t, StageBefore, (<-sub.Spans()).Stage)
+	})
+
+	t.Run("should drop spans above the rate", func(t *testing.T) {
+		hub := NewHub(5)
+		now := time.Unix(1700000000, 0)
+		hub.now = func() time.Time { return now }
+
+		sub, err := hub.Subscribe(Options{Rate: 100})
+		assert.NoError(t, err)
+		defer sub.Close()
+
+		hub.Publish(StageBefore, "", newResourceSpans("1", "2", "3", "4", "5", "6", "7"))
+
+		assert.Len(t, sub.Spans(), 5)
+		assert.Equal(t, int64(2), sub.Dropped())
+		assert.Equal(t, int64(0), sub.Dropped())
+
+		now = now.Add(200 * time.Millisecond)
+		hub.Publish(StageBefore, "", newResourceSpans("8", "9"))
+
+		assert.Len(t, sub.Spans(), 6)
+		assert.Equal(t, int64(1), sub.Dropped())
+	})
+
+	t.Run("should drop spans when the subscriber is too slow", func(t *testing.T) {
+		var names []string
+
+		for range bufferSize + 3 {
+			names = append(names, "GET")
+		}
+
+		hub := NewHub(bufferSize * 2)
+
+		sub, err := hub.Subscribe(Options{})
+		assert.NoError(t, err)
+		defer sub.Close()
+
+		hub.Publish(StageBefore, "", newResourceSpans(names...))
+
+		assert.Len(t, sub.Spans(), bufferSize)
+		assert.Equal(t, int64(3), sub.Dropped())
+	})
+
+	t.Run("should stop sending after closing", func(t *testing.T) {
+		hub := NewHub(0)
+
+		sub, err := hub.Subscribe(Options{})
+		assert.NoError(t, err)
+
+		sub.Close()
+		sub.Close()
+		hub.Publish(StageBefore, "", newResourceSpans("GET"))
+
+		assert.Empty(t, sub.Spans())
+		assert.Equal(t, int32(0), hub.active.Load())
+	})
+}
@@ -0,0 +1,331 @@
+package translator
+
+import (
+	"time"
+
+	model "github.com/jaegertracing/jaeger-idl/model/v1"
+	"github.com/jaegertracing/jaeger-idl/thrift-gen/jaeger"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+// JaegerToOTLP converts a Jaeger batch into OTLP resources, spans carrying
+// their own process are placed in a resource apart from the batch one
+func JaegerToOTLP(batch *model.Batch) []*trace.ResourceSpans {
+	var resources []*trace.ResourceSpans
+	var builders = make(map[*model.Process]*resourceBuilder)
+
+	for _, span := range batch.Spans {
+		process := span.Process
+		if process == nil {
+			process = batch.Process
+		}
+
+		builder, ok := builders[process]
+		if !ok {
+			builder = newResourceBuilder(jaegerProcessAttributes(process))
+			builders[process] = builder
+			resources = append(resources, builder.rs)
+		}
+
+		info, otlpSpan := jaegerSpanToOTLP(span)
+		builder.add(info, otlpSpan)
+	}
+
+	return resources
+}
+
+// JaegerThriftToOTLP converts a Jaeger Thrift batch into OTLP resources
+func JaegerThriftToOTLP(batch *jaeger.Batch) []*trace.ResourceSpans {
+	var converted = &model.Batch{Process: jaegerThriftProcess(batch.Process)}
+
+	for _, span := range batch.Spans {
+		converted.Spans = append(converted.Spans, jaegerThriftSpan(span))
+	}
+
+	return JaegerToOTLP(converted)
+}
+
+func jaegerProcessAttributes(process *model.Process) []*common.KeyValue {
+	if process == nil {
+		return nil
+	}
+
+	var attrs = []*common.KeyValue{stringKV(otlputil.AttributeServiceName, process.ServiceName)}
+
+	for _, tag := range process.Tags {
+		attrs = append(attrs, jaegerTagToOTLP(tag))
+	}
+
+	return attrs
+}
+
+func jaegerSpanToOTLP(span *model.Span) (*spanInfo, *trace.Span) {
+	var info = &spanInfo{}
+	var parentID = span.ParentSpanID()
+
+	otlpSpan := &trace.Span{
+		TraceId:           traceIDBytes(span.TraceID.High, span.TraceID.Low),
+		SpanId:            spanIDBytes(uint64(span.SpanID)),
+		Name:              span.OperationName,
+		StartTimeUnixNano: unixNano(span.StartTime),
+		EndTimeUnixNano:   unixNano(span.StartTime.Add(span.Duration)),
+	}
+
+	if parentID != 0 {
+		otlpSpan.ParentSpanId = spanIDBytes(uint64(parentID))
+	}
+
+	for _, tag := range span.Tags {
+		if tag.Key == TagError {
+			info.isError = tag.VType == model.ValueType_BOOL && tag.VBool ||
+				tag.VType == model.ValueType_STRING && tag.VStr == "true"
+			continue
+		}
+
+		if tag.VType == model.ValueType_STRING && info.consume(tag.Key, tag.VStr) {
+			continue
+		}
+
+		otlpSpan.Attributes = append(otlpSpan.Attributes, jaegerTagToOTLP(tag))
+	}
+
+	otlpSpan.Kind = info.kind
+	otlpSpan.TraceState = info.traceState
+	otlpSpan.Status = info.status()
+
+	var parentSkipped bool
+
+	for _, ref := range span.References {
+		// the reference chosen as parent isn't a link
+		if !parentSkipped && parentID != 0 && ref.SpanID == parentID && ref.TraceID == span.TraceID {
+			parentSkipped = true
+			continue
+		}
+
+		otlpSpan.Links = append(otlpSpan.Links, &trace.Span_Link{
+			TraceId: traceIDBytes(ref.TraceID.High, ref.TraceID.Low),
+			SpanId:  spanIDBytes(uint64(ref.SpanID)),
+		})
+	}
+
+	for _, log := range span.Logs {
+		event := &trace.Span_Event{TimeUnixNano: unixNano(log.Timestamp)}
+
+		for _, field := range log.Fields {
+			if field.Key == TagEvent && field.VType == model.ValueType_STRING {
+				event.Name = field.VStr
+				continue
+			}
+
+			event.Attributes = append(event.Attributes, jaegerTagToOTLP(field))
+		}
+
+		otlpSpan.Events = append(otlpSpan.Events, event)
+	}
+
+	return info, otlpSpan
+}
+
+func jaegerTagToOTLP(tag model.KeyValue) *common.KeyValue {
+	var value = &common.AnyValue{}
+
+	switch tag.VType {
+	case model.ValueType_BOOL:
+		value.Value = &common.AnyValue_BoolValue{BoolValue: tag.VBool}
+	case model.ValueType_INT64:
+		value.Value = &common.AnyValue_IntValue{IntValue: tag.VInt64}
+	case model.ValueType_FLOAT64:
+		value.Value = &common.AnyValue_DoubleValue{DoubleValue: tag.VFloat64}
+	case model.ValueType_BINARY:
+		value.Value = &common.AnyValue_BytesValue{BytesValue: tag.VBinary}
+	default:
+		value.Value = &common.AnyValue_StringValue{StringValue: tag.VStr}
+	}
+
+	return &common.KeyValue{Key: tag.Key, Value: value}
+}
+
+func jaegerThriftProcess(process *jaeger.Process) *model.Process {
+	if process == nil {
+		return nil
+	}
+
+	return &model.Process{ServiceName: process.ServiceName, Tags: jaegerThriftTags(process.Tags)}
+}
+
+func jaegerThriftSpan(span *jaeger.Span) *model.Span {
+	var traceID = model.NewTraceID(uint64(span.TraceIdHigh), uint64(span.TraceIdLow))
+	var hasParentRef bool
+
+	converted := &model.Span{
+		TraceID:       traceID,
+		SpanID:        model.NewSpanID(uint64(span.SpanId)),
+		OperationName: span.OperationName,
+		Flags:         model.Flags(span.Flags),
+		StartTime:     model.EpochMicrosecondsAsTime(uint64(span.StartTime)),
+		Duration:      time.Duration(span.Duration) * time.Microsecond,
+		Tags:          jaegerThriftTags(span.Tags),
+	}
+
+	for _, ref := range span.References {
+		refType := model.SpanRefType_CHILD_OF
+		if ref.RefType == jaeger.SpanRefType_FOLLOWS_FROM {
+			refType = model.SpanRefType_FOLLOWS_FROM
+		}
+
+		converted.References = append(converted.References, model.SpanRef{
+			TraceID: model.NewTraceID(uint64(ref.TraceIdHigh), uint64(ref.TraceIdLow)),
+			SpanID:  model.NewSpanID(uint64(ref.SpanId)),
+			RefType: refType,
+		})
+
+		hasParentRef = hasParentRef || ref.SpanId == span.ParentSpanId
+	}
+
+	// Thrift carries the parent apart from the references, it's moved to
+	// the first position as Jaeger considers the first CHILD_OF the parent
+	if span.ParentSpanId != 0 && !hasParentRef {
+		converted.References = append([]model.SpanRef{
+			model.NewChildOfRef(traceID, model.NewSpanID(uint64(span.ParentSpanId))),
+		}, converted.References...)
+	}
+
+	for _, log := range span.Logs {
+		converted.Logs = append(converted.Logs, model.Log{
+			Timestamp: model.EpochMicrosecondsAsTime(uint64(log.Timestamp)),
+			Fields:    jaegerThriftTags(log.Fields),
+		})
+	}
+
+	return converted
+}
+
+func jaegerThriftTags(tags []*jaeger.Tag) []model.KeyValue {
+	var converted = make([]model.KeyValue, 0, len(tags))
+
+	for _, tag := range tags {
+		switch tag.VType {
+		case jaeger.TagType_BOOL:
+			converted = append(converted, model.Bool(tag.Key, tag.GetVBool()))
+		case jaeger.TagType_LONG:
+			converted = append(converted, model.Int64(tag.Key, tag.GetVLong()))
+		case jaeger.TagType_DOUBLE:
+			converted = append(converted, model.Float64(tag.Key, tag.GetVDouble()))
+		case jaeger.TagType_BINARY:
+			converted = append(converted, model.Binary(tag.Key, tag.GetVBinary()))
+		default:
+			converted = append(converted, model.String(tag.Key, tag.GetVStr()))
+		}
+	}
+
+	return converted
+}
+
+// OTLPToJaeger converts an OTLP resource into a Jaeger batch, the fields
+// Jaeger doesn't have are carried as tags following the OpenTelemetry
+// conventions so they are restored by JaegerToOTLP
+func OTLPToJaeger(rs *trace.ResourceSpans) *model.Batch {
+	name, attrs := serviceName(rs)
+
+	batch := &model.Batch{Process: &model.Process{ServiceName: name}}
+
+	for _, kv := range attrs {
+		batch.Process.Tags = append(batch.Process.Tags, otlpAttributeToJaeger(kv))
+	}
+
+	for _, ss := range rs.ScopeSpans {
+		for _, span := range ss.Spans {
+			batch.Spans = append(batch.Spans, otlpSpanToJaeger(ss.Scope, span))
+		}
+	}
+
+	return batch
+}
+
+func otlpSpanToJaeger(scope *common.InstrumentationScope, span *trace.Span) *model.Span {
+	var traceID = model.NewTraceID(traceIDParts(span.TraceId))
+
+	converted := &model.Span{
+		TraceID:       traceID,
+		SpanID:        model.NewSpanID(spanIDUint(span.SpanId)),
+		OperationName: span.Name,
+		StartTime:     timeFromUnixNano(span.StartTimeUnixNano),
+		Duration:      durationBetween(span.StartTimeUnixNano, span.EndTimeUnixNano),
+	}
+
+	if len(span.ParentSpanId) > 0 {
+		converted.References = append(converted.References, model.NewChildOfRef(traceID, model.NewSpanID(spanIDUint(span.ParentSpanId))))
+	}
+
+	// Jaeger has no links, they are the closest concept to a follows from
+	for _, link := range span.Links {
+		converted.References = append(converted.References, model.NewFollowsFromRef(
+			model.NewTraceID(traceIDParts(link.TraceId)),
+			model.NewSpanID(spanIDUint(link.SpanId)),
+		))
+	}
+
+	for _, kv := range span.Attributes {
+		converted.Tags = append(converted.Tags, otlpAttributeToJaeger(kv))
+	}
+
+	if kind := kindToString(span.Kind); kind != "" {
+		converted.Tags = append(converted.Tags, model.String(TagSpanKind, kind))
+	}
+
+	switch span.Status.GetCode() {
+	case trace.Status_STATUS_CODE_OK:
+		converted.Tags = append(converted.Tags, model.String(TagStatusCode, "OK"))
+	case trace.Status_STATUS_CODE_ERROR:
+		converted.Tags = append(converted.Tags, model.Bool(TagError, true), model.String(TagStatusCode, "ERROR"))
+	}
+
+	if msg := span.Status.GetMessage(); msg != "" {
+		converted.Tags = append(converted.Tags, model.String(TagStatusDescription, msg))
+	}
+
+	if scope.GetName() != "" {
+		converted.Tags = append(converted.Tags, model.String(TagScopeName, scope.GetName()))
+	}
+
+	if scope.GetVersion() != "" {
+		converted.Tags = append(converted.Tags, model.String(TagScopeVersion, scope.GetVersion()))
+	}
+
+	if span.TraceState != "" {
+		converted.Tags = append(converted.Tags, model.String(TagTraceState, span.TraceState))
+	}
+
+	for _, event := range span.Events {
+		log := model.Log{
+			Timestamp: timeFromUnixNano(event.TimeUnixNano),
+			Fields:    []model.KeyValue{model.String(TagEvent, event.Name)},
+		}
+
+		for _, kv := range event.Attributes {
+			log.Fields = append(log.Fields, otlpAttributeToJaeger(kv))
+		}
+
+		converted.Logs = append(converted.Logs, log)
+	}
+
+	return converted
+}
+
+func otlpAttributeToJaeger(kv *common.KeyValue) model.KeyValue {
+	switch v := kv.Value.GetValue().(type) {
+	case *common.AnyValue_BoolValue:
+		return model.Bool(kv.Key, v.BoolValue)
+	case *common.AnyValue_IntValue:
+		return model.Int64(kv.Key, v.IntValue)
+	case *common.AnyValue_DoubleValue:
+		return model.Float64(kv.Key, v.DoubleValue)
+	case *common.AnyValue_BytesValue:
+		return model.Binary(kv.Key, v.BytesValue)
+	default:
+		return model.String(kv.Key, otlputil.ValueString(kv.Value))
+	}
+}
@@ -0,0 +1,127 @@
+package translator
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/jaegertracing/jaeger-idl/model/v1"
+	"github.com/jaegertracing/jaeger-idl/thrift-gen/jaeger"
+	"github.com/stretchr/testify/assert"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func Test_JaegerToOTLP(t *testing.T) {
+	var start = time.Unix(1700000000, 0)
+	var traceID = model.NewTraceID(1, 2)
+
+	t.Run("should convert spans and group them by process", func(t *testing.T) {
+		batch := &model.Batch{
+			Process: &model.Process{ServiceName: "checkout", Tags: []model.KeyValue{model.String("host.name", "node-1")}},
+			Spans: []*model.Span{
+				{
+					TraceID:       traceID,
+					SpanID:        model.NewSpanID(3),
+					OperationName: "GET /cart",
+					StartTime:     start,
+					Duration:      time.Second,
+					References: []model.SpanRef{
+						model.NewChildOfRef(traceID, model.NewSpanID(4)),
+						model.NewFollowsFromRef(traceID, model.NewSpanID(5)),
+					},
+					Tags: []model.KeyValue{
+						model.String(TagSpanKind, "server"),
+						model.Bool(TagError, true),
+						model.String(TagScopeName, "net/http"),
+						model.Int64("http.status_code", 500),
+					},
+					Logs: []model.Log{{
+						Timestamp: start,
+						Fields:    []model.KeyValue{model.String(TagEvent, "exception"), model.String("message", "boom")},
+					}},
+				},
+				{
+					TraceID:       traceID,
+					SpanID:        model.NewSpanID(6),
+					OperationName: "SELECT",
+					Process:       &model.Process{ServiceName: "database"},
+				},
+			},
+		}
+
+		resources := JaegerToOTLP(batch)
+
+		assert.Len(t, resources, 2)
+		assert.Equal(t, "checkout", resources[0].Resource.Attributes[0].Value.GetStringValue())
+		assert.Equal(t, "node-1", resources[0].Resource.Attributes[1].Value.GetStringValue())
+		assert.Equal(t, "database", resources[1].Resource.Attributes[0].Value.GetStringValue())
+
+		ss := resources[0].ScopeSpans[0]
+		span := ss.Spans[0]
+
+		assert.Equal(t, "net/http", ss.Scope.Name)
+		assert.Equal(t, traceIDBytes(1, 2), span.TraceId)
+		assert.Equal(t, spanIDBytes(3), span.SpanId)
+		assert.Equal(t, spanIDBytes(4), span.ParentSpanId)
+		assert.Equal(t, trace.Span_SPAN_KIND_SERVER, span.Kind)
+		assert.Equal(t, trace.Status_STATUS_CODE_ERROR, span.Status.Code)
+		assert.Equal(t, uint64(time.Second), span.EndTimeUnixNano-span.StartTimeUnixNano)
+		assert.Len(t, span.Attributes, 1)
+		assert.Equal(t, int64(500), span.Attributes[0].Value.GetIntValue())
+		assert.Len(t, span.Links, 1)
+		assert.Equal(t, spanIDBytes(5), span.Links[0].SpanId)
+		assert.Equal(t, "exception", span.Events[0].Name)
+		assert.Equal(t, "boom", span.Events[0].Attributes[0].Value.GetStringValue())
+	})
+
+	t.Run("should keep the status from otel tags", func(t *testing.T) {
+		batch := &model.Batch{
+			Process: &model.Process{ServiceName: "checkout"},
+			Spans: []*model.Span{{
+				TraceID: traceID,
+				SpanID:  model.NewSpanID(3),
+				Tags: []model.KeyValue{
+					model.String(TagStatusCode, "OK"),
+					model.String(TagStatusDescription, "done"),
+				},
+			}},
+		}
+
+		span := JaegerToOTLP(batch)[0].ScopeSpans[0].Spans[0]
+
+		assert.Nil(t, span.ParentSpanId)
+		assert.Equal(t, trace.Status_STATUS_CODE_OK, span.Status.Code)
+		assert.Equal(t, "done", span.Status.Message)
+		assert.Empty(t, span.Attributes)
+	})
+}
+
+func Test_JaegerThriftToOTLP(t *testing.T) {
+	t.Run("should convert thrift batches using the parent span id", func(t *testing.T) {
+		vStr := "GET"
+
+		batch := &jaeger.Batch{
+			Process: &jaeger.Process{ServiceName: "checkout"},
+			Spans: []*jaeger.Span{{
+				TraceIdLow:    2,
+				TraceIdHigh:   1,
+				SpanId:        3,
+				ParentSpanId:  4,
+				OperationName: "GET /cart",
+				StartTime:     1700000000000000,
+				Duration:      1500,
+				Tags:          []*jaeger.Tag{{Key: "http.method", VType: jaeger.TagType_STRING, VStr: &vStr}},
+			}},
+		}
+
+		resources := JaegerThriftToOTLP(batch)
+		span := resources[0].ScopeSpans[0].Spans[0]
+
+		assert.Equal(t, "checkout", resources[0].Resource.Attributes[0].Value.GetStringValue())
+		assert.Equal(t, traceIDBytes(1, 2), span.TraceId)
+		assert.Equal(t, spanIDBytes(4), span.ParentSpanId)
+		assert.Empty(t, span.Links)
+		assert.Equal(t, uint64(1700000000000000000), span.StartTimeUnixNano)
+		assert.Equal(t, uint64(1500*time.Microsecond), span.EndTimeUnixNano-span.StartTimeUnixNano)
+		assert.Equal(t, "GET", span.Attributes[0].Value.GetStringValue())
+	})
+}
@@ -0,0 +1,232 @@
+package translator
+
+import (
+	"encoding/binary"
+	"strings"
+	"time"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+// Tags and attributes used to carry OTLP concepts that legacy formats
+// don't have, following the OpenTelemetry conventions for Jaeger and Zipkin
+const (
+	AttributePeerService = "peer.service"
+	AttributePeerIP      = "net.peer.ip"
+	AttributePeerPort    = "net.peer.port"
+
+	TagSpanKind          = "span.kind"
+	TagStatusCode        = "otel.status_code"
+	TagStatusDescription = "otel.status_description"
+	TagScopeName         = "otel.scope.name"
+	TagScopeVersion      = "otel.scope.version"
+	TagLibraryName       = "otel.library.name"
+	TagLibraryVersion    = "otel.library.version"
+	TagTraceState        = "w3c.tracestate"
+	TagError             = "error"
+	TagEvent             = "event"
+)
+
+// spanInfo holds the span fields that legacy formats carry as tags
+type spanInfo struct {
+	kind          trace.Span_SpanKind
+	statusCode    trace.Status_StatusCode
+	statusMessage string
+	scopeName     string
+	scopeVersion  string
+	traceState    string
+	isError       bool
+}
+
+// consume interprets the tag when it represents a span field, returning
+// false for the tags that must be kept as attributes
+func (i *spanInfo) consume(key, value string) bool {
+	switch key {
+	case TagSpanKind:
+		i.kind = kindFromString(value)
+	case TagStatusCode:
+		switch strings.ToUpper(value) {
+		case "OK":
+			i.statusCode = trace.Status_STATUS_CODE_OK
+		case "ERROR":
+			i.statusCode = trace.Status_STATUS_CODE_ERROR
+		}
+	case TagStatusDescription:
+		i.statusMessage = value
+	case TagScopeName, TagLibraryName:
+		i.scopeName = value
+	case TagScopeVersion, TagLibraryVersion:
+		i.scopeVersion = value
+	case TagTraceState:
+		i.traceState = value
+	default:
+		return false
+	}
+
+	return true
+}
+
+func (i *spanInfo) status() *trace.Status {
+	code := i.statusCode
+
+	if code == trace.Status_STATUS_CODE_UNSET && i.isError {
+		code = trace.Status_STATUS_CODE_ERROR
+	}
+
+	if code == trace.Status_STATUS_CODE_UNSET && i.statusMessage == "" {
+		return nil
+	}
+
+	return &trace.Status{Code: code, Message: i.statusMessage}
+}
+
+// resourceBuilder groups spans in scopes of a single resource
+type resourceBuilder struct {
+	rs     *trace.ResourceSpans
+	scopes map[[2]string]*trace.ScopeSpans
+}
+
+func newResourceBuilder(attrs []*common.KeyValue) *resourceBuilder {
+	return &resourceBuilder{
+		rs:     &trace.ResourceSpans{Resource: &resource.Resource{Attributes: attrs}},
+		scopes: make(map[[2]string]*trace.ScopeSpans),
+	}
+}
+
+func (b *resourceBuilder) add(info *spanInfo, span *trace.Span) {
+	key := [2]string{info.scopeName, info.scopeVersion}
+
+	ss, ok := b.scopes[key]
+	if !ok {
+		ss = &trace.ScopeSpans{}
+		if info.scopeName != "" || info.scopeVersion != "" {
+			ss.Scope = &common.InstrumentationScope{Name: info.scopeName, Version: info.scopeVersion}
+		}
+
+		b.scopes[key] = ss
+		b.rs.ScopeSpans = append(b.rs.ScopeSpans, ss)
+	}
+
+	ss.Spans = append(ss.Spans, span)
+}
+
+func kindFromString(kind string) trace.Span_SpanKind {
+	switch strings.ToLower(kind) {
+	case "client":
+		return trace.Span_SPAN_KIND_CLIENT
+	case "server":
+		return trace.Span_SPAN_KIND_SERVER
+	case "producer":
+		return trace.Span_SPAN_KIND_PRODUCER
+	case "consumer":
+		return trace.Span_SPAN_KIND_CONSUMER
+	case "internal":
+		return trace.Span_SPAN_KIND_INTERNAL
+	default:
+		return trace.Span_SPAN_KIND_UNSPECIFIED
+	}
+}
+
+func kindToString(kind trace.Span_SpanKind) string {
+	switch kind {
+	case trace.Span_SPAN_KIND_CLIENT:
+		return "client"
+	case trace.Span_SPAN_KIND_SERVER:
+		return "server"
+	case trace.Span_SPAN_KIND_PRODUCER:
+		return "producer"
+	case trace.Span_SPAN_KIND_CONSUMER:
+		return "consumer"
+	case trace.Span_SPAN_KIND_INTERNAL:
+		return "internal"
+	default:
+		return ""
+	}
+}
+
+func traceIDBytes(high, low uint64) []byte {
+	var id = make([]byte, 16)
+
+	binary.BigEndian.PutUint64(id[:8], high)
+	binary.BigEndian.PutUint64(id[8:], low)
+
+	return id
+}
+
+func spanIDBytes(id uint64) []byte {
+	var b = make([]byte, 8)
+
+	binary.BigEndian.PutUint64(b, id)
+
+	return b
+}
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return uint64(t.UnixNano())
+}
+
+func stringKV(key, value string) *common.KeyValue {
+	return &common.KeyValue{Key: key, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: value}}}
+}
+
+func intKV(key string, value int64) *common.KeyValue {
+	return &common.KeyValue{Key: key, Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: value}}}
+}
+
+// traceIDParts splits an OTLP trace id into its high and low halves, shorter
+// ids are taken as the low bits
+func traceIDParts(id []byte) (uint64, uint64) {
+	if len(id) <= 8 {
+		return 0, spanIDUint(id)
+	}
+
+	return spanIDUint(id[:len(id)-8]), spanIDUint(id[len(id)-8:])
+}
+
+func spanIDUint(id []byte) uint64 {
+	var value uint64
+
+	for _, b := range id {
+		value = value<<8 | uint64(b)
+	}
+
+	return value
+}
+
+func timeFromUnixNano(ns uint64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(ns)).UTC()
+}
+
+func durationBetween(start, end uint64) time.Duration {
+	if end < start {
+		return 0
+	}
+
+	return time.Duration(end - start)
+}
+
+// serviceName returns the service.name of the resource, as named by
+// otlputil.ServiceName, and its remaining attributes
+func serviceName(rs *trace.ResourceSpans) (string, []*common.KeyValue) {
+	var attrs []*common.KeyValue
+
+	for _, kv := range rs.GetResource().GetAttributes() {
+		if kv.Key != otlputil.AttributeServiceName {
+			attrs = append(attrs, kv)
+		}
+	}
+
+	return otlputil.ServiceName(rs), attrs
+}
@@ -0,0 +1,23 @@
+package translator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_traceIDParts(t *testing.T) {
+	t.Run("should split 128 bits ids", func(t *testing.T) {
+		high, low := traceIDParts(traceIDBytes(1, 2))
+
+		assert.Equal(t, uint64(1), high)
+		assert.Equal(t, uint64(2), low)
+	})
+
+	t.Run("should take short ids as the low bits", func(t *testing.T) {
+		high, low := traceIDParts(spanIDBytes(3))
+
+		assert.Equal(t, uint64(0), high)
+		assert.Equal(t, uint64(3), low)
+	})
+}
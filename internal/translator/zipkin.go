@@ -0,0 +1,266 @@
+package translator
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+// ZipkinToOTLP converts Zipkin v2 spans into OTLP resources, one for each
+// service found in the local endpoints
+func ZipkinToOTLP(spans []*zipkinmodel.SpanModel) []*trace.ResourceSpans {
+	var resources []*trace.ResourceSpans
+	var builders = make(map[string]*resourceBuilder)
+
+	for _, span := range spans {
+		var serviceName = otlputil.UnknownService
+
+		if span.LocalEndpoint != nil && span.LocalEndpoint.ServiceName != "" {
+			serviceName = span.LocalEndpoint.ServiceName
+		}
+
+		builder, ok := builders[serviceName]
+		if !ok {
+			builder = newResourceBuilder([]*common.KeyValue{stringKV(otlputil.AttributeServiceName, serviceName)})
+			builders[serviceName] = builder
+			resources = append(resources, builder.rs)
+		}
+
+		info, otlpSpan := zipkinSpanToOTLP(span)
+		builder.add(info, otlpSpan)
+	}
+
+	return resources
+}
+
+func zipkinSpanToOTLP(span *zipkinmodel.SpanModel) (*spanInfo, *trace.Span) {
+	var info = &spanInfo{kind: kindFromString(string(span.Kind))}
+
+	otlpSpan := &trace.Span{
+		TraceId:           traceIDBytes(span.TraceID.High, span.TraceID.Low),
+		SpanId:            spanIDBytes(uint64(span.ID)),
+		Name:              span.Name,
+		StartTimeUnixNano: unixNano(span.Timestamp),
+	}
+
+	// without a timestamp, the end is unknown as well
+	if !span.Timestamp.IsZero() {
+		otlpSpan.EndTimeUnixNano = unixNano(span.Timestamp.Add(span.Duration))
+	}
+
+	if span.ParentID != nil && *span.ParentID != 0 {
+		otlpSpan.ParentSpanId = spanIDBytes(uint64(*span.ParentID))
+	}
+
+	for _, key := range sortedKeys(span.Tags) {
+		value := span.Tags[key]
+
+		if key == TagError {
+			info.isError = true
+			if info.statusMessage == "" && value != "true" {
+				info.statusMessage = value
+			}
+			continue
+		}
+
+		if info.consume(key, value) {
+			continue
+		}
+
+		otlpSpan.Attributes = append(otlpSpan.Attributes, stringKV(key, value))
+	}
+
+	if remote := span.RemoteEndpoint; !remote.Empty() {
+		if remote.ServiceName != "" {
+			otlpSpan.Attributes = append(otlpSpan.Attributes, stringKV(AttributePeerService, remote.ServiceName))
+		}
+
+		if ip := remote.IPv4; len(ip) > 0 {
+			otlpSpan.Attributes = append(otlpSpan.Attributes, stringKV(AttributePeerIP, ip.String()))
+		} else if ip := remote.IPv6; len(ip) > 0 {
+			otlpSpan.Attributes = append(otlpSpan.Attributes, stringKV(AttributePeerIP, ip.String()))
+		}
+
+		if remote.Port != 0 {
+			otlpSpan.Attributes = append(otlpSpan.Attributes, intKV(AttributePeerPort, int64(remote.Port)))
+		}
+	}
+
+	otlpSpan.Kind = info.kind
+	otlpSpan.TraceState = info.traceState
+	otlpSpan.Status = info.status()
+
+	for _, annotation := range span.Annotations {
+		event := &trace.Span_Event{TimeUnixNano: unixNano(annotation.Timestamp), Name: annotation.Value}
+
+		// events with attributes are written as {"name":{"key":"value"}}
+		var encoded map[string]map[string]string
+		if strings.HasPrefix(annotation.Value, "{") && json.Unmarshal([]byte(annotation.Value), &encoded) == nil && len(encoded) == 1 {
+			for name, attrs := range encoded {
+				event.Name = name
+				event.Attributes = sortedStringKVs(attrs)
+			}
+		}
+
+		otlpSpan.Events = append(otlpSpan.Events, event)
+	}
+
+	return info, otlpSpan
+}
+
+// OTLPToZipkin converts an OTLP resource into Zipkin v2 spans, the fields
+// Zipkin doesn't have are carried as tags following the OpenTelemetry
+// conventions so they are restored by ZipkinToOTLP
+//
+// Zipkin has no span links, so they are left out.
+func OTLPToZipkin(rs *trace.ResourceSpans) []*zipkinmodel.SpanModel {
+	var spans []*zipkinmodel.SpanModel
+
+	name, attrs := serviceName(rs)
+
+	for _, ss := range rs.ScopeSpans {
+		for _, span := range ss.Spans {
+			spans = append(spans, otlpSpanToZipkin(name, attrs, ss.Scope, span))
+		}
+	}
+
+	return spans
+}
+
+func otlpSpanToZipkin(service string, resourceAttrs []*common.KeyValue, scope *common.InstrumentationScope, span *trace.Span) *zipkinmodel.SpanModel {
+	high, low := traceIDParts(span.TraceId)
+
+	converted := &zipkinmodel.SpanModel{
+		SpanContext: zipkinmodel.SpanContext{
+			TraceID: zipkinmodel.TraceID{High: high, Low: low},
+			ID:      zipkinmodel.ID(spanIDUint(span.SpanId)),
+		},
+		Name:          span.Name,
+		Kind:          zipkinmodel.Kind(strings.ToUpper(kindToString(span.Kind))),
+		Timestamp:     timeFromUnixNano(span.StartTimeUnixNano),
+		Duration:      durationBetween(span.StartTimeUnixNano, span.EndTimeUnixNano),
+		LocalEndpoint: &zipkinmodel.Endpoint{ServiceName: service},
+		Tags:          make(map[string]string),
+	}
+
+	// zipkin has no internal kind, it's kept as a tag instead
+	if span.Kind == trace.Span_SPAN_KIND_INTERNAL {
+		converted.Kind = zipkinmodel.Undetermined
+		converted.Tags[TagSpanKind] = kindToString(span.Kind)
+	}
+
+	if len(span.ParentSpanId) > 0 {
+		parentID := zipkinmodel.ID(spanIDUint(span.ParentSpanId))
+		converted.ParentID = &parentID
+	}
+
+	for _, kv := range resourceAttrs {
+		converted.Tags[kv.Key] = otlputil.ValueString(kv.Value)
+	}
+
+	remote := &zipkinmodel.Endpoint{}
+
+	for _, kv := range span.Attributes {
+		value := otlputil.ValueString(kv.Value)
+
+		switch kv.Key {
+		case AttributePeerService:
+			remote.ServiceName = value
+		case AttributePeerIP:
+			if ip := net.ParseIP(value); ip.To4() != nil {
+				remote.IPv4 = ip.To4()
+			} else if ip != nil {
+				remote.IPv6 = ip
+			} else {
+				converted.Tags[kv.Key] = value
+			}
+		case AttributePeerPort:
+			if port, err := strconv.ParseUint(value, 10, 16); err == nil {
+				remote.Port = uint16(port)
+			} else {
+				converted.Tags[kv.Key] = value
+			}
+		default:
+			converted.Tags[kv.Key] = value
+		}
+	}
+
+	if !remote.Empty() {
+		converted.RemoteEndpoint = remote
+	}
+
+	switch span.Status.GetCode() {
+	case trace.Status_STATUS_CODE_OK:
+		converted.Tags[TagStatusCode] = "OK"
+	case trace.Status_STATUS_CODE_ERROR:
+		converted.Tags[TagStatusCode] = "ERROR"
+		converted.Tags[TagError] = "true"
+		if msg := span.Status.GetMessage(); msg != "" {
+			converted.Tags[TagError] = msg
+		}
+	}
+
+	if msg := span.Status.GetMessage(); msg != "" {
+		converted.Tags[TagStatusDescription] = msg
+	}
+
+	if scope.GetName() != "" {
+		converted.Tags[TagScopeName] = scope.GetName()
+	}
+
+	if scope.GetVersion() != "" {
+		converted.Tags[TagScopeVersion] = scope.GetVersion()
+	}
+
+	if span.TraceState != "" {
+		converted.Tags[TagTraceState] = span.TraceState
+	}
+
+	for _, event := range span.Events {
+		annotation := zipkinmodel.Annotation{Timestamp: timeFromUnixNano(event.TimeUnixNano), Value: event.Name}
+
+		if len(event.Attributes) > 0 {
+			attrs := make(map[string]string, len(event.Attributes))
+			for _, kv := range event.Attributes {
+				attrs[kv.Key] = otlputil.ValueString(kv.Value)
+			}
+
+			encoded, _ := json.Marshal(map[string]map[string]string{event.Name: attrs})
+			annotation.Value = string(encoded)
+		}
+
+		converted.Annotations = append(converted.Annotations, annotation)
+	}
+
+	return converted
+}
+
+// sortedKeys returns the keys of a tags map sorted, keeping the attributes
+// order stable
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func sortedStringKVs(tags map[string]string) []*common.KeyValue {
+	var attrs []*common.KeyValue
+
+	for _, key := range sortedKeys(tags) {
+		attrs = append(attrs, stringKV(key, tags[key]))
+	}
+
+	return attrs
+}
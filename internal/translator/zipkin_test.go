@@ -0,0 +1,106 @@
+package translator
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/stretchr/testify/assert"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/tracedock/tracedock/internal/otlputil"
+)
+
+func Test_ZipkinToOTLP(t *testing.T) {
+	var start = time.Unix(1700000000, 0)
+	var parentID = zipkinmodel.ID(4)
+
+	t.Run("should convert spans and group them by local service", func(t *testing.T) {
+		spans := []*zipkinmodel.SpanModel{
+			{
+				SpanContext: zipkinmodel.SpanContext{
+					TraceID:  zipkinmodel.TraceID{High: 1, Low: 2},
+					ID:       3,
+					ParentID: &parentID,
+				},
+				Name:           "get /cart",
+				Kind:           zipkinmodel.Client,
+				Timestamp:      start,
+				Duration:       time.Millisecond,
+				LocalEndpoint:  &zipkinmodel.Endpoint{ServiceName: "frontend"},
+				RemoteEndpoint: &zipkinmodel.Endpoint{ServiceName: "checkout", IPv4: net.IPv4(10, 0, 0, 1), Port: 8080},
+				Annotations:    []zipkinmodel.Annotation{{Timestamp: start, Value: "retry"}},
+				Tags: map[string]string{
+					"http.method":   "GET",
+					TagError:        "timeout",
+					TagScopeName:    "zipkin-go",
+					TagScopeVersion: "0.4.3",
+					TagTraceState:   "vendor=value",
+				},
+			},
+			{
+				SpanContext:   zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{Low: 2}, ID: 5},
+				Name:          "get /cart",
+				Kind:          zipkinmodel.Server,
+				LocalEndpoint: &zipkinmodel.Endpoint{ServiceName: "checkout"},
+			},
+		}
+
+		resources := ZipkinToOTLP(spans)
+
+		assert.Len(t, resources, 2)
+		assert.Equal(t, "frontend", resources[0].Resource.Attributes[0].Value.GetStringValue())
+		assert.Equal(t, "checkout", resources[1].Resource.Attributes[0].Value.GetStringValue())
+
+		ss := resources[0].ScopeSpans[0]
+		span := ss.Spans[0]
+
+		assert.Equal(t, "zipkin-go", ss.Scope.Name)
+		assert.Equal(t, "0.4.3", ss.Scope.Version)
+		assert.Equal(t, traceIDBytes(1, 2), span.TraceId)
+		assert.Equal(t, spanIDBytes(4), span.ParentSpanId)
+		assert.Equal(t, trace.Span_SPAN_KIND_CLIENT, span.Kind)
+		assert.Equal(t, "vendor=value", span.TraceState)
+		assert.Equal(t, trace.Status_STATUS_CODE_ERROR, span.Status.Code)
+		assert.Equal(t, "timeout", span.Status.Message)
+		assert.Equal(t, uint64(time.Millisecond), span.EndTimeUnixNano-span.StartTimeUnixNano)
+		assert.Equal(t, "retry", span.Events[0].Name)
+
+		assert.Len(t, span.Attributes, 4)
+		assert.Equal(t, "http.method", span.Attributes[0].Key)
+		assert.Equal(t, "checkout", span.Attributes[1].Value.GetStringValue())
+		assert.Equal(t, "10.0.0.1", span.Attributes[2].Value.GetStringValue())
+		assert.Equal(t, int64(8080), span.Attributes[3].Value.GetIntValue())
+
+		server := resources[1].ScopeSpans[0].Spans[0]
+
+		assert.Nil(t, server.ParentSpanId)
+		assert.Nil(t, server.Status)
+		assert.Equal(t, trace.Span_SPAN_KIND_SERVER, server.Kind)
+	})
+
+	t.Run("should leave the times unset without timestamp", func(t *testing.T) {
+		resources := ZipkinToOTLP([]*zipkinmodel.SpanModel{{
+			SpanContext:   zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{Low: 2}, ID: 5},
+			Duration:      time.Millisecond,
+			LocalEndpoint: &zipkinmodel.Endpoint{ServiceName: "checkout"},
+		}})
+
+		span := resources[0].ScopeSpans[0].Spans[0]
+
+		assert.Zero(t, span.StartTimeUnixNano)
+		assert.Zero(t, span.EndTimeUnixNano)
+	})
+
+	t.Run("should name the service unknown without local endpoint", func(t *testing.T) {
+		resources := ZipkinToOTLP([]*zipkinmodel.SpanModel{
+			{SpanContext: zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{Low: 2}, ID: 5}},
+			{SpanContext: zipkinmodel.SpanContext{TraceID: zipkinmodel.TraceID{Low: 2}, ID: 6}, LocalEndpoint: &zipkinmodel.Endpoint{}},
+		})
+
+		assert.Len(t, resources, 1)
+		assert.Equal(t, otlputil.UnknownService, otlputil.ServiceName(resources[0]))
+		assert.Len(t, resources[0].ScopeSpans[0].Spans, 2)
+	})
+}
@@ -0,0 +1,376 @@
+// Package validation checks the ingested spans against the invariants of
+// OTLP, fixing, dropping or rejecting the offending ones
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/tracedock/tracedock/internal/config"
+	"github.com/tracedock/tracedock/internal/metrics"
+)
+
+// Policies applied to the offending spans
+const (
+	// PolicyFix fixes the spans when possible, dropping the other ones
+	PolicyFix = "fix"
+
+	// PolicyDrop drops the spans, the other ones of the resource being kept
+	PolicyDrop = "drop"
+
+	// PolicyReject rejects the whole resource
+	PolicyReject = "reject"
+)
+
+// Reasons the spans are invalid for
+const (
+	reasonTraceID      = "invalid trace ID"
+	reasonSpanID       = "invalid span ID"
+	reasonParentSpanID = "invalid parent span ID"
+	reasonLink         = "invalid link"
+	reasonTimestamps   = "end before start"
+	reasonUTF8         = "invalid UTF-8"
+	reasonValueLength  = "attribute value too long"
+	reasonArrayLength  = "attribute array too long"
+)
+
+const (
+	traceIDSize = 16
+	spanIDSize  = 8
+)
+
+var (
+	// ErrUnknownPolicy is returned when the configured policy isn't
+	// supported
+	ErrUnknownPolicy = errors.New("unknown validation policy")
+
+	// ErrInvalidSpans is wrapped by the errors of the resources with
+	// invalid spans
+	ErrInvalidSpans = errors.New("invalid spans")
+)
+
+var invalidSpans = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "validation",
+	Name:      "invalid_spans_total",
+	Help:      "Total of invalid spans by reason and the action taken",
+}, []string{"reason", "action"})
+
+func init() {
+	metrics.MustRegister(invalidSpans)
+}
+
+// RejectedError is returned for the resources some spans of were dropped,
+// the other ones being kept, or rejected as a whole
+type RejectedError struct {
+	Rejected int
+	Reasons  []string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidSpans, strings.Join(e.Reasons, ", "))
+}
+
+func (e *RejectedError) Unwrap() error {
+	return ErrInvalidSpans
+}
+
+// RejectedSpans returns the number of spans dropped
+func (e *RejectedError) RejectedSpans() int {
+	return e.Rejected
+}
+
+// GRPCStatus returns an InvalidArgument status, so clients don't retry the
+// requests failing because of it
+func (e *RejectedError) GRPCStatus() *status.Status {
+	return status.New(codes.InvalidArgument, e.Error())
+}
+
+// Validator checks the spans of the resources
+type Validator struct {
+	policy string
+
+	// maxValueLength and maxArrayLength limit the attribute values, in
+	// bytes and elements, unlimited when zero
+	maxValueLength int
+	maxArrayLength int
+}
+
+// NewValidator creates a validator applying the configured policy
+func NewValidator(cfg config.ConfigValidation) (*Validator, error) {
+	var v = &Validator{
+		policy:         cfg.Policy,
+		maxValueLength: cfg.MaxValueLength,
+		maxArrayLength: cfg.MaxArrayLength,
+	}
+
+	if v.policy == "" {
+		v.policy = PolicyFix
+	}
+
+	switch v.policy {
+	case PolicyFix, PolicyDrop, PolicyReject:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPolicy, v.policy)
+	}
+
+	return v, nil
+}
+
+// Validate checks the spans of the resource, the attributes of the resource
+// applying to all of them
+//
+// Depending on the policy, the offending spans are fixed, dropped or the
+// resource is rejected, returning a *RejectedError counting the spans dropped,
+// or all the spans of the resource when rejected. IDs can't be fixed, such
+// spans are always dropped. The scopes left without spans are removed.
+func (v *Validator) Validate(rs *trace.ResourceSpans) error {
+	var reasons []string
+	var invalid, total int
+
+	fix := v.policy == PolicyFix
+
+	// rejected resources are left as they are
+	reject := v.policy == PolicyReject
+
+	action := "dropped"
+	if reject {
+		action = "rejected"
+	}
+
+	// fixed once for all the spans, otherwise all of them are invalid
+	resourceReasons := v.checkAttributes(rs.GetResource().GetAttributes(), fix)
+	if fix {
+		record(resourceReasons, "fixed")
+		resourceReasons = nil
+	}
+
+	for _, ss := range rs.ScopeSpans {
+		var kept = ss.Spans[:0]
+
+		total += len(ss.Spans)
+
+		for _, span := range ss.Spans {
+			spanReasons, fixable := v.checkSpan(span, fix)
+			spanReasons = merge(spanReasons, resourceReasons...)
+
+			switch {
+			case len(spanReasons) == 0:
+			case fix && fixable:
+				record(spanReasons, "fixed")
+			default:
+				record(spanReasons, action)
+				reasons = merge(reasons, spanReasons...)
+				invalid++
+				continue
+			}
+
+			if !reject {
+				kept = append(kept, span)
+			}
+		}
+
+		if !reject {
+			clear(ss.Spans[len(kept):])
+			ss.Spans = kept
+		}
+	}
+
+	if invalid == 0 {
+		return nil
+	}
+
+	if reject {
+		return &RejectedError{Rejected: total, Reasons: reasons}
+	}
+
+	rs.ScopeSpans = slices.DeleteFunc(rs.ScopeSpans, func(ss *trace.ScopeSpans) bool {
+		return len(ss.Spans) == 0
+	})
+
+	return &RejectedError{Rejected: invalid, Reasons: reasons}
+}
+
+// checkSpan returns the reasons the span is invalid for, and whether they
+// can be fixed. When fixing, the fixable ones are fixed in place.
+func (v *Validator) checkSpan(span *trace.Span, fix bool) ([]string, bool) {
+	var reasons []string
+	var fixable = true
+
+	if !validID(span.TraceId, traceIDSize) {
+		reasons = append(reasons, reasonTraceID)
+		fixable = false
+	}
+
+	if !validID(span.SpanId, spanIDSize) {
+		reasons = append(reasons, reasonSpanID)
+		fixable = false
+	}
+
+	// root spans have no parent
+	if len(span.ParentSpanId) > 0 && !validID(span.ParentSpanId, spanIDSize) {
+		reasons = append(reasons, reasonParentSpanID)
+		fixable = false
+	}
+
+	if span.EndTimeUnixNano < span.StartTimeUnixNano {
+		reasons = append(reasons, reasonTimestamps)
+
+		if fix {
+			span.EndTimeUnixNano = span.StartTimeUnixNano
+		}
+	}
+
+	if !utf8.ValidString(span.Name) {
+		reasons = merge(reasons, reasonUTF8)
+
+		if fix {
+			span.Name = strings.ToValidUTF8(span.Name, string(utf8.RuneError))
+		}
+	}
+
+	reasons = merge(reasons, v.checkAttributes(span.Attributes, fix)...)
+
+	for _, event := range span.Events {
+		if !utf8.ValidString(event.Name) {
+			reasons = merge(reasons, reasonUTF8)
+
+			if fix {
+				event.Name = strings.ToValidUTF8(event.Name, string(utf8.RuneError))
+			}
+		}
+
+		reasons = merge(reasons, v.checkAttributes(event.Attributes, fix)...)
+	}
+
+	// links to invalid spans are useless, they are removed when fixing
+	links := span.Links[:0]
+
+	for _, link := range span.Links {
+		if !validID(link.TraceId, traceIDSize) || !validID(link.SpanId, spanIDSize) {
+			reasons = merge(reasons, reasonLink)
+			if fix {
+				continue
+			}
+		}
+
+		reasons = merge(reasons, v.checkAttributes(link.Attributes, fix)...)
+		links = append(links, link)
+	}
+
+	clear(span.Links[len(links):])
+	span.Links = links
+
+	return reasons, fixable
+}
+
+// checkAttributes returns the reasons the attributes are invalid for, they
+// are fixed in place when fixing
+func (v *Validator) checkAttributes(attrs []*common.KeyValue, fix bool) []string {
+	var reasons []string
+
+	for _, kv := range attrs {
+		if !utf8.ValidString(kv.Key) {
+			reasons = merge(reasons, reasonUTF8)
+
+			if fix {
+				kv.Key = strings.ToValidUTF8(kv.Key, string(utf8.RuneError))
+			}
+		}
+
+		reasons = merge(reasons, v.checkValue(kv.Value, fix)...)
+	}
+
+	return reasons
+}
+
+func (v *Validator) checkValue(value *common.AnyValue, fix bool) []string {
+	var reasons []string
+
+	switch val := value.GetValue().(type) {
+	case *common.AnyValue_StringValue:
+		if !utf8.ValidString(val.StringValue) {
+			reasons = append(reasons, reasonUTF8)
+
+			if fix {
+				val.StringValue = strings.ToValidUTF8(val.StringValue, string(utf8.RuneError))
+			}
+		}
+
+		if v.maxValueLength > 0 && len(val.StringValue) > v.maxValueLength {
+			reasons = append(reasons, reasonValueLength)
+
+			// the runes cut in the middle are removed
+			if fix {
+				val.StringValue = strings.ToValidUTF8(val.StringValue[:v.maxValueLength], "")
+			}
+		}
+
+	case *common.AnyValue_BytesValue:
+		if v.maxValueLength > 0 && len(val.BytesValue) > v.maxValueLength {
+			reasons = append(reasons, reasonValueLength)
+
+			if fix {
+				val.BytesValue = val.BytesValue[:v.maxValueLength]
+			}
+		}
+
+	case *common.AnyValue_ArrayValue:
+		if v.maxArrayLength > 0 && len(val.ArrayValue.GetValues()) > v.maxArrayLength {
+			reasons = append(reasons, reasonArrayLength)
+
+			if fix {
+				val.ArrayValue.Values = val.ArrayValue.Values[:v.maxArrayLength]
+			}
+		}
+
+		for _, item := range val.ArrayValue.GetValues() {
+			reasons = merge(reasons, v.checkValue(item, fix)...)
+		}
+
+	case *common.AnyValue_KvlistValue:
+		if v.maxArrayLength > 0 && len(val.KvlistValue.GetValues()) > v.maxArrayLength {
+			reasons = append(reasons, reasonArrayLength)
+
+			if fix {
+				val.KvlistValue.Values = val.KvlistValue.Values[:v.maxArrayLength]
+			}
+		}
+
+		reasons = merge(reasons, v.checkAttributes(val.KvlistValue.GetValues(), fix)...)
+	}
+
+	return reasons
+}
+
+// validID reports whether the ID has the given size and isn't all zeros,
+// which the specification defines as invalid
+func validID(id []byte, size int) bool {
+	return len(id) == size && slices.ContainsFunc(id, func(b byte) bool { return b != 0 })
+}
+
+// merge appends the reasons not listed yet
+func merge(reasons []string, others ...string) []string {
+	for _, reason := range others {
+		if !slices.Contains(reasons, reason) {
+			reasons = append(reasons, reason)
+		}
+	}
+
+	return reasons
+}
+
+func record(reasons []string, action string) {
+	for _, reason := range reasons {
+		invalidSpans.WithLabelValues(reason, action).Inc()
+	}
+}
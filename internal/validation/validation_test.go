@@ -0,0 +1,183 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/tracedock/tracedock/internal/config"
+)
+
+func newValidator(t *testing.T, cfg config.ConfigValidation) *Validator {
+	v, err := NewValidator(cfg)
+	assert.NoError(t, err)
+
+	return v
+}
+
+func newSpan(name string) *trace.Span {
+	return &trace.Span{
+		TraceId:           []byte{15: 1},
+		SpanId:            []byte{7: 1},
+		Name:              name,
+		StartTimeUnixNano: 1000,
+		EndTimeUnixNano:   2000,
+	}
+}
+
+func newResourceSpans(spans ...*trace.Span) *trace.ResourceSpans {
+	return &trace.ResourceSpans{ScopeSpans: []*trace.ScopeSpans{{Spans: spans}}}
+}
+
+func stringValue(value string) *common.AnyValue {
+	return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: value}}
+}
+
+func arrayValue(size int) *common.AnyValue {
+	values := make([]*common.AnyValue, size)
+	for n := range values {
+		values[n] = &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: int64(n)}}
+	}
+
+	return &common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{Values: values}}}
+}
+
+func Test_Validator_Validate(t *testing.T) {
+	limits := config.ConfigValidation{MaxValueLength: 8, MaxArrayLength: 2}
+
+	t.Run("should accept valid spans", func(t *testing.T) {
+		span := newSpan("GET /")
+		span.ParentSpanId = []byte{7: 2}
+		span.Attributes = []*common.KeyValue{{Key: "http.method", Value: stringValue("GET")}}
+		span.Links = []*trace.Span_Link{{TraceId: []byte{15: 2}, SpanId: []byte{7: 3}}}
+
+		for _, policy := range []string{PolicyFix, PolicyDrop, PolicyReject} {
+			rs := newResourceSpans(span)
+
+			assert.NoError(t, newValidator(t, config.ConfigValidation{Policy: policy}).Validate(rs))
+			assert.Equal(t, []*trace.Span{span}, rs.ScopeSpans[0].Spans)
+		}
+	})
+
+	t.Run("should fix the spans when possible", func(t *testing.T) {
+		limits := limits
+		limits.Policy = PolicyFix
+
+		span := newSpan("GET /\xff")
+		span.EndTimeUnixNano = 500
+		span.Attributes = []*common.KeyValue{
+			{Key: "db.statement", Value: stringValue("SELECT * FROM users")},
+			{Key: "name", Value: stringValue("café 日本")},
+			{Key: "ids", Value: arrayValue(5)},
+		}
+		span.Links = []*trace.Span_Link{{TraceId: []byte{15: 2}}, {TraceId: []byte{15: 2}, SpanId: []byte{7: 3}}}
+
+		fixed := invalidSpans.WithLabelValues(reasonTimestamps, "fixed")
+		before := testutil.ToFloat64(fixed)
+
+		rs := newResourceSpans(span)
+		assert.NoError(t, newValidator(t, limits).Validate(rs))
+
+		assert.Equal(t, []*trace.Span{span}, rs.ScopeSpans[0].Spans)
+		assert.Equal(t, "GET /�", span.Name)
+		assert.Equal(t, uint64(1000), span.EndTimeUnixNano)
+		assert.Equal(t, "SELECT *", span.Attributes[0].Value.GetStringValue())
+		assert.Equal(t, "café ", span.Attributes[1].Value.GetStringValue())
+		assert.Len(t, span.Attributes[2].Value.GetArrayValue().Values, 2)
+		assert.Len(t, span.Links, 1)
+		assert.Equal(t, before+1, testutil.ToFloat64(fixed))
+	})
+
+	t.Run("should drop the spans with invalid IDs when fixing", func(t *testing.T) {
+		noTraceID := newSpan("no trace ID")
+		noTraceID.TraceId = nil
+		zeroSpanID := newSpan("zero span ID")
+		zeroSpanID.SpanId = make([]byte, 8)
+		shortParent := newSpan("short parent")
+		shortParent.ParentSpanId = []byte{1}
+		valid := newSpan("valid")
+
+		rs := &trace.ResourceSpans{ScopeSpans: []*trace.ScopeSpans{
+			{Spans: []*trace.Span{noTraceID, valid}},
+			{Spans: []*trace.Span{zeroSpanID, shortParent}},
+		}}
+
+		err := newValidator(t, config.ConfigValidation{}).Validate(rs)
+
+		assert.ErrorIs(t, err, ErrInvalidSpans)
+		assert.Equal(t, &RejectedError{
+			Rejected: 3,
+			Reasons:  []string{reasonTraceID, reasonSpanID, reasonParentSpanID},
+		}, err)
+		assert.Equal(t, []*trace.ScopeSpans{{Spans: []*trace.Span{valid}}}, rs.ScopeSpans)
+	})
+
+	t.Run("should drop the offending spans", func(t *testing.T) {
+		limits := limits
+		limits.Policy = PolicyDrop
+
+		backwards := newSpan("backwards")
+		backwards.EndTimeUnixNano = 0
+		huge := newSpan("huge")
+		huge.Events = []*trace.Span_Event{{Attributes: []*common.KeyValue{{Key: "ids", Value: arrayValue(3)}}}}
+		valid := newSpan("valid")
+
+		rs := newResourceSpans(backwards, valid, huge)
+		err := newValidator(t, limits).Validate(rs)
+
+		assert.Equal(t, &RejectedError{Rejected: 2, Reasons: []string{reasonTimestamps, reasonArrayLength}}, err)
+		assert.EqualError(t, err, "invalid spans: end before start, attribute array too long")
+		assert.Equal(t, []*trace.Span{valid}, rs.ScopeSpans[0].Spans)
+		assert.Equal(t, uint64(0), backwards.EndTimeUnixNano)
+	})
+
+	t.Run("should drop all the spans of invalid resources", func(t *testing.T) {
+		rs := newResourceSpans(newSpan("a"), newSpan("b"))
+		rs.Resource = &resource.Resource{Attributes: []*common.KeyValue{{Key: "service.name", Value: stringValue("\xfe")}}}
+
+		err := newValidator(t, config.ConfigValidation{Policy: PolicyDrop}).Validate(rs)
+
+		assert.Equal(t, &RejectedError{Rejected: 2, Reasons: []string{reasonUTF8}}, err)
+		assert.Empty(t, rs.ScopeSpans)
+	})
+
+	t.Run("should reject resources with offending spans", func(t *testing.T) {
+		limits := limits
+		limits.Policy = PolicyReject
+
+		invalid := newSpan("invalid")
+		invalid.Attributes = []*common.KeyValue{{Key: "payload", Value: &common.AnyValue{
+			Value: &common.AnyValue_BytesValue{BytesValue: make([]byte, 9)},
+		}}}
+		valid := newSpan("valid")
+
+		rs := newResourceSpans(invalid, valid)
+		err := newValidator(t, limits).Validate(rs)
+
+		assert.Equal(t, &RejectedError{Rejected: 2, Reasons: []string{"attribute value too long"}}, err)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, []*trace.Span{invalid, valid}, rs.ScopeSpans[0].Spans)
+		assert.Len(t, invalid.Attributes[0].Value.GetBytesValue(), 9)
+	})
+}
+
+func Test_NewValidator(t *testing.T) {
+	t.Run("should fix by default", func(t *testing.T) {
+		v, err := NewValidator(config.ConfigValidation{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, PolicyFix, v.policy)
+	})
+
+	t.Run("should return error for unknown policies", func(t *testing.T) {
+		_, err := NewValidator(config.ConfigValidation{Policy: "ignore"})
+
+		assert.ErrorIs(t, err, ErrUnknownPolicy)
+	})
+}